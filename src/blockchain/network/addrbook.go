@@ -0,0 +1,317 @@
+package network
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxAddressFailures is the number of consecutive failures after which a
+// discovered address is dropped from the address book
+const MaxAddressFailures = 10
+
+// DefaultMaxAddresses is the default number of addresses an address book
+// keeps
+const DefaultMaxAddresses = 1024
+
+// maxAddressBackoff bounds the delay between attempts to dial an address
+const maxAddressBackoff = time.Hour
+
+// AddressBook tracks the addresses of known peers
+type AddressBook struct {
+	// Path is the file the address book is persisted to
+	Path string
+
+	// MaxEntries is the number of addresses the book keeps. Once it is
+	// full, the worst scored addresses make way for new ones.
+	MaxEntries int
+
+	// Mutex is a mutex to protect access to the entries
+	Mutex sync.RWMutex
+
+	// Entries are the known addresses, keyed by address
+	Entries map[string]*AddressEntry
+}
+
+// AddressEntry represents a known peer address
+type AddressEntry struct {
+	// ID is the ID of the peer, if known. Once a connection to the address
+	// has succeeded, it is the ID the peer's certificate proved.
+	ID string `json:"id,omitempty"`
+
+	// Address is the address of the peer
+	Address string `json:"address"`
+
+	// Bootstrap is true for configured bootstrap addresses
+	Bootstrap bool `json:"bootstrap,omitempty"`
+
+	// LastSeen is the last time a connection to the address succeeded
+	LastSeen time.Time `json:"last_seen,omitempty"`
+
+	// LastAttempt is the last time the address was dialed
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+
+	// Failures is the number of consecutive failed dials
+	Failures int `json:"failures"`
+}
+
+// Score ranks an entry for dialing. Recently seen addresses with few
+// failures score highest.
+func (e *AddressEntry) Score(now time.Time) float64 {
+	score := -10 * float64(e.Failures)
+	if !e.LastSeen.IsZero() {
+		hours := now.Sub(e.LastSeen).Hours()
+		if hours < 100 {
+			score += 100 - hours
+		}
+	}
+	if e.Bootstrap {
+		score += 10
+	}
+	return score
+}
+
+// ready reports whether the entry's backoff has elapsed
+func (e *AddressEntry) ready(now time.Time) bool {
+	if e.Failures == 0 || e.LastAttempt.IsZero() {
+		return true
+	}
+	backoff := time.Second << uint(e.Failures)
+	if backoff > maxAddressBackoff || backoff <= 0 {
+		backoff = maxAddressBackoff
+	}
+	return now.Sub(e.LastAttempt) >= backoff
+}
+
+// NewAddressBook returns a new address book persisted at path
+func NewAddressBook(path string) *AddressBook {
+	return &AddressBook{
+		Path:       path,
+		MaxEntries: DefaultMaxAddresses,
+		Entries:    make(map[string]*AddressEntry),
+	}
+}
+
+// Add adds an address, merging it with an existing entry. The ID of an
+// address that has been connected to is not replaced, since an advertised
+// ID is only a claim.
+func (b *AddressBook) Add(entry AddressEntry) {
+	if entry.Address == "" {
+		return
+	}
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+	existing, ok := b.Entries[entry.Address]
+	if !ok {
+		b.insert(&entry)
+		return
+	}
+	if entry.ID != "" && existing.LastSeen.IsZero() {
+		existing.ID = entry.ID
+	}
+	existing.Bootstrap = existing.Bootstrap || entry.Bootstrap
+}
+
+// insert adds a new entry, evicting the worst scored address if the book is
+// full. The new entry is dropped instead if every address scores higher.
+// The caller must hold the mutex.
+func (b *AddressBook) insert(entry *AddressEntry) {
+	limit := b.MaxEntries
+	if limit <= 0 {
+		limit = DefaultMaxAddresses
+	}
+	if len(b.Entries) >= limit {
+		now := time.Now()
+		var worst *AddressEntry
+		for _, candidate := range b.Entries {
+			if candidate.Bootstrap {
+				continue
+			}
+			if worst == nil || candidate.Score(now) < worst.Score(now) {
+				worst = candidate
+			}
+		}
+		if worst == nil || (!entry.Bootstrap && worst.Score(now) > entry.Score(now)) {
+			return
+		}
+		delete(b.Entries, worst.Address)
+	}
+	b.Entries[entry.Address] = entry
+}
+
+// Get returns the entry for an address
+func (b *AddressBook) Get(address string) (AddressEntry, bool) {
+	b.Mutex.RLock()
+	defer b.Mutex.RUnlock()
+	entry, ok := b.Entries[address]
+	if !ok {
+		return AddressEntry{}, false
+	}
+	return *entry, true
+}
+
+// MarkAttempt records that an address is being dialed
+func (b *AddressBook) MarkAttempt(address string) {
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+	if entry, ok := b.Entries[address]; ok {
+		entry.LastAttempt = time.Now()
+	}
+}
+
+// MarkSuccess records a successful connection to an address
+func (b *AddressBook) MarkSuccess(address string, id string) {
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+	entry, ok := b.Entries[address]
+	if !ok {
+		entry = &AddressEntry{Address: address}
+	}
+	entry.ID = id
+	entry.LastSeen = time.Now()
+	entry.Failures = 0
+	if !ok {
+		b.insert(entry)
+	}
+}
+
+// MarkFailure records a failed connection to an address. Addresses that
+// keep failing are dropped unless they are bootstrap addresses.
+func (b *AddressBook) MarkFailure(address string) {
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+	entry, ok := b.Entries[address]
+	if !ok {
+		return
+	}
+	entry.Failures++
+	entry.LastAttempt = time.Now()
+	if entry.Failures >= MaxAddressFailures && !entry.Bootstrap {
+		delete(b.Entries, address)
+	}
+}
+
+// Remove removes an address
+func (b *AddressBook) Remove(address string) {
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+	delete(b.Entries, address)
+}
+
+// Size returns the number of known addresses
+func (b *AddressBook) Size() int {
+	b.Mutex.RLock()
+	defer b.Mutex.RUnlock()
+	return len(b.Entries)
+}
+
+// Candidates returns up to n addresses to dial, best first. Addresses for
+// which skip returns true and addresses in backoff are left out.
+func (b *AddressBook) Candidates(n int, skip func(entry AddressEntry) bool) []AddressEntry {
+	now := time.Now()
+	b.Mutex.RLock()
+	candidates := make([]AddressEntry, 0, len(b.Entries))
+	for _, entry := range b.Entries {
+		if entry.ready(now) && (skip == nil || !skip(*entry)) {
+			candidates = append(candidates, *entry)
+		}
+	}
+	b.Mutex.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Score(now) > candidates[j].Score(now)
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
+
+// Sample returns up to n random addresses for sharing with peers. Only
+// addresses that have been connected to successfully, or for which live
+// returns true, are included.
+func (b *AddressBook) Sample(n int, live func(entry AddressEntry) bool) []AddressEntry {
+	b.Mutex.RLock()
+	sample := make([]AddressEntry, 0, len(b.Entries))
+	for _, entry := range b.Entries {
+		if entry.ID != "" && (!entry.LastSeen.IsZero() || (live != nil && live(*entry))) {
+			sample = append(sample, *entry)
+		}
+	}
+	b.Mutex.RUnlock()
+
+	rand.Shuffle(len(sample), func(i, j int) {
+		sample[i], sample[j] = sample[j], sample[i]
+	})
+	if len(sample) > n {
+		sample = sample[:n]
+	}
+	return sample
+}
+
+// Save persists the address book to disk
+func (b *AddressBook) Save() error {
+	if b.Path == "" {
+		return nil
+	}
+	b.Mutex.RLock()
+	entries := make([]AddressEntry, 0, len(b.Entries))
+	for _, entry := range b.Entries {
+		entries = append(entries, *entry)
+	}
+	b.Mutex.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Address < entries[j].Address
+	})
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.Path), 0755); err != nil {
+		return err
+	}
+	tmp := b.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.Path)
+}
+
+// Load loads the address book from disk. A missing file is not an error.
+// A file with more addresses than MaxEntries, such as one saved with a
+// larger limit, keeps only the best scored addresses.
+func (b *AddressBook) Load() error {
+	if b.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(b.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []AddressEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	b.Mutex.Lock()
+	defer b.Mutex.Unlock()
+	for i := range entries {
+		entry := entries[i]
+		if entry.Address == "" {
+			continue
+		}
+		if _, ok := b.Entries[entry.Address]; ok {
+			b.Entries[entry.Address] = &entry
+			continue
+		}
+		b.insert(&entry)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package network
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAddressBook_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "addrbook.json")
+	book := NewAddressBook(path)
+	book.Add(AddressEntry{Address: "10.0.0.1:8080", Bootstrap: true})
+	book.MarkSuccess("10.0.0.2:8080", "peer-2")
+	book.Add(AddressEntry{ID: "peer-3", Address: "10.0.0.3:8080"})
+	book.MarkFailure("10.0.0.3:8080")
+	err := book.Save()
+	if err != nil {
+		t.Fatalf("Expected Save to succeed, got %v", err)
+	}
+
+	loaded := NewAddressBook(path)
+	err = loaded.Load()
+	if err != nil {
+		t.Fatalf("Expected Load to succeed, got %v", err)
+	}
+	if loaded.Size() != 3 {
+		t.Fatalf("Expected 3 entries after Load, got %d", loaded.Size())
+	}
+	entry, _ := loaded.Get("10.0.0.2:8080")
+	if entry.ID != "peer-2" || entry.LastSeen.IsZero() {
+		t.Errorf("Expected the last seen time to be persisted, got %+v", entry)
+	}
+	entry, _ = loaded.Get("10.0.0.3:8080")
+	if entry.Failures != 1 {
+		t.Errorf("Expected the failure count to be persisted, got %d", entry.Failures)
+	}
+}
+
+func TestAddressBook_LoadMissing(t *testing.T) {
+	book := NewAddressBook(filepath.Join(t.TempDir(), "missing.json"))
+	if err := book.Load(); err != nil {
+		t.Errorf("Expected Load to ignore a missing file, got %v", err)
+	}
+}
+
+func TestAddressBook_Candidates(t *testing.T) {
+	book := NewAddressBook("")
+	book.Add(AddressEntry{Address: "new:1"})
+	book.MarkSuccess("good:1", "good")
+	book.Add(AddressEntry{Address: "bad:1"})
+	book.MarkFailure("bad:1")
+	book.Add(AddressEntry{Address: "skipped:1"})
+
+	candidates := book.Candidates(10, func(entry AddressEntry) bool {
+		return entry.Address == "skipped:1"
+	})
+	if len(candidates) != 2 {
+		t.Fatalf("Expected 2 candidates, got %d", len(candidates))
+	}
+	if candidates[0].Address != "good:1" {
+		t.Errorf("Expected the recently seen address first, got %s", candidates[0].Address)
+	}
+	for _, candidate := range candidates {
+		if candidate.Address == "bad:1" {
+			t.Errorf("Expected a recently failed address to be backed off")
+		}
+	}
+
+	book.Mutex.Lock()
+	book.Entries["bad:1"].LastAttempt = time.Now().Add(-time.Minute)
+	book.Mutex.Unlock()
+	if len(book.Candidates(10, nil)) != 4 {
+		t.Errorf("Expected the failed address to be retried after its backoff")
+	}
+}
+
+func TestAddressBook_DropsFailingAddresses(t *testing.T) {
+	book := NewAddressBook("")
+	book.Add(AddressEntry{Address: "flaky:1"})
+	book.Add(AddressEntry{Address: "bootstrap:1", Bootstrap: true})
+	for i := 0; i < MaxAddressFailures; i++ {
+		book.MarkFailure("flaky:1")
+		book.MarkFailure("bootstrap:1")
+	}
+	if _, ok := book.Get("flaky:1"); ok {
+		t.Errorf("Expected a failing address to be dropped")
+	}
+	if _, ok := book.Get("bootstrap:1"); !ok {
+		t.Errorf("Expected a bootstrap address to be kept")
+	}
+}
+
+func TestAddressBook_Sample(t *testing.T) {
+	book := NewAddressBook("")
+	book.MarkSuccess("seen:1", "seen")
+	book.Add(AddressEntry{ID: "live", Address: "live:1"})
+	book.Add(AddressEntry{ID: "unknown", Address: "unknown:1"})
+
+	sample := book.Sample(10, func(entry AddressEntry) bool {
+		return entry.ID == "live"
+	})
+	if len(sample) != 2 {
+		t.Errorf("Expected seen and live addresses to be shared, got %d", len(sample))
+	}
+	for _, entry := range sample {
+		if entry.ID == "unknown" {
+			t.Errorf("Expected an unverified address not to be shared")
+		}
+	}
+}
+
+func TestAddressBook_KeepsVerifiedID(t *testing.T) {
+	book := NewAddressBook("")
+	book.MarkSuccess("good:1", "good")
+	book.Add(AddressEntry{ID: "forged", Address: "good:1"})
+	if entry, _ := book.Get("good:1"); entry.ID != "good" {
+		t.Errorf("Expected an advertised ID not to replace a verified one, got %s", entry.ID)
+	}
+
+	book.Add(AddressEntry{ID: "old", Address: "new:1"})
+	book.Add(AddressEntry{ID: "renamed", Address: "new:1"})
+	if entry, _ := book.Get("new:1"); entry.ID != "renamed" {
+		t.Errorf("Expected an unverified ID to be updated, got %s", entry.ID)
+	}
+}
+
+func TestAddressBook_MaxEntries(t *testing.T) {
+	book := NewAddressBook("")
+	book.MaxEntries = 2
+	book.MarkSuccess("good:1", "good")
+	book.Add(AddressEntry{Address: "bad:1"})
+	book.MarkFailure("bad:1")
+
+	// A new address replaces the worst scored one
+	book.Add(AddressEntry{Address: "new:1"})
+	if book.Size() != 2 {
+		t.Fatalf("Expected the book to stay at 2 entries, got %d", book.Size())
+	}
+	if _, ok := book.Get("bad:1"); ok {
+		t.Errorf("Expected the failing address to be evicted")
+	}
+	if _, ok := book.Get("good:1"); !ok {
+		t.Errorf("Expected the verified address to be kept")
+	}
+}
+
+func TestAddressBook_LoadMaxEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "addrbook.json")
+	book := NewAddressBook(path)
+	book.Add(AddressEntry{Address: "seed:1", Bootstrap: true})
+	book.MarkSuccess("good:1", "good")
+	book.Add(AddressEntry{Address: "new:1"})
+	book.Add(AddressEntry{Address: "bad:1"})
+	book.MarkFailure("bad:1")
+	if err := book.Save(); err != nil {
+		t.Fatalf("Expected Save to succeed, got %v", err)
+	}
+
+	// A smaller limit evicts the worst scored addresses while loading
+	loaded := NewAddressBook(path)
+	loaded.MaxEntries = 2
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Expected Load to succeed, got %v", err)
+	}
+	if loaded.Size() != 2 {
+		t.Fatalf("Expected Load to keep 2 entries, got %d", loaded.Size())
+	}
+	for _, address := range []string{"seed:1", "good:1"} {
+		if _, ok := loaded.Get(address); !ok {
+			t.Errorf("Expected %s to be kept", address)
+		}
+	}
+}
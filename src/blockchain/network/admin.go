@@ -0,0 +1,115 @@
+package network
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BanRequest is the body of a request to ban a peer through the admin API
+type BanRequest struct {
+	// ID is the ID of the peer to ban
+	ID string `json:"id"`
+
+	// Duration is the duration of the ban, such as "1h". An empty duration
+	// bans the peer permanently.
+	Duration string `json:"duration,omitempty"`
+
+	// Reason is the reason for the ban
+	Reason string `json:"reason"`
+}
+
+// AdminHandler returns an HTTP handler for administering peers. It serves
+// GET /peers to list peers with their scores, GET /bans to list bans,
+// POST /bans to ban a peer, DELETE /bans/{id} to lift a ban and
+// GET /bandwidth to report traffic counters.
+func (n *Network) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/peers", n.servePeers)
+	mux.HandleFunc("/bans", n.serveBans)
+	mux.HandleFunc("/bans/", n.serveBan)
+	mux.HandleFunc("/bandwidth", n.serveBandwidth)
+	return mux
+}
+
+// servePeers lists the connected peers
+func (n *Network) servePeers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	statuses := n.PeerStatuses()
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ID < statuses[j].ID
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// serveBans lists bans and bans peers
+func (n *Network) serveBans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(n.Bans())
+	case http.MethodPost:
+		var request BanRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.ID == "" {
+			http.Error(w, "peer ID is required", http.StatusBadRequest)
+			return
+		}
+		var duration time.Duration
+		if request.Duration != "" {
+			var err error
+			duration, err = time.ParseDuration(request.Duration)
+			if err != nil || duration <= 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := n.BanPeer(request.ID, duration, request.Reason); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// serveBan lifts the ban on a peer
+func (n *Network) serveBan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/bans/")
+	if id == "" {
+		http.Error(w, "peer ID is required", http.StatusBadRequest)
+		return
+	}
+	switch err := n.Unban(id); err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case ErrNotBanned:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// serveBandwidth reports the network's traffic counters
+func (n *Network) serveBandwidth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n.Bandwidth())
+}
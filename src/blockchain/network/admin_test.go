@@ -0,0 +1,97 @@
+package network
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNetwork_AdminHandler(t *testing.T) {
+	network := NewNetwork(Config{})
+	server := httptest.NewServer(network.AdminHandler())
+	defer server.Close()
+
+	body := strings.NewReader(`{"id":"peer","duration":"1h","reason":"spam"}`)
+	resp, err := http.Post(server.URL+"/bans", "application/json", body)
+	if err != nil {
+		t.Fatalf("Expected the ban request to succeed, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+	if !network.IsBanned("peer") {
+		t.Errorf("Expected the peer to be banned")
+	}
+
+	resp, err = http.Post(server.URL+"/bans", "application/json", strings.NewReader(`{"id":"peer","duration":"soon"}`))
+	if err != nil {
+		t.Fatalf("Expected the request to succeed, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an invalid duration to be rejected, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/bans")
+	if err != nil {
+		t.Fatalf("Expected the list request to succeed, got %v", err)
+	}
+	var bans []Ban
+	err = json.NewDecoder(resp.Body).Decode(&bans)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Expected a JSON list of bans, got %v", err)
+	}
+	if len(bans) != 1 || bans[0].ID != "peer" || bans[0].Reason != "spam" {
+		t.Errorf("Expected the ban to be listed, got %+v", bans)
+	}
+
+	for _, expected := range []int{http.StatusNoContent, http.StatusNotFound} {
+		request, _ := http.NewRequest(http.MethodDelete, server.URL+"/bans/peer", nil)
+		resp, err = http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Expected the lift request to succeed, got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Errorf("Expected status %d, got %d", expected, resp.StatusCode)
+		}
+	}
+	if network.IsBanned("peer") {
+		t.Errorf("Expected the ban to be lifted")
+	}
+
+	resp, err = http.Get(server.URL + "/peers")
+	if err != nil {
+		t.Fatalf("Expected the peers request to succeed, got %v", err)
+	}
+	var statuses []PeerStatus
+	err = json.NewDecoder(resp.Body).Decode(&statuses)
+	resp.Body.Close()
+	if err != nil || len(statuses) != 0 {
+		t.Errorf("Expected an empty list of peers, got %v %+v", err, statuses)
+	}
+}
+
+func TestNetwork_AdminBandwidth(t *testing.T) {
+	network := NewNetwork(Config{})
+	server := httptest.NewServer(network.AdminHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/bandwidth")
+	if err != nil {
+		t.Fatalf("Expected the bandwidth request to succeed, got %v", err)
+	}
+	var stats BandwidthStats
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("Expected JSON bandwidth stats, got %v", err)
+	}
+	if stats.Total != (TrafficStats{}) || stats.Types == nil || stats.Peers == nil {
+		t.Errorf("Expected empty bandwidth stats, got %+v", stats)
+	}
+}
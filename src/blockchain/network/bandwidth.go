@@ -0,0 +1,313 @@
+package network
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Priority is the priority of a message when bandwidth is capped. When a
+// link is congested, waiting messages are sent highest priority first.
+type Priority int
+
+const (
+	// PriorityLow is used for bulk traffic such as chain sync
+	PriorityLow Priority = iota
+
+	// PriorityNormal is used for messages without a configured priority
+	PriorityNormal
+
+	// PriorityHigh is used for latency sensitive traffic such as votes
+	PriorityHigh
+
+	// PriorityControl is used for connection control messages
+	PriorityControl
+)
+
+// DefaultPriorities are the priorities of message types. Message types that
+// are not listed have PriorityNormal.
+var DefaultPriorities = map[MessageType]Priority{
+	MsgHandshake:    PriorityControl,
+	MsgPing:         PriorityControl,
+	MsgPong:         PriorityControl,
+	MsgDisconnect:   PriorityControl,
+	MsgVote:         PriorityHigh,
+	MsgSyncRequest:  PriorityLow,
+	MsgSyncResponse: PriorityLow,
+}
+
+// priorityOf returns the priority of a message type
+func priorityOf(t MessageType) Priority {
+	if priority, ok := DefaultPriorities[t]; ok {
+		return priority
+	}
+	return PriorityNormal
+}
+
+// BandwidthConfig caps the bandwidth used by the network. Caps are in bytes
+// per second of framed traffic; zero means unlimited.
+type BandwidthConfig struct {
+	// Upload caps the bytes sent to all peers
+	Upload int64 `json:"upload,omitempty"`
+
+	// Download caps the bytes received from all peers
+	Download int64 `json:"download,omitempty"`
+
+	// PeerUpload caps the bytes sent to each peer
+	PeerUpload int64 `json:"peer_upload,omitempty"`
+
+	// PeerDownload caps the bytes received from each peer
+	PeerDownload int64 `json:"peer_download,omitempty"`
+}
+
+// TrafficStats counts the traffic of a peer, a message type or the node
+type TrafficStats struct {
+	// BytesIn is the number of bytes received, including frame headers
+	BytesIn uint64 `json:"bytes_in"`
+
+	// BytesOut is the number of bytes sent, including frame headers
+	BytesOut uint64 `json:"bytes_out"`
+
+	// MessagesIn is the number of frames received
+	MessagesIn uint64 `json:"messages_in"`
+
+	// MessagesOut is the number of frames sent
+	MessagesOut uint64 `json:"messages_out"`
+}
+
+// add counts a frame of size bytes
+func (s *TrafficStats) add(size int, inbound bool) {
+	if inbound {
+		s.BytesIn += uint64(size)
+		s.MessagesIn++
+	} else {
+		s.BytesOut += uint64(size)
+		s.MessagesOut++
+	}
+}
+
+// Meter counts traffic in total and per message type. Traffic recorded on
+// a meter is also recorded on its parent.
+type Meter struct {
+	// Parent is the meter that aggregates this one, if any
+	Parent *Meter
+
+	mutex sync.Mutex
+	total TrafficStats
+	types map[MessageType]*TrafficStats
+}
+
+// NewMeter returns a meter that also records to parent, which may be nil
+func NewMeter(parent *Meter) *Meter {
+	return &Meter{
+		Parent: parent,
+		types:  make(map[MessageType]*TrafficStats),
+	}
+}
+
+// Record counts a frame of message type t and size bytes
+func (m *Meter) Record(t MessageType, size int, inbound bool) {
+	for ; m != nil; m = m.Parent {
+		m.mutex.Lock()
+		m.total.add(size, inbound)
+		stats, ok := m.types[t]
+		if !ok {
+			stats = &TrafficStats{}
+			m.types[t] = stats
+		}
+		stats.add(size, inbound)
+		m.mutex.Unlock()
+	}
+}
+
+// Total returns the traffic counted by the meter
+func (m *Meter) Total() TrafficStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.total
+}
+
+// ByType returns the traffic counted by the meter for each message type
+func (m *Meter) ByType() map[string]TrafficStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	result := make(map[string]TrafficStats, len(m.types))
+	for t, stats := range m.types {
+		result[t.String()] = *stats
+	}
+	return result
+}
+
+// Shaper delays traffic to keep it within a rate. Waiting traffic is let
+// through highest priority first, and in arrival order within a priority.
+// Traffic let through a shaper is then shaped by its parent.
+type Shaper struct {
+	// Parent is the shaper that aggregates this one, if any
+	Parent *Shaper
+
+	rate    float64
+	mutex   sync.Mutex
+	tokens  float64
+	last    time.Time
+	waiters []*shaperWaiter
+}
+
+// shaperWaiter is traffic waiting in a shaper
+type shaperWaiter struct {
+	priority Priority
+	wake     chan struct{}
+}
+
+// NewShaper returns a shaper that allows rate bytes per second, with bursts
+// of up to a second of traffic. A rate of zero or less only applies the
+// parent's limit.
+func NewShaper(rate int64, parent *Shaper) *Shaper {
+	return &Shaper{
+		Parent: parent,
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// Wait waits until size bytes of the given priority may pass. It returns
+// false if done is closed first. Traffic larger than a burst is let
+// through once the shaper is not in debt, and later traffic waits for the
+// debt to be repaid.
+func (s *Shaper) Wait(done <-chan struct{}, priority Priority, size int) bool {
+	for ; s != nil; s = s.Parent {
+		if s.rate > 0 && !s.wait(done, priority, float64(size)) {
+			return false
+		}
+	}
+	return true
+}
+
+// wait waits for this shaper's tokens
+func (s *Shaper) wait(done <-chan struct{}, priority Priority, size float64) bool {
+	s.mutex.Lock()
+	waiter := &shaperWaiter{priority: priority, wake: make(chan struct{}, 1)}
+	i := sort.Search(len(s.waiters), func(i int) bool {
+		return s.waiters[i].priority < priority
+	})
+	s.waiters = append(s.waiters, nil)
+	copy(s.waiters[i+1:], s.waiters[i:])
+	s.waiters[i] = waiter
+
+	for {
+		var delay time.Duration
+		if s.waiters[0] == waiter {
+			now := time.Now()
+			s.tokens += now.Sub(s.last).Seconds() * s.rate
+			if s.tokens > s.rate {
+				s.tokens = s.rate
+			}
+			s.last = now
+			need := size
+			if need > s.rate {
+				need = s.rate
+			}
+			if s.tokens >= need {
+				s.tokens -= size
+				s.remove(waiter)
+				s.mutex.Unlock()
+				return true
+			}
+			delay = time.Duration((need - s.tokens) / s.rate * float64(time.Second))
+		}
+		s.mutex.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if delay > 0 {
+			timer = time.NewTimer(delay)
+			expired = timer.C
+		}
+		select {
+		case <-done:
+			s.mutex.Lock()
+			s.remove(waiter)
+			s.mutex.Unlock()
+			return false
+		case <-waiter.wake:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		s.mutex.Lock()
+	}
+}
+
+// remove removes a waiter and wakes the waiter at the head of the queue
+func (s *Shaper) remove(waiter *shaperWaiter) {
+	for i, w := range s.waiters {
+		if w == waiter {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			break
+		}
+	}
+	if len(s.waiters) > 0 {
+		select {
+		case s.waiters[0].wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// BandwidthStats is a snapshot of the network's traffic
+type BandwidthStats struct {
+	// Total is the traffic with all peers
+	Total TrafficStats `json:"total"`
+
+	// Types is the traffic with all peers for each message type
+	Types map[string]TrafficStats `json:"types"`
+
+	// Peers is the traffic with each connected peer
+	Peers map[string]TrafficStats `json:"peers"`
+}
+
+// traffic returns the node wide meter and shapers
+func (n *Network) traffic() (*Meter, *Shaper, *Shaper) {
+	n.trafficOnce.Do(func() {
+		n.meter = NewMeter(nil)
+		n.upload = NewShaper(n.Config.Bandwidth.Upload, nil)
+		n.download = NewShaper(n.Config.Bandwidth.Download, nil)
+	})
+	return n.meter, n.upload, n.download
+}
+
+// shapeSession sets up a session's traffic accounting and shaping
+func (n *Network) shapeSession(session *Session) {
+	meter, upload, download := n.traffic()
+	session.Meter = NewMeter(meter)
+	session.Upload = NewShaper(n.Config.Bandwidth.PeerUpload, upload)
+	session.Download = NewShaper(n.Config.Bandwidth.PeerDownload, download)
+}
+
+// Bandwidth returns the traffic of the node and of each connected peer
+func (n *Network) Bandwidth() BandwidthStats {
+	meter, _, _ := n.traffic()
+	stats := BandwidthStats{
+		Total: meter.Total(),
+		Types: meter.ByType(),
+		Peers: make(map[string]TrafficStats),
+	}
+	n.Mutex.RLock()
+	defer n.Mutex.RUnlock()
+	for _, peer := range n.Peers {
+		if peer.Session != nil && peer.Session.Meter != nil {
+			stats.Peers[peer.ID] = peer.Session.Meter.Total()
+		}
+	}
+	return stats
+}
+
+// PublishMetrics exports the network's bandwidth counters with expvar under
+// name. Like expvar.Publish, it panics if name is already published.
+func (n *Network) PublishMetrics(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return n.Bandwidth()
+	}))
+}
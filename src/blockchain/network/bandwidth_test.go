@@ -0,0 +1,164 @@
+package network
+
+import (
+	"crypto/rand"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMeter_Record(t *testing.T) {
+	parent := NewMeter(nil)
+	meter := NewMeter(parent)
+	meter.Record(MsgTx, 100, false)
+	meter.Record(MsgTx, 50, true)
+	meter.Record(MsgVote, 20, false)
+
+	total := meter.Total()
+	if total.BytesOut != 120 || total.BytesIn != 50 || total.MessagesOut != 2 || total.MessagesIn != 1 {
+		t.Errorf("Expected the meter to count every frame, got %+v", total)
+	}
+	if parent.Total() != total {
+		t.Errorf("Expected the parent to count the same traffic, got %+v", parent.Total())
+	}
+	types := parent.ByType()
+	if types["tx"].BytesOut != 100 || types["tx"].BytesIn != 50 || types["vote"].MessagesOut != 1 {
+		t.Errorf("Expected traffic to be counted per message type, got %+v", types)
+	}
+}
+
+func TestShaper_Wait(t *testing.T) {
+	shaper := NewShaper(100000, nil)
+	start := time.Now()
+	if !shaper.Wait(nil, PriorityNormal, 100000) {
+		t.Fatalf("Expected a burst to pass")
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected a burst to pass immediately, took %v", elapsed)
+	}
+	if !shaper.Wait(nil, PriorityNormal, 20000) {
+		t.Fatalf("Expected traffic to pass")
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected traffic over the burst to be delayed, took %v", elapsed)
+	}
+
+	unlimited := NewShaper(0, nil)
+	if !unlimited.Wait(nil, PriorityNormal, 1<<30) {
+		t.Errorf("Expected an unlimited shaper to let traffic through")
+	}
+	var nilShaper *Shaper
+	if !nilShaper.Wait(nil, PriorityNormal, 1) {
+		t.Errorf("Expected a nil shaper to let traffic through")
+	}
+}
+
+func TestShaper_Priority(t *testing.T) {
+	shaper := NewShaper(1000, nil)
+	shaper.Wait(nil, PriorityNormal, 1000)
+
+	var mutex sync.Mutex
+	var order []Priority
+	var wg sync.WaitGroup
+	send := func(priority Priority) {
+		defer wg.Done()
+		shaper.Wait(nil, priority, 100)
+		mutex.Lock()
+		order = append(order, priority)
+		mutex.Unlock()
+	}
+	wg.Add(3)
+	go send(PriorityLow)
+	time.Sleep(20 * time.Millisecond)
+	go send(PriorityNormal)
+	time.Sleep(20 * time.Millisecond)
+	go send(PriorityHigh)
+	wg.Wait()
+
+	if len(order) != 3 || order[0] != PriorityHigh || order[1] != PriorityNormal || order[2] != PriorityLow {
+		t.Errorf("Expected waiting traffic to pass highest priority first, got %v", order)
+	}
+}
+
+func TestShaper_Done(t *testing.T) {
+	parent := NewShaper(1000, nil)
+	shaper := NewShaper(0, parent)
+	shaper.Wait(nil, PriorityNormal, 1000)
+
+	done := make(chan struct{})
+	result := make(chan bool)
+	go func() {
+		result <- shaper.Wait(done, PriorityNormal, 1000)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(done)
+	select {
+	case ok := <-result:
+		if ok {
+			t.Errorf("Expected Wait to fail when done is closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Wait to return when done is closed")
+	}
+	if len(parent.waiters) != 0 {
+		t.Errorf("Expected the abandoned waiter to be removed")
+	}
+}
+
+func TestNetwork_Bandwidth(t *testing.T) {
+	server := startTestNetwork(t)
+	client := startTestNetwork(t, func(config *Config) {
+		config.Bandwidth.PeerUpload = 50000
+	})
+	received := make(chan struct{}, 10)
+	server.Handle(MsgTx, func(peer Peer, payload []byte) {
+		received <- struct{}{}
+	})
+	connectTestNetworks(t, client, server)
+
+	// Random payloads are not compressed, so 100KB takes a second at 50KB/s
+	payload := make([]byte, 10000)
+	rand.Read(payload)
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := client.Send(server.Config.ID, MsgTx, payload); err != nil {
+			t.Fatalf("Expected Send to succeed, got %v", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected every message to arrive")
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+		t.Errorf("Expected the upload cap to slow the sender, took %v", elapsed)
+	}
+
+	size := uint64(10 * (HeaderSize + len(payload)))
+	stats := client.Bandwidth()
+	if stats.Types["tx"].BytesOut != size || stats.Types["tx"].MessagesOut != 10 {
+		t.Errorf("Expected %d bytes of tx traffic to be counted, got %+v", size, stats.Types["tx"])
+	}
+	if stats.Peers[server.Config.ID].BytesOut < size || stats.Total.BytesOut < size {
+		t.Errorf("Expected the traffic to be counted for the peer and the node, got %+v", stats)
+	}
+	status, ok := server.PeerStatus(client.Config.ID)
+	if !ok || status.Traffic.BytesIn < size {
+		t.Errorf("Expected the peer status to report the received traffic, got %+v", status.Traffic)
+	}
+}
+
+func TestNetwork_PublishMetrics(t *testing.T) {
+	network := NewNetwork(Config{})
+	name := fmt.Sprintf("network_%p", network)
+	network.PublishMetrics(name)
+	published := expvar.Get(name)
+	if published == nil || !strings.Contains(published.String(), `"total"`) {
+		t.Errorf("Expected the bandwidth counters to be published, got %v", published)
+	}
+}
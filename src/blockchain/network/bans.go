@@ -0,0 +1,209 @@
+package network
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Ban represents a banned peer
+type Ban struct {
+	// ID is the ID of the banned peer
+	ID string `json:"id"`
+
+	// Reason is the reason for the ban
+	Reason string `json:"reason"`
+
+	// CreatedAt is the time of the most recent ban
+	CreatedAt time.Time `json:"created_at"`
+
+	// Until is the time a temporary ban expires
+	Until time.Time `json:"until,omitempty"`
+
+	// Permanent is true if the ban never expires
+	Permanent bool `json:"permanent"`
+
+	// Count is the number of times the peer has been banned
+	Count int `json:"count"`
+}
+
+// Active reports whether the ban is in effect at the given time
+func (b Ban) Active(now time.Time) bool {
+	return b.Permanent || now.Before(b.Until)
+}
+
+// BanList tracks banned peers
+type BanList struct {
+	// Path is the file the ban list is persisted to
+	Path string
+
+	// Mutex is a mutex to protect access to the bans
+	Mutex sync.RWMutex
+
+	// Bans are the bans, keyed by peer ID. Expired bans are kept so repeat
+	// offenders can be escalated to a permanent ban.
+	Bans map[string]*Ban
+}
+
+// NewBanList returns a new ban list persisted at path
+func NewBanList(path string) *BanList {
+	return &BanList{
+		Path: path,
+		Bans: make(map[string]*Ban),
+	}
+}
+
+// Ban bans a peer for a duration. A zero duration bans it permanently.
+func (l *BanList) Ban(id string, duration time.Duration, reason string) Ban {
+	now := time.Now()
+	l.Mutex.Lock()
+	ban, ok := l.Bans[id]
+	if !ok {
+		ban = &Ban{ID: id}
+		l.Bans[id] = ban
+	}
+	ban.Reason = reason
+	ban.CreatedAt = now
+	ban.Count++
+	if duration <= 0 {
+		ban.Permanent = true
+		ban.Until = time.Time{}
+	} else {
+		ban.Until = now.Add(duration)
+	}
+	result := *ban
+	l.Mutex.Unlock()
+	return result
+}
+
+// Escalate bans a peer that is not currently banned, for a duration or
+// permanently once it has been banned maxTemporary times. It reports false
+// without changing the ban if the peer is already banned.
+func (l *BanList) Escalate(id string, duration time.Duration, maxTemporary int, reason string) (Ban, bool) {
+	now := time.Now()
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+	ban, ok := l.Bans[id]
+	if ok && ban.Active(now) {
+		return *ban, false
+	}
+	if !ok {
+		ban = &Ban{ID: id}
+		l.Bans[id] = ban
+	}
+	ban.Reason = reason
+	ban.CreatedAt = now
+	ban.Count++
+	if ban.Count >= maxTemporary {
+		ban.Permanent = true
+		ban.Until = time.Time{}
+	} else {
+		ban.Until = now.Add(duration)
+	}
+	return *ban, true
+}
+
+// Unban lifts a ban and forgets the peer's ban history. A peer that is not
+// currently banned keeps its history.
+func (l *BanList) Unban(id string) bool {
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+	ban, ok := l.Bans[id]
+	if !ok || !ban.Active(time.Now()) {
+		return false
+	}
+	delete(l.Bans, id)
+	return true
+}
+
+// IsBanned reports whether a peer is currently banned
+func (l *BanList) IsBanned(id string) bool {
+	l.Mutex.RLock()
+	defer l.Mutex.RUnlock()
+	ban, ok := l.Bans[id]
+	return ok && ban.Active(time.Now())
+}
+
+// Count returns the number of times a peer has been banned
+func (l *BanList) Count(id string) int {
+	l.Mutex.RLock()
+	defer l.Mutex.RUnlock()
+	if ban, ok := l.Bans[id]; ok {
+		return ban.Count
+	}
+	return 0
+}
+
+// Active returns the bans currently in effect
+func (l *BanList) Active() []Ban {
+	now := time.Now()
+	l.Mutex.RLock()
+	bans := make([]Ban, 0, len(l.Bans))
+	for _, ban := range l.Bans {
+		if ban.Active(now) {
+			bans = append(bans, *ban)
+		}
+	}
+	l.Mutex.RUnlock()
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].ID < bans[j].ID
+	})
+	return bans
+}
+
+// Save persists the ban list to disk
+func (l *BanList) Save() error {
+	if l.Path == "" {
+		return nil
+	}
+	l.Mutex.RLock()
+	bans := make([]Ban, 0, len(l.Bans))
+	for _, ban := range l.Bans {
+		bans = append(bans, *ban)
+	}
+	l.Mutex.RUnlock()
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].ID < bans[j].ID
+	})
+
+	data, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		return err
+	}
+	tmp := l.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.Path)
+}
+
+// Load loads the ban list from disk. A missing file is not an error.
+func (l *BanList) Load() error {
+	if l.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(l.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var bans []Ban
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return err
+	}
+	l.Mutex.Lock()
+	defer l.Mutex.Unlock()
+	for i := range bans {
+		ban := bans[i]
+		l.Bans[ban.ID] = &ban
+	}
+	return nil
+}
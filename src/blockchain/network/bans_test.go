@@ -0,0 +1,100 @@
+package network
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBanList_Ban(t *testing.T) {
+	list := NewBanList("")
+	list.Ban("a", 50*time.Millisecond, "spam")
+	list.Ban("b", 0, "attack")
+	if !list.IsBanned("a") || !list.IsBanned("b") {
+		t.Fatalf("Expected both peers to be banned")
+	}
+	if len(list.Active()) != 2 {
+		t.Errorf("Expected 2 active bans, got %d", len(list.Active()))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if list.IsBanned("a") {
+		t.Errorf("Expected the temporary ban to expire")
+	}
+	if !list.IsBanned("b") {
+		t.Errorf("Expected the permanent ban not to expire")
+	}
+	if list.Count("a") != 1 {
+		t.Errorf("Expected an expired ban to be remembered, got count %d", list.Count("a"))
+	}
+
+	list.Ban("a", time.Hour, "spam again")
+	if list.Count("a") != 2 {
+		t.Errorf("Expected the ban count to be 2, got %d", list.Count("a"))
+	}
+}
+
+func TestBanList_Unban(t *testing.T) {
+	list := NewBanList("")
+	list.Ban("a", time.Hour, "spam")
+	if !list.Unban("a") {
+		t.Errorf("Expected Unban to lift an active ban")
+	}
+	if list.IsBanned("a") || list.Count("a") != 0 {
+		t.Errorf("Expected Unban to forget the peer")
+	}
+	if list.Unban("a") {
+		t.Errorf("Expected Unban to report a peer that is not banned")
+	}
+
+	// An expired ban is not lifted, and its history is kept
+	list.Ban("b", time.Millisecond, "spam")
+	time.Sleep(5 * time.Millisecond)
+	if list.Unban("b") {
+		t.Errorf("Expected Unban to report an expired ban")
+	}
+	if list.Count("b") != 1 {
+		t.Errorf("Expected the expired ban to be remembered, got count %d", list.Count("b"))
+	}
+}
+
+func TestBanList_Escalate(t *testing.T) {
+	list := NewBanList("")
+	if _, ok := list.Escalate("a", time.Millisecond, 2, "spam"); !ok {
+		t.Fatalf("Expected Escalate to ban the peer")
+	}
+	if _, ok := list.Escalate("a", time.Millisecond, 2, "spam"); ok {
+		t.Errorf("Expected Escalate to leave an active ban alone")
+	}
+	time.Sleep(5 * time.Millisecond)
+	ban, ok := list.Escalate("a", time.Millisecond, 2, "spam")
+	if !ok || !ban.Permanent || ban.Count != 2 {
+		t.Errorf("Expected the second ban to be permanent, got %+v", ban)
+	}
+}
+
+func TestBanList_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	list := NewBanList(path)
+	list.Ban("a", time.Hour, "spam")
+	list.Ban("b", 0, "attack")
+	if err := list.Save(); err != nil {
+		t.Fatalf("Expected Save to succeed, got %v", err)
+	}
+
+	loaded := NewBanList(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Expected Load to succeed, got %v", err)
+	}
+	if !loaded.IsBanned("a") || !loaded.IsBanned("b") {
+		t.Errorf("Expected bans to survive a restart")
+	}
+	bans := loaded.Active()
+	if len(bans) != 2 || bans[0].Reason != "spam" || !bans[1].Permanent {
+		t.Errorf("Expected the loaded bans to match, got %+v", bans)
+	}
+
+	if err := NewBanList(filepath.Join(t.TempDir(), "missing.json")).Load(); err != nil {
+		t.Errorf("Expected loading a missing file to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// CompressionThreshold is the payload size from which frames are compressed
+const CompressionThreshold = 512
+
+// DefaultCompression lists the compression algorithms offered by default
+var DefaultCompression = []string{"deflate", "gzip"}
+
+// ErrDecompression is returned for frames that fail to decompress
+var ErrDecompression = errors.New("invalid compressed frame")
+
+// codec compresses frame payloads
+type codec struct {
+	writer func(w io.Writer) (io.WriteCloser, error)
+	reader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// codecs are the supported compression algorithms
+var codecs = map[string]*codec{
+	"deflate": {
+		writer: func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, flate.DefaultCompression)
+		},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			return flate.NewReader(r), nil
+		},
+	},
+	"gzip": {
+		writer: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+}
+
+// compress compresses a payload with the named algorithm
+func compress(name string, payload []byte) ([]byte, error) {
+	c, ok := codecs[name]
+	if !ok {
+		return nil, errors.New("unsupported compression " + name)
+	}
+	var buf bytes.Buffer
+	w, err := c.writer(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress decompresses a payload with the named algorithm, refusing to
+// produce more than maxSize bytes
+func decompress(name string, payload []byte, maxSize int) ([]byte, error) {
+	c, ok := codecs[name]
+	if !ok {
+		return nil, ErrDecompression
+	}
+	r, err := c.reader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, ErrDecompression
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, ErrDecompression
+	}
+	if len(data) > maxSize {
+		return nil, ErrFrameTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,89 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCompress(t *testing.T) {
+	payload := bytes.Repeat([]byte("skybridge "), 200)
+	for name := range codecs {
+		compressed, err := compress(name, payload)
+		if err != nil {
+			t.Fatalf("Expected %s compression to succeed, got %v", name, err)
+		}
+		if len(compressed) >= len(payload) {
+			t.Errorf("Expected %s to shrink a repetitive payload", name)
+		}
+		decompressed, err := decompress(name, compressed, len(payload))
+		if err != nil || !bytes.Equal(decompressed, payload) {
+			t.Errorf("Expected %s to round trip, got %v", name, err)
+		}
+		if _, err := decompress(name, compressed, len(payload)-1); err != ErrFrameTooLarge {
+			t.Errorf("Expected %s output over the limit to be rejected, got %v", name, err)
+		}
+		if _, err := decompress(name, []byte("garbage"), len(payload)); err == nil {
+			t.Errorf("Expected %s to reject invalid data", name)
+		}
+	}
+	if _, err := compress("lz4", payload); err == nil {
+		t.Errorf("Expected an unsupported algorithm to fail")
+	}
+}
+
+// countingConn counts the bytes written to a connection
+type countingConn struct {
+	net.Conn
+	written int64
+}
+
+// Write counts and writes data
+func (c *countingConn) Write(b []byte) (int, error) {
+	atomic.AddInt64(&c.written, int64(len(b)))
+	return c.Conn.Write(b)
+}
+
+func TestSession_Compression(t *testing.T) {
+	a, b := net.Pipe()
+	counter := &countingConn{Conn: a}
+	initiator := NewSession(counter, true, DefaultMaxFrameSize)
+	acceptor := NewSession(b, false, DefaultMaxFrameSize)
+	initiator.Compression = "deflate"
+	acceptor.Compression = "deflate"
+	defer initiator.Close()
+	defer acceptor.Close()
+
+	received := make(chan Frame, 2)
+	acceptor.OnMessage = func(frame Frame) {
+		received <- frame
+	}
+	go initiator.Run()
+	go acceptor.Run()
+
+	payload := bytes.Repeat([]byte("block "), 1000)
+	if err := initiator.Send(MsgBlock, payload); err != nil {
+		t.Fatalf("Expected Send to succeed, got %v", err)
+	}
+	select {
+	case frame := <-received:
+		if !bytes.Equal(frame.Payload, payload) || frame.Flags&FlagCompressed != 0 {
+			t.Errorf("Expected the payload to be decompressed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the block to be delivered")
+	}
+	if written := atomic.LoadInt64(&counter.written); written >= int64(len(payload)) {
+		t.Errorf("Expected the payload to be compressed on the wire, wrote %d bytes", written)
+	}
+
+	// Small payloads are sent as they are
+	before := atomic.LoadInt64(&counter.written)
+	initiator.Send(MsgTx, []byte("tx"))
+	<-received
+	if written := atomic.LoadInt64(&counter.written) - before; written != HeaderSize+2 {
+		t.Errorf("Expected a small payload not to be compressed, wrote %d bytes", written)
+	}
+}
@@ -0,0 +1,329 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// DefaultMaxInbound is the default maximum number of inbound peers
+const DefaultMaxInbound = 32
+
+// DefaultMaxOutbound is the default maximum number of outbound peers
+const DefaultMaxOutbound = 16
+
+// DefaultHandshakeTimeout is the default time a new connection has to
+// complete the handshakes
+const DefaultHandshakeTimeout = 10 * time.Second
+
+// DefaultKeepAliveInterval is the default interval between keepalive pings
+const DefaultKeepAliveInterval = 15 * time.Second
+
+// DefaultKeepAliveTimeout is the default time to wait for a keepalive pong
+const DefaultKeepAliveTimeout = 10 * time.Second
+
+// DefaultReconnectDelay is the default initial delay before redialing a
+// persistent peer
+const DefaultReconnectDelay = time.Second
+
+// DefaultMaxReconnectDelay is the default maximum delay before redialing a
+// persistent peer
+const DefaultMaxReconnectDelay = 5 * time.Minute
+
+// maxFailedPings is the number of consecutive failed keepalives after which
+// a peer is considered dead
+const maxFailedPings = 3
+
+// ErrTooManyPeers is returned when a connection would exceed a peer limit
+var ErrTooManyPeers = errors.New("too many peers")
+
+// PeerStatus represents the health of a connected peer
+type PeerStatus struct {
+	// ID is the ID of the peer
+	ID string `json:"id"`
+
+	// Address is the address of the peer
+	Address string `json:"address"`
+
+	// Outbound is true if the connection was dialed by this node
+	Outbound bool `json:"outbound"`
+
+	// Persistent is true if the peer is redialed when disconnected
+	Persistent bool `json:"persistent"`
+
+	// ConnectedAt is the time the connection was established
+	ConnectedAt time.Time `json:"connected_at"`
+
+	// LastPong is the time of the last successful keepalive
+	LastPong time.Time `json:"last_pong,omitempty"`
+
+	// RTT is the round trip time of the last successful keepalive
+	RTT time.Duration `json:"rtt"`
+
+	// FailedPings is the number of consecutive failed keepalives
+	FailedPings int `json:"failed_pings"`
+
+	// Score is the peer's current score
+	Score float64 `json:"score"`
+
+	// Traffic is the traffic exchanged with the peer
+	Traffic TrafficStats `json:"traffic"`
+
+	meter *Meter
+}
+
+// applyConnDefaults fills in the connection manager defaults
+func (c *Config) applyConnDefaults() {
+	if c.MaxInbound <= 0 {
+		c.MaxInbound = DefaultMaxInbound
+	}
+	if c.MaxOutbound <= 0 {
+		c.MaxOutbound = DefaultMaxOutbound
+	}
+	if c.HandshakeTimeout <= 0 {
+		c.HandshakeTimeout = c.Timeout
+		if c.HandshakeTimeout <= 0 {
+			c.HandshakeTimeout = DefaultHandshakeTimeout
+		}
+	}
+	if c.KeepAliveInterval <= 0 {
+		c.KeepAliveInterval = DefaultKeepAliveInterval
+	}
+	if c.KeepAliveTimeout <= 0 {
+		c.KeepAliveTimeout = DefaultKeepAliveTimeout
+	}
+	if c.ReconnectDelay <= 0 {
+		c.ReconnectDelay = DefaultReconnectDelay
+	}
+	if c.MaxReconnectDelay <= 0 {
+		c.MaxReconnectDelay = DefaultMaxReconnectDelay
+	}
+}
+
+// isPersistent reports whether an address or peer ID is a persistent peer
+func (n *Network) isPersistent(address string, id string) bool {
+	n.statusMutex.RLock()
+	defer n.statusMutex.RUnlock()
+	if _, ok := n.persistent[address]; ok {
+		return true
+	}
+	for _, persistentID := range n.persistent {
+		if id != "" && persistentID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// countPeers returns the number of inbound and outbound peers. The caller
+// must hold the peers mutex.
+func (n *Network) countPeers() (int, int) {
+	inbound, outbound := 0, 0
+	for _, peer := range n.Peers {
+		if peer.Outbound {
+			outbound++
+		} else {
+			inbound++
+		}
+	}
+	return inbound, outbound
+}
+
+// outboundFull reports whether the outbound peer limit has been reached
+func (n *Network) outboundFull() bool {
+	n.Mutex.RLock()
+	defer n.Mutex.RUnlock()
+	_, outbound := n.countPeers()
+	return outbound >= n.Config.MaxOutbound
+}
+
+// admitPeer checks the peer limits and adds the peer to the list of peers.
+// Persistent peers are admitted regardless of the limits.
+func (n *Network) admitPeer(peer Peer) error {
+	n.Mutex.Lock()
+	defer n.Mutex.Unlock()
+	if n.closing {
+		return ErrNetworkStopped
+	}
+	for _, p := range n.Peers {
+		if p.ID == peer.ID {
+			return errDuplicatePeer
+		}
+	}
+	if !peer.Persistent {
+		inbound, outbound := n.countPeers()
+		if peer.Outbound && outbound >= n.Config.MaxOutbound {
+			return ErrTooManyPeers
+		}
+		if !peer.Outbound && inbound >= n.Config.MaxInbound {
+			return ErrTooManyPeers
+		}
+	}
+	n.Peers = append(n.Peers, peer)
+
+	n.statusMutex.Lock()
+	n.statuses[peer.ID] = &PeerStatus{
+		ID:          peer.ID,
+		Address:     peer.Address,
+		Outbound:    peer.Outbound,
+		Persistent:  peer.Persistent,
+		ConnectedAt: peer.ConnectedAt,
+		meter:       peer.Session.Meter,
+	}
+	n.statusMutex.Unlock()
+	return nil
+}
+
+// errDuplicatePeer is returned when a peer is already connected
+var errDuplicatePeer = errors.New("peer is already connected")
+
+// disconnectReason returns the disconnect reason for an admission error
+func disconnectReason(err error) DisconnectReason {
+	switch err {
+	case ErrTooManyPeers:
+		return ReasonTooManyPeers
+	case errDuplicatePeer:
+		return ReasonDuplicate
+	case ErrNetworkStopped:
+		return ReasonShutdown
+	default:
+		return ReasonProtocolError
+	}
+}
+
+// PeerStatuses returns the status of every connected peer
+func (n *Network) PeerStatuses() []PeerStatus {
+	n.statusMutex.RLock()
+	defer n.statusMutex.RUnlock()
+	now := time.Now()
+	statuses := make([]PeerStatus, 0, len(n.statuses))
+	for _, status := range n.statuses {
+		result := *status
+		if score, ok := n.scores[status.ID]; ok {
+			result.Score = score.at(now)
+		}
+		if status.meter != nil {
+			result.Traffic = status.meter.Total()
+		}
+		statuses = append(statuses, result)
+	}
+	return statuses
+}
+
+// PeerStatus returns the status of a connected peer
+func (n *Network) PeerStatus(id string) (PeerStatus, bool) {
+	n.statusMutex.RLock()
+	defer n.statusMutex.RUnlock()
+	status, ok := n.statuses[id]
+	if !ok {
+		return PeerStatus{}, false
+	}
+	result := *status
+	if score, ok := n.scores[id]; ok {
+		result.Score = score.at(time.Now())
+	}
+	if status.meter != nil {
+		result.Traffic = status.meter.Total()
+	}
+	return result, true
+}
+
+// keepAlive pings a peer until its session ends, closing the session when
+// the peer stops answering
+func (n *Network) keepAlive(ctx context.Context, peer Peer) {
+	ticker := time.NewTicker(n.Config.KeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-peer.Session.Done():
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, n.Config.KeepAliveTimeout)
+		rtt, err := peer.Session.Ping(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		failed := 0
+		n.statusMutex.Lock()
+		if status, ok := n.statuses[peer.ID]; ok {
+			if err == nil {
+				status.LastPong = time.Now()
+				status.RTT = rtt
+				status.FailedPings = 0
+			} else {
+				status.FailedPings++
+			}
+			failed = status.FailedPings
+		}
+		n.statusMutex.Unlock()
+
+		if failed >= maxFailedPings {
+			log.Printf("peer %s stopped answering keepalives", peer.ID)
+			peer.Session.Disconnect(ReasonTimeout, "keepalive timeout")
+			return
+		}
+	}
+}
+
+// maintainPersistentPeer keeps a connection to a persistent peer open,
+// redialing it with exponential backoff whenever it is lost, until ctx is done
+func (n *Network) maintainPersistentPeer(ctx context.Context, address string) {
+	delay := n.Config.ReconnectDelay
+	for ctx.Err() == nil {
+		n.statusMutex.RLock()
+		id := n.persistent[address]
+		n.statusMutex.RUnlock()
+
+		// The peer may have connected to us
+		if peer, ok := n.Peer(id); ok && id != "" {
+			waitSession(ctx, peer.Session)
+			continue
+		}
+
+		peer, err := n.Dial(Peer{Address: address})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("dialing persistent peer %s failed: %v", address, err)
+			timer := time.NewTimer(jitter(delay))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			delay *= 2
+			if delay > n.Config.MaxReconnectDelay {
+				delay = n.Config.MaxReconnectDelay
+			}
+			continue
+		}
+		delay = n.Config.ReconnectDelay
+		waitSession(ctx, peer.Session)
+	}
+}
+
+// waitSession waits until a session ends or ctx is done
+func waitSession(ctx context.Context, session *Session) {
+	select {
+	case <-session.Done():
+	case <-ctx.Done():
+	}
+}
+
+// jitter returns a random duration between half and all of d
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,153 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestNetwork_MaxInbound(t *testing.T) {
+	server := startTestNetwork(t, func(config *Config) {
+		config.MaxInbound = 1
+	})
+	first := startTestNetwork(t)
+	second := startTestNetwork(t)
+
+	connectTestNetworks(t, first, server)
+	_, err := second.Dial(Peer{Address: server.Listener.Addr().String()})
+	disconnect, ok := err.(*DisconnectError)
+	if !ok || disconnect.Reason != ReasonTooManyPeers {
+		if _, found := server.Peer(second.Config.ID); found {
+			t.Fatalf("Expected the second inbound peer to be rejected")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, found := server.Peer(second.Config.ID); found {
+		t.Errorf("Expected the second inbound peer not to be added")
+	}
+	if len(server.PeerStatuses()) != 1 {
+		t.Errorf("Expected one peer status, got %d", len(server.PeerStatuses()))
+	}
+}
+
+func TestNetwork_MaxOutbound(t *testing.T) {
+	client := startTestNetwork(t, func(config *Config) {
+		config.MaxOutbound = 1
+	})
+	first := startTestNetwork(t)
+	second := startTestNetwork(t)
+
+	connectTestNetworks(t, client, first)
+	_, err := client.Dial(Peer{Address: second.Listener.Addr().String()})
+	if err != ErrTooManyPeers {
+		t.Errorf("Expected Dial to fail with ErrTooManyPeers, got %v", err)
+	}
+}
+
+func TestNetwork_KeepAlive(t *testing.T) {
+	server := startTestNetwork(t, func(config *Config) {
+		config.KeepAliveInterval = 20 * time.Millisecond
+		config.KeepAliveTimeout = time.Second
+	})
+	client := startTestNetwork(t)
+	connectTestNetworks(t, client, server)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, ok := server.PeerStatus(client.Config.ID)
+		if ok && !status.LastPong.IsZero() {
+			if status.Outbound || status.ConnectedAt.IsZero() {
+				t.Errorf("Expected an inbound status with a connection time, got %+v", status)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected keepalives to update the peer status")
+}
+
+func TestNetwork_KeepAliveRemovesDeadPeer(t *testing.T) {
+	server := startTestNetwork(t, func(config *Config) {
+		config.KeepAliveInterval = 100 * time.Millisecond
+		config.KeepAliveTimeout = 100 * time.Millisecond
+	})
+
+	// Complete a handshake by hand and then stop reading, like a hung peer
+	cert, key, err := GenerateTLS()
+	if err != nil {
+		t.Fatalf("Expected GenerateTLS to succeed, got %v", err)
+	}
+	pair, err := tls.X509KeyPair([]byte(cert), []byte(key))
+	if err != nil {
+		t.Fatalf("Expected the key pair to load, got %v", err)
+	}
+	block, _ := pem.Decode([]byte(cert))
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Expected the certificate to parse, got %v", err)
+	}
+	id := PeerID(parsed)
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{
+		Certificates:       []tls.Certificate{pair},
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected the TLS connection to succeed, got %v", err)
+	}
+	defer conn.Close()
+	payload, _ := json.Marshal(Handshake{ID: id, Version: ProtocolVersion})
+	WriteFrame(conn, Frame{Type: MsgHandshake, Payload: payload}, DefaultMaxFrameSize)
+	ReadFrame(conn, DefaultMaxFrameSize)
+	waitForPeer(t, server, id)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := server.Peer(id); !ok {
+			if _, ok := server.PeerStatus(id); ok {
+				t.Errorf("Expected the status of a removed peer to be dropped")
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected a peer that stopped answering keepalives to be removed")
+}
+
+func TestNetwork_PersistentPeerReconnects(t *testing.T) {
+	server := startTestNetwork(t)
+	client := startTestNetwork(t, func(config *Config) {
+		config.PersistentPeers = []string{server.Listener.Addr().String()}
+		config.ReconnectDelay = 10 * time.Millisecond
+	})
+
+	first := waitForPeer(t, client, server.Config.ID)
+	if !first.Persistent || !first.Outbound {
+		t.Errorf("Expected a persistent outbound peer, got %+v", first)
+	}
+	waitForPeer(t, server, client.Config.ID)
+
+	server.Disconnect(client.Config.ID, ReasonRequested, "")
+	<-first.Session.Done()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if peer, ok := client.Peer(server.Config.ID); ok && peer.Session != first.Session {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected the persistent peer to be redialed")
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second)
+		if d < 500*time.Millisecond || d > time.Second {
+			t.Fatalf("Expected jitter to stay between half and all of the delay, got %s", d)
+		}
+	}
+}
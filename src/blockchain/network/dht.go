@@ -0,0 +1,744 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultAlpha is the default number of concurrent requests in a lookup
+const DefaultAlpha = 3
+
+// DefaultProviderTTL is the default time a provider record is kept
+const DefaultProviderTTL = 24 * time.Hour
+
+// DefaultRepublishInterval is the default interval between republishing
+// the content this node provides
+const DefaultRepublishInterval = time.Hour
+
+// DefaultRefreshInterval is the default interval after which a bucket
+// nobody was heard from in is refreshed
+const DefaultRefreshInterval = time.Hour
+
+// DefaultDHTRequestTimeout is the default timeout of a DHT request
+const DefaultDHTRequestTimeout = 5 * time.Second
+
+// DefaultMaxProvidersPerKey is the default number of providers kept for a
+// key
+const DefaultMaxProvidersPerKey = 20
+
+// DefaultMaxProviderRecords is the default number of provider records kept
+// across all keys
+const DefaultMaxProviderRecords = 10000
+
+// dhtRedialDelay is the delay before dialing a peer again that rejected a
+// transient connection as a duplicate
+const dhtRedialDelay = 10 * time.Millisecond
+
+// ErrPeerNotFound is returned when a lookup does not find a peer
+var ErrPeerNotFound = errors.New("peer not found")
+
+// DHT request types
+const (
+	dhtPing          = "ping"
+	dhtFindNode      = "find_node"
+	dhtFindProviders = "find_providers"
+	dhtAddProvider   = "add_provider"
+)
+
+// dhtRequest is the payload of a DHT request
+type dhtRequest struct {
+	Type    string `json:"type"`
+	Target  Key    `json:"target"`
+	Address string `json:"address,omitempty"`
+}
+
+// dhtResponse is the payload of a DHT response
+type dhtResponse struct {
+	Contacts  []Contact `json:"contacts,omitempty"`
+	Providers []Contact `json:"providers,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// DHTConfig represents the configuration of the DHT
+type DHTConfig struct {
+	// BucketSize is the number of contacts per k-bucket, and the number of
+	// closest peers a lookup converges on
+	BucketSize int
+
+	// Alpha is the number of concurrent requests in a lookup
+	Alpha int
+
+	// ProviderTTL is the time a provider record is kept
+	ProviderTTL time.Duration
+
+	// RepublishInterval is the interval between republishing provided
+	// content
+	RepublishInterval time.Duration
+
+	// RefreshInterval is the interval after which idle buckets are
+	// refreshed
+	RefreshInterval time.Duration
+
+	// RequestTimeout is the timeout of a single request
+	RequestTimeout time.Duration
+
+	// MaxProvidersPerKey is the number of providers kept for a key
+	MaxProvidersPerKey int
+
+	// MaxProviderRecords is the number of provider records kept across all
+	// keys. Once a limit is reached, the records closest to expiry make way
+	// for new ones.
+	MaxProviderRecords int
+}
+
+// providerRecord records a peer that provides content
+type providerRecord struct {
+	contact Contact
+	expires time.Time
+}
+
+// DHT is a Kademlia distributed hash table for finding peers by ID and the
+// providers of content by hash
+type DHT struct {
+	// Config is the configuration of the DHT
+	Config DHTConfig
+
+	// Table is the routing table
+	Table *RoutingTable
+
+	// Mutex is a mutex to protect access to the provider records and the
+	// checks in flight
+	Mutex sync.RWMutex
+
+	network   *Network
+	providers map[Key]map[string]providerRecord
+	records   int
+	provided  map[Key]bool
+	verifying map[string]bool
+	pinging   map[int]bool
+}
+
+// NewDHT returns a DHT on top of a started network and registers its
+// handler
+func NewDHT(network *Network, config DHTConfig) (*DHT, error) {
+	self, err := KeyFromID(network.Config.ID)
+	if err != nil {
+		return nil, fmt.Errorf("network must be started before the DHT: %w", err)
+	}
+	if config.BucketSize <= 0 {
+		config.BucketSize = DefaultBucketSize
+	}
+	if config.Alpha <= 0 {
+		config.Alpha = DefaultAlpha
+	}
+	if config.ProviderTTL <= 0 {
+		config.ProviderTTL = DefaultProviderTTL
+	}
+	if config.RepublishInterval <= 0 {
+		config.RepublishInterval = DefaultRepublishInterval
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = DefaultRefreshInterval
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = DefaultDHTRequestTimeout
+	}
+	if config.MaxProvidersPerKey <= 0 {
+		config.MaxProvidersPerKey = DefaultMaxProvidersPerKey
+	}
+	if config.MaxProviderRecords <= 0 {
+		config.MaxProviderRecords = DefaultMaxProviderRecords
+	}
+	d := &DHT{
+		Config:    config,
+		Table:     NewRoutingTable(self, config.BucketSize),
+		network:   network,
+		providers: make(map[Key]map[string]providerRecord),
+		provided:  make(map[Key]bool),
+		verifying: make(map[string]bool),
+		pinging:   make(map[int]bool),
+	}
+	network.HandleStream(MsgDHT, d.handle)
+	network.EnableCapability(CapDHT)
+	return d, nil
+}
+
+// Bootstrap adds the connected peers to the routing table and looks up the
+// local node to populate the buckets around it
+func (d *DHT) Bootstrap(ctx context.Context) error {
+	d.network.Mutex.RLock()
+	peers := make([]Peer, len(d.network.Peers))
+	copy(peers, d.network.Peers)
+	d.network.Mutex.RUnlock()
+	for _, peer := range peers {
+		if peer.Supports(CapDHT) {
+			d.Table.Update(Contact{ID: peer.ID, Address: peer.Address})
+		}
+	}
+	if d.Table.Size() == 0 {
+		return errors.New("no peers to bootstrap from")
+	}
+	_, _, err := d.lookup(ctx, d.Table.Self, false)
+	return err
+}
+
+// Run refreshes idle buckets, republishes provided content and expires
+// provider records until the context is done
+func (d *DHT) Run(ctx context.Context) {
+	interval := d.Config.RepublishInterval
+	if d.Config.RefreshInterval < interval {
+		interval = d.Config.RefreshInterval
+	}
+	ticker := time.NewTicker(interval / 4)
+	defer ticker.Stop()
+	lastRepublish := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		d.expireProviders(time.Now())
+		d.refresh(ctx)
+		if time.Since(lastRepublish) >= d.Config.RepublishInterval {
+			d.republish(ctx)
+			lastRepublish = time.Now()
+		}
+	}
+}
+
+// FindPeer looks up the contact of a peer by ID
+func (d *DHT) FindPeer(ctx context.Context, id string) (Contact, error) {
+	target, err := KeyFromID(id)
+	if err != nil {
+		return Contact{}, err
+	}
+	if contact, ok := d.Table.Contact(id); ok {
+		return contact, nil
+	}
+	closest, _, err := d.lookup(ctx, target, false)
+	if err != nil {
+		return Contact{}, err
+	}
+	for _, contact := range closest {
+		if contact.ID == id {
+			return contact, nil
+		}
+	}
+	return Contact{}, ErrPeerNotFound
+}
+
+// FindClosest looks up the peers closest to a key
+func (d *DHT) FindClosest(ctx context.Context, key Key) ([]Contact, error) {
+	closest, _, err := d.lookup(ctx, key, false)
+	return closest, err
+}
+
+// Provide announces that this node provides the content with a key. The
+// announcement is republished until the context passed to Run is done.
+func (d *DHT) Provide(ctx context.Context, key Key) error {
+	d.Mutex.Lock()
+	d.provided[key] = true
+	d.Mutex.Unlock()
+	d.addProvider(key, d.self())
+	return d.announce(ctx, key)
+}
+
+// announce stores this node as a provider on the peers closest to a key
+func (d *DHT) announce(ctx context.Context, key Key) error {
+	closest, _, err := d.lookup(ctx, key, false)
+	if err != nil {
+		return err
+	}
+	stored := 0
+	var lastErr error
+	for _, contact := range closest {
+		_, err := d.call(ctx, contact, dhtRequest{Type: dhtAddProvider, Target: key})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		stored++
+	}
+	if stored == 0 && lastErr != nil {
+		return fmt.Errorf("no peer stored the provider record: %w", lastErr)
+	}
+	return nil
+}
+
+// FindProviders looks up the peers that provide the content with a key
+func (d *DHT) FindProviders(ctx context.Context, key Key) ([]Contact, error) {
+	providers := d.localProviders(key)
+	if len(providers) > 0 {
+		return providers, nil
+	}
+	_, providers, err := d.lookup(ctx, key, true)
+	return providers, err
+}
+
+// lookup iteratively queries the peers closest to a target until the
+// closest peers have all been queried. When looking for providers it stops
+// as soon as any are found.
+func (d *DHT) lookup(ctx context.Context, target Key, findProviders bool) ([]Contact, []Contact, error) {
+	k := d.Config.BucketSize
+	shortlist := d.Table.Closest(target, k)
+	if len(shortlist) == 0 {
+		return nil, nil, errors.New("routing table is empty")
+	}
+	known := map[string]bool{d.network.Config.ID: true}
+	for _, contact := range shortlist {
+		known[contact.ID] = true
+	}
+	queried := make(map[string]bool)
+	failed := make(map[string]bool)
+	var providers []Contact
+	providerIDs := make(map[string]bool)
+
+	request := dhtRequest{Type: dhtFindNode, Target: target}
+	if findProviders {
+		request.Type = dhtFindProviders
+	}
+	type result struct {
+		contact  Contact
+		response dhtResponse
+		err      error
+	}
+
+	for {
+		batch := make([]Contact, 0, d.Config.Alpha)
+		for _, contact := range shortlist {
+			if len(batch) == d.Config.Alpha {
+				break
+			}
+			if !queried[contact.ID] {
+				batch = append(batch, contact)
+				queried[contact.ID] = true
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		results := make(chan result, len(batch))
+		for _, contact := range batch {
+			go func(contact Contact) {
+				response, err := d.call(ctx, contact, request)
+				results <- result{contact: contact, response: response, err: err}
+			}(contact)
+		}
+		for range batch {
+			r := <-results
+			if r.err != nil {
+				failed[r.contact.ID] = true
+				continue
+			}
+			for _, contact := range r.response.Contacts {
+				if !known[contact.ID] {
+					if _, err := KeyFromID(contact.ID); err != nil {
+						continue
+					}
+					known[contact.ID] = true
+					shortlist = append(shortlist, contact)
+				}
+			}
+			for _, provider := range r.response.Providers {
+				if !providerIDs[provider.ID] {
+					providerIDs[provider.ID] = true
+					providers = append(providers, provider)
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if findProviders && len(providers) > 0 {
+			break
+		}
+
+		// Keep the k closest peers that have not failed
+		alive := shortlist[:0]
+		for _, contact := range shortlist {
+			if !failed[contact.ID] {
+				alive = append(alive, contact)
+			}
+		}
+		shortlist = alive
+		sortByDistance(shortlist, target)
+		if len(shortlist) > k {
+			shortlist = shortlist[:k]
+		}
+	}
+
+	closest := make([]Contact, 0, len(shortlist))
+	for _, contact := range shortlist {
+		if queried[contact.ID] && !failed[contact.ID] {
+			closest = append(closest, contact)
+		}
+	}
+	return closest, providers, nil
+}
+
+// call sends a request to a contact. Contacts that are not connected peers
+// are reached over a short-lived connection that does not take up a peer
+// slot.
+func (d *DHT) call(ctx context.Context, contact Contact, request dhtRequest) (dhtResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Config.RequestTimeout)
+	defer cancel()
+	peer, ok := d.network.Peer(contact.ID)
+	if !ok {
+		var err error
+		peer, err = d.dialTransient(ctx, contact)
+		if err != nil {
+			if err != ErrNotStarted && err != ErrNetworkStopped {
+				d.Table.Remove(contact.ID)
+			}
+			return dhtResponse{}, err
+		}
+		defer peer.Session.Disconnect(ReasonRequested, "")
+	}
+	if !peer.Supports(CapDHT) {
+		d.Table.Remove(contact.ID)
+		return dhtResponse{}, fmt.Errorf("peer %s does not support the DHT", contact.ID)
+	}
+	request.Address = d.self().Address
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return dhtResponse{}, err
+	}
+	reply, err := peer.Session.Request(ctx, MsgDHT, payload)
+	if err != nil {
+		// A peer that is only out of room is still alive
+		var disconnect *DisconnectError
+		if !errors.As(err, &disconnect) || disconnect.Reason != ReasonTooManyPeers {
+			d.Table.Remove(contact.ID)
+		}
+		return dhtResponse{}, err
+	}
+	var response dhtResponse
+	if err := json.Unmarshal(reply.Payload, &response); err != nil {
+		d.network.ReportPeer(contact.ID, EventInvalidMessage)
+		return dhtResponse{}, err
+	}
+	if response.Error != "" {
+		return dhtResponse{}, errors.New(response.Error)
+	}
+	d.seen(contact)
+	return response, nil
+}
+
+// handle answers a DHT request from a peer
+func (d *DHT) handle(peer Peer, stream *Stream, payload []byte) {
+	defer stream.Close()
+	var request dhtRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		d.network.ReportPeer(peer.ID, EventInvalidMessage)
+		return
+	}
+
+	var response dhtResponse
+	var provide *Key
+	switch request.Type {
+	case dhtPing:
+	case dhtFindNode:
+		response.Contacts = d.closestExcept(request.Target, peer.ID)
+	case dhtFindProviders:
+		response.Providers = d.localProviders(request.Target)
+		response.Contacts = d.closestExcept(request.Target, peer.ID)
+	case dhtAddProvider:
+		// Peers can only announce themselves as providers
+		provide = &request.Target
+	default:
+		response.Error = fmt.Sprintf("unknown request type %q", request.Type)
+	}
+	d.record(peer, request.Address, provide)
+
+	reply, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("failed to encode DHT response: %v", err)
+		return
+	}
+	stream.SendEnd(MsgDHT, reply)
+}
+
+// closestExcept returns the closest contacts to a key, leaving out a peer
+func (d *DHT) closestExcept(target Key, id string) []Contact {
+	closest := d.Table.Closest(target, d.Config.BucketSize+1)
+	result := make([]Contact, 0, len(closest))
+	for _, contact := range closest {
+		if contact.ID != id && len(result) < d.Config.BucketSize {
+			result = append(result, contact)
+		}
+	}
+	return result
+}
+
+// record adds a peer that sent a request to the routing table, and as a
+// provider of a key if it announced one. The address the peer claims to
+// listen on is only trusted once it is known to reach the peer: when it is
+// the address the connection was dialed at or came from, when the routing
+// table already holds it, or when dialing it reaches the same peer.
+func (d *DHT) record(peer Peer, claimed string, provide *Key) {
+	observed := peer.Address
+	if !peer.Outbound && peer.Conn != nil {
+		observed = peer.Conn.RemoteAddr().String()
+	}
+	contact := Contact{ID: peer.ID, Address: claimed}
+	if claimed == "" || claimed == observed {
+		contact.Address = observed
+	} else if known, ok := d.Table.Contact(peer.ID); !ok || known.Address != claimed {
+		// An announcement is answered once it is recorded, so its
+		// address is checked before replying
+		if provide == nil {
+			d.verify(contact)
+			return
+		}
+		if err := d.checkAddress(contact); err != nil {
+			return
+		}
+	}
+	if provide != nil {
+		d.addProvider(*provide, contact)
+	}
+	d.seen(contact)
+}
+
+// verify checks the address a peer claims in the background and records the
+// peer if it holds. Only one check per peer is in flight at a time.
+func (d *DHT) verify(contact Contact) {
+	d.Mutex.Lock()
+	if d.verifying[contact.ID] {
+		d.Mutex.Unlock()
+		return
+	}
+	d.verifying[contact.ID] = true
+	d.Mutex.Unlock()
+	done := func() {
+		d.Mutex.Lock()
+		delete(d.verifying, contact.ID)
+		d.Mutex.Unlock()
+	}
+	if !d.network.spawn(func() {
+		defer done()
+		if err := d.checkAddress(contact); err == nil {
+			d.seen(contact)
+		}
+	}) {
+		done()
+	}
+}
+
+// dialTransient connects to a contact for a request. A peer that has not
+// yet torn down the connection of a previous request rejects the next one as
+// a duplicate, so the dial is retried until the request times out.
+func (d *DHT) dialTransient(ctx context.Context, contact Contact) (Peer, error) {
+	for {
+		peer, err := d.network.dialTransient(ctx, Peer{ID: contact.ID, Address: contact.Address})
+		var disconnect *DisconnectError
+		if !errors.As(err, &disconnect) || disconnect.Reason != ReasonDuplicate {
+			return peer, err
+		}
+		select {
+		case <-ctx.Done():
+			return Peer{}, err
+		case <-time.After(dhtRedialDelay):
+		}
+	}
+}
+
+// checkAddress dials the address of a contact to check it reaches the
+// contact
+func (d *DHT) checkAddress(contact Contact) error {
+	ctx, cancel := context.WithTimeout(d.network.context(), d.Config.RequestTimeout)
+	defer cancel()
+	return d.network.verifyAddress(ctx, contact.ID, contact.Address)
+}
+
+// seen records that a contact was heard from. When its bucket is full the
+// least recently seen contact is pinged and replaced if it is dead, with at
+// most one ping per bucket in flight.
+func (d *DHT) seen(contact Contact) {
+	contact.LastSeen = time.Now()
+	added, oldest := d.Table.Update(contact)
+	if added || oldest.ID == "" {
+		return
+	}
+	key, err := KeyFromID(oldest.ID)
+	if err != nil {
+		return
+	}
+	bucket := commonPrefixLen(d.Table.Self, key)
+	d.Mutex.Lock()
+	if d.pinging[bucket] {
+		d.Mutex.Unlock()
+		return
+	}
+	d.pinging[bucket] = true
+	d.Mutex.Unlock()
+	done := func() {
+		d.Mutex.Lock()
+		delete(d.pinging, bucket)
+		d.Mutex.Unlock()
+	}
+	if !d.network.spawn(func() {
+		defer done()
+		ctx, cancel := context.WithTimeout(d.network.context(), d.Config.RequestTimeout)
+		defer cancel()
+		if _, err := d.call(ctx, oldest, dhtRequest{Type: dhtPing}); err != nil {
+			d.Table.Remove(oldest.ID)
+			d.Table.Update(contact)
+		}
+	}) {
+		done()
+	}
+}
+
+// self returns the contact of the local node
+func (d *DHT) self() Contact {
+	address := d.network.Config.Address
+	if address == "" && d.network.Listener != nil {
+		address = d.network.Listener.Addr().String()
+	}
+	return Contact{ID: d.network.Config.ID, Address: address}
+}
+
+// addProvider records a provider of the content with a key. When the key or
+// the whole DHT is at its limit, expired records are dropped first and then
+// the records closest to expiry. The local node's records are always kept.
+func (d *DHT) addProvider(key Key, contact Contact) {
+	now := time.Now()
+	self := contact.ID == d.network.Config.ID
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	records, ok := d.providers[key]
+	if !ok {
+		records = make(map[string]providerRecord)
+		d.providers[key] = records
+	}
+	if _, ok := records[contact.ID]; !ok && !self {
+		if len(records) >= d.Config.MaxProvidersPerKey {
+			d.expireLocked(now)
+			if len(records) >= d.Config.MaxProvidersPerKey {
+				d.evictLocked(key, records)
+			}
+		}
+		if d.records >= d.Config.MaxProviderRecords {
+			d.expireLocked(now)
+			if d.records >= d.Config.MaxProviderRecords {
+				d.evictLocked(Key{}, nil)
+			}
+		}
+		// Expiry may have removed the key's records
+		if d.providers[key] == nil {
+			d.providers[key] = records
+		}
+	}
+	if _, ok := records[contact.ID]; !ok {
+		d.records++
+	}
+	records[contact.ID] = providerRecord{
+		contact: contact,
+		expires: now.Add(d.Config.ProviderTTL),
+	}
+}
+
+// evictLocked removes the provider record closest to expiry, from the given
+// key's records or from any key if records is nil. Records of the local node
+// are not evicted. The caller must hold the mutex.
+func (d *DHT) evictLocked(key Key, records map[string]providerRecord) {
+	var victimKey Key
+	var victimID string
+	var victim providerRecord
+	consider := func(key Key, records map[string]providerRecord) {
+		for id, record := range records {
+			if id == d.network.Config.ID {
+				continue
+			}
+			if victimID == "" || record.expires.Before(victim.expires) {
+				victimKey, victimID, victim = key, id, record
+			}
+		}
+	}
+	if records != nil {
+		consider(key, records)
+	} else {
+		for key, records := range d.providers {
+			consider(key, records)
+		}
+	}
+	if victimID == "" {
+		return
+	}
+	delete(d.providers[victimKey], victimID)
+	d.records--
+	if len(d.providers[victimKey]) == 0 {
+		delete(d.providers, victimKey)
+	}
+}
+
+// localProviders returns the unexpired providers of a key known locally
+func (d *DHT) localProviders(key Key) []Contact {
+	now := time.Now()
+	d.Mutex.RLock()
+	defer d.Mutex.RUnlock()
+	var providers []Contact
+	for _, record := range d.providers[key] {
+		if now.Before(record.expires) {
+			providers = append(providers, record.contact)
+		}
+	}
+	return providers
+}
+
+// expireProviders removes expired provider records
+func (d *DHT) expireProviders(now time.Time) {
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	d.expireLocked(now)
+}
+
+// expireLocked removes expired provider records. The caller must hold the
+// mutex.
+func (d *DHT) expireLocked(now time.Time) {
+	for key, records := range d.providers {
+		for id, record := range records {
+			if !now.Before(record.expires) {
+				delete(records, id)
+				d.records--
+			}
+		}
+		if len(records) == 0 {
+			delete(d.providers, key)
+		}
+	}
+}
+
+// republish announces the provided content again
+func (d *DHT) republish(ctx context.Context) {
+	d.Mutex.RLock()
+	keys := make([]Key, 0, len(d.provided))
+	for key := range d.provided {
+		keys = append(keys, key)
+	}
+	d.Mutex.RUnlock()
+	for _, key := range keys {
+		d.addProvider(key, d.self())
+		if err := d.announce(ctx, key); err != nil {
+			log.Printf("failed to republish %s: %v", key, err)
+		}
+	}
+}
+
+// refresh looks up a random key in each bucket nobody was heard from in
+// during the refresh interval
+func (d *DHT) refresh(ctx context.Context) {
+	for _, i := range d.Table.StaleBuckets(time.Now().Add(-d.Config.RefreshInterval)) {
+		if _, _, err := d.lookup(ctx, d.Table.randomKey(i), false); err != nil {
+			log.Printf("failed to refresh bucket %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// startDHTNetworks starts n networks on an in-memory network, each connected
+// to the previous one, with a bootstrapped DHT
+func startDHTNetworks(t *testing.T, n int, config DHTConfig) ([]*Network, []*DHT) {
+	memory := NewMemoryNetwork(1)
+	networks := make([]*Network, n)
+	dhts := make([]*DHT, n)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := range networks {
+		networks[i] = startTestNetwork(t, func(config *Config) {
+			config.Transport = memory.Transport()
+		})
+		dht, err := NewDHT(networks[i], config)
+		if err != nil {
+			t.Fatalf("Expected NewDHT to succeed, got %v", err)
+		}
+		dhts[i] = dht
+		if i > 0 {
+			connectTestNetworks(t, networks[i], networks[i-1])
+			if err := dht.Bootstrap(ctx); err != nil {
+				t.Fatalf("Expected Bootstrap to succeed, got %v", err)
+			}
+		}
+	}
+	return networks, dhts
+}
+
+func TestNewDHT(t *testing.T) {
+	if _, err := NewDHT(NewNetwork(Config{}), DHTConfig{}); err == nil {
+		t.Errorf("Expected NewDHT to fail before the network is started")
+	}
+}
+
+func TestDHT_FindPeer(t *testing.T) {
+	networks, dhts := startDHTNetworks(t, 10, DHTConfig{BucketSize: 4})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	last := networks[len(networks)-1]
+	contact, err := dhts[0].FindPeer(ctx, last.Config.ID)
+	if err != nil {
+		t.Fatalf("Expected FindPeer to succeed, got %v", err)
+	}
+	if contact.Address != last.Listener.Addr().String() {
+		t.Errorf("Expected the address of the peer, got %s", contact.Address)
+	}
+	if _, err := dhts[0].FindPeer(ctx, ContentKey([]byte("nobody")).String()); err != ErrPeerNotFound {
+		t.Errorf("Expected ErrPeerNotFound, got %v", err)
+	}
+	if dhts[0].Table.Size() < 4 {
+		t.Errorf("Expected lookups to populate the routing table, got %d contacts", dhts[0].Table.Size())
+	}
+
+	// Contacts reached during lookups do not become peers
+	if peers := len(networks[0].PeerStatuses()); peers != 1 {
+		t.Errorf("Expected lookups not to take up peer slots, got %d peers", peers)
+	}
+}
+
+func TestDHT_Providers(t *testing.T) {
+	networks, dhts := startDHTNetworks(t, 8, DHTConfig{BucketSize: 3})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key := ContentKey([]byte("block 42"))
+	if err := dhts[2].Provide(ctx, key); err != nil {
+		t.Fatalf("Expected Provide to succeed, got %v", err)
+	}
+	providers, err := dhts[7].FindProviders(ctx, key)
+	if err != nil {
+		t.Fatalf("Expected FindProviders to succeed, got %v", err)
+	}
+	if len(providers) != 1 || providers[0].ID != networks[2].Config.ID {
+		t.Errorf("Expected node 2 to provide the content, got %+v", providers)
+	}
+
+	providers, err = dhts[7].FindProviders(ctx, ContentKey([]byte("missing")))
+	if err != nil || len(providers) != 0 {
+		t.Errorf("Expected no providers for unknown content, got %+v %v", providers, err)
+	}
+}
+
+func TestDHT_Republish(t *testing.T) {
+	networks, dhts := startDHTNetworks(t, 4, DHTConfig{
+		ProviderTTL:       200 * time.Millisecond,
+		RepublishInterval: 40 * time.Millisecond,
+		RefreshInterval:   time.Hour,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, dht := range dhts {
+		go dht.Run(ctx)
+	}
+
+	key := ContentKey([]byte("snapshot chunk"))
+	if err := dhts[0].Provide(ctx, key); err != nil {
+		t.Fatalf("Expected Provide to succeed, got %v", err)
+	}
+	// Records outlive their TTL while the provider keeps republishing
+	time.Sleep(500 * time.Millisecond)
+	if providers := dhts[3].localProviders(key); len(providers) != 1 || providers[0].ID != networks[0].Config.ID {
+		t.Errorf("Expected the provider record to be republished, got %+v", providers)
+	}
+
+	dhts[0].Mutex.Lock()
+	delete(dhts[0].provided, key)
+	dhts[0].Mutex.Unlock()
+	time.Sleep(500 * time.Millisecond)
+	if providers := dhts[3].localProviders(key); len(providers) != 0 {
+		t.Errorf("Expected the provider record to expire, got %+v", providers)
+	}
+}
+
+func TestDHT_ProviderLimits(t *testing.T) {
+	network := startTestNetwork(t)
+	dht, err := NewDHT(network, DHTConfig{MaxProvidersPerKey: 2, MaxProviderRecords: 3})
+	if err != nil {
+		t.Fatalf("Expected NewDHT to succeed, got %v", err)
+	}
+	first := ContentKey([]byte("first"))
+	second := ContentKey([]byte("second"))
+	for i := 0; i < 4; i++ {
+		dht.addProvider(first, Contact{ID: fmt.Sprintf("first-%d", i)})
+	}
+	if providers := dht.localProviders(first); len(providers) != 2 {
+		t.Errorf("Expected 2 providers for the key, got %d", len(providers))
+	}
+	for i := 0; i < 4; i++ {
+		dht.addProvider(second, Contact{ID: fmt.Sprintf("second-%d", i)})
+	}
+	if dht.records != 3 {
+		t.Errorf("Expected 3 provider records in total, got %d", dht.records)
+	}
+
+	// The local node's records are kept regardless of the limits
+	dht.addProvider(second, dht.self())
+	found := false
+	for _, provider := range dht.localProviders(second) {
+		found = found || provider.ID == network.Config.ID
+	}
+	if !found {
+		t.Errorf("Expected the local node to be recorded as a provider")
+	}
+}
+
+func TestDHT_ClaimedAddress(t *testing.T) {
+	networks, dhts := startDHTNetworks(t, 3, DHTConfig{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Node 1 claims to listen on node 2's address
+	peer, ok := networks[1].Peer(networks[0].Config.ID)
+	if !ok {
+		t.Fatalf("Expected node 1 to be connected to node 0")
+	}
+	genuine := networks[1].Listener.Addr().String()
+	spoofed := networks[2].Listener.Addr().String()
+	payload, _ := json.Marshal(dhtRequest{Type: dhtAddProvider, Target: ContentKey([]byte("block")), Address: spoofed})
+	if _, err := peer.Session.Request(ctx, MsgDHT, payload); err != nil {
+		t.Fatalf("Expected the request to be answered, got %v", err)
+	}
+	if providers := dhts[0].localProviders(ContentKey([]byte("block"))); len(providers) != 0 {
+		t.Errorf("Expected an announcement from an unverified address to be dropped, got %+v", providers)
+	}
+
+	// A lookup from node 1 carries its real address, which is verified
+	if _, err := dhts[1].FindPeer(ctx, networks[0].Config.ID); err != nil {
+		t.Fatalf("Expected FindPeer to succeed, got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		contact, ok := dhts[0].Table.Contact(networks[1].Config.ID)
+		if ok && contact.Address == spoofed {
+			t.Fatalf("Expected the spoofed address not to be recorded")
+		}
+		if ok && contact.Address == genuine {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected node 1 to be recorded at %s, got %+v", genuine, contact)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
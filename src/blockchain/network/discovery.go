@@ -0,0 +1,197 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// DefaultTargetOutbound is the default number of outbound connections
+// maintained when discovery is enabled
+const DefaultTargetOutbound = 8
+
+// DefaultDiscoveryInterval is the default interval between discovery rounds
+const DefaultDiscoveryInterval = 30 * time.Second
+
+// maxExchangeAddresses is the maximum number of addresses in a peer exchange
+const maxExchangeAddresses = 32
+
+// PeerAddress is an address shared in a peer exchange
+type PeerAddress struct {
+	// ID is the ID of the peer
+	ID string `json:"id"`
+
+	// Address is the address of the peer
+	Address string `json:"address"`
+}
+
+// startDiscovery loads the address book and, if discovery is enabled,
+// starts dialing peers to maintain the target number of outbound connections
+func (n *Network) startDiscovery(ctx context.Context) error {
+	book := NewAddressBook(n.Config.AddressBookPath)
+	if err := book.Load(); err != nil {
+		return fmt.Errorf("loading address book: %w", err)
+	}
+	for _, address := range n.Config.Bootstrap {
+		book.Add(AddressEntry{Address: address, Bootstrap: true})
+	}
+	n.AddressBook = book
+	n.HandleStream(MsgPeerExchange, n.handlePeerExchange)
+	n.EnableCapability(CapPeerExchange)
+
+	if n.Config.TargetOutbound <= 0 && len(n.Config.Bootstrap) == 0 {
+		return nil
+	}
+	if n.Config.TargetOutbound <= 0 {
+		n.Config.TargetOutbound = DefaultTargetOutbound
+	}
+	if n.Config.DiscoveryInterval <= 0 {
+		n.Config.DiscoveryInterval = DefaultDiscoveryInterval
+	}
+	n.spawn(func() {
+		n.discoveryLoop(ctx)
+	})
+	return nil
+}
+
+// discoveryLoop runs discovery rounds until ctx is done
+func (n *Network) discoveryLoop(ctx context.Context) {
+	ticker := time.NewTicker(n.Config.DiscoveryInterval)
+	defer ticker.Stop()
+	for {
+		n.discover(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// discover runs a discovery round: it asks a connected peer for addresses
+// and dials the best known addresses until the outbound target is reached
+func (n *Network) discover(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, n.discoveryTimeout())
+	defer cancel()
+
+	connectedIDs := make(map[string]bool)
+	connectedAddresses := make(map[string]bool)
+	outbound := 0
+	n.Mutex.RLock()
+	for _, peer := range n.Peers {
+		connectedIDs[peer.ID] = true
+		connectedAddresses[peer.Address] = true
+		if peer.Outbound {
+			outbound++
+		}
+	}
+	peers := make([]Peer, 0, len(n.Peers))
+	for _, peer := range n.Peers {
+		if peer.Supports(CapPeerExchange) {
+			peers = append(peers, peer)
+		}
+	}
+	n.Mutex.RUnlock()
+
+	if len(peers) > 0 {
+		peer := peers[rand.Intn(len(peers))]
+		if _, err := n.RequestPeers(ctx, peer.ID); err != nil {
+			log.Printf("peer exchange with %s failed: %v", peer.ID, err)
+		}
+	}
+
+	target := n.Config.TargetOutbound
+	if target > n.Config.MaxOutbound {
+		target = n.Config.MaxOutbound
+	}
+	need := target - outbound
+	if need > 0 {
+		candidates := n.AddressBook.Candidates(need, func(entry AddressEntry) bool {
+			return entry.ID == n.Config.ID ||
+				entry.Address == n.Config.Address ||
+				connectedIDs[entry.ID] ||
+				connectedAddresses[entry.Address] ||
+				(entry.ID != "" && n.IsBanned(entry.ID))
+		})
+		for _, entry := range candidates {
+			if ctx.Err() != nil {
+				break
+			}
+			n.AddressBook.MarkAttempt(entry.Address)
+			peer, err := n.Dial(Peer{ID: entry.ID, Address: entry.Address})
+			if err != nil {
+				n.AddressBook.MarkFailure(entry.Address)
+				continue
+			}
+			connectedIDs[peer.ID] = true
+		}
+	}
+
+	if err := n.AddressBook.Save(); err != nil {
+		log.Printf("saving address book failed: %v", err)
+	}
+}
+
+// discoveryTimeout returns the timeout for a discovery round
+func (n *Network) discoveryTimeout() time.Duration {
+	if n.Config.Timeout > 0 {
+		return n.Config.Timeout
+	}
+	return n.Config.DiscoveryInterval
+}
+
+// handlePeerExchange answers a peer exchange request with known addresses
+func (n *Network) handlePeerExchange(peer Peer, stream *Stream, payload []byte) {
+	// Addresses advertised by connected peers are shared as well, since the
+	// connection shows the peer is live
+	live := func(entry AddressEntry) bool {
+		_, ok := n.Peer(entry.ID)
+		return ok
+	}
+	addresses := make([]PeerAddress, 0, maxExchangeAddresses)
+	for _, entry := range n.AddressBook.Sample(maxExchangeAddresses+1, live) {
+		if entry.ID == peer.ID || len(addresses) == maxExchangeAddresses {
+			continue
+		}
+		addresses = append(addresses, PeerAddress{ID: entry.ID, Address: entry.Address})
+	}
+	data, err := json.Marshal(addresses)
+	if err != nil {
+		stream.Close()
+		return
+	}
+	stream.SendEnd(MsgPeerExchange, data)
+}
+
+// RequestPeers asks a connected peer for the addresses it knows and adds
+// them to the address book
+func (n *Network) RequestPeers(ctx context.Context, id string) ([]PeerAddress, error) {
+	payload, err := n.Request(ctx, id, MsgPeerExchange, nil)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []PeerAddress
+	if err := json.Unmarshal(payload, &addresses); err != nil {
+		return nil, err
+	}
+	if len(addresses) > maxExchangeAddresses {
+		addresses = addresses[:maxExchangeAddresses]
+	}
+
+	accepted := make([]PeerAddress, 0, len(addresses))
+	for _, address := range addresses {
+		if address.ID == n.Config.ID || address.Address == n.Config.Address {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(address.Address); err != nil {
+			continue
+		}
+		n.AddressBook.Add(AddressEntry{ID: address.ID, Address: address.Address})
+		accepted = append(accepted, address)
+	}
+	return accepted, nil
+}
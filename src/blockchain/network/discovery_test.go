@@ -0,0 +1,45 @@
+package network
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withDiscovery configures a network to advertise a free loopback address
+// and discover peers from the bootstrap list
+func withDiscovery(t *testing.T, bootstrap ...string) func(config *Config) {
+	return func(config *Config) {
+		config.Address, config.Port = freeAddress(t)
+		config.Bootstrap = bootstrap
+		config.AddressBookPath = filepath.Join(t.TempDir(), "addrbook.json")
+		config.TargetOutbound = 2
+		config.DiscoveryInterval = 50 * time.Millisecond
+	}
+}
+
+func TestNetwork_Discovery(t *testing.T) {
+	seed := startTestNetwork(t, withDiscovery(t))
+	first := startTestNetwork(t, withDiscovery(t, seed.Config.Address))
+	waitForPeer(t, seed, first.Config.ID)
+
+	// The second node only knows the seed, and learns about the first node
+	// through peer exchange
+	second := startTestNetwork(t, withDiscovery(t, seed.Config.Address))
+	waitForPeer(t, second, seed.Config.ID)
+	waitForPeer(t, second, first.Config.ID)
+
+	entry, ok := second.AddressBook.Get(first.Config.Address)
+	if !ok || entry.ID != first.Config.ID {
+		t.Errorf("Expected the discovered peer to be recorded in the address book, got %+v", entry)
+	}
+
+	err := second.AddressBook.Save()
+	if err != nil {
+		t.Fatalf("Expected Save to succeed, got %v", err)
+	}
+	book := NewAddressBook(second.Config.AddressBookPath)
+	if err := book.Load(); err != nil || book.Size() < 2 {
+		t.Errorf("Expected the address book to be persisted, got %d entries (%v)", book.Size(), err)
+	}
+}
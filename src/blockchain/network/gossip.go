@@ -0,0 +1,343 @@
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Topic is the topic of a gossiped message
+type Topic string
+
+const (
+	// TopicTxs carries transactions
+	TopicTxs Topic = "txs"
+
+	// TopicBlocks carries blocks
+	TopicBlocks Topic = "blocks"
+
+	// TopicVotes carries consensus votes
+	TopicVotes Topic = "votes"
+
+	// TopicEvidence carries evidence of misbehaviour
+	TopicEvidence Topic = "evidence"
+)
+
+// topicPriority returns the send priority of a topic's messages, so votes
+// and evidence are not held up behind bulk traffic
+func topicPriority(topic Topic) Priority {
+	switch topic {
+	case TopicVotes, TopicEvidence:
+		return PriorityHigh
+	default:
+		return PriorityNormal
+	}
+}
+
+// DefaultFanout is the default number of peers a message is forwarded to
+const DefaultFanout = 6
+
+// DefaultSeenTTL is the default time a message ID is remembered
+const DefaultSeenTTL = 2 * time.Minute
+
+// DefaultMaxHops is the default number of times a message is forwarded
+const DefaultMaxHops = 16
+
+// DefaultMaxSeen is the default number of message IDs remembered
+const DefaultMaxSeen = 65536
+
+// ValidationResult is the outcome of validating a gossiped message
+type ValidationResult int
+
+const (
+	// ValidationAccept delivers the message and propagates it
+	ValidationAccept ValidationResult = iota
+
+	// ValidationIgnore drops the message without penalising the sender
+	ValidationIgnore
+
+	// ValidationReject drops the message as invalid and penalises the sender
+	ValidationReject
+)
+
+// Validator validates a gossiped message before it is delivered and
+// propagated
+type Validator func(message GossipMessage) ValidationResult
+
+// Subscriber receives gossiped messages on a topic. Subscribers are called
+// from the peer's read loop and must not block.
+type Subscriber func(message GossipMessage)
+
+// GossipMessage is a message spread through the network by gossip
+type GossipMessage struct {
+	// ID is the hex encoded SHA-256 of the topic and data
+	ID string `json:"id"`
+
+	// Topic is the topic of the message
+	Topic Topic `json:"topic"`
+
+	// Origin is the ID of the node that claims to have published the
+	// message. It is not covered by the ID, so any relay can change it, and
+	// it is only informational.
+	Origin string `json:"origin"`
+
+	// Hops is the number of times the message has been forwarded. Relays can
+	// change it too, so it is only a hint that limits how far this node
+	// forwards the message.
+	Hops int `json:"hops"`
+
+	// Data is the message payload
+	Data []byte `json:"data"`
+
+	// From is the ID of the peer the message was received from
+	From string `json:"-"`
+}
+
+// MessageID returns the ID of a message with the given topic and data
+func MessageID(topic Topic, data []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(topic))
+	hash.Write([]byte{0})
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// GossipConfig represents the configuration for gossip
+type GossipConfig struct {
+	// Fanout is the number of peers a message is forwarded to
+	Fanout int
+
+	// SeenTTL is the time a message ID is remembered for deduplication
+	SeenTTL time.Duration
+
+	// MaxHops is the number of times a message is forwarded
+	MaxHops int
+
+	// MaxSeen is the number of message IDs remembered. Once it is reached
+	// the oldest IDs are forgotten before their TTL.
+	MaxSeen int
+}
+
+// Gossip spreads messages to all peers without the publisher knowing the
+// peer topology
+type Gossip struct {
+	// Config is the configuration for gossip
+	Config GossipConfig
+
+	// Mutex is a mutex to protect access to the subscriptions
+	Mutex sync.RWMutex
+
+	network     *Network
+	subscribers map[Topic][]Subscriber
+	validators  map[Topic]Validator
+	seen        *seenCache
+}
+
+// NewGossip returns a new gossip layer on top of a network
+func NewGossip(network *Network, config GossipConfig) *Gossip {
+	if config.Fanout <= 0 {
+		config.Fanout = DefaultFanout
+	}
+	if config.SeenTTL <= 0 {
+		config.SeenTTL = DefaultSeenTTL
+	}
+	if config.MaxHops <= 0 {
+		config.MaxHops = DefaultMaxHops
+	}
+	if config.MaxSeen <= 0 {
+		config.MaxSeen = DefaultMaxSeen
+	}
+	g := &Gossip{
+		Config:      config,
+		network:     network,
+		subscribers: make(map[Topic][]Subscriber),
+		validators:  make(map[Topic]Validator),
+		seen:        newSeenCache(config.SeenTTL, config.MaxSeen),
+	}
+	network.Handle(MsgGossip, g.handle)
+	network.EnableCapability(CapGossip)
+	return g
+}
+
+// Subscribe registers a subscriber for a topic
+func (g *Gossip) Subscribe(topic Topic, subscriber Subscriber) {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+	g.subscribers[topic] = append(g.subscribers[topic], subscriber)
+}
+
+// SetValidator sets the validator for a topic
+func (g *Gossip) SetValidator(topic Topic, validator Validator) {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+	g.validators[topic] = validator
+}
+
+// Publish gossips data on a topic and returns the message ID
+func (g *Gossip) Publish(topic Topic, data []byte) (string, error) {
+	message := GossipMessage{
+		ID:     MessageID(topic, data),
+		Topic:  topic,
+		Origin: g.network.Config.ID,
+		Data:   data,
+	}
+	if !g.seen.add(message.ID) {
+		return message.ID, errors.New("message was already gossiped")
+	}
+	return message.ID, g.forward(message)
+}
+
+// handle handles a gossiped message received from a peer
+func (g *Gossip) handle(peer Peer, payload []byte) {
+	var message GossipMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		log.Printf("invalid gossip message from %s: %v", peer.ID, err)
+		g.network.ReportPeer(peer.ID, EventInvalidMessage)
+		return
+	}
+	if message.ID != MessageID(message.Topic, message.Data) || message.Hops < 0 {
+		log.Printf("gossip message from %s has a mismatched ID or hop count", peer.ID)
+		g.network.ReportPeer(peer.ID, EventInvalidMessage)
+		return
+	}
+	if g.seen.has(message.ID) {
+		return
+	}
+	message.From = peer.ID
+
+	g.Mutex.RLock()
+	validator := g.validators[message.Topic]
+	subscribers := g.subscribers[message.Topic]
+	g.Mutex.RUnlock()
+
+	if validator != nil {
+		switch validator(message) {
+		case ValidationIgnore:
+			return
+		case ValidationReject:
+			g.network.ReportPeer(peer.ID, EventInvalidMessage)
+			return
+		}
+	}
+
+	// Only an accepted message is remembered, so one that was ignored can
+	// be accepted when it arrives again. Of copies validated at the same
+	// time from different peers, only the first is delivered.
+	if !g.seen.add(message.ID) {
+		return
+	}
+	g.network.ReportPeer(peer.ID, EventUsefulMessage)
+	for _, subscriber := range subscribers {
+		subscriber(message)
+	}
+
+	// Forwarding is tracked so Stop waits for it, and is dropped once the
+	// network is stopping
+	if message.Hops+1 < g.Config.MaxHops {
+		message.Hops++
+		g.network.spawn(func() {
+			g.forward(message)
+		})
+	}
+}
+
+// forward sends a message to a random subset of peers, excluding the peer
+// it came from. The claimed origin is not excluded, since a relay could name
+// any peer to keep the message from it.
+func (g *Gossip) forward(message GossipMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	g.network.Mutex.RLock()
+	peers := make([]Peer, 0, len(g.network.Peers))
+	for _, peer := range g.network.Peers {
+		if peer.Session != nil && peer.Supports(CapGossip) && peer.ID != message.From {
+			peers = append(peers, peer)
+		}
+	}
+	g.network.Mutex.RUnlock()
+
+	rand.Shuffle(len(peers), func(i, j int) {
+		peers[i], peers[j] = peers[j], peers[i]
+	})
+	if len(peers) > g.Config.Fanout {
+		peers = peers[:g.Config.Fanout]
+	}
+	priority := topicPriority(message.Topic)
+	for _, peer := range peers {
+		if err := peer.Session.SendPriority(MsgGossip, priority, data); err != nil {
+			log.Printf("gossip to %s failed: %v", peer.ID, err)
+		}
+	}
+	return nil
+}
+
+// seenCache remembers up to max message IDs for a limited time
+type seenCache struct {
+	ttl     time.Duration
+	max     int
+	mutex   sync.Mutex
+	entries map[string]time.Time
+	order   []seenEntry
+}
+
+// seenEntry is an entry in the seen cache's expiry queue
+type seenEntry struct {
+	id      string
+	expires time.Time
+}
+
+// newSeenCache returns a new seen cache
+func newSeenCache(ttl time.Duration, max int) *seenCache {
+	return &seenCache{
+		ttl:     ttl,
+		max:     max,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// add records an ID and reports whether it was new
+func (c *seenCache) add(id string) bool {
+	now := time.Now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Entries are added in expiry order, so expired entries are at the front
+	for len(c.order) > 0 && now.After(c.order[0].expires) {
+		delete(c.entries, c.order[0].id)
+		c.order = c.order[1:]
+	}
+	if _, ok := c.entries[id]; ok {
+		return false
+	}
+	for len(c.order) > 0 && len(c.entries) >= c.max {
+		delete(c.entries, c.order[0].id)
+		c.order = c.order[1:]
+	}
+	expires := now.Add(c.ttl)
+	c.entries[id] = expires
+	c.order = append(c.order, seenEntry{id: id, expires: expires})
+	return true
+}
+
+// has reports whether an ID is remembered
+func (c *seenCache) has(id string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	expires, ok := c.entries[id]
+	return ok && !time.Now().After(expires)
+}
+
+// size returns the number of remembered IDs
+func (c *seenCache) size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.entries)
+}
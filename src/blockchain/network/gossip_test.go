@@ -0,0 +1,223 @@
+package network
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// connectTestNetworks dials b from a and waits until both sides are connected
+func connectTestNetworks(t *testing.T, a, b *Network) {
+	_, err := a.Dial(Peer{Address: b.Listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("Expected to be able to dial the peer, got %v", err)
+	}
+	waitForPeer(t, b, a.Config.ID)
+}
+
+func TestGossip_Publish(t *testing.T) {
+	// Build a ring so every node can receive a message on two paths
+	networks := make([]*Network, 4)
+	gossips := make([]*Gossip, 4)
+	received := make([][]GossipMessage, 4)
+	var mutex sync.Mutex
+	for i := range networks {
+		networks[i] = startTestNetwork(t)
+		gossips[i] = NewGossip(networks[i], GossipConfig{})
+		i := i
+		gossips[i].Subscribe(TopicTxs, func(message GossipMessage) {
+			mutex.Lock()
+			received[i] = append(received[i], message)
+			mutex.Unlock()
+		})
+	}
+	for i := range networks {
+		connectTestNetworks(t, networks[i], networks[(i+1)%len(networks)])
+	}
+
+	id, err := gossips[0].Publish(TopicTxs, []byte("tx"))
+	if err != nil {
+		t.Fatalf("Expected Publish to succeed, got %v", err)
+	}
+	if id != MessageID(TopicTxs, []byte("tx")) {
+		t.Errorf("Expected Publish to return the message ID")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mutex.Lock()
+		done := len(received[1]) > 0 && len(received[2]) > 0 && len(received[3]) > 0
+		mutex.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(received[0]) != 0 {
+		t.Errorf("Expected the publisher not to receive its own message")
+	}
+	for i := 1; i < len(networks); i++ {
+		if len(received[i]) != 1 {
+			t.Errorf("Expected node %d to receive the message once, got %d", i, len(received[i]))
+			continue
+		}
+		if string(received[i][0].Data) != "tx" || received[i][0].Origin != networks[0].Config.ID {
+			t.Errorf("Expected node %d to receive the published message, got %+v", i, received[i][0])
+		}
+	}
+}
+
+func TestGossip_Validator(t *testing.T) {
+	// a - b - c in a line; b rejects everything, so c never hears about it
+	a := startTestNetwork(t)
+	b := startTestNetwork(t)
+	c := startTestNetwork(t)
+	gossipA := NewGossip(a, GossipConfig{})
+	gossipB := NewGossip(b, GossipConfig{})
+	gossipC := NewGossip(c, GossipConfig{})
+	connectTestNetworks(t, a, b)
+	connectTestNetworks(t, b, c)
+
+	validated := make(chan struct{}, 1)
+	gossipB.SetValidator(TopicBlocks, func(message GossipMessage) ValidationResult {
+		validated <- struct{}{}
+		return ValidationReject
+	})
+	delivered := make(chan struct{}, 2)
+	gossipB.Subscribe(TopicBlocks, func(message GossipMessage) {
+		delivered <- struct{}{}
+	})
+	gossipC.Subscribe(TopicBlocks, func(message GossipMessage) {
+		delivered <- struct{}{}
+	})
+
+	_, err := gossipA.Publish(TopicBlocks, []byte("bad block"))
+	if err != nil {
+		t.Fatalf("Expected Publish to succeed, got %v", err)
+	}
+	select {
+	case <-validated:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the validator to be called")
+	}
+	select {
+	case <-delivered:
+		t.Errorf("Expected a rejected message not to be delivered or propagated")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if score := b.Score(a.Config.ID); score >= 0 {
+		t.Errorf("Expected the sender of a rejected message to be penalised, got %f", score)
+	}
+}
+
+func TestGossip_Relay(t *testing.T) {
+	// a - b - c in a line; a relays a message to b claiming c published it
+	a := startTestNetwork(t)
+	b := startTestNetwork(t)
+	c := startTestNetwork(t)
+	NewGossip(a, GossipConfig{})
+	gossipB := NewGossip(b, GossipConfig{})
+	gossipC := NewGossip(c, GossipConfig{})
+	connectTestNetworks(t, a, b)
+	connectTestNetworks(t, b, c)
+
+	// b ignores the message the first time it is validated
+	validated := make(chan ValidationResult, 3)
+	var calls int32
+	gossipB.SetValidator(TopicTxs, func(message GossipMessage) ValidationResult {
+		result := ValidationAccept
+		if atomic.AddInt32(&calls, 1) == 1 {
+			result = ValidationIgnore
+		}
+		validated <- result
+		return result
+	})
+	delivered := make(chan GossipMessage, 2)
+	gossipC.Subscribe(TopicTxs, func(message GossipMessage) {
+		delivered <- message
+	})
+
+	peer, ok := a.Peer(b.Config.ID)
+	if !ok {
+		t.Fatalf("Expected a to be connected to b")
+	}
+	send := func(message GossipMessage) {
+		payload, _ := json.Marshal(message)
+		if err := peer.Session.Send(MsgGossip, payload); err != nil {
+			t.Fatalf("Expected Send to succeed, got %v", err)
+		}
+	}
+	message := GossipMessage{
+		ID:     MessageID(TopicTxs, []byte("tx")),
+		Topic:  TopicTxs,
+		Origin: c.Config.ID,
+		Data:   []byte("tx"),
+	}
+	for _, expected := range []ValidationResult{ValidationIgnore, ValidationAccept} {
+		send(message)
+		select {
+		case result := <-validated:
+			if result != expected {
+				t.Fatalf("Expected validation result %d, got %d", expected, result)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the message to be validated again after it was ignored")
+		}
+	}
+
+	// The claimed origin does not keep the message from c
+	select {
+	case received := <-delivered:
+		if string(received.Data) != "tx" {
+			t.Errorf("Expected c to receive the message, got %+v", received)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the message to be forwarded to its claimed origin")
+	}
+
+	// An accepted message is not validated again, and a negative hop count
+	// is invalid
+	send(message)
+	message.Hops = -1
+	send(message)
+	waitForScore(t, b, a.Config.ID, 0)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected the message to be validated twice, got %d", n)
+	}
+}
+
+func TestSeenCache(t *testing.T) {
+	cache := newSeenCache(50*time.Millisecond, DefaultMaxSeen)
+	if !cache.add("a") {
+		t.Errorf("Expected the first add to be new")
+	}
+	if cache.add("a") {
+		t.Errorf("Expected a repeated add to be a duplicate")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !cache.add("b") || cache.size() != 1 {
+		t.Errorf("Expected expired IDs to be pruned")
+	}
+	if !cache.add("a") {
+		t.Errorf("Expected an expired ID to be new again")
+	}
+}
+
+func TestSeenCache_Max(t *testing.T) {
+	cache := newSeenCache(time.Hour, 2)
+	for _, id := range []string{"a", "b", "c"} {
+		cache.add(id)
+	}
+	if cache.size() != 2 {
+		t.Errorf("Expected the cache to hold 2 IDs, got %d", cache.size())
+	}
+	if !cache.add("a") {
+		t.Errorf("Expected the oldest ID to be forgotten")
+	}
+}
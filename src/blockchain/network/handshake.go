@@ -0,0 +1,145 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ProtocolVersion is the version of the wire protocol spoken by this node
+const ProtocolVersion = 1
+
+// MinProtocolVersion is the oldest protocol version this node can talk to
+const MinProtocolVersion = 1
+
+// Capabilities advertised in the handshake. A feature is only used with a
+// peer when both sides advertise it.
+const (
+	// CapGossip is the gossip protocol
+	CapGossip = "gossip"
+
+	// CapPeerExchange is the peer exchange protocol
+	CapPeerExchange = "pex"
+
+	// CapDHT is the DHT protocol
+	CapDHT = "dht"
+)
+
+// negotiated is the outcome of a handshake
+type negotiated struct {
+	version      uint32
+	capabilities []string
+	compression  string
+}
+
+// negotiate checks that a remote handshake is compatible with the local one
+// and returns the settings both sides agree on
+func negotiate(local Handshake, remote Handshake, initiator bool) (negotiated, *DisconnectError) {
+	if remote.Version < MinProtocolVersion || remote.MinVersion > local.Version {
+		return negotiated{}, &DisconnectError{
+			Reason: ReasonIncompatibleVersion,
+			Message: fmt.Sprintf("protocol version %d is not supported, need %d to %d",
+				remote.Version, MinProtocolVersion, local.Version),
+		}
+	}
+	if remote.ChainID != local.ChainID {
+		return negotiated{}, &DisconnectError{
+			Reason:  ReasonWrongChain,
+			Message: fmt.Sprintf("chain ID %q does not match %q", remote.ChainID, local.ChainID),
+		}
+	}
+	if remote.GenesisHash != local.GenesisHash {
+		return negotiated{}, &DisconnectError{
+			Reason:  ReasonWrongChain,
+			Message: fmt.Sprintf("genesis hash %s does not match %s", remote.GenesisHash, local.GenesisHash),
+		}
+	}
+
+	version := local.Version
+	if remote.Version < version {
+		version = remote.Version
+	}
+	compression := chooseCompression(remote.Compression, local.Compression)
+	if initiator {
+		compression = chooseCompression(local.Compression, remote.Compression)
+	}
+	return negotiated{
+		version:      version,
+		capabilities: intersect(local.Capabilities, remote.Capabilities),
+		compression:  compression,
+	}, nil
+}
+
+// intersect returns the sorted values present in both lists
+func intersect(a []string, b []string) []string {
+	present := make(map[string]bool, len(b))
+	for _, value := range b {
+		present[value] = true
+	}
+	var both []string
+	for _, value := range a {
+		if present[value] {
+			both = append(both, value)
+			present[value] = false
+		}
+	}
+	sort.Strings(both)
+	return both
+}
+
+// chooseCompression returns the first algorithm in the initiator's order of
+// preference that the acceptor also supports, so both sides agree
+func chooseCompression(initiator []string, acceptor []string) string {
+	supported := make(map[string]bool, len(acceptor))
+	for _, name := range acceptor {
+		supported[name] = true
+	}
+	for _, name := range initiator {
+		if supported[name] && codecs[name] != nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// EnableCapability advertises a capability to peers that connect afterwards
+func (n *Network) EnableCapability(capability string) {
+	n.handlerMutex.Lock()
+	defer n.handlerMutex.Unlock()
+	for _, existing := range n.Config.Capabilities {
+		if existing == capability {
+			return
+		}
+	}
+	n.Config.Capabilities = append(n.Config.Capabilities, capability)
+}
+
+// localHandshake returns the handshake this node sends
+func (n *Network) localHandshake() Handshake {
+	n.handlerMutex.RLock()
+	capabilities := append([]string(nil), n.Config.Capabilities...)
+	n.handlerMutex.RUnlock()
+	compression := n.Config.Compression
+	if compression == nil {
+		compression = DefaultCompression
+	}
+	return Handshake{
+		ID:           n.Config.ID,
+		Address:      n.Config.Address,
+		Version:      ProtocolVersion,
+		MinVersion:   MinProtocolVersion,
+		ChainID:      n.Config.ChainID,
+		GenesisHash:  n.Config.GenesisHash,
+		Capabilities: capabilities,
+		Compression:  compression,
+	}
+}
+
+// Supports reports whether a capability was negotiated with the peer
+func (p Peer) Supports(capability string) bool {
+	for _, c := range p.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
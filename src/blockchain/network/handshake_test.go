@@ -0,0 +1,126 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	local := Handshake{
+		Version:      ProtocolVersion,
+		MinVersion:   MinProtocolVersion,
+		ChainID:      "skybridge-1",
+		GenesisHash:  "abc",
+		Capabilities: []string{CapGossip, CapDHT},
+		Compression:  []string{"gzip", "deflate"},
+	}
+	remote := local
+	remote.Capabilities = []string{CapDHT, CapPeerExchange}
+	remote.Compression = []string{"deflate", "gzip"}
+
+	agreed, rejected := negotiate(local, remote, true)
+	if rejected != nil {
+		t.Fatalf("Expected compatible handshakes to negotiate, got %v", rejected)
+	}
+	if agreed.version != ProtocolVersion {
+		t.Errorf("Expected version %d, got %d", ProtocolVersion, agreed.version)
+	}
+	if len(agreed.capabilities) != 1 || agreed.capabilities[0] != CapDHT {
+		t.Errorf("Expected only the shared capability, got %v", agreed.capabilities)
+	}
+	if agreed.compression != "gzip" {
+		t.Errorf("Expected the initiator's preference, got %s", agreed.compression)
+	}
+	if other, _ := negotiate(remote, local, false); other.compression != agreed.compression {
+		t.Errorf("Expected both sides to agree on compression, got %s and %s", agreed.compression, other.compression)
+	}
+
+	remote.Compression = nil
+	if agreed, _ := negotiate(local, remote, true); agreed.compression != "" {
+		t.Errorf("Expected no compression without a shared algorithm, got %s", agreed.compression)
+	}
+
+	cases := []struct {
+		name   string
+		change func(h *Handshake)
+		reason DisconnectReason
+	}{
+		{"missing version", func(h *Handshake) { h.Version = 0 }, ReasonIncompatibleVersion},
+		{"too new", func(h *Handshake) { h.Version, h.MinVersion = ProtocolVersion+2, ProtocolVersion+1 }, ReasonIncompatibleVersion},
+		{"chain ID", func(h *Handshake) { h.ChainID = "other" }, ReasonWrongChain},
+		{"genesis", func(h *Handshake) { h.GenesisHash = "def" }, ReasonWrongChain},
+	}
+	for _, c := range cases {
+		bad := local
+		c.change(&bad)
+		if _, rejected := negotiate(local, bad, false); rejected == nil || rejected.Reason != c.reason {
+			t.Errorf("Expected %s to be rejected with %s, got %v", c.name, c.reason, rejected)
+		}
+	}
+
+	newer := local
+	newer.Version = ProtocolVersion + 1
+	if agreed, rejected := negotiate(local, newer, false); rejected != nil || agreed.version != ProtocolVersion {
+		t.Errorf("Expected a newer peer to fall back to version %d, got %d %v", ProtocolVersion, agreed.version, rejected)
+	}
+}
+
+func TestNetwork_HandshakeWrongChain(t *testing.T) {
+	server := startTestNetwork(t, func(config *Config) {
+		config.ChainID = "skybridge-main"
+	})
+	client := startTestNetwork(t, func(config *Config) {
+		config.ChainID = "skybridge-test"
+	})
+	_, err := client.Dial(Peer{Address: server.Listener.Addr().String()})
+	disconnect, ok := err.(*DisconnectError)
+	if !ok || disconnect.Reason != ReasonWrongChain {
+		t.Fatalf("Expected the peer to be rejected for being on the wrong chain, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := server.Peer(client.Config.ID); ok {
+		t.Errorf("Expected a peer on another chain not to be added")
+	}
+}
+
+func TestNetwork_Capabilities(t *testing.T) {
+	server := startTestNetwork(t)
+	client := startTestNetwork(t)
+	server.EnableCapability(CapDHT)
+	server.EnableCapability(CapDHT)
+	server.EnableCapability(CapGossip)
+	client.EnableCapability(CapGossip)
+	connectTestNetworks(t, client, server)
+
+	peer, _ := client.Peer(server.Config.ID)
+	if !peer.Supports(CapGossip) || peer.Supports(CapDHT) {
+		t.Errorf("Expected only the shared capabilities to be negotiated, got %v", peer.Capabilities)
+	}
+	if peer.Version != ProtocolVersion || peer.Compression != DefaultCompression[0] {
+		t.Errorf("Expected the version and compression to be negotiated, got %d %s", peer.Version, peer.Compression)
+	}
+	if len(server.Config.Capabilities) != 3 {
+		t.Errorf("Expected capabilities not to be duplicated, got %v", server.Config.Capabilities)
+	}
+}
+
+func TestGossip_RequiresCapability(t *testing.T) {
+	a := startTestNetwork(t)
+	b := startTestNetwork(t)
+	gossipA := NewGossip(a, GossipConfig{})
+	connectTestNetworks(t, a, b)
+
+	// b has no gossip layer, so a must not send it gossip
+	received := make(chan struct{}, 1)
+	b.Handle(MsgGossip, func(peer Peer, payload []byte) {
+		received <- struct{}{}
+	})
+	if _, err := gossipA.Publish(TopicTxs, []byte("tx")); err != nil {
+		t.Fatalf("Expected Publish to succeed, got %v", err)
+	}
+	select {
+	case <-received:
+		t.Errorf("Expected gossip not to be sent to a peer without the capability")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
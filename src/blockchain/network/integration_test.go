@@ -0,0 +1,168 @@
+package network_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skybridge/blockchain/network"
+)
+
+// startMemoryNode starts a network node on an in-memory network
+func startMemoryNode(t *testing.T, memory *network.MemoryNetwork) *network.Network {
+	cert, key, err := network.GenerateTLS()
+	if err != nil {
+		t.Fatalf("Expected GenerateTLS to succeed, got %v", err)
+	}
+	node := network.NewNetwork(network.Config{
+		Timeout:   5 * time.Second,
+		Transport: memory.Transport(),
+		TLS: network.TLSConfig{
+			Cert: cert,
+			Key:  key,
+		},
+	})
+	if err := node.Start(); err != nil {
+		t.Fatalf("Expected Start to succeed, got %v", err)
+	}
+	t.Cleanup(func() {
+		node.Stop(context.Background())
+	})
+	return node
+}
+
+// connectNodes dials b from a and waits for b to see the connection
+func connectNodes(t *testing.T, a, b *network.Network) {
+	if _, err := a.Dial(network.Peer{Address: b.Listener.Addr().String()}); err != nil {
+		t.Fatalf("Expected to be able to dial the peer, got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := b.Peer(a.Config.ID); ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to be connected", a.Config.ID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// collector records the gossiped messages received by each node
+type collector struct {
+	mutex    sync.Mutex
+	received map[int][]string
+}
+
+// subscribe records the messages a node receives on a topic
+func (c *collector) subscribe(i int, gossip *network.Gossip, topic network.Topic) {
+	gossip.Subscribe(topic, func(message network.GossipMessage) {
+		c.mutex.Lock()
+		c.received[i] = append(c.received[i], string(message.Data))
+		c.mutex.Unlock()
+	})
+}
+
+// count returns the number of messages a node received
+func (c *collector) count(i int) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.received[i])
+}
+
+// waitFor waits until condition holds
+func waitFor(t *testing.T, timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+func TestNetworkGossipInMemory(t *testing.T) {
+	memory := network.NewMemoryNetwork(42)
+	memory.SetLatency(5 * time.Millisecond)
+	memory.SetLoss(0.2)
+	memory.RetransmitTimeout = 10 * time.Millisecond
+
+	// a - b - c - d - e in a line
+	nodes := make([]*network.Network, 5)
+	gossips := make([]*network.Gossip, 5)
+	received := &collector{received: make(map[int][]string)}
+	for i := range nodes {
+		nodes[i] = startMemoryNode(t, memory)
+		gossips[i] = network.NewGossip(nodes[i], network.GossipConfig{})
+		received.subscribe(i, gossips[i], network.TopicTxs)
+	}
+	for i := 1; i < len(nodes); i++ {
+		connectNodes(t, nodes[i-1], nodes[i])
+	}
+
+	if _, err := gossips[0].Publish(network.TopicTxs, []byte("tx")); err != nil {
+		t.Fatalf("Expected Publish to succeed, got %v", err)
+	}
+	for i := 1; i < len(nodes); i++ {
+		i := i
+		if !waitFor(t, 5*time.Second, func() bool { return received.count(i) == 1 }) {
+			t.Errorf("Expected node %d to receive the message once, got %d", i, received.count(i))
+		}
+	}
+}
+
+func TestNetworkPartitionInMemory(t *testing.T) {
+	memory := network.NewMemoryNetwork(7)
+	memory.SetLatency(time.Millisecond)
+
+	nodes := make([]*network.Network, 4)
+	gossips := make([]*network.Gossip, 4)
+	received := &collector{received: make(map[int][]string)}
+	for i := range nodes {
+		nodes[i] = startMemoryNode(t, memory)
+		gossips[i] = network.NewGossip(nodes[i], network.GossipConfig{})
+		received.subscribe(i, gossips[i], network.TopicBlocks)
+	}
+	for i := 1; i < len(nodes); i++ {
+		connectNodes(t, nodes[i-1], nodes[i])
+	}
+
+	address := func(i int) string {
+		return nodes[i].Listener.Addr().String()
+	}
+	memory.Partition([]string{address(0), address(1)}, []string{address(2), address(3)})
+
+	if _, err := nodes[0].Dial(network.Peer{Address: address(3)}); err == nil {
+		t.Errorf("Expected dialing across the partition to fail")
+	}
+	if _, err := gossips[0].Publish(network.TopicBlocks, []byte("block")); err != nil {
+		t.Fatalf("Expected Publish to succeed, got %v", err)
+	}
+	if !waitFor(t, 5*time.Second, func() bool { return received.count(1) == 1 }) {
+		t.Fatalf("Expected the node on the same side of the partition to receive the block")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if received.count(2) != 0 || received.count(3) != 0 {
+		t.Fatalf("Expected the partition to hold the block back")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	peer, ok := nodes[1].Peer(nodes[2].Config.ID)
+	if !ok {
+		t.Fatalf("Expected the connection across the partition to stay open")
+	}
+	if _, err := peer.Session.Ping(ctx); err == nil {
+		t.Errorf("Expected a ping across the partition to time out")
+	}
+
+	memory.Heal()
+	for _, i := range []int{2, 3} {
+		i := i
+		if !waitFor(t, 5*time.Second, func() bool { return received.count(i) == 1 }) {
+			t.Errorf("Expected node %d to receive the block once the partition healed", i)
+		}
+	}
+}
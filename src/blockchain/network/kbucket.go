@@ -0,0 +1,281 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeySize is the size of a DHT key in bytes
+const KeySize = 32
+
+// DefaultBucketSize is the default number of contacts per k-bucket
+const DefaultBucketSize = 20
+
+// Key is a key in the DHT keyspace. Peer IDs and content hashes share the
+// keyspace so peers store the providers of the content closest to them.
+type Key [KeySize]byte
+
+// KeyFromID returns the key of a peer ID
+func KeyFromID(id string) (Key, error) {
+	var key Key
+	data, err := hex.DecodeString(id)
+	if err != nil {
+		return key, err
+	}
+	if len(data) != KeySize {
+		return key, errors.New("peer ID is not a SHA-256 fingerprint")
+	}
+	copy(key[:], data)
+	return key, nil
+}
+
+// ContentKey returns the key of a piece of content
+func ContentKey(data []byte) Key {
+	return sha256.Sum256(data)
+}
+
+// String returns the key as hex
+func (k Key) String() string {
+	return hex.EncodeToString(k[:])
+}
+
+// MarshalText encodes the key as hex
+func (k Key) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a hex key
+func (k *Key) UnmarshalText(text []byte) error {
+	key, err := KeyFromID(string(text))
+	if err != nil {
+		return err
+	}
+	*k = key
+	return nil
+}
+
+// Distance returns the XOR distance between two keys
+func (k Key) Distance(other Key) Key {
+	var distance Key
+	for i := range k {
+		distance[i] = k[i] ^ other[i]
+	}
+	return distance
+}
+
+// Less reports whether the key is smaller than another key
+func (k Key) Less(other Key) bool {
+	for i := range k {
+		if k[i] != other[i] {
+			return k[i] < other[i]
+		}
+	}
+	return false
+}
+
+// commonPrefixLen returns the number of leading bits two keys share
+func commonPrefixLen(a Key, b Key) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return KeySize * 8
+}
+
+// Contact is a peer known to the DHT
+type Contact struct {
+	// ID is the ID of the peer
+	ID string `json:"id"`
+
+	// Address is the address of the peer
+	Address string `json:"address"`
+
+	// LastSeen is the last time the peer was heard from
+	LastSeen time.Time `json:"-"`
+
+	key Key
+}
+
+// RoutingTable holds contacts in k-buckets by their distance from the local
+// node. Bucket i holds contacts whose keys share exactly i leading bits with
+// the local key.
+type RoutingTable struct {
+	// Self is the key of the local node
+	Self Key
+
+	// BucketSize is the maximum number of contacts per bucket
+	BucketSize int
+
+	// Mutex is a mutex to protect access to the buckets
+	Mutex sync.RWMutex
+
+	buckets [KeySize*8 + 1][]Contact
+}
+
+// NewRoutingTable returns an empty routing table
+func NewRoutingTable(self Key, bucketSize int) *RoutingTable {
+	if bucketSize <= 0 {
+		bucketSize = DefaultBucketSize
+	}
+	return &RoutingTable{
+		Self:       self,
+		BucketSize: bucketSize,
+	}
+}
+
+// Update records that a contact was seen. A known contact moves to the tail
+// of its bucket. A new contact is added if its bucket has room; otherwise
+// Update returns false with the least recently seen contact of the bucket,
+// which the caller should ping and remove if it is dead.
+func (t *RoutingTable) Update(contact Contact) (bool, Contact) {
+	key, err := KeyFromID(contact.ID)
+	if err != nil || key == t.Self {
+		return false, Contact{}
+	}
+	contact.key = key
+	if contact.LastSeen.IsZero() {
+		contact.LastSeen = time.Now()
+	}
+
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+	index := commonPrefixLen(t.Self, key)
+	bucket := t.buckets[index]
+	for i, existing := range bucket {
+		if existing.ID == contact.ID {
+			if contact.Address == "" {
+				contact.Address = existing.Address
+			}
+			bucket = append(bucket[:i], bucket[i+1:]...)
+			t.buckets[index] = append(bucket, contact)
+			return true, Contact{}
+		}
+	}
+	if len(bucket) < t.BucketSize {
+		t.buckets[index] = append(bucket, contact)
+		return true, Contact{}
+	}
+	return false, bucket[0]
+}
+
+// Remove removes a contact
+func (t *RoutingTable) Remove(id string) {
+	key, err := KeyFromID(id)
+	if err != nil {
+		return
+	}
+	t.Mutex.Lock()
+	defer t.Mutex.Unlock()
+	index := commonPrefixLen(t.Self, key)
+	bucket := t.buckets[index]
+	for i, existing := range bucket {
+		if existing.ID == id {
+			t.buckets[index] = append(bucket[:i], bucket[i+1:]...)
+			return
+		}
+	}
+}
+
+// Contact returns a known contact
+func (t *RoutingTable) Contact(id string) (Contact, bool) {
+	key, err := KeyFromID(id)
+	if err != nil {
+		return Contact{}, false
+	}
+	t.Mutex.RLock()
+	defer t.Mutex.RUnlock()
+	for _, contact := range t.buckets[commonPrefixLen(t.Self, key)] {
+		if contact.ID == id {
+			return contact, true
+		}
+	}
+	return Contact{}, false
+}
+
+// Closest returns up to n contacts closest to a key, closest first
+func (t *RoutingTable) Closest(target Key, n int) []Contact {
+	t.Mutex.RLock()
+	contacts := make([]Contact, 0, n)
+	for _, bucket := range t.buckets {
+		contacts = append(contacts, bucket...)
+	}
+	t.Mutex.RUnlock()
+	sortByDistance(contacts, target)
+	if len(contacts) > n {
+		contacts = contacts[:n]
+	}
+	return contacts
+}
+
+// Size returns the number of contacts
+func (t *RoutingTable) Size() int {
+	t.Mutex.RLock()
+	defer t.Mutex.RUnlock()
+	size := 0
+	for _, bucket := range t.buckets {
+		size += len(bucket)
+	}
+	return size
+}
+
+// StaleBuckets returns the indexes of non-empty buckets with no contact
+// seen since the given time
+func (t *RoutingTable) StaleBuckets(since time.Time) []int {
+	t.Mutex.RLock()
+	defer t.Mutex.RUnlock()
+	var stale []int
+	for i, bucket := range t.buckets {
+		if len(bucket) == 0 {
+			continue
+		}
+		fresh := false
+		for _, contact := range bucket {
+			if contact.LastSeen.After(since) {
+				fresh = true
+				break
+			}
+		}
+		if !fresh {
+			stale = append(stale, i)
+		}
+	}
+	return stale
+}
+
+// randomKey returns a random key that falls into bucket i
+func (t *RoutingTable) randomKey(i int) Key {
+	var key Key
+	rand.Read(key[:])
+	if i >= KeySize*8 {
+		return t.Self
+	}
+	// Share the first i bits with the local key and differ in bit i
+	for bit := 0; bit <= i; bit++ {
+		mask := byte(0x80) >> uint(bit%8)
+		selfBit := t.Self[bit/8] & mask
+		if bit == i {
+			selfBit ^= mask
+		}
+		key[bit/8] = key[bit/8]&^mask | selfBit
+	}
+	return key
+}
+
+// sortByDistance sorts contacts by their distance to a key, closest first
+func sortByDistance(contacts []Contact, target Key) {
+	for i := range contacts {
+		if contacts[i].key == (Key{}) {
+			contacts[i].key, _ = KeyFromID(contacts[i].ID)
+		}
+	}
+	sort.Slice(contacts, func(i, j int) bool {
+		return contacts[i].key.Distance(target).Less(contacts[j].key.Distance(target))
+	})
+}
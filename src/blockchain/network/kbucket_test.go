@@ -0,0 +1,112 @@
+package network
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testKey returns a key whose first byte is b and whose other bytes are 0
+func testKey(b byte) Key {
+	var key Key
+	key[0] = b
+	return key
+}
+
+func TestKeyFromID(t *testing.T) {
+	id := strings.Repeat("ab", KeySize)
+	key, err := KeyFromID(id)
+	if err != nil {
+		t.Fatalf("Expected KeyFromID to succeed, got %v", err)
+	}
+	if key.String() != id {
+		t.Errorf("Expected the key to round trip, got %s", key)
+	}
+	if _, err := KeyFromID("abcd"); err == nil {
+		t.Errorf("Expected a short ID to be rejected")
+	}
+	if _, err := KeyFromID(strings.Repeat("zz", KeySize)); err == nil {
+		t.Errorf("Expected a non-hex ID to be rejected")
+	}
+}
+
+func TestKey_Distance(t *testing.T) {
+	a, b := testKey(0x0f), testKey(0xf0)
+	if a.Distance(b) != testKey(0xff) {
+		t.Errorf("Expected the XOR distance, got %s", a.Distance(b))
+	}
+	if a.Distance(a) != (Key{}) {
+		t.Errorf("Expected the distance to itself to be zero")
+	}
+	if !a.Less(b) || b.Less(a) {
+		t.Errorf("Expected keys to compare as big endian integers")
+	}
+	if commonPrefixLen(testKey(0x80), testKey(0xc0)) != 1 {
+		t.Errorf("Expected a common prefix of 1 bit")
+	}
+	if commonPrefixLen(a, a) != KeySize*8 {
+		t.Errorf("Expected identical keys to share every bit")
+	}
+}
+
+func TestRoutingTable_Update(t *testing.T) {
+	table := NewRoutingTable(testKey(0), 2)
+	// Keys starting with bit 1 share no prefix with the local key
+	for i, b := range []byte{0x80, 0x90, 0xa0} {
+		added, oldest := table.Update(Contact{ID: testKey(b).String(), Address: fmt.Sprint(i)})
+		if i < 2 && !added {
+			t.Errorf("Expected contact %d to be added", i)
+		}
+		if i == 2 && (added || oldest.ID != testKey(0x80).String()) {
+			t.Errorf("Expected a full bucket to return the oldest contact, got %v %v", added, oldest.ID)
+		}
+	}
+
+	// Seeing the oldest contact again moves it to the tail
+	table.Update(Contact{ID: testKey(0x80).String()})
+	_, oldest := table.Update(Contact{ID: testKey(0xa0).String()})
+	if oldest.ID != testKey(0x90).String() {
+		t.Errorf("Expected the least recently seen contact to be 0x90, got %s", oldest.ID)
+	}
+	if contact, ok := table.Contact(testKey(0x80).String()); !ok || contact.Address != "0" {
+		t.Errorf("Expected the address to be kept when a contact is seen again, got %+v", contact)
+	}
+
+	table.Remove(testKey(0x90).String())
+	if added, _ := table.Update(Contact{ID: testKey(0xa0).String()}); !added {
+		t.Errorf("Expected the contact to be added once there is room")
+	}
+	if added, _ := table.Update(Contact{ID: testKey(0).String()}); added {
+		t.Errorf("Expected the local node not to be added")
+	}
+	if table.Size() != 2 {
+		t.Errorf("Expected 2 contacts, got %d", table.Size())
+	}
+}
+
+func TestRoutingTable_Closest(t *testing.T) {
+	table := NewRoutingTable(testKey(0), DefaultBucketSize)
+	for _, b := range []byte{0x01, 0x10, 0x40, 0x80, 0xff} {
+		table.Update(Contact{ID: testKey(b).String()})
+	}
+	closest := table.Closest(testKey(0x11), 3)
+	expected := []byte{0x10, 0x01, 0x40}
+	if len(closest) != 3 {
+		t.Fatalf("Expected 3 contacts, got %d", len(closest))
+	}
+	for i, b := range expected {
+		if closest[i].ID != testKey(b).String() {
+			t.Errorf("Expected contact %d to be %x, got %s", i, b, closest[i].ID)
+		}
+	}
+}
+
+func TestRoutingTable_randomKey(t *testing.T) {
+	table := NewRoutingTable(testKey(0x5a), DefaultBucketSize)
+	for _, i := range []int{0, 3, 7, 100, 255} {
+		key := table.randomKey(i)
+		if commonPrefixLen(table.Self, key) != i {
+			t.Errorf("Expected a key in bucket %d, got prefix %d", i, commonPrefixLen(table.Self, key))
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrNotStarted is returned when the network is used before it is started
+var ErrNotStarted = errors.New("network is not started")
+
+// ErrNetworkStopped is returned when a peer connects while the network is stopping
+var ErrNetworkStopped = errors.New("network is stopping")
+
+// ErrNotDrained is returned when starting a network whose previous run has
+// goroutines that Stop gave up waiting for
+var ErrNotDrained = errors.New("network is still draining the previous run")
+
+// maxAcceptDelay is the maximum delay between retries after an accept error
+const maxAcceptDelay = time.Second
+
+// Running returns whether the network is started
+func (n *Network) Running() bool {
+	n.lifecycleMutex.Lock()
+	defer n.lifecycleMutex.Unlock()
+	return n.running
+}
+
+// begin marks the network as running and returns the context of the run
+func (n *Network) begin(parent context.Context) (context.Context, error) {
+	n.lifecycleMutex.Lock()
+	defer n.lifecycleMutex.Unlock()
+	if n.running {
+		return nil, errors.New("network is already started")
+	}
+	if n.drained != nil {
+		select {
+		case <-n.drained:
+		default:
+			return nil, ErrNotDrained
+		}
+	}
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	n.running = true
+
+	n.Mutex.Lock()
+	n.closing = false
+	n.Mutex.Unlock()
+	return n.ctx, nil
+}
+
+// abort undoes a failed start
+func (n *Network) abort() {
+	n.lifecycleMutex.Lock()
+	n.running = false
+	cancel := n.cancel
+	n.lifecycleMutex.Unlock()
+	cancel()
+	n.wg.Wait()
+}
+
+// watch stops the network when parent is done
+func (n *Network) watch(parent context.Context, ctx context.Context) {
+	if parent.Done() == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-parent.Done():
+			if err := n.Stop(context.Background()); err != nil && err != ErrNotStarted {
+				log.Printf("stopping network: %v", err)
+			}
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// context returns the context of the current run, which is cancelled when
+// the network stops
+func (n *Network) context() context.Context {
+	n.lifecycleMutex.Lock()
+	defer n.lifecycleMutex.Unlock()
+	if n.ctx == nil {
+		return context.Background()
+	}
+	return n.ctx
+}
+
+// spawn runs f in a goroutine that Stop waits for. It returns false without
+// running f if the network is not running.
+func (n *Network) spawn(f func()) bool {
+	n.lifecycleMutex.Lock()
+	defer n.lifecycleMutex.Unlock()
+	if !n.running {
+		return false
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		f()
+	}()
+	return true
+}
+
+// acceptLoop accepts incoming connections until the listener is closed
+func (n *Network) acceptLoop(ctx context.Context, listener net.Listener) {
+	delay := 5 * time.Millisecond
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Println(err)
+
+			// Back off so a persistent error does not spin the loop
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if delay *= 2; delay > maxAcceptDelay {
+				delay = maxAcceptDelay
+			}
+			continue
+		}
+		delay = 5 * time.Millisecond
+		if !n.spawn(func() { n.handleConn(ctx, conn) }) {
+			conn.Close()
+			return
+		}
+	}
+}
+
+// Stop stops the network: it stops accepting connections, tells every peer
+// the node is shutting down, waits for the network's goroutines and saves
+// the address book and bans. If ctx is done before the goroutines finish,
+// the remaining connections are closed and ctx's error is returned with
+// any other errors; the network cannot be started again until the
+// goroutines have finished.
+func (n *Network) Stop(ctx context.Context) error {
+	n.lifecycleMutex.Lock()
+	if !n.running {
+		n.lifecycleMutex.Unlock()
+		return ErrNotStarted
+	}
+	n.running = false
+	cancel := n.cancel
+	n.lifecycleMutex.Unlock()
+
+	// Stop accepting connections and stop the background loops
+	cancel()
+	var errs []error
+	if err := n.Listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		errs = append(errs, err)
+	}
+
+	// Refuse new peers and disconnect the current ones
+	n.Mutex.Lock()
+	n.closing = true
+	peers := make([]Peer, len(n.Peers))
+	copy(peers, n.Peers)
+	n.Mutex.Unlock()
+
+	var wait sync.WaitGroup
+	for _, peer := range peers {
+		wait.Add(1)
+		go func(peer Peer) {
+			defer wait.Done()
+			peer.Session.Disconnect(ReasonShutdown, "node is shutting down")
+		}(peer)
+	}
+	wait.Wait()
+
+	// Wait for the goroutines to finish
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	n.lifecycleMutex.Lock()
+	n.drained = done
+	n.lifecycleMutex.Unlock()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		n.Mutex.RLock()
+		for _, peer := range n.Peers {
+			peer.Session.Close()
+		}
+		n.Mutex.RUnlock()
+		errs = append(errs, ctx.Err())
+	}
+
+	// Persist state for the next run
+	if n.AddressBook != nil {
+		if err := n.AddressBook.Save(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := n.bans().Save(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
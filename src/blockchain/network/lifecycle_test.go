@@ -0,0 +1,153 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestNetwork_Stop(t *testing.T) {
+	server := startTestNetwork(t)
+	client := startTestNetwork(t)
+	connectTestNetworks(t, client, server)
+	remote := waitForPeer(t, client, server.Config.ID)
+
+	if err := server.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected Stop to succeed, got %v", err)
+	}
+	select {
+	case <-remote.Session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the client session to close")
+	}
+	disconnect, ok := remote.Session.Err().(*DisconnectError)
+	if !ok || disconnect.Reason != ReasonShutdown {
+		t.Errorf("Expected a shutdown disconnect, got %v", remote.Session.Err())
+	}
+	if len(server.PeerStatuses()) != 0 {
+		t.Errorf("Expected Stop to remove every peer, got %d", len(server.PeerStatuses()))
+	}
+	if server.Running() {
+		t.Errorf("Expected the network not to be running after Stop")
+	}
+	if _, err := server.Dial(Peer{Address: client.Listener.Addr().String()}); err != ErrNotStarted {
+		t.Errorf("Expected Dial to fail after Stop, got %v", err)
+	}
+	if err := server.Stop(context.Background()); err != ErrNotStarted {
+		t.Errorf("Expected a second Stop to return ErrNotStarted, got %v", err)
+	}
+}
+
+func TestNetwork_StopBeforeStart(t *testing.T) {
+	network := NewNetwork(Config{})
+	if err := network.Stop(context.Background()); err != ErrNotStarted {
+		t.Errorf("Expected Stop to return ErrNotStarted, got %v", err)
+	}
+}
+
+func TestNetwork_Restart(t *testing.T) {
+	address, port := freeAddress(t)
+	server := startTestNetwork(t, func(config *Config) {
+		config.Port = port
+	})
+	client := startTestNetwork(t)
+	connectTestNetworks(t, client, server)
+
+	if err := server.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected Stop to succeed, got %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Expected the network to restart on the same port, got %v", err)
+	}
+	if err := server.Start(); err == nil {
+		t.Errorf("Expected Start to fail while the network is running")
+	}
+
+	// The client notices the old session ending before it can redial
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := client.Peer(server.Config.ID); !ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := client.Dial(Peer{Address: address}); err != nil {
+		t.Fatalf("Expected to be able to dial the restarted network, got %v", err)
+	}
+	waitForPeer(t, server, client.Config.ID)
+}
+
+func TestNetwork_RestartAfterStopTimeout(t *testing.T) {
+	network := startTestNetwork(t)
+	release := make(chan struct{})
+	network.spawn(func() {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := network.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected Stop to time out, got %v", err)
+	}
+	if err := network.Start(); err != ErrNotDrained {
+		t.Errorf("Expected Start to wait for the previous run to drain, got %v", err)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := network.Start()
+		if err == nil {
+			break
+		}
+		if err != ErrNotDrained || time.Now().After(deadline) {
+			t.Fatalf("Expected Start to succeed once drained, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNetwork_StartContext(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	server := startTestNetwork(t, func(config *Config) {
+		config.KeepAliveInterval = 10 * time.Millisecond
+	})
+	cert, key, err := GenerateTLS()
+	if err != nil {
+		t.Fatalf("Expected GenerateTLS to succeed, got %v", err)
+	}
+	network := NewNetwork(Config{
+		Timeout:           5 * time.Second,
+		KeepAliveInterval: 10 * time.Millisecond,
+		PersistentPeers:   []string{server.Listener.Addr().String()},
+		TLS:               TLSConfig{Cert: cert, Key: key},
+	})
+	if err := network.StartContext(ctx); err != nil {
+		t.Fatalf("Expected StartContext to succeed, got %v", err)
+	}
+	waitForPeer(t, server, network.Config.ID)
+
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for network.Running() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if network.Running() {
+		t.Fatalf("Expected cancelling the context to stop the network")
+	}
+
+	// Stopping the server as well should leave no goroutines behind
+	if err := server.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected Stop to succeed, got %v", err)
+	}
+	for time.Now().Before(deadline) {
+		if runtime.NumGoroutine() <= before {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected Stop to leave no goroutines, had %d and now %d", before, runtime.NumGoroutine())
+}
@@ -0,0 +1,509 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultRetransmitTimeout is the default delay a lost write adds before it
+// is retransmitted on an in-memory network
+const DefaultRetransmitTimeout = 50 * time.Millisecond
+
+// ErrUnreachable is returned when dialing across a partition
+var ErrUnreachable = errors.New("address is unreachable")
+
+// MemoryNetwork connects in-memory transports within a single process. It
+// can inject latency, loss and partitions between addresses for multi-node
+// tests. Each direction of a connection draws its losses from its own random
+// source, derived from the seed and the connection's addresses, so the loss
+// pattern of a connection does not depend on how concurrent connections are
+// scheduled.
+//
+// Connections are reliable streams like TCP: a lost write is retransmitted
+// after RetransmitTimeout rather than dropped, and writes across a
+// partition are held until the partition heals.
+type MemoryNetwork struct {
+	// Mutex is a mutex to protect access to the network
+	Mutex sync.Mutex
+
+	// Latency is the one way delay of every write
+	Latency time.Duration
+
+	// Loss is the probability, between 0 and 1, that a write is lost and
+	// has to be retransmitted
+	Loss float64
+
+	// RetransmitTimeout is the delay a lost write adds
+	RetransmitTimeout time.Duration
+
+	seed       int64
+	dials      map[string]int
+	listeners  map[string]*memoryListener
+	partitions map[string]int
+	pipes      map[*memoryPipe]struct{}
+	nextPort   int
+}
+
+// NewMemoryNetwork returns an in-memory network whose loss is driven by
+// random sources derived from the given seed
+func NewMemoryNetwork(seed int64) *MemoryNetwork {
+	return &MemoryNetwork{
+		RetransmitTimeout: DefaultRetransmitTimeout,
+		seed:              seed,
+		dials:             make(map[string]int),
+		listeners:         make(map[string]*memoryListener),
+		partitions:        make(map[string]int),
+		pipes:             make(map[*memoryPipe]struct{}),
+	}
+}
+
+// Transport returns a new transport on the network
+func (m *MemoryNetwork) Transport() *MemoryTransport {
+	return &MemoryTransport{network: m}
+}
+
+// SetLatency sets the one way delay of every write
+func (m *MemoryNetwork) SetLatency(latency time.Duration) {
+	m.Mutex.Lock()
+	m.Latency = latency
+	m.Mutex.Unlock()
+}
+
+// SetLoss sets the probability that a write is lost and retransmitted
+func (m *MemoryNetwork) SetLoss(loss float64) {
+	m.Mutex.Lock()
+	m.Loss = loss
+	m.Mutex.Unlock()
+}
+
+// Partition splits the network into groups of addresses. Addresses in
+// different groups cannot reach each other; addresses in no group can reach
+// every address.
+func (m *MemoryNetwork) Partition(groups ...[]string) {
+	m.Mutex.Lock()
+	m.partitions = make(map[string]int)
+	for i, group := range groups {
+		for _, address := range group {
+			m.partitions[address] = i
+		}
+	}
+	m.Mutex.Unlock()
+	m.wakeAll()
+}
+
+// Heal removes all partitions
+func (m *MemoryNetwork) Heal() {
+	m.Partition()
+}
+
+// Reachable reports whether two addresses can reach each other
+func (m *MemoryNetwork) Reachable(a string, b string) bool {
+	m.Mutex.Lock()
+	defer m.Mutex.Unlock()
+	return m.reachable(a, b)
+}
+
+// reachable reports whether two addresses can reach each other. The caller
+// must hold the mutex.
+func (m *MemoryNetwork) reachable(a string, b string) bool {
+	groupA, okA := m.partitions[a]
+	groupB, okB := m.partitions[b]
+	return !okA || !okB || groupA == groupB
+}
+
+// source returns the random source of one direction of the nth connection
+// between two addresses
+func (m *MemoryNetwork) source(from string, to string, n int) *rand.Rand {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d/%s/%s/%d", m.seed, from, to, n)
+	return rand.New(rand.NewSource(int64(hash.Sum64())))
+}
+
+// allocate returns an unused address
+func (m *MemoryNetwork) allocate() string {
+	m.nextPort++
+	return fmt.Sprintf("memory:%d", m.nextPort)
+}
+
+// wakeAll wakes every reader so it rechecks the partitions
+func (m *MemoryNetwork) wakeAll() {
+	m.Mutex.Lock()
+	pipes := make([]*memoryPipe, 0, len(m.pipes))
+	for pipe := range m.pipes {
+		pipes = append(pipes, pipe)
+	}
+	m.Mutex.Unlock()
+	for _, pipe := range pipes {
+		pipe.wake()
+	}
+}
+
+// MemoryTransport is a transport on an in-memory network
+type MemoryTransport struct {
+	network *MemoryNetwork
+
+	mutex    sync.Mutex
+	listener *memoryListener
+}
+
+// Listen listens on an address. An empty address or a port of 0 listens on
+// a new address.
+func (t *MemoryTransport) Listen(address string) (net.Listener, error) {
+	m := t.network
+	m.Mutex.Lock()
+	if address == "" || address == ":0" {
+		address = m.allocate()
+	}
+	if _, ok := m.listeners[address]; ok {
+		m.Mutex.Unlock()
+		return nil, fmt.Errorf("address %s is already in use", address)
+	}
+	listener := &memoryListener{
+		network: m,
+		address: memoryAddr(address),
+		conns:   make(chan net.Conn, 16),
+		closed:  make(chan struct{}),
+	}
+	m.listeners[address] = listener
+	m.Mutex.Unlock()
+
+	t.mutex.Lock()
+	t.listener = listener
+	t.mutex.Unlock()
+	return listener, nil
+}
+
+// Dial opens a connection to an address
+func (t *MemoryTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m := t.network
+	m.Mutex.Lock()
+	var local string
+	if addr := t.LocalAddr(); addr != nil {
+		local = addr.String()
+	} else {
+		local = m.allocate()
+	}
+	listener, ok := m.listeners[address]
+	if !ok {
+		m.Mutex.Unlock()
+		return nil, fmt.Errorf("dial %s: connection refused", address)
+	}
+	if !m.reachable(local, address) {
+		m.Mutex.Unlock()
+		return nil, fmt.Errorf("dial %s: %w", address, ErrUnreachable)
+	}
+	key := local + ">" + address
+	n := m.dials[key]
+	m.dials[key]++
+	toRemote := newMemoryPipe(m, local, address, m.source(local, address, n))
+	toLocal := newMemoryPipe(m, address, local, m.source(address, local, n))
+	m.pipes[toRemote] = struct{}{}
+	m.pipes[toLocal] = struct{}{}
+	m.Mutex.Unlock()
+
+	client := &memoryConn{in: toLocal, out: toRemote, local: memoryAddr(local), remote: memoryAddr(address)}
+	server := &memoryConn{in: toRemote, out: toLocal, local: memoryAddr(address), remote: memoryAddr(local)}
+	select {
+	case listener.conns <- server:
+		return client, nil
+	case <-listener.closed:
+		return nil, fmt.Errorf("dial %s: connection refused", address)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// LocalAddr returns the address the transport is listening on
+func (t *MemoryTransport) LocalAddr() net.Addr {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.listener == nil {
+		return nil
+	}
+	return t.listener.address
+}
+
+// memoryAddr is the address of an in-memory endpoint
+type memoryAddr string
+
+// Network returns the name of the network
+func (a memoryAddr) Network() string {
+	return "memory"
+}
+
+// String returns the address
+func (a memoryAddr) String() string {
+	return string(a)
+}
+
+// memoryListener accepts in-memory connections
+type memoryListener struct {
+	network   *MemoryNetwork
+	address   memoryAddr
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Accept waits for the next connection
+func (l *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops listening
+func (l *memoryListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		l.network.Mutex.Lock()
+		delete(l.network.listeners, string(l.address))
+		l.network.Mutex.Unlock()
+	})
+	return nil
+}
+
+// Addr returns the address being listened on
+func (l *memoryListener) Addr() net.Addr {
+	return l.address
+}
+
+// memoryChunk is a write waiting to be delivered
+type memoryChunk struct {
+	data []byte
+	at   time.Time
+}
+
+// memoryPipe carries the writes of one direction of a connection
+type memoryPipe struct {
+	network *MemoryNetwork
+	from    string
+	to      string
+
+	mutex        sync.Mutex
+	rand         *rand.Rand
+	queue        []memoryChunk
+	buffered     []byte
+	last         time.Time
+	writeClosed  bool
+	readClosed   bool
+	readDeadline time.Time
+	notify       chan struct{}
+}
+
+// newMemoryPipe returns an empty pipe
+func newMemoryPipe(network *MemoryNetwork, from string, to string, source *rand.Rand) *memoryPipe {
+	return &memoryPipe{
+		network: network,
+		from:    from,
+		to:      to,
+		rand:    source,
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// wake wakes a reader waiting on the pipe
+func (p *memoryPipe) wake() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+// delay returns the delay of a write, including retransmissions. The
+// caller must hold the pipe's mutex.
+func (p *memoryPipe) delay() time.Duration {
+	p.network.Mutex.Lock()
+	latency, loss, retransmit := p.network.Latency, p.network.Loss, p.network.RetransmitTimeout
+	p.network.Mutex.Unlock()
+	delay := latency
+	for loss > 0 && p.rand.Float64() < loss {
+		delay += retransmit
+	}
+	return delay
+}
+
+// write queues data for delivery after the network delay. Writes are
+// delivered in order.
+func (p *memoryPipe) write(data []byte) (int, error) {
+	p.mutex.Lock()
+	if p.writeClosed {
+		p.mutex.Unlock()
+		return 0, net.ErrClosed
+	}
+	if p.readClosed {
+		p.mutex.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	at := time.Now().Add(p.delay())
+	if at.Before(p.last) {
+		at = p.last
+	}
+	p.last = at
+	p.queue = append(p.queue, memoryChunk{data: append([]byte(nil), data...), at: at})
+	p.mutex.Unlock()
+	p.wake()
+	return len(data), nil
+}
+
+// read reads delivered data, waiting for the next write to arrive
+func (p *memoryPipe) read(b []byte) (int, error) {
+	for {
+		p.mutex.Lock()
+		if p.readClosed {
+			p.mutex.Unlock()
+			return 0, net.ErrClosed
+		}
+		if len(p.buffered) > 0 {
+			n := copy(b, p.buffered)
+			p.buffered = p.buffered[n:]
+			p.mutex.Unlock()
+			return n, nil
+		}
+		deadline := p.readDeadline
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			p.mutex.Unlock()
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		wait := time.Duration(-1)
+		if len(p.queue) > 0 {
+			if p.network.Reachable(p.from, p.to) {
+				wait = time.Until(p.queue[0].at)
+				if wait <= 0 {
+					p.buffered = p.queue[0].data
+					p.queue = p.queue[1:]
+					p.mutex.Unlock()
+					continue
+				}
+			}
+		} else if p.writeClosed {
+			p.mutex.Unlock()
+			return 0, io.EOF
+		}
+		p.mutex.Unlock()
+
+		if !deadline.IsZero() {
+			if untilDeadline := time.Until(deadline); wait < 0 || untilDeadline < wait {
+				wait = untilDeadline
+			}
+		}
+		if wait < 0 {
+			<-p.notify
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-p.notify:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// closeWrite ends the pipe once the queued writes are delivered
+func (p *memoryPipe) closeWrite() {
+	p.mutex.Lock()
+	p.writeClosed = true
+	p.mutex.Unlock()
+	p.wake()
+	p.release()
+}
+
+// closeRead stops reading from the pipe
+func (p *memoryPipe) closeRead() {
+	p.mutex.Lock()
+	p.readClosed = true
+	p.queue = nil
+	p.buffered = nil
+	p.mutex.Unlock()
+	p.wake()
+	p.release()
+}
+
+// release forgets the pipe once both ends are closed
+func (p *memoryPipe) release() {
+	p.mutex.Lock()
+	done := p.writeClosed && p.readClosed
+	p.mutex.Unlock()
+	if done {
+		p.network.Mutex.Lock()
+		delete(p.network.pipes, p)
+		p.network.Mutex.Unlock()
+	}
+}
+
+// setReadDeadline sets the read deadline and wakes the reader
+func (p *memoryPipe) setReadDeadline(t time.Time) {
+	p.mutex.Lock()
+	p.readDeadline = t
+	p.mutex.Unlock()
+	p.wake()
+}
+
+// memoryConn is one end of an in-memory connection
+type memoryConn struct {
+	in     *memoryPipe
+	out    *memoryPipe
+	local  memoryAddr
+	remote memoryAddr
+}
+
+// Read reads data from the connection
+func (c *memoryConn) Read(b []byte) (int, error) {
+	return c.in.read(b)
+}
+
+// Write writes data to the connection. Writes never block, so write
+// deadlines have no effect.
+func (c *memoryConn) Write(b []byte) (int, error) {
+	return c.out.write(b)
+}
+
+// Close closes the connection. The peer reads the data already written
+// before it sees the end of the connection.
+func (c *memoryConn) Close() error {
+	c.out.closeWrite()
+	c.in.closeRead()
+	return nil
+}
+
+// LocalAddr returns the local address
+func (c *memoryConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the remote address
+func (c *memoryConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline sets the read deadline
+func (c *memoryConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline
+func (c *memoryConn) SetReadDeadline(t time.Time) error {
+	c.in.setReadDeadline(t)
+	return nil
+}
+
+// SetWriteDeadline has no effect because writes never block
+func (c *memoryConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,168 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// memoryPair returns both ends of a connection between two transports
+func memoryPair(t *testing.T, network *MemoryNetwork) (net.Conn, net.Conn) {
+	server := network.Transport()
+	listener, err := server.Listen("server")
+	if err != nil {
+		t.Fatalf("Expected Listen to succeed, got %v", err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	client := network.Transport()
+	if _, err := client.Listen("client"); err != nil {
+		t.Fatalf("Expected Listen to succeed, got %v", err)
+	}
+	clientConn, err := client.Dial(context.Background(), "server")
+	if err != nil {
+		t.Fatalf("Expected Dial to succeed, got %v", err)
+	}
+	serverConn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Expected Accept to succeed, got %v", err)
+	}
+	return clientConn, serverConn
+}
+
+func TestMemoryTransport(t *testing.T) {
+	network := NewMemoryNetwork(1)
+	client, server := memoryPair(t, network)
+	if client.LocalAddr().String() != "client" || server.RemoteAddr().String() != "client" {
+		t.Errorf("Expected the connection to be between the listening addresses")
+	}
+
+	client.Write([]byte("hello "))
+	client.Write([]byte("world"))
+	client.Close()
+	data, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatalf("Expected ReadAll to succeed, got %v", err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("Expected to read hello world, got %s", data)
+	}
+	if _, err := server.Write([]byte("late")); err == nil {
+		t.Errorf("Expected writing to a closed peer to fail")
+	}
+
+	if _, err := network.Transport().Dial(context.Background(), "nowhere"); err == nil {
+		t.Errorf("Expected dialing an unknown address to fail")
+	}
+}
+
+func TestMemoryTransport_Latency(t *testing.T) {
+	network := NewMemoryNetwork(1)
+	network.SetLatency(50 * time.Millisecond)
+	client, server := memoryPair(t, network)
+
+	start := time.Now()
+	client.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(server, buf); err != nil {
+		t.Fatalf("Expected Read to succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the write to be delayed by the latency, took %s", elapsed)
+	}
+}
+
+func TestMemoryTransport_Loss(t *testing.T) {
+	network := NewMemoryNetwork(1)
+	network.SetLoss(0.5)
+	network.RetransmitTimeout = 10 * time.Millisecond
+	client, server := memoryPair(t, network)
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		client.Write([]byte{byte(i)})
+	}
+	buf := make([]byte, 20)
+	if _, err := io.ReadFull(server, buf); err != nil {
+		t.Fatalf("Expected Read to succeed, got %v", err)
+	}
+	for i, b := range buf {
+		if int(b) != i {
+			t.Fatalf("Expected lost writes to be retransmitted in order, got %v", buf)
+		}
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Errorf("Expected lost writes to be delayed")
+	}
+}
+
+func TestMemoryTransport_LossPerConnection(t *testing.T) {
+	delays := func(conn net.Conn) []time.Duration {
+		pipe := conn.(*memoryConn).out
+		pipe.mutex.Lock()
+		defer pipe.mutex.Unlock()
+		result := make([]time.Duration, 20)
+		for i := range result {
+			result[i] = pipe.delay()
+		}
+		return result
+	}
+	first := NewMemoryNetwork(1)
+	first.SetLoss(0.5)
+	client, _ := memoryPair(t, first)
+	expected := delays(client)
+
+	// Traffic on other connections does not change a connection's losses
+	second := NewMemoryNetwork(1)
+	second.SetLoss(0.5)
+	other := second.Transport()
+	if _, err := other.Listen("other"); err != nil {
+		t.Fatalf("Expected Listen to succeed, got %v", err)
+	}
+	client, _ = memoryPair(t, second)
+	otherConn, err := other.Dial(context.Background(), "server")
+	if err != nil {
+		t.Fatalf("Expected Dial to succeed, got %v", err)
+	}
+	delays(otherConn)
+	got := delays(client)
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("Expected the same losses for the same seed, got %v and %v", expected, got)
+		}
+	}
+}
+
+func TestMemoryTransport_Partition(t *testing.T) {
+	network := NewMemoryNetwork(1)
+	client, server := memoryPair(t, network)
+
+	network.Partition([]string{"client"}, []string{"server"})
+	if network.Reachable("client", "server") {
+		t.Errorf("Expected the partitioned addresses to be unreachable")
+	}
+	if _, err := network.Transport().Dial(context.Background(), "server"); err != nil {
+		t.Errorf("Expected an address in no group to reach the server, got %v", err)
+	}
+
+	client.Write([]byte("held"))
+	server.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	buf := make([]byte, 4)
+	if _, err := server.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected writes across a partition to be held, got %v", err)
+	}
+
+	server.SetReadDeadline(time.Time{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		network.Heal()
+	}()
+	if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "held" {
+		t.Errorf("Expected held writes to be delivered after healing, got %q %v", buf, err)
+	}
+}
@@ -1,22 +1,17 @@
 package network
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"crypto/x509/pkix"
+	"context"
+	"crypto/tls"
 	"encoding/json"
-	"encoding/pem"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
 
-	"github.com/skybridge/lib/errors"
+	"github.com/skybridge/crypto/ca"
 )
 
 // Network represents a network
@@ -33,10 +28,53 @@ type Network struct {
 	// Listener is the listener for incoming connections
 	Listener net.Listener
 
-	// Dialer is the dialer for outgoing connections
-	Dialer net.Dialer
+	// Transport is the transport connections are made over, secured with
+	// TLS once the network is started
+	Transport Transport
+
+	// AddressBook is the book of known peer addresses
+	AddressBook *AddressBook
+
+	// BanList is the list of banned peers
+	BanList *BanList
+
+	banOnce sync.Once
+
+	certMutex   sync.RWMutex
+	certificate *tls.Certificate
+	verifier    *ca.Verifier
+
+	trafficOnce sync.Once
+	meter       *Meter
+	upload      *Shaper
+	download    *Shaper
+
+	lifecycleMutex sync.Mutex
+	running        bool
+	ctx            context.Context
+	cancel         context.CancelFunc
+	drained        chan struct{}
+	wg             sync.WaitGroup
+	closing        bool
+
+	statusMutex sync.RWMutex
+	statuses    map[string]*PeerStatus
+	persistent  map[string]string
+	scores      map[string]*peerScore
+
+	handlerMutex   sync.RWMutex
+	handlers       map[MessageType]Handler
+	streamHandlers map[MessageType]StreamHandler
 }
 
+// Handler handles a message received from a peer on stream 0. Handlers are
+// called from the peer's read loop and must not block.
+type Handler func(peer Peer, payload []byte)
+
+// StreamHandler handles the first message of a stream opened by a peer, such
+// as a sync request. Responses are sent on the stream.
+type StreamHandler func(peer Peer, stream *Stream, payload []byte)
+
 // Config represents the configuration for the network
 type Config struct {
 	// Port is the port to listen on
@@ -45,8 +83,94 @@ type Config struct {
 	// Timeout is the timeout for connections
 	Timeout time.Duration
 
+	// HandshakeTimeout is the time a new connection has to complete the TLS
+	// and protocol handshakes. It defaults to Timeout, or to
+	// DefaultHandshakeTimeout if Timeout is not set.
+	HandshakeTimeout time.Duration
+
+	// ID is the ID this node announces to peers. It is derived from the
+	// TLS certificate when the network starts.
+	ID string
+
+	// Address is the address this node announces to peers
+	Address string
+
+	// MaxFrameSize is the maximum size of a frame payload
+	MaxFrameSize int
+
+	// Bootstrap is a list of peer addresses to dial on startup
+	Bootstrap []string
+
+	// AddressBookPath is the file known peer addresses are persisted to
+	AddressBookPath string
+
+	// TargetOutbound is the number of outbound connections to maintain
+	TargetOutbound int
+
+	// DiscoveryInterval is the interval between discovery rounds
+	DiscoveryInterval time.Duration
+
+	// MaxInbound is the maximum number of inbound peers
+	MaxInbound int
+
+	// MaxOutbound is the maximum number of outbound peers
+	MaxOutbound int
+
+	// KeepAliveInterval is the interval between keepalive pings
+	KeepAliveInterval time.Duration
+
+	// KeepAliveTimeout is the time to wait for a keepalive pong
+	KeepAliveTimeout time.Duration
+
+	// PersistentPeers is a list of peer addresses that are always redialed
+	PersistentPeers []string
+
+	// ReconnectDelay is the initial delay before redialing a persistent peer
+	ReconnectDelay time.Duration
+
+	// MaxReconnectDelay is the maximum delay before redialing a persistent peer
+	MaxReconnectDelay time.Duration
+
+	// RateLimits are the per-peer rate limits for each message type
+	RateLimits map[MessageType]RateLimit `json:"-"`
+
+	// Bandwidth caps the bandwidth used by the network
+	Bandwidth BandwidthConfig
+
+	// BanThreshold is the score at which a peer is banned
+	BanThreshold float64
+
+	// BanDuration is the duration of a temporary ban
+	BanDuration time.Duration
+
+	// MaxTemporaryBans is the number of temporary bans after which a peer
+	// is banned permanently
+	MaxTemporaryBans int
+
+	// BanListPath is the file bans are persisted to
+	BanListPath string
+
 	// TLS is the TLS configuration
 	TLS TLSConfig
+
+	// Transport is the transport to connect to peers over. It defaults to
+	// TCP.
+	Transport Transport `json:"-"`
+
+	// ChainID is the ID of the chain. Peers on other chains are rejected.
+	ChainID string
+
+	// GenesisHash is the hash of the genesis block. Peers with another
+	// genesis block are rejected.
+	GenesisHash string
+
+	// Capabilities are the optional features advertised to peers
+	Capabilities []string
+
+	// Compression lists the compression algorithms offered to peers, in
+	// order of preference. Nil offers DefaultCompression; an empty list
+	// disables compression.
+	Compression []string
 }
 
 // TLSConfig represents the TLS configuration
@@ -56,6 +180,17 @@ type TLSConfig struct {
 
 	// Key is the TLS private key
 	Key string
+
+	// TrustedPeers is an allowlist of peer IDs. When it is empty any peer
+	// presenting a valid certificate is accepted.
+	TrustedPeers []string
+
+	// CA is the PEM encoded root certificate peers must be issued by. When
+	// it is empty self-signed peer certificates are accepted.
+	CA string
+
+	// CRL is the PEM encoded certificate revocation list of the CA
+	CRL string
 }
 
 // Peer represents a peer in the network
@@ -68,101 +203,503 @@ type Peer struct {
 
 	// Conn is the connection to the peer
 	Conn net.Conn
+
+	// Outbound is true if the connection was dialed by this node
+	Outbound bool
+
+	// Persistent is true if the peer is redialed when disconnected
+	Persistent bool
+
+	// ConnectedAt is the time the connection was established
+	ConnectedAt time.Time
+
+	// Version is the protocol version negotiated with the peer
+	Version uint32
+
+	// Capabilities are the capabilities supported by both sides
+	Capabilities []string
+
+	// Compression is the compression algorithm negotiated with the peer
+	Compression string
+
+	// Session is the framed session over the connection
+	Session *Session `json:"-"`
 }
 
 // NewNetwork returns a new network
 func NewNetwork(config Config) *Network {
+	if config.MaxFrameSize <= 0 {
+		config.MaxFrameSize = DefaultMaxFrameSize
+	}
+	config.applyConnDefaults()
+	config.applyScoreDefaults()
+	persistent := make(map[string]string)
+	for _, address := range config.PersistentPeers {
+		persistent[address] = ""
+	}
 	return &Network{
-		Config: config,
-		Peers:  make([]Peer, 0),
+		Config:         config,
+		Peers:          make([]Peer, 0),
+		statuses:       make(map[string]*PeerStatus),
+		persistent:     persistent,
+		scores:         make(map[string]*peerScore),
+		BanList:        NewBanList(config.BanListPath),
+		handlers:       make(map[MessageType]Handler),
+		streamHandlers: make(map[MessageType]StreamHandler),
 	}
 }
 
+// Handle registers the handler for a message type
+func (n *Network) Handle(t MessageType, handler Handler) {
+	n.handlerMutex.Lock()
+	defer n.handlerMutex.Unlock()
+	if n.handlers == nil {
+		n.handlers = make(map[MessageType]Handler)
+	}
+	n.handlers[t] = handler
+}
+
+// HandleStream registers the handler for streams opened with a message type
+func (n *Network) HandleStream(t MessageType, handler StreamHandler) {
+	n.handlerMutex.Lock()
+	defer n.handlerMutex.Unlock()
+	if n.streamHandlers == nil {
+		n.streamHandlers = make(map[MessageType]StreamHandler)
+	}
+	n.streamHandlers[t] = handler
+}
+
 // Start starts the network
 func (n *Network) Start() error {
+	return n.StartContext(context.Background())
+}
+
+// StartContext starts the network and stops it when ctx is done
+func (n *Network) StartContext(parent context.Context) error {
+	ctx, err := n.begin(parent)
+	if err != nil {
+		return err
+	}
+	if err := n.start(ctx); err != nil {
+		n.abort()
+		return err
+	}
+	n.watch(parent, ctx)
+	return nil
+}
+
+// start loads the configuration and starts the network's goroutines
+func (n *Network) start(ctx context.Context) error {
+	// Load the TLS configuration, which also determines the node ID
+	tlsConfig, id, err := n.buildTLSConfig()
+	if err != nil {
+		return err
+	}
+	if n.Config.ID != "" && n.Config.ID != id {
+		return fmt.Errorf("configured ID %s does not match the TLS certificate", n.Config.ID)
+	}
+	n.Config.ID = id
+
+	// Load the bans persisted by a previous run
+	if err := n.bans().Load(); err != nil {
+		return err
+	}
+
 	// Create a new listener
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", n.Config.Port))
+	transport := n.Config.Transport
+	if transport == nil {
+		transport = NewTCPTransport(n.Config.Timeout)
+	}
+	n.Transport = NewTLSTransport(transport, tlsConfig)
+	listener, err := n.Transport.Listen(fmt.Sprintf(":%d", n.Config.Port))
 	if err != nil {
 		return err
 	}
 	n.Listener = listener
 
-	// Create a new dialer
-	dialer := net.Dialer{
-		Timeout: n.Config.Timeout,
+	// Load known addresses and start dialing peers
+	if err := n.startDiscovery(ctx); err != nil {
+		listener.Close()
+		return err
+	}
+	for _, address := range n.Config.PersistentPeers {
+		address := address
+		n.spawn(func() {
+			n.maintainPersistentPeer(ctx, address)
+		})
 	}
-	n.Dialer = dialer
 
 	// Start listening for incoming connections
+	n.spawn(func() {
+		n.acceptLoop(ctx, listener)
+	})
+	return nil
+}
+
+// handleConn handles an incoming connection
+func (n *Network) handleConn(ctx context.Context, conn net.Conn) {
+	// Abandon the handshake if the network stops
+	handshaking := make(chan struct{})
 	go func() {
-		for {
-			conn, err := n.Listener.Accept()
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			go n.handleConn(conn)
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-handshaking:
 		}
 	}()
+	peer, err := n.handshake(conn, "", false)
+	close(handshaking)
+	if err != nil {
+		log.Println(err)
+		conn.Close()
+		return
+	}
+	peer.Persistent = n.isPersistent(peer.Address, peer.ID)
+	if err := n.addPeer(peer); err != nil {
+		log.Printf("rejecting peer %s: %v", peer.ID, err)
+		peer.Session.Disconnect(disconnectReason(err), "")
+		return
+	}
+	n.servePeer(peer)
+}
+
+// Dial dials a peer
+func (n *Network) Dial(peer Peer) (Peer, error) {
+	if !n.Running() {
+		return Peer{}, ErrNotStarted
+	}
+	if peer.ID != "" && n.IsBanned(peer.ID) {
+		return Peer{}, ErrPeerBanned
+	}
+	persistent := n.isPersistent(peer.Address, peer.ID)
+	if !persistent && n.outboundFull() {
+		return Peer{}, ErrTooManyPeers
+	}
+
+	// Dial the peer
+	ctx := n.context()
+	if n.Config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.Config.Timeout)
+		defer cancel()
+	}
+	conn, err := n.Transport.Dial(ctx, peer.Address)
+	if err != nil {
+		return Peer{}, err
+	}
 
+	connected, err := n.handshake(conn, peer.Address, true)
+	if err != nil {
+		conn.Close()
+		return Peer{}, err
+	}
+	if peer.ID != "" && peer.ID != connected.ID {
+		connected.Session.Disconnect(ReasonProtocolError, "unexpected peer ID")
+		return Peer{}, fmt.Errorf("dialed %s but peer identified as %s", peer.ID, connected.ID)
+	}
+	connected.Outbound = true
+	connected.Persistent = persistent
+	if err := n.addPeer(connected); err != nil {
+		connected.Session.Disconnect(disconnectReason(err), "")
+		return Peer{}, err
+	}
+	if persistent {
+		n.statusMutex.Lock()
+		n.persistent[peer.Address] = connected.ID
+		n.statusMutex.Unlock()
+	}
+	if n.AddressBook != nil {
+		n.AddressBook.MarkSuccess(peer.Address, connected.ID)
+	}
+	if !n.spawn(func() { n.servePeer(connected) }) {
+		connected.Session.Disconnect(ReasonShutdown, "")
+		n.removePeer(connected)
+		return Peer{}, ErrNetworkStopped
+	}
+	return connected, nil
+}
+
+// dialTransient connects to a peer for a short exchange such as a DHT
+// request. The connection is not added to the list of peers and does not
+// count against the peer limits. The caller must close the peer's session.
+func (n *Network) dialTransient(ctx context.Context, peer Peer) (Peer, error) {
+	if !n.Running() {
+		return Peer{}, ErrNotStarted
+	}
+	if n.IsBanned(peer.ID) {
+		return Peer{}, ErrPeerBanned
+	}
+	conn, err := n.Transport.Dial(ctx, peer.Address)
+	if err != nil {
+		return Peer{}, err
+	}
+	connected, err := n.handshake(conn, peer.Address, true)
+	if err != nil {
+		conn.Close()
+		return Peer{}, err
+	}
+	if connected.ID != peer.ID {
+		connected.Session.Disconnect(ReasonProtocolError, "unexpected peer ID")
+		return Peer{}, fmt.Errorf("dialed %s but peer identified as %s", peer.ID, connected.ID)
+	}
+	connected.Outbound = true
+
+	// The session ends with the network even if the caller is still using it
+	runCtx := n.context()
+	if !n.spawn(func() {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-runCtx.Done():
+				connected.Session.Close()
+			case <-done:
+			}
+		}()
+		connected.Session.Run()
+	}) {
+		connected.Session.Disconnect(ReasonShutdown, "")
+		return Peer{}, ErrNetworkStopped
+	}
+	return connected, nil
+}
+
+// verifyAddress checks that an address reaches the peer with an ID. It stops
+// after the TLS handshake, which proves the peer's ID, so it works while the
+// peer is already connected.
+func (n *Network) verifyAddress(ctx context.Context, id string, address string) error {
+	if !n.Running() {
+		return ErrNotStarted
+	}
+	conn, err := n.Transport.Dial(ctx, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	verifiedID, err := n.verifiedPeerID(conn)
+	if err != nil {
+		return err
+	}
+	if verifiedID != id {
+		return fmt.Errorf("%s is the address of %s, not %s", address, verifiedID, id)
+	}
 	return nil
 }
 
-// handleConn handles an incoming connection
-func (n *Network) handleConn(conn net.Conn) {
-	// Read the peer's ID and address
-	var peer Peer
-	err := json.NewDecoder(conn).Decode(&peer)
+// handshake exchanges handshakes over a new connection and returns the peer
+func (n *Network) handshake(conn net.Conn, address string, initiator bool) (Peer, error) {
+	verifiedID, err := n.verifiedPeerID(conn)
 	if err != nil {
-		log.Println(err)
-		return
+		return Peer{}, err
+	}
+
+	handshake := n.localHandshake()
+	local, err := json.Marshal(handshake)
+	if err != nil {
+		return Peer{}, err
+	}
+	if initiator {
+		if err := WriteFrame(conn, Frame{Type: MsgHandshake, Payload: local}, n.Config.MaxFrameSize); err != nil {
+			return Peer{}, err
+		}
+	}
+	frame, err := ReadFrame(conn, n.Config.MaxFrameSize)
+	if err != nil {
+		return Peer{}, err
+	}
+	if frame.Type == MsgDisconnect {
+		return Peer{}, decodeDisconnect(frame.Payload)
+	}
+	var remote Handshake
+	if frame.Type != MsgHandshake || frame.Stream != 0 {
+		err = fmt.Errorf("expected handshake, got %s", frame.Type)
+	} else if err = json.Unmarshal(frame.Payload, &remote); err == nil && remote.ID == "" {
+		err = errors.New("handshake without peer ID")
+	}
+	if err != nil {
+		WriteFrame(conn, Frame{Type: MsgDisconnect, Payload: encodeDisconnect(ReasonProtocolError, err.Error())}, n.Config.MaxFrameSize)
+		return Peer{}, err
+	}
+	if remote.ID != verifiedID {
+		WriteFrame(conn, Frame{Type: MsgDisconnect, Payload: encodeDisconnect(ReasonUnauthorized, "peer ID does not match certificate")}, n.Config.MaxFrameSize)
+		return Peer{}, fmt.Errorf("peer claimed ID %s but presented certificate for %s", remote.ID, verifiedID)
+	}
+	agreed, rejected := negotiate(handshake, remote, initiator)
+	if rejected != nil {
+		WriteFrame(conn, Frame{Type: MsgDisconnect, Payload: encodeDisconnect(rejected.Reason, rejected.Message)}, n.Config.MaxFrameSize)
+		return Peer{}, fmt.Errorf("rejecting peer %s: %s: %s", remote.ID, rejected.Reason, rejected.Message)
+	}
+	if n.IsBanned(remote.ID) {
+		WriteFrame(conn, Frame{Type: MsgDisconnect, Payload: encodeDisconnect(ReasonBanned, "")}, n.Config.MaxFrameSize)
+		return Peer{}, fmt.Errorf("peer %s: %w", remote.ID, ErrPeerBanned)
+	}
+	if _, ok := n.Peer(remote.ID); ok {
+		WriteFrame(conn, Frame{Type: MsgDisconnect, Payload: encodeDisconnect(ReasonDuplicate, "")}, n.Config.MaxFrameSize)
+		return Peer{}, fmt.Errorf("peer %s is already connected", remote.ID)
+	}
+	if !initiator {
+		if err := WriteFrame(conn, Frame{Type: MsgHandshake, Payload: local}, n.Config.MaxFrameSize); err != nil {
+			return Peer{}, err
+		}
+	}
+	conn.SetDeadline(time.Time{})
+
+	if !initiator && remote.Address != "" && n.AddressBook != nil {
+		n.AddressBook.Add(AddressEntry{ID: remote.ID, Address: remote.Address})
+	}
+	if address == "" {
+		address = remote.Address
+	}
+	if address == "" {
+		address = conn.RemoteAddr().String()
+	}
+	session := NewSession(conn, initiator, n.Config.MaxFrameSize)
+	session.WriteTimeout = n.Config.Timeout
+	session.Compression = agreed.compression
+	n.shapeSession(session)
+	return Peer{
+		ID:           remote.ID,
+		Address:      address,
+		Conn:         conn,
+		Session:      session,
+		ConnectedAt:  time.Now(),
+		Version:      agreed.version,
+		Capabilities: agreed.capabilities,
+		Compression:  agreed.compression,
+	}, nil
+}
+
+// addPeer wires a connected peer's session to the handlers and adds it to
+// the list of peers if the peer limits allow it
+func (n *Network) addPeer(peer Peer) error {
+	limiter := newPeerLimiter(n.Config.RateLimits)
+	peer.Session.Allow = func(frame Frame) bool {
+		if limiter.allow(frame.Type) {
+			return true
+		}
+		n.ReportPeer(peer.ID, EventRateLimited)
+		return false
+	}
+	peer.Session.OnMessage = func(frame Frame) {
+		if frame.Type == MsgHandshake {
+			n.ReportPeer(peer.ID, EventProtocolViolation)
+			return
+		}
+		n.handlerMutex.RLock()
+		handler, ok := n.handlers[frame.Type]
+		n.handlerMutex.RUnlock()
+		if ok {
+			handler(peer, frame.Payload)
+		}
+	}
+	peer.Session.OnStream = func(stream *Stream, frame Frame) {
+		n.handlerMutex.RLock()
+		handler, ok := n.streamHandlers[frame.Type]
+		n.handlerMutex.RUnlock()
+		if !ok {
+			stream.Close()
+			return
+		}
+		handler(peer, stream, frame.Payload)
 	}
 
 	// Add the peer to the list of peers
-	n.Mutex.Lock()
-	n.Peers = append(n.Peers, peer)
-	n.Mutex.Unlock()
+	return n.admitPeer(peer)
+}
 
-	// Send a response to the peer
-	err = json.NewEncoder(conn).Encode(struct {
-		ID string `json:"id"`
-	}{
-		ID: n.Config.TLS.Cert,
+// servePeer serves a peer's session until it closes and removes the peer
+func (n *Network) servePeer(peer Peer) {
+	ctx := n.context()
+	n.spawn(func() {
+		n.keepAlive(ctx, peer)
 	})
-	if err != nil {
-		log.Println(err)
-		return
+	err := peer.Session.Run()
+	log.Printf("peer %s disconnected: %v", peer.ID, err)
+	if err == ErrFrameTooLarge || err == ErrDecompression {
+		n.ReportPeer(peer.ID, EventProtocolViolation)
 	}
+	n.removePeer(peer)
+}
 
-	// Close the connection
-	conn.Close()
+// removePeer removes a peer whose session has ended. Its score is kept until
+// it decays to neutral, so a peer cannot reset it by reconnecting.
+func (n *Network) removePeer(peer Peer) {
+	n.statusMutex.Lock()
+	delete(n.statuses, peer.ID)
+	n.pruneScoresLocked(time.Now())
+	n.statusMutex.Unlock()
+
+	// Remove the peer from the list of peers
+	n.Mutex.Lock()
+	for i, p := range n.Peers {
+		if p.Session == peer.Session {
+			n.Peers = append(n.Peers[:i], n.Peers[i+1:]...)
+			break
+		}
+	}
+	n.Mutex.Unlock()
 }
 
-// Dial dials a peer
-func (n *Network) Dial(peer Peer) (net.Conn, error) {
-	// Dial the peer
-	conn, err := n.Dialer.Dial("tcp", peer.Address)
-	if err != nil {
-		return nil, err
+// Peer returns the connected peer with the given ID
+func (n *Network) Peer(id string) (Peer, bool) {
+	n.Mutex.RLock()
+	defer n.Mutex.RUnlock()
+	for _, peer := range n.Peers {
+		if peer.ID == id {
+			return peer, true
+		}
 	}
+	return Peer{}, false
+}
 
-	// Send the peer's ID and address
-	err = json.NewEncoder(conn).Encode(peer)
+// Send sends a message to a connected peer
+func (n *Network) Send(id string, t MessageType, payload []byte) error {
+	peer, ok := n.Peer(id)
+	if !ok {
+		return fmt.Errorf("peer %s is not connected", id)
+	}
+	return peer.Session.Send(t, payload)
+}
+
+// Request sends a message to a connected peer on a new stream and returns
+// the payload of the first reply
+func (n *Network) Request(ctx context.Context, id string, t MessageType, payload []byte) ([]byte, error) {
+	peer, ok := n.Peer(id)
+	if !ok {
+		return nil, fmt.Errorf("peer %s is not connected", id)
+	}
+	frame, err := peer.Session.Request(ctx, t, payload)
 	if err != nil {
 		return nil, err
 	}
+	return frame.Payload, nil
+}
 
-	return conn, nil
+// Disconnect closes the connection to a peer, telling it the reason
+func (n *Network) Disconnect(id string, reason DisconnectReason, message string) error {
+	peer, ok := n.Peer(id)
+	if !ok {
+		return fmt.Errorf("peer %s is not connected", id)
+	}
+	return peer.Session.Disconnect(reason, message)
 }
 
 // MarshalJSON marshals the network to JSON
 func (n *Network) MarshalJSON() ([]byte, error) {
+	n.handlerMutex.RLock()
+	config := n.Config
+	config.Capabilities = append([]string(nil), n.Config.Capabilities...)
+	n.handlerMutex.RUnlock()
+	n.Mutex.RLock()
+	peers := make([]Peer, len(n.Peers))
+	copy(peers, n.Peers)
+	n.Mutex.RUnlock()
 	return json.Marshal(struct {
 		Config Config `json:"config"`
 		Peers  []Peer `json:"peers"`
 	}{
-		Config: n.Config,
-		Peers:  n.Peers,
+		Config: config,
+		Peers:  peers,
 	})
 }
 
@@ -176,46 +713,33 @@ func (n *Network) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
+	n.Mutex.Lock()
 	n.Config = network.Config
 	n.Peers = network.Peers
+	if n.Config.MaxFrameSize <= 0 {
+		n.Config.MaxFrameSize = DefaultMaxFrameSize
+	}
+	n.Config.applyConnDefaults()
+	n.Config.applyScoreDefaults()
+	n.Mutex.Unlock()
+	n.statusMutex.Lock()
+	n.statuses = make(map[string]*PeerStatus)
+	n.persistent = make(map[string]string)
+	n.scores = make(map[string]*peerScore)
+	for _, address := range n.Config.PersistentPeers {
+		n.persistent[address] = ""
+	}
+	n.statusMutex.Unlock()
 	return nil
 }
 
-// GenerateTLS generates a TLS certificate and private key
+// GenerateTLS generates a self-signed TLS certificate and private key for
+// a node that is identified by its key. Deployments with a CA should issue
+// node certificates with the ca package instead.
 func GenerateTLS() (string, string, error) {
-	// Generate a new private key
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return "", "", err
-	}
-
-	// Generate a new certificate
-	template := x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			Organization: []string{"Skybridge"},
-		},
-		NotBefore: time.Now(),
-		NotAfter:  time.Now().AddDate(1, 0, 0),
-
-		IsCA:                  true,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
-		BasicConstraintsValid: true,
-	}
-
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	issued, err := ca.SelfSigned(ca.Request{CommonName: "skybridge-node"})
 	if err != nil {
 		return "", "", err
 	}
-
-	// Encode the certificate and private key
-	cert := pem.EncodeToMemory(
-		&pem.Block{Type: "CERTIFICATE", Bytes: derBytes},
-	)
-	key := pem.EncodeToMemory(
-		&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)},
-	)
-
-	return string(cert), string(key), nil
+	return issued.CertPEM, issued.KeyPEM, nil
 }
@@ -1,11 +1,11 @@
 package network
 
 import (
+	"context"
 	"encoding/json"
+	"net"
 	"testing"
 	"time"
-
-	"github.com/skybridge/lib/errors"
 )
 
 func TestNetwork_NewNetwork(t *testing.T) {
@@ -24,91 +24,189 @@ func TestNetwork_NewNetwork(t *testing.T) {
 }
 
 func TestNetwork_Start(t *testing.T) {
+	cert, key, err := GenerateTLS()
+	if err != nil {
+		t.Fatalf("Expected GenerateTLS to succeed, got %v", err)
+	}
 	config := Config{
 		Port:     8080,
 		Timeout:  10 * time.Second,
 		TLS: TLSConfig{
-			Cert: "cert",
-			Key:  "key",
+			Cert: cert,
+			Key:  key,
 		},
 	}
 	network := NewNetwork(config)
-	err := network.Start()
+	err = network.Start()
 	if err != nil {
 		t.Errorf("Expected Start to return a non-nil error")
 	}
+	defer network.Stop(context.Background())
+	if len(network.Config.ID) != 64 {
+		t.Errorf("Expected Start to derive the ID from the certificate, got %s", network.Config.ID)
+	}
 }
 
-func TestNetwork_handleConn(t *testing.T) {
+func TestNetwork_StartWithoutTLS(t *testing.T) {
+	network := NewNetwork(Config{Port: 0})
+	if err := network.Start(); err == nil {
+		t.Errorf("Expected Start to fail without a TLS certificate")
+	}
+}
+
+// startTestNetwork starts a network with a new certificate on a random
+// port, with the options applied to its configuration first
+func startTestNetwork(t *testing.T, options ...func(config *Config)) *Network {
+	cert, key, err := GenerateTLS()
+	if err != nil {
+		t.Fatalf("Expected GenerateTLS to succeed, got %v", err)
+	}
 	config := Config{
-		Port:     8080,
-		Timeout:  10 * time.Second,
+		Port:    0,
+		Timeout: 10 * time.Second,
 		TLS: TLSConfig{
-			Cert: "cert",
-			Key:  "key",
+			Cert: cert,
+			Key:  key,
 		},
 	}
+	for _, option := range options {
+		option(&config)
+	}
 	network := NewNetwork(config)
-	conn, err := net.Dial("tcp", fmt.Sprintf(":%d", config.Port))
+	err = network.Start()
 	if err != nil {
-		t.Errorf("Expected to be able to dial the network")
+		t.Fatalf("Expected Start to succeed, got %v", err)
 	}
-	defer conn.Close()
+	t.Cleanup(func() {
+		network.Stop(context.Background())
+	})
+	return network
+}
 
-	peer := Peer{
-		ID:      "peer-id",
-		Address: "peer-address",
-	}
-	err = json.NewEncoder(conn).Encode(peer)
+// freeAddress returns a loopback address with a free port
+func freeAddress(t *testing.T) (string, int) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		t.Errorf("Expected to be able to encode the peer")
+		t.Fatalf("Expected to find a free port, got %v", err)
 	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	return listener.Addr().String(), port
+}
 
-	// Check that the peer was added to the list of peers
-	network.Mutex.RLock()
-	defer network.Mutex.RUnlock()
-	for _, p := range network.Peers {
-		if p.ID == peer.ID && p.Address == peer.Address {
-			return
+// waitForPeer waits until the network is connected to a peer
+func waitForPeer(t *testing.T, network *Network, id string) Peer {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if peer, ok := network.Peer(id); ok {
+			return peer
 		}
+		time.Sleep(10 * time.Millisecond)
 	}
-	t.Errorf("Expected the peer to be added to the list of peers")
+	t.Fatalf("Expected %s to be connected to %s", network.Config.ID, id)
+	return Peer{}
+}
+
+func TestNetwork_handleConn(t *testing.T) {
+	server := startTestNetwork(t)
+	client := startTestNetwork(t)
+
+	_, err := client.Dial(Peer{Address: server.Listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("Expected to be able to dial the network, got %v", err)
+	}
+
+	// Check that the peer was added to the list of peers
+	waitForPeer(t, server, client.Config.ID)
 }
 
 func TestNetwork_Dial(t *testing.T) {
-	config := Config{
-		Port:     8080,
-		Timeout:  10 * time.Second,
-		TLS: TLSConfig{
-			Cert: "cert",
-			Key:  "key",
-		},
+	server := startTestNetwork(t)
+	client := startTestNetwork(t)
+
+	received := make(chan []byte, 1)
+	server.Handle(MsgTx, func(peer Peer, payload []byte) {
+		if peer.ID == client.Config.ID {
+			received <- payload
+		}
+	})
+	server.HandleStream(MsgSyncRequest, func(peer Peer, stream *Stream, payload []byte) {
+		stream.SendEnd(MsgSyncResponse, append([]byte("blocks from "), payload...))
+	})
+
+	peer, err := client.Dial(Peer{ID: server.Config.ID, Address: server.Listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("Expected to be able to dial the peer, got %v", err)
 	}
-	network := NewNetwork(config)
-	err := network.Start()
+	if peer.ID != server.Config.ID {
+		t.Errorf("Expected the peer to identify as %s, got %s", server.Config.ID, peer.ID)
+	}
+
+	err = client.Send(server.Config.ID, MsgTx, []byte("tx"))
 	if err != nil {
-		t.Errorf("Expected Start to return a non-nil error")
+		t.Errorf("Expected Send to succeed, got %v", err)
+	}
+	select {
+	case payload := <-received:
+		if string(payload) != "tx" {
+			t.Errorf("Expected the tx payload to be delivered, got %s", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the tx to be delivered")
 	}
 
-	peer := Peer{
-		ID:      "peer-id",
-		Address: "peer-address",
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	response, err := client.Request(ctx, server.Config.ID, MsgSyncRequest, []byte("height 1"))
+	if err != nil {
+		t.Fatalf("Expected Request to succeed, got %v", err)
+	}
+	if string(response) != "blocks from height 1" {
+		t.Errorf("Expected the sync response to be returned, got %s", response)
 	}
-	conn, err := network.Dial(peer)
+}
+
+func TestNetwork_DialWrongID(t *testing.T) {
+	server := startTestNetwork(t)
+	client := startTestNetwork(t)
+
+	_, err := client.Dial(Peer{ID: "someone-else", Address: server.Listener.Addr().String()})
+	if err == nil {
+		t.Errorf("Expected Dial to fail when the peer ID does not match")
+	}
+}
+
+func TestNetwork_Disconnect(t *testing.T) {
+	server := startTestNetwork(t)
+	client := startTestNetwork(t)
+
+	peer, err := client.Dial(Peer{Address: server.Listener.Addr().String()})
 	if err != nil {
-		t.Errorf("Expected to be able to dial the peer")
+		t.Fatalf("Expected to be able to dial the peer, got %v", err)
 	}
-	defer conn.Close()
+	remote := waitForPeer(t, server, client.Config.ID)
 
-	// Check that the peer's ID and address were sent
-	var receivedPeer Peer
-	err = json.NewDecoder(conn).Decode(&receivedPeer)
+	err = client.Disconnect(server.Config.ID, ReasonRequested, "bye")
 	if err != nil {
-		t.Errorf("Expected to be able to decode the peer")
+		t.Errorf("Expected Disconnect to succeed, got %v", err)
+	}
+	select {
+	case <-remote.Session.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the server session to close")
 	}
-	if receivedPeer.ID != peer.ID || receivedPeer.Address != peer.Address {
-		t.Errorf("Expected the peer's ID and address to be sent")
+	disconnect, ok := remote.Session.Err().(*DisconnectError)
+	if !ok || disconnect.Reason != ReasonRequested || disconnect.Message != "bye" {
+		t.Errorf("Expected the disconnect reason to be delivered, got %v", remote.Session.Err())
+	}
+	<-peer.Session.Done()
+	for i := 0; i < 100; i++ {
+		if _, ok := server.Peer(client.Config.ID); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
+	t.Errorf("Expected the peer to be removed after disconnecting")
 }
 
 func TestNetwork_MarshalJSON(t *testing.T) {
@@ -139,6 +237,23 @@ func TestNetwork_MarshalJSON(t *testing.T) {
 	}
 }
 
+func TestNetwork_MarshalJSONWhileConnecting(t *testing.T) {
+	a := startTestNetwork(t)
+	b := startTestNetwork(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if _, err := a.MarshalJSON(); err != nil {
+				t.Errorf("Expected MarshalJSON to succeed, got %v", err)
+				return
+			}
+		}
+	}()
+	connectTestNetworks(t, a, b)
+	<-done
+}
+
 func TestNetwork_UnmarshalJSON(t *testing.T) {
 	config := Config{
 		Port:     8080,
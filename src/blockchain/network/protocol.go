@@ -0,0 +1,277 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// HeaderSize is the size of a frame header in bytes
+const HeaderSize = 10
+
+// DefaultMaxFrameSize is the default maximum size of a frame payload
+const DefaultMaxFrameSize = 4 << 20
+
+// MessageType identifies the kind of message carried by a frame
+type MessageType uint8
+
+const (
+	// MsgHandshake identifies the node to a peer when a connection is opened
+	MsgHandshake MessageType = iota + 1
+
+	// MsgPing requests a MsgPong carrying the same payload
+	MsgPing
+
+	// MsgPong answers a MsgPing
+	MsgPong
+
+	// MsgTx carries a transaction
+	MsgTx
+
+	// MsgBlock carries a block
+	MsgBlock
+
+	// MsgVote carries a consensus vote
+	MsgVote
+
+	// MsgSyncRequest requests chain data from a peer
+	MsgSyncRequest
+
+	// MsgSyncResponse answers a MsgSyncRequest
+	MsgSyncResponse
+
+	// MsgDisconnect tells a peer why the connection is being closed
+	MsgDisconnect
+
+	// MsgPeerExchange requests, and answers with, known peer addresses
+	MsgPeerExchange
+
+	// MsgGossip carries a gossiped message
+	MsgGossip
+
+	// MsgDHT carries a DHT request or response
+	MsgDHT
+)
+
+// String returns the name of the message type
+func (t MessageType) String() string {
+	switch t {
+	case MsgHandshake:
+		return "handshake"
+	case MsgPing:
+		return "ping"
+	case MsgPong:
+		return "pong"
+	case MsgTx:
+		return "tx"
+	case MsgBlock:
+		return "block"
+	case MsgVote:
+		return "vote"
+	case MsgSyncRequest:
+		return "sync-request"
+	case MsgSyncResponse:
+		return "sync-response"
+	case MsgDisconnect:
+		return "disconnect"
+	case MsgPeerExchange:
+		return "peer-exchange"
+	case MsgGossip:
+		return "gossip"
+	case MsgDHT:
+		return "dht"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+// FlagEnd marks the last frame sent on a stream
+const FlagEnd uint8 = 1 << 0
+
+// FlagCompressed marks a frame whose payload is compressed with the
+// algorithm negotiated in the handshake
+const FlagCompressed uint8 = 1 << 1
+
+// ErrFrameTooLarge is returned for frames larger than the maximum frame size
+var ErrFrameTooLarge = errors.New("frame exceeds maximum size")
+
+// Frame represents a single message on the wire. A frame is encoded as a
+// 4 byte payload length, a 1 byte message type, a 1 byte flags field and a
+// 4 byte stream ID, all big endian, followed by the payload. Stream 0 carries
+// connection level messages; other streams carry request/response exchanges.
+type Frame struct {
+	// Type is the type of the message
+	Type MessageType
+
+	// Flags are the frame flags
+	Flags uint8
+
+	// Stream is the ID of the stream the frame belongs to
+	Stream uint32
+
+	// Payload is the message payload
+	Payload []byte
+}
+
+// WriteFrame writes a frame to w in a single write
+func WriteFrame(w io.Writer, frame Frame, maxFrameSize int) error {
+	if len(frame.Payload) > maxFrameSize {
+		return ErrFrameTooLarge
+	}
+	buf := make([]byte, HeaderSize+len(frame.Payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(frame.Payload)))
+	buf[4] = byte(frame.Type)
+	buf[5] = frame.Flags
+	binary.BigEndian.PutUint32(buf[6:10], frame.Stream)
+	copy(buf[HeaderSize:], frame.Payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadFrame reads a frame from r
+func ReadFrame(r io.Reader, maxFrameSize int) (Frame, error) {
+	var header [HeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Frame{}, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if uint64(length) > uint64(maxFrameSize) {
+		return Frame{}, ErrFrameTooLarge
+	}
+	frame := Frame{
+		Type:    MessageType(header[4]),
+		Flags:   header[5],
+		Stream:  binary.BigEndian.Uint32(header[6:10]),
+		Payload: make([]byte, length),
+	}
+	if _, err := io.ReadFull(r, frame.Payload); err != nil {
+		return Frame{}, err
+	}
+	return frame, nil
+}
+
+// Handshake is the payload of a MsgHandshake
+type Handshake struct {
+	// ID is the ID of the sending node
+	ID string `json:"id"`
+
+	// Address is the address the sending node accepts connections on
+	Address string `json:"address,omitempty"`
+
+	// Version is the newest protocol version the sending node speaks
+	Version uint32 `json:"version"`
+
+	// MinVersion is the oldest protocol version the sending node speaks
+	MinVersion uint32 `json:"min_version"`
+
+	// ChainID is the ID of the chain the sending node is on
+	ChainID string `json:"chain_id,omitempty"`
+
+	// GenesisHash is the hash of the genesis block of the chain
+	GenesisHash string `json:"genesis_hash,omitempty"`
+
+	// Capabilities are the optional features the sending node supports
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// Compression lists the compression algorithms the sending node
+	// supports, in order of preference
+	Compression []string `json:"compression,omitempty"`
+}
+
+// DisconnectReason is the reason a connection was closed
+type DisconnectReason uint8
+
+const (
+	// ReasonRequested is used when a node closes a connection on purpose
+	ReasonRequested DisconnectReason = iota
+
+	// ReasonProtocolError is used when a peer violates the protocol
+	ReasonProtocolError
+
+	// ReasonDuplicate is used when a peer is already connected
+	ReasonDuplicate
+
+	// ReasonShutdown is used when a node is shutting down
+	ReasonShutdown
+
+	// ReasonUnauthorized is used when a peer fails authentication
+	ReasonUnauthorized
+
+	// ReasonTooManyPeers is used when a node has no room for another peer
+	ReasonTooManyPeers
+
+	// ReasonTimeout is used when a peer stops answering keepalives
+	ReasonTimeout
+
+	// ReasonBanned is used when a peer is banned
+	ReasonBanned
+
+	// ReasonIncompatibleVersion is used when no protocol version is
+	// supported by both sides
+	ReasonIncompatibleVersion
+
+	// ReasonWrongChain is used when a peer is on a different chain
+	ReasonWrongChain
+)
+
+// String returns a description of the disconnect reason
+func (r DisconnectReason) String() string {
+	switch r {
+	case ReasonRequested:
+		return "requested"
+	case ReasonProtocolError:
+		return "protocol error"
+	case ReasonDuplicate:
+		return "duplicate connection"
+	case ReasonShutdown:
+		return "shutdown"
+	case ReasonUnauthorized:
+		return "unauthorized"
+	case ReasonTooManyPeers:
+		return "too many peers"
+	case ReasonTimeout:
+		return "timeout"
+	case ReasonBanned:
+		return "banned"
+	case ReasonIncompatibleVersion:
+		return "incompatible protocol version"
+	case ReasonWrongChain:
+		return "wrong chain"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(r))
+	}
+}
+
+// DisconnectError is the error of a session closed by the remote peer
+type DisconnectError struct {
+	// Reason is the reason given by the peer
+	Reason DisconnectReason
+
+	// Message is an optional description given by the peer
+	Message string
+}
+
+// Error implements the error interface
+func (e *DisconnectError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("peer disconnected: %s", e.Reason)
+	}
+	return fmt.Sprintf("peer disconnected: %s: %s", e.Reason, e.Message)
+}
+
+// encodeDisconnect encodes the payload of a MsgDisconnect
+func encodeDisconnect(reason DisconnectReason, message string) []byte {
+	return append([]byte{byte(reason)}, message...)
+}
+
+// decodeDisconnect decodes the payload of a MsgDisconnect
+func decodeDisconnect(payload []byte) *DisconnectError {
+	if len(payload) == 0 {
+		return &DisconnectError{Reason: ReasonRequested}
+	}
+	return &DisconnectError{
+		Reason:  DisconnectReason(payload[0]),
+		Message: string(payload[1:]),
+	}
+}
@@ -0,0 +1,57 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFrame_WriteRead(t *testing.T) {
+	var buf bytes.Buffer
+	frame := Frame{
+		Type:    MsgBlock,
+		Flags:   FlagEnd,
+		Stream:  7,
+		Payload: []byte("block"),
+	}
+	err := WriteFrame(&buf, frame, DefaultMaxFrameSize)
+	if err != nil {
+		t.Fatalf("Expected WriteFrame to succeed, got %v", err)
+	}
+	if buf.Len() != HeaderSize+len(frame.Payload) {
+		t.Errorf("Expected the frame to be %d bytes, got %d", HeaderSize+len(frame.Payload), buf.Len())
+	}
+	read, err := ReadFrame(&buf, DefaultMaxFrameSize)
+	if err != nil {
+		t.Fatalf("Expected ReadFrame to succeed, got %v", err)
+	}
+	if read.Type != frame.Type || read.Flags != frame.Flags || read.Stream != frame.Stream || !bytes.Equal(read.Payload, frame.Payload) {
+		t.Errorf("Expected the frame to round trip, got %+v", read)
+	}
+}
+
+func TestFrame_MaxSize(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteFrame(&buf, Frame{Type: MsgTx, Payload: make([]byte, 11)}, 10)
+	if err != ErrFrameTooLarge {
+		t.Errorf("Expected WriteFrame to reject a large frame, got %v", err)
+	}
+
+	err = WriteFrame(&buf, Frame{Type: MsgTx, Payload: make([]byte, 11)}, 100)
+	if err != nil {
+		t.Fatalf("Expected WriteFrame to succeed, got %v", err)
+	}
+	_, err = ReadFrame(&buf, 10)
+	if err != ErrFrameTooLarge {
+		t.Errorf("Expected ReadFrame to reject a large frame, got %v", err)
+	}
+}
+
+func TestDisconnect_EncodeDecode(t *testing.T) {
+	disconnect := decodeDisconnect(encodeDisconnect(ReasonShutdown, "restarting"))
+	if disconnect.Reason != ReasonShutdown || disconnect.Message != "restarting" {
+		t.Errorf("Expected the disconnect to round trip, got %+v", disconnect)
+	}
+	if disconnect.Error() != "peer disconnected: shutdown: restarting" {
+		t.Errorf("Unexpected disconnect error: %s", disconnect.Error())
+	}
+}
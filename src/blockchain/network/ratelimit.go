@@ -0,0 +1,91 @@
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimit represents a token bucket rate limit
+type RateLimit struct {
+	// Rate is the number of messages allowed per second
+	Rate float64
+
+	// Burst is the number of messages allowed at once
+	Burst int
+}
+
+// DefaultRateLimits are the per-peer rate limits used when none are
+// configured
+var DefaultRateLimits = map[MessageType]RateLimit{
+	MsgTx:           {Rate: 100, Burst: 200},
+	MsgBlock:        {Rate: 10, Burst: 20},
+	MsgVote:         {Rate: 100, Burst: 200},
+	MsgSyncRequest:  {Rate: 10, Burst: 20},
+	MsgPeerExchange: {Rate: 1, Burst: 5},
+	MsgGossip:       {Rate: 200, Burst: 400},
+	MsgDHT:          {Rate: 50, Burst: 100},
+	MsgPing:         {Rate: 1, Burst: 10},
+}
+
+// TokenBucket is a token bucket rate limiter
+type TokenBucket struct {
+	limit  RateLimit
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a full token bucket
+func NewTokenBucket(limit RateLimit) *TokenBucket {
+	return &TokenBucket{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket if one is available
+func (b *TokenBucket) Allow() bool {
+	return b.AllowN(1)
+}
+
+// AllowN takes n tokens from the bucket if they are available
+func (b *TokenBucket) AllowN(n float64) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// peerLimiter applies per message type rate limits to one peer
+type peerLimiter struct {
+	buckets map[MessageType]*TokenBucket
+}
+
+// newPeerLimiter returns a limiter for the given limits
+func newPeerLimiter(limits map[MessageType]RateLimit) *peerLimiter {
+	buckets := make(map[MessageType]*TokenBucket, len(limits))
+	for t, limit := range limits {
+		buckets[t] = NewTokenBucket(limit)
+	}
+	return &peerLimiter{buckets: buckets}
+}
+
+// allow reports whether a message of type t is within its limit. Message
+// types without a limit are always allowed.
+func (l *peerLimiter) allow(t MessageType) bool {
+	bucket, ok := l.buckets[t]
+	if !ok {
+		return true
+	}
+	return bucket.Allow()
+}
@@ -0,0 +1,39 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket_Allow(t *testing.T) {
+	bucket := NewTokenBucket(RateLimit{Rate: 20, Burst: 3})
+	for i := 0; i < 3; i++ {
+		if !bucket.Allow() {
+			t.Fatalf("Expected message %d to be within the burst", i)
+		}
+	}
+	if bucket.Allow() {
+		t.Errorf("Expected the bucket to be empty after the burst")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if !bucket.Allow() {
+		t.Errorf("Expected the bucket to refill over time")
+	}
+}
+
+func TestPeerLimiter_Allow(t *testing.T) {
+	limiter := newPeerLimiter(map[MessageType]RateLimit{
+		MsgTx: {Rate: 1, Burst: 1},
+	})
+	if !limiter.allow(MsgTx) {
+		t.Errorf("Expected the first tx to be allowed")
+	}
+	if limiter.allow(MsgTx) {
+		t.Errorf("Expected the second tx to be rate limited")
+	}
+	for i := 0; i < 10; i++ {
+		if !limiter.allow(MsgBlock) {
+			t.Errorf("Expected message types without a limit to be allowed")
+		}
+	}
+}
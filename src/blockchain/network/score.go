@@ -0,0 +1,237 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// DefaultBanThreshold is the default score at which a peer is banned
+const DefaultBanThreshold = -100
+
+// DefaultBanDuration is the default duration of a temporary ban
+const DefaultBanDuration = time.Hour
+
+// DefaultMaxTemporaryBans is the default number of temporary bans after
+// which a peer is banned permanently
+const DefaultMaxTemporaryBans = 3
+
+// ScoreHalfLife is the time it takes for a peer's score to decay halfway
+// back to zero
+const ScoreHalfLife = 10 * time.Minute
+
+// maxScore bounds the credit a peer can build up with useful messages
+const maxScore = 100
+
+// neutralScore is how close to zero the score of a disconnected peer must
+// decay before it is forgotten
+const neutralScore = 1
+
+// ErrPeerBanned is returned when connecting to a banned peer
+var ErrPeerBanned = errors.New("peer is banned")
+
+// ErrNotBanned is returned when lifting a ban on a peer that is not banned
+var ErrNotBanned = errors.New("peer is not banned")
+
+// ScoreEvent is something a peer did that changes its score
+type ScoreEvent int
+
+const (
+	// EventUsefulMessage is a valid message that was new to this node
+	EventUsefulMessage ScoreEvent = iota
+
+	// EventInvalidMessage is a message that failed to decode or validate
+	EventInvalidMessage
+
+	// EventProtocolViolation is a frame that breaks the wire protocol
+	EventProtocolViolation
+
+	// EventRateLimited is a message that exceeded its rate limit
+	EventRateLimited
+)
+
+// String returns the name of the event
+func (e ScoreEvent) String() string {
+	switch e {
+	case EventUsefulMessage:
+		return "useful message"
+	case EventInvalidMessage:
+		return "invalid message"
+	case EventProtocolViolation:
+		return "protocol violation"
+	case EventRateLimited:
+		return "rate limited"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(e))
+	}
+}
+
+// Delta returns the change in score caused by the event
+func (e ScoreEvent) Delta() float64 {
+	switch e {
+	case EventUsefulMessage:
+		return 1
+	case EventInvalidMessage:
+		return -10
+	case EventProtocolViolation:
+		return -50
+	case EventRateLimited:
+		return -5
+	default:
+		return 0
+	}
+}
+
+// peerScore is the score of a peer, decaying towards zero over time
+type peerScore struct {
+	value   float64
+	updated time.Time
+}
+
+// at returns the score decayed to the given time
+func (s *peerScore) at(now time.Time) float64 {
+	elapsed := now.Sub(s.updated)
+	if elapsed <= 0 {
+		return s.value
+	}
+	return s.value * math.Pow(0.5, float64(elapsed)/float64(ScoreHalfLife))
+}
+
+// applyScoreDefaults fills in the scoring and banning defaults
+func (c *Config) applyScoreDefaults() {
+	if c.BanThreshold >= 0 {
+		c.BanThreshold = DefaultBanThreshold
+	}
+	if c.BanDuration <= 0 {
+		c.BanDuration = DefaultBanDuration
+	}
+	if c.MaxTemporaryBans <= 0 {
+		c.MaxTemporaryBans = DefaultMaxTemporaryBans
+	}
+	if c.RateLimits == nil {
+		// Copied so that networks do not share the package map
+		c.RateLimits = make(map[MessageType]RateLimit, len(DefaultRateLimits))
+		for t, limit := range DefaultRateLimits {
+			c.RateLimits[t] = limit
+		}
+	}
+}
+
+// Score returns the current score of a peer
+func (n *Network) Score(id string) float64 {
+	n.statusMutex.RLock()
+	defer n.statusMutex.RUnlock()
+	score, ok := n.scores[id]
+	if !ok {
+		return 0
+	}
+	return score.at(time.Now())
+}
+
+// ReportPeer records an event for a peer and bans the peer once its score
+// falls to the ban threshold. It returns the peer's new score. Events for a
+// peer that is already banned are ignored.
+func (n *Network) ReportPeer(id string, event ScoreEvent) float64 {
+	if n.IsBanned(id) {
+		return n.Score(id)
+	}
+	now := time.Now()
+	n.statusMutex.Lock()
+	if n.scores == nil {
+		n.scores = make(map[string]*peerScore)
+	}
+	score, ok := n.scores[id]
+	if !ok {
+		n.pruneScoresLocked(now)
+		score = &peerScore{}
+		n.scores[id] = score
+	}
+	value := score.at(now) + event.Delta()
+	if value > maxScore {
+		value = maxScore
+	}
+	score.value = value
+	score.updated = now
+	n.statusMutex.Unlock()
+
+	if value > n.Config.BanThreshold {
+		return value
+	}
+
+	// Only a new ban counts towards escalation, so a burst of reports for a
+	// peer that is already banned does not make the ban permanent. Repeat
+	// offenders are banned permanently.
+	reason := fmt.Sprintf("score %.0f after %s", value, event)
+	if _, ok := n.bans().Escalate(id, n.Config.BanDuration, n.Config.MaxTemporaryBans, reason); !ok {
+		return value
+	}
+	if err := n.banned(id, reason); err != nil {
+		log.Printf("failed to save bans: %v", err)
+	}
+	return value
+}
+
+// pruneScoresLocked forgets the scores of peers that are not connected once
+// they have decayed to neutral. The caller must hold the status mutex.
+func (n *Network) pruneScoresLocked(now time.Time) {
+	for id, score := range n.scores {
+		if _, connected := n.statuses[id]; connected {
+			continue
+		}
+		if math.Abs(score.at(now)) < neutralScore {
+			delete(n.scores, id)
+		}
+	}
+}
+
+// BanPeer bans a peer for a duration, disconnecting it if it is connected.
+// A zero duration bans the peer permanently.
+func (n *Network) BanPeer(id string, duration time.Duration, reason string) error {
+	n.bans().Ban(id, duration, reason)
+	return n.banned(id, reason)
+}
+
+// banned resets the score of a peer that was just banned, disconnects it and
+// saves the ban list
+func (n *Network) banned(id string, reason string) error {
+	n.statusMutex.Lock()
+	delete(n.scores, id)
+	n.statusMutex.Unlock()
+
+	if peer, ok := n.Peer(id); ok {
+		log.Printf("banning peer %s: %s", id, reason)
+		peer.Session.Disconnect(ReasonBanned, reason)
+	}
+	return n.bans().Save()
+}
+
+// Unban lifts the ban on a peer
+func (n *Network) Unban(id string) error {
+	if !n.bans().Unban(id) {
+		return ErrNotBanned
+	}
+	return n.bans().Save()
+}
+
+// Bans returns the bans currently in effect
+func (n *Network) Bans() []Ban {
+	return n.bans().Active()
+}
+
+// IsBanned reports whether a peer is currently banned
+func (n *Network) IsBanned(id string) bool {
+	return n.bans().IsBanned(id)
+}
+
+// bans returns the ban list, creating it if the network was not created
+// with NewNetwork
+func (n *Network) bans() *BanList {
+	n.banOnce.Do(func() {
+		if n.BanList == nil {
+			n.BanList = NewBanList(n.Config.BanListPath)
+		}
+	})
+	return n.BanList
+}
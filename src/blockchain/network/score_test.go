@@ -0,0 +1,248 @@
+package network
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPeerScore_Decay(t *testing.T) {
+	now := time.Now()
+	score := peerScore{value: -80, updated: now}
+	if decayed := score.at(now.Add(ScoreHalfLife)); decayed < -40.01 || decayed > -39.99 {
+		t.Errorf("Expected the score to halve after one half life, got %f", decayed)
+	}
+}
+
+func TestNetwork_ReportPeer(t *testing.T) {
+	network := NewNetwork(Config{})
+	for i := 0; i < 5; i++ {
+		network.ReportPeer("peer", EventUsefulMessage)
+	}
+	if score := network.Score("peer"); score < 4.9 || score > 5 {
+		t.Errorf("Expected a score of 5, got %f", score)
+	}
+
+	network.ReportPeer("peer", EventProtocolViolation)
+	network.ReportPeer("peer", EventProtocolViolation)
+	if network.IsBanned("peer") {
+		t.Fatalf("Expected the peer not to be banned above the threshold")
+	}
+	network.ReportPeer("peer", EventInvalidMessage)
+	if !network.IsBanned("peer") {
+		t.Fatalf("Expected the peer to be banned at the threshold")
+	}
+	bans := network.Bans()
+	if len(bans) != 1 || bans[0].Permanent {
+		t.Errorf("Expected a temporary ban, got %+v", bans)
+	}
+	if network.Score("peer") != 0 {
+		t.Errorf("Expected the score to reset after a ban")
+	}
+}
+
+func TestNetwork_ReportPeerEscalates(t *testing.T) {
+	network := NewNetwork(Config{MaxTemporaryBans: 2, BanDuration: time.Millisecond})
+	for ban := 0; ban < 2; ban++ {
+		for i := 0; i < 3; i++ {
+			network.ReportPeer("peer", EventProtocolViolation)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	bans := network.Bans()
+	if len(bans) != 1 || !bans[0].Permanent {
+		t.Errorf("Expected a repeat offender to be banned permanently, got %+v", bans)
+	}
+}
+
+func TestNetwork_ReportPeerBanned(t *testing.T) {
+	network := NewNetwork(Config{MaxTemporaryBans: 3})
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			network.ReportPeer("peer", EventProtocolViolation)
+		}()
+	}
+	wg.Wait()
+
+	// Reports for a peer that is already banned do not escalate the ban
+	bans := network.Bans()
+	if len(bans) != 1 || bans[0].Permanent || bans[0].Count != 1 {
+		t.Errorf("Expected a single temporary ban, got %+v", bans)
+	}
+}
+
+func TestNetwork_BanPeer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	server := startTestNetwork(t, func(config *Config) {
+		config.BanListPath = path
+	})
+	client := startTestNetwork(t)
+	connectTestNetworks(t, client, server)
+
+	if err := server.BanPeer(client.Config.ID, time.Hour, "test"); err != nil {
+		t.Fatalf("Expected BanPeer to succeed, got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, clientSide := client.Peer(server.Config.ID)
+		_, serverSide := server.Peer(client.Config.ID)
+		if !clientSide && !serverSide {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the banned peer to be disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, err := client.Dial(Peer{Address: server.Listener.Addr().String()})
+	disconnect, ok := err.(*DisconnectError)
+	if !ok || disconnect.Reason != ReasonBanned {
+		t.Errorf("Expected the banned peer to be rejected, got %v", err)
+	}
+	if _, err := server.Dial(Peer{ID: client.Config.ID, Address: client.Listener.Addr().String()}); err != ErrPeerBanned {
+		t.Errorf("Expected dialing a banned peer to fail, got %v", err)
+	}
+
+	// The ban is persisted across restarts
+	restarted := NewNetwork(Config{BanListPath: path})
+	if err := restarted.bans().Load(); err != nil {
+		t.Fatalf("Expected Load to succeed, got %v", err)
+	}
+	if !restarted.IsBanned(client.Config.ID) {
+		t.Errorf("Expected the ban to be persisted")
+	}
+
+	if err := server.Unban(client.Config.ID); err != nil {
+		t.Fatalf("Expected Unban to succeed, got %v", err)
+	}
+	if err := server.Unban(client.Config.ID); err != ErrNotBanned {
+		t.Errorf("Expected ErrNotBanned, got %v", err)
+	}
+	connectTestNetworks(t, client, server)
+}
+
+func TestNetwork_RateLimit(t *testing.T) {
+	server := startTestNetwork(t, func(config *Config) {
+		config.RateLimits = map[MessageType]RateLimit{MsgTx: {Rate: 0.001, Burst: 2}}
+	})
+	client := startTestNetwork(t)
+	connectTestNetworks(t, client, server)
+
+	received := make(chan struct{}, 10)
+	server.Handle(MsgTx, func(peer Peer, payload []byte) {
+		received <- struct{}{}
+	})
+	for i := 0; i < 5; i++ {
+		if err := client.Send(server.Config.ID, MsgTx, []byte("tx")); err != nil {
+			t.Fatalf("Expected Send to succeed, got %v", err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for server.Score(client.Config.ID) > -14 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected rate limited messages to be penalised, got score %f", server.Score(client.Config.ID))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(received) != 2 {
+		t.Errorf("Expected 2 messages within the limit to be handled, got %d", len(received))
+	}
+}
+
+func TestNetwork_RateLimitPingsAndStreams(t *testing.T) {
+	server := startTestNetwork(t, func(config *Config) {
+		config.RateLimits = map[MessageType]RateLimit{
+			MsgSyncRequest: {Rate: 0.001, Burst: 2},
+			MsgPing:        {Rate: 0.001, Burst: 1},
+		}
+	})
+	client := startTestNetwork(t)
+	connectTestNetworks(t, client, server)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Every frame of a stream is charged, not only the first
+	frames := make(chan struct{}, 10)
+	server.HandleStream(MsgSyncRequest, func(peer Peer, stream *Stream, payload []byte) {
+		frames <- struct{}{}
+		for {
+			if _, err := stream.Recv(ctx); err != nil {
+				return
+			}
+			frames <- struct{}{}
+		}
+	})
+	peer, _ := client.Peer(server.Config.ID)
+	stream, err := peer.Session.OpenStream()
+	if err != nil {
+		t.Fatalf("Expected OpenStream to succeed, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		stream.Send(MsgSyncRequest, []byte("blocks"))
+		select {
+		case <-frames:
+		case <-ctx.Done():
+			t.Fatalf("Expected stream frame %d within the limit to be handled", i)
+		}
+	}
+	if score := server.Score(client.Config.ID); score != 0 {
+		t.Errorf("Expected frames within the limit not to be penalised, got %f", score)
+	}
+	stream.Send(MsgSyncRequest, []byte("blocks"))
+	waitForScore(t, server, client.Config.ID, -4)
+
+	// Pings are answered in the read loop and charged there
+	if _, err := peer.Session.Ping(ctx); err != nil {
+		t.Fatalf("Expected the first ping to be answered, got %v", err)
+	}
+	pingCtx, pingCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer pingCancel()
+	if _, err := peer.Session.Ping(pingCtx); err == nil {
+		t.Errorf("Expected a ping beyond the limit to go unanswered")
+	}
+	waitForScore(t, server, client.Config.ID, -9)
+}
+
+// waitForScore waits until a peer's score falls to below a value
+func waitForScore(t *testing.T, network *Network, id string, below float64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for network.Score(id) > below {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the score to fall below %f, got %f", below, network.Score(id))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNetwork_ScoresPruned(t *testing.T) {
+	network := NewNetwork(Config{})
+	network.ReportPeer("decayed", EventInvalidMessage)
+	network.ReportPeer("penalised", EventInvalidMessage)
+	network.statusMutex.Lock()
+	network.scores["decayed"].updated = time.Now().Add(-10 * ScoreHalfLife)
+	network.statusMutex.Unlock()
+
+	// A disconnect forgets the scores that have decayed to neutral
+	network.removePeer(Peer{ID: "decayed"})
+	network.statusMutex.RLock()
+	_, decayed := network.scores["decayed"]
+	_, penalised := network.scores["penalised"]
+	network.statusMutex.RUnlock()
+	if decayed || !penalised {
+		t.Errorf("Expected only the neutral score to be forgotten, got decayed %t penalised %t", decayed, penalised)
+	}
+}
+
+func TestNetwork_RateLimitsNotShared(t *testing.T) {
+	network := NewNetwork(Config{})
+	network.Config.RateLimits[MsgTx] = RateLimit{Rate: 1, Burst: 1}
+	if DefaultRateLimits[MsgTx].Burst == 1 {
+		t.Errorf("Expected a network's rate limits not to change the defaults")
+	}
+}
@@ -0,0 +1,498 @@
+package network
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrSessionClosed is returned for operations on a closed session
+var ErrSessionClosed = errors.New("session closed")
+
+// ErrStreamClosed is returned when receiving on a stream that was closed
+// locally
+var ErrStreamClosed = errors.New("stream closed")
+
+// ErrStreamReset is returned when receiving on a stream that was reset
+// because its receive buffer overflowed or the peer exceeded its rate limit
+var ErrStreamReset = errors.New("stream reset")
+
+// streamBuffer is the number of frames buffered per stream
+const streamBuffer = 16
+
+// DefaultMaxStreams is the default number of streams the peer may have open
+// at once
+const DefaultMaxStreams = 64
+
+// Session multiplexes streams of frames over a single connection
+type Session struct {
+	// OnMessage is called for frames received on stream 0
+	OnMessage func(frame Frame)
+
+	// OnStream is called with the first frame of a stream opened by the
+	// peer. The stream is closed when OnStream returns.
+	OnStream func(stream *Stream, frame Frame)
+
+	// Allow is called for each frame the peer sends on stream 0 or on a
+	// stream it opened, before the frame is handled. A frame it rejects is
+	// dropped, along with the rest of its stream.
+	Allow func(frame Frame) bool
+
+	// WriteTimeout is the timeout for writing a frame
+	WriteTimeout time.Duration
+
+	// MaxStreams is the number of streams the peer may have open at once.
+	// A stream stops counting once the peer ends it or its handler returns.
+	// Streams opened beyond it are dropped.
+	MaxStreams int
+
+	// Compression is the algorithm large payloads are compressed with. It
+	// must be set before the session runs.
+	Compression string
+
+	// Meter counts the session's traffic, if set
+	Meter *Meter
+
+	// Upload shapes the frames the session sends, if set
+	Upload *Shaper
+
+	// Download shapes the frames the session receives, if set
+	Download *Shaper
+
+	conn         net.Conn
+	maxFrameSize int
+
+	writeMutex sync.Mutex
+
+	mutex   sync.Mutex
+	streams map[uint32]*Stream
+	nextID  uint32
+	pings   map[uint64]chan struct{}
+
+	// lastRemote is the highest stream ID the peer has opened. The peer
+	// allocates IDs in increasing order, so lower IDs that are not open
+	// belong to streams that were already closed.
+	lastRemote    uint32
+	remoteStreams int
+
+	// handlers tracks the OnStream calls in flight
+	handlers sync.WaitGroup
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// NewSession returns a new session over conn. The side that opened the
+// connection is the initiator and uses odd stream IDs; the other side uses
+// even stream IDs.
+func NewSession(conn net.Conn, initiator bool, maxFrameSize int) *Session {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	nextID := uint32(2)
+	if initiator {
+		nextID = 1
+	}
+	return &Session{
+		conn:         conn,
+		maxFrameSize: maxFrameSize,
+		streams:      make(map[uint32]*Stream),
+		nextID:       nextID,
+		pings:        make(map[uint64]chan struct{}),
+		MaxStreams:   DefaultMaxStreams,
+		closed:       make(chan struct{}),
+	}
+}
+
+// Run reads frames until the session is closed and returns the reason. It
+// waits for the OnStream calls in flight before returning.
+func (s *Session) Run() error {
+	defer s.handlers.Wait()
+	for {
+		frame, err := ReadFrame(s.conn, s.maxFrameSize)
+		if err != nil {
+			if err == io.EOF {
+				err = ErrSessionClosed
+			}
+			s.closeWithError(err)
+			return s.Err()
+		}
+		size := HeaderSize + len(frame.Payload)
+		if s.Meter != nil {
+			s.Meter.Record(frame.Type, size, true)
+		}
+		// Delaying the next read pushes back on the sender
+		if !s.Download.Wait(s.closed, priorityOf(frame.Type), size) {
+			return s.Err()
+		}
+		if frame.Flags&FlagCompressed != 0 {
+			frame.Payload, err = decompress(s.Compression, frame.Payload, s.maxFrameSize)
+			if err != nil {
+				s.closeWithError(err)
+				return s.Err()
+			}
+			frame.Flags &^= FlagCompressed
+		}
+		s.dispatch(frame)
+	}
+}
+
+// dispatch routes a frame to its stream or handler
+func (s *Session) dispatch(frame Frame) {
+	if s.Allow != nil && (frame.Stream == 0 || s.isRemote(frame.Stream)) && !s.Allow(frame) {
+		if frame.Stream != 0 {
+			s.reject(frame.Stream)
+		}
+		return
+	}
+	if frame.Stream == 0 {
+		switch frame.Type {
+		case MsgPing:
+			s.writeFrame(Frame{Type: MsgPong, Payload: frame.Payload})
+		case MsgPong:
+			if len(frame.Payload) == 8 {
+				// Forgetting the ping before closing its channel drops
+				// repeated pongs
+				key := binary.BigEndian.Uint64(frame.Payload)
+				s.mutex.Lock()
+				ch, ok := s.pings[key]
+				delete(s.pings, key)
+				s.mutex.Unlock()
+				if ok {
+					close(ch)
+				}
+			}
+		case MsgDisconnect:
+			s.closeWithError(decodeDisconnect(frame.Payload))
+		default:
+			if s.OnMessage != nil {
+				s.OnMessage(frame)
+			}
+		}
+		return
+	}
+
+	s.mutex.Lock()
+	stream, ok := s.streams[frame.Stream]
+	opened := false
+	if !ok && s.isRemote(frame.Stream) && frame.Stream > s.lastRemote {
+		s.lastRemote = frame.Stream
+		if s.remoteStreams < s.MaxStreams {
+			stream = newStream(s, frame.Stream)
+			s.streams[frame.Stream] = stream
+			s.remoteStreams++
+			opened = true
+		}
+	}
+	s.mutex.Unlock()
+	if stream == nil {
+		// Late frame for a stream that was already closed, or a stream
+		// beyond the limit
+		return
+	}
+
+	if opened {
+		if frame.Flags&FlagEnd != 0 {
+			stream.end()
+		}
+		if s.OnStream == nil {
+			stream.Close()
+			return
+		}
+		s.handlers.Add(1)
+		go func() {
+			defer s.handlers.Done()
+			defer stream.Close()
+			s.OnStream(stream, frame)
+		}()
+		return
+	}
+	stream.deliver(frame)
+}
+
+// reject resets a stream opened by the peer after one of its frames was not
+// allowed. A stream that is not open yet is marked as seen so its later
+// frames are dropped as well.
+func (s *Session) reject(id uint32) {
+	s.mutex.Lock()
+	stream := s.streams[id]
+	if stream == nil && id > s.lastRemote {
+		s.lastRemote = id
+	}
+	s.mutex.Unlock()
+	if stream != nil {
+		stream.closeWithError(ErrStreamReset)
+	}
+}
+
+// isRemote reports whether a stream ID belongs to the remote side
+func (s *Session) isRemote(id uint32) bool {
+	return id%2 != s.nextID%2
+}
+
+// Send sends a message on stream 0
+func (s *Session) Send(t MessageType, payload []byte) error {
+	return s.writeFrame(Frame{Type: t, Payload: payload})
+}
+
+// SendPriority sends a message on stream 0 with a priority other than the
+// message type's
+func (s *Session) SendPriority(t MessageType, priority Priority, payload []byte) error {
+	return s.writeFramePriority(Frame{Type: t, Payload: payload}, priority)
+}
+
+// OpenStream opens a new stream to the peer
+func (s *Session) OpenStream() (*Stream, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-s.closed:
+		return nil, ErrSessionClosed
+	default:
+	}
+	stream := newStream(s, s.nextID)
+	s.streams[stream.ID] = stream
+	s.nextID += 2
+	return stream, nil
+}
+
+// Request sends a message on a new stream and waits for the first reply
+func (s *Session) Request(ctx context.Context, t MessageType, payload []byte) (Frame, error) {
+	stream, err := s.OpenStream()
+	if err != nil {
+		return Frame{}, err
+	}
+	defer stream.Close()
+	if err := stream.Send(t, payload); err != nil {
+		return Frame{}, err
+	}
+	return stream.Recv(ctx)
+}
+
+// Ping sends a ping and returns the round trip time
+func (s *Session) Ping(ctx context.Context) (time.Duration, error) {
+	var nonce [8]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return 0, err
+	}
+	key := binary.BigEndian.Uint64(nonce[:])
+	ch := make(chan struct{})
+	s.mutex.Lock()
+	s.pings[key] = ch
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.pings, key)
+		s.mutex.Unlock()
+	}()
+
+	start := time.Now()
+	if err := s.writeFrame(Frame{Type: MsgPing, Payload: nonce[:]}); err != nil {
+		return 0, err
+	}
+	select {
+	case <-ch:
+		return time.Since(start), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-s.closed:
+		return 0, s.Err()
+	}
+}
+
+// Disconnect tells the peer why the session is ending and closes it
+func (s *Session) Disconnect(reason DisconnectReason, message string) error {
+	err := s.writeFrame(Frame{Type: MsgDisconnect, Payload: encodeDisconnect(reason, message)})
+	s.Close()
+	return err
+}
+
+// Close closes the session and its connection
+func (s *Session) Close() error {
+	s.closeWithError(ErrSessionClosed)
+	return nil
+}
+
+// Done returns a channel that is closed when the session ends
+func (s *Session) Done() <-chan struct{} {
+	return s.closed
+}
+
+// Err returns the reason the session ended, or nil if it is open
+func (s *Session) Err() error {
+	select {
+	case <-s.closed:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// closeWithError closes the session, recording the first error
+func (s *Session) closeWithError(err error) {
+	s.closeOnce.Do(func() {
+		s.err = err
+		close(s.closed)
+		s.conn.Close()
+	})
+}
+
+// writeFrame writes a frame to the connection with its type's priority
+func (s *Session) writeFrame(frame Frame) error {
+	return s.writeFramePriority(frame, priorityOf(frame.Type))
+}
+
+// writeFramePriority writes a frame to the connection, compressing large
+// payloads and waiting for the upload shaper
+func (s *Session) writeFramePriority(frame Frame, priority Priority) error {
+	if s.Compression != "" && len(frame.Payload) >= CompressionThreshold && len(frame.Payload) <= s.maxFrameSize {
+		compressed, err := compress(s.Compression, frame.Payload)
+		if err == nil && len(compressed) < len(frame.Payload) {
+			frame.Payload = compressed
+			frame.Flags |= FlagCompressed
+		}
+	}
+	size := HeaderSize + len(frame.Payload)
+	if !s.Upload.Wait(s.closed, priority, size) {
+		return ErrSessionClosed
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	select {
+	case <-s.closed:
+		return ErrSessionClosed
+	default:
+	}
+	if s.WriteTimeout > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+	}
+	err := WriteFrame(s.conn, frame, s.maxFrameSize)
+	if err != nil && err != ErrFrameTooLarge {
+		s.closeWithError(err)
+	}
+	if err == nil && s.Meter != nil {
+		s.Meter.Record(frame.Type, size, false)
+	}
+	return err
+}
+
+// removeStream forgets a stream
+func (s *Session) removeStream(id uint32) {
+	s.mutex.Lock()
+	if _, ok := s.streams[id]; ok {
+		delete(s.streams, id)
+		if s.isRemote(id) {
+			s.remoteStreams--
+		}
+	}
+	s.mutex.Unlock()
+}
+
+// Stream is a bidirectional sequence of frames within a session
+type Stream struct {
+	// ID is the ID of the stream
+	ID uint32
+
+	session *Session
+	frames  chan Frame
+	ended   bool
+	done    chan struct{}
+	once    sync.Once
+	err     error
+}
+
+// newStream returns a new stream
+func newStream(session *Session, id uint32) *Stream {
+	return &Stream{
+		ID:      id,
+		session: session,
+		frames:  make(chan Frame, streamBuffer),
+		done:    make(chan struct{}),
+	}
+}
+
+// Send sends a message on the stream
+func (s *Stream) Send(t MessageType, payload []byte) error {
+	return s.session.writeFrame(Frame{Type: t, Stream: s.ID, Payload: payload})
+}
+
+// SendEnd sends the last message on the stream and closes it
+func (s *Stream) SendEnd(t MessageType, payload []byte) error {
+	defer s.Close()
+	return s.session.writeFrame(Frame{Type: t, Flags: FlagEnd, Stream: s.ID, Payload: payload})
+}
+
+// Recv waits for the next frame on the stream. It returns io.EOF once the
+// peer has ended the stream and all frames have been received, and
+// ErrStreamReset if the stream overflowed.
+func (s *Stream) Recv(ctx context.Context) (Frame, error) {
+	select {
+	case <-s.done:
+		return Frame{}, s.err
+	default:
+	}
+	select {
+	case frame, ok := <-s.frames:
+		if !ok {
+			return Frame{}, io.EOF
+		}
+		return frame, nil
+	case <-s.done:
+		return Frame{}, s.err
+	case <-ctx.Done():
+		return Frame{}, ctx.Err()
+	case <-s.session.closed:
+		return Frame{}, s.session.Err()
+	}
+}
+
+// Close releases the stream. Frames that arrive afterwards are dropped.
+func (s *Stream) Close() error {
+	s.closeWithError(ErrStreamClosed)
+	return nil
+}
+
+// closeWithError releases the stream, recording why Recv fails from now on
+func (s *Stream) closeWithError(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+		s.session.removeStream(s.ID)
+	})
+}
+
+// deliver queues a frame received from the peer. It is only called from the
+// session read loop, which it never blocks: a stream whose buffer is full is
+// reset so that other streams keep flowing.
+func (s *Stream) deliver(frame Frame) {
+	if s.ended {
+		return
+	}
+	select {
+	case s.frames <- frame:
+	case <-s.done:
+		return
+	default:
+		s.closeWithError(ErrStreamReset)
+		return
+	}
+	if frame.Flags&FlagEnd != 0 {
+		s.end()
+	}
+}
+
+// end marks the stream as ended by the peer. No more frames arrive, so the
+// session forgets the stream while its frames are still read.
+func (s *Stream) end() {
+	if !s.ended {
+		s.ended = true
+		close(s.frames)
+		s.session.removeStream(s.ID)
+	}
+}
@@ -0,0 +1,213 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestSessions returns two running sessions connected by a pipe
+func newTestSessions(t *testing.T) (*Session, *Session) {
+	a, b := net.Pipe()
+	initiator := NewSession(a, true, DefaultMaxFrameSize)
+	acceptor := NewSession(b, false, DefaultMaxFrameSize)
+	t.Cleanup(func() {
+		initiator.Close()
+		acceptor.Close()
+	})
+	return initiator, acceptor
+}
+
+func TestSession_Messages(t *testing.T) {
+	initiator, acceptor := newTestSessions(t)
+	received := make(chan Frame, 1)
+	acceptor.OnMessage = func(frame Frame) {
+		received <- frame
+	}
+	go initiator.Run()
+	go acceptor.Run()
+
+	err := initiator.Send(MsgVote, []byte("vote"))
+	if err != nil {
+		t.Fatalf("Expected Send to succeed, got %v", err)
+	}
+	select {
+	case frame := <-received:
+		if frame.Type != MsgVote || string(frame.Payload) != "vote" {
+			t.Errorf("Expected the vote to be delivered, got %+v", frame)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the vote to be delivered")
+	}
+}
+
+func TestSession_Streams(t *testing.T) {
+	initiator, acceptor := newTestSessions(t)
+	acceptor.OnStream = func(stream *Stream, frame Frame) {
+		for _, chunk := range []string{"a", "b"} {
+			stream.Send(MsgSyncResponse, []byte(chunk))
+		}
+		stream.SendEnd(MsgSyncResponse, []byte("c"))
+	}
+	go initiator.Run()
+	go acceptor.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Several streams can be in flight at once
+	streams := make([]*Stream, 3)
+	for i := range streams {
+		stream, err := initiator.OpenStream()
+		if err != nil {
+			t.Fatalf("Expected OpenStream to succeed, got %v", err)
+		}
+		if stream.ID%2 != 1 {
+			t.Errorf("Expected the initiator to use odd stream IDs, got %d", stream.ID)
+		}
+		streams[i] = stream
+		stream.Send(MsgSyncRequest, nil)
+	}
+	for _, stream := range streams {
+		var got string
+		for {
+			frame, err := stream.Recv(ctx)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Expected Recv to succeed, got %v", err)
+			}
+			got += string(frame.Payload)
+		}
+		if got != "abc" {
+			t.Errorf("Expected stream %d to receive abc, got %s", stream.ID, got)
+		}
+		stream.Close()
+	}
+}
+
+func TestSession_Ping(t *testing.T) {
+	initiator, acceptor := newTestSessions(t)
+	go initiator.Run()
+	go acceptor.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := acceptor.Ping(ctx)
+	if err != nil {
+		t.Errorf("Expected Ping to succeed, got %v", err)
+	}
+}
+
+func TestSession_Disconnect(t *testing.T) {
+	initiator, acceptor := newTestSessions(t)
+	go initiator.Run()
+	go acceptor.Run()
+
+	initiator.Disconnect(ReasonProtocolError, "bad frame")
+	select {
+	case <-acceptor.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the session to close")
+	}
+	disconnect, ok := acceptor.Err().(*DisconnectError)
+	if !ok || disconnect.Reason != ReasonProtocolError {
+		t.Errorf("Expected a protocol error disconnect, got %v", acceptor.Err())
+	}
+	if err := acceptor.Send(MsgTx, nil); err != ErrSessionClosed {
+		t.Errorf("Expected Send on a closed session to fail, got %v", err)
+	}
+}
+
+func TestSession_DuplicatePong(t *testing.T) {
+	session, _ := newTestSessions(t)
+	ch := make(chan struct{})
+	session.pings[7] = ch
+	payload := []byte{0, 0, 0, 0, 0, 0, 0, 7}
+
+	// A repeated pong must not close the channel twice
+	session.dispatch(Frame{Type: MsgPong, Payload: payload})
+	session.dispatch(Frame{Type: MsgPong, Payload: payload})
+	select {
+	case <-ch:
+	default:
+		t.Errorf("Expected the pong to complete the ping")
+	}
+}
+
+func TestSession_RemoteStreams(t *testing.T) {
+	_, acceptor := newTestSessions(t)
+	acceptor.MaxStreams = 2
+	opened := make(chan *Stream, 8)
+	release := make(chan struct{})
+	acceptor.OnStream = func(stream *Stream, frame Frame) {
+		opened <- stream
+		<-release
+	}
+	ids := func(n int) map[uint32]bool {
+		received := make(map[uint32]bool)
+		for i := 0; i < n; i++ {
+			received[(<-opened).ID] = true
+		}
+		return received
+	}
+
+	acceptor.dispatch(Frame{Type: MsgSyncRequest, Stream: 1})
+	first := <-opened
+	first.Close()
+
+	// A late frame for a closed stream does not open it again
+	acceptor.dispatch(Frame{Type: MsgSyncRequest, Stream: 1})
+
+	// Streams beyond the limit are dropped
+	for _, id := range []uint32{3, 5, 7} {
+		acceptor.dispatch(Frame{Type: MsgSyncRequest, Stream: id})
+	}
+	if received := ids(2); !received[3] || !received[5] {
+		t.Errorf("Expected streams 3 and 5 to open, got %v", received)
+	}
+
+	// A stream the peer ends frees its slot
+	acceptor.dispatch(Frame{Type: MsgSyncRequest, Stream: 3, Flags: FlagEnd})
+	acceptor.dispatch(Frame{Type: MsgSyncRequest, Stream: 9})
+	if received := ids(1); !received[9] {
+		t.Errorf("Expected stream 9 to open once stream 3 ended, got %v", received)
+	}
+
+	// A stream whose handler returns frees its slot
+	close(release)
+	acceptor.handlers.Wait()
+	acceptor.dispatch(Frame{Type: MsgSyncRequest, Stream: 11})
+	acceptor.dispatch(Frame{Type: MsgSyncRequest, Stream: 13})
+	acceptor.handlers.Wait()
+	if len(opened) != 2 {
+		t.Errorf("Expected 2 streams to open once the handlers returned, got %d", len(opened))
+	}
+}
+
+func TestSession_StreamOverflow(t *testing.T) {
+	_, acceptor := newTestSessions(t)
+	opened := make(chan *Stream, 1)
+	release := make(chan struct{})
+	defer close(release)
+	acceptor.OnStream = func(stream *Stream, frame Frame) {
+		opened <- stream
+		<-release
+	}
+	acceptor.dispatch(Frame{Type: MsgSyncRequest, Stream: 1})
+	stream := <-opened
+
+	// A stream whose reader falls behind is reset instead of blocking the
+	// read loop
+	for i := 0; i <= streamBuffer; i++ {
+		acceptor.dispatch(Frame{Type: MsgSyncRequest, Stream: 1})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := stream.Recv(ctx); err != ErrStreamReset {
+		t.Errorf("Expected the stream to be reset, got %v", err)
+	}
+}
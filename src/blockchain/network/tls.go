@@ -0,0 +1,191 @@
+package network
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/skybridge/crypto/ca"
+)
+
+// ErrUntrustedPeer is returned when a peer's key is not in the allowlist
+var ErrUntrustedPeer = errors.New("peer key is not trusted")
+
+// PeerID returns the peer ID for a certificate, which is the hex encoded
+// SHA-256 fingerprint of its public key
+func PeerID(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadCertificate parses a PEM encoded certificate and key pair
+func loadCertificate(certPEM string, keyPEM string) (*tls.Certificate, string, error) {
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, "", err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, "", err
+	}
+	cert.Leaf = leaf
+	return &cert, PeerID(leaf), nil
+}
+
+// buildTLSConfig returns the TLS configuration for peer connections and the
+// ID of the local node derived from its certificate
+func (n *Network) buildTLSConfig() (*tls.Config, string, error) {
+	if n.Config.TLS.Cert == "" || n.Config.TLS.Key == "" {
+		return nil, "", errors.New("TLS certificate and key are required")
+	}
+	cert, id, err := loadCertificate(n.Config.TLS.Cert, n.Config.TLS.Key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var verifier *ca.Verifier
+	if n.Config.TLS.CA != "" {
+		verifier, err = ca.NewVerifier(n.Config.TLS.CA)
+		if err != nil {
+			return nil, "", err
+		}
+		if n.Config.TLS.CRL != "" {
+			if err := verifier.SetCRL([]byte(n.Config.TLS.CRL)); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := verifier.Verify([]*x509.Certificate{cert.Leaf}); err != nil {
+			return nil, "", fmt.Errorf("node certificate is not valid for the CA: %w", err)
+		}
+	}
+
+	trusted := make(map[string]bool)
+	for _, id := range n.Config.TLS.TrustedPeers {
+		trusted[strings.ToLower(id)] = true
+	}
+
+	n.certMutex.Lock()
+	n.certificate = cert
+	n.verifier = verifier
+	n.certMutex.Unlock()
+
+	config := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return n.currentCertificate(), nil
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return n.currentCertificate(), nil
+		},
+		MinVersion: tls.VersionTLS13,
+		ClientAuth: tls.RequireAnyClientCert,
+		// Peers are identified by their key rather than by a host name, so
+		// the chain is checked in VerifyPeerCertificate instead
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPeerCertificate(rawCerts, trusted, verifier)
+		},
+	}
+	return config, id, nil
+}
+
+// currentCertificate returns the certificate presented to peers
+func (n *Network) currentCertificate() *tls.Certificate {
+	n.certMutex.RLock()
+	defer n.certMutex.RUnlock()
+	return n.certificate
+}
+
+// SetCertificate replaces the certificate presented to peers, such as after
+// renewal. The new certificate must have the same key so the node ID does
+// not change.
+func (n *Network) SetCertificate(certPEM string, keyPEM string) error {
+	cert, id, err := loadCertificate(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	if id != n.Config.ID {
+		return errors.New("certificate key does not match the node ID")
+	}
+	n.certMutex.Lock()
+	defer n.certMutex.Unlock()
+	if n.verifier != nil {
+		if err := n.verifier.Verify([]*x509.Certificate{cert.Leaf}); err != nil {
+			return fmt.Errorf("certificate is not valid for the CA: %w", err)
+		}
+	}
+	n.certificate = cert
+	return nil
+}
+
+// SetCRL replaces the certificate revocation list peers are checked against.
+// Peers that are already connected are not rechecked.
+func (n *Network) SetCRL(crl []byte) error {
+	n.certMutex.RLock()
+	verifier := n.verifier
+	n.certMutex.RUnlock()
+	if verifier == nil {
+		return errors.New("network has no CA configured")
+	}
+	return verifier.SetCRL(crl)
+}
+
+// verifyPeerCertificate checks the certificate presented by a peer
+func verifyPeerCertificate(rawCerts [][]byte, trusted map[string]bool, verifier *ca.Verifier) error {
+	if len(rawCerts) == 0 {
+		return errors.New("peer did not present a certificate")
+	}
+	chain := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		chain[i] = cert
+	}
+	cert := chain[0]
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("peer certificate is not valid at %s", now.Format(time.RFC3339))
+	}
+	if verifier != nil {
+		if err := verifier.Verify(chain); err != nil {
+			return err
+		}
+	}
+	if len(trusted) > 0 && !trusted[PeerID(cert)] {
+		return ErrUntrustedPeer
+	}
+	return nil
+}
+
+// verifiedPeerID completes the TLS handshake on a connection and returns the
+// ID of the authenticated peer. The deadline it sets also bounds the protocol
+// handshake that follows, so a peer that stalls cannot hold the connection.
+func (n *Network) verifiedPeerID(conn net.Conn) (string, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", errors.New("connection is not a TLS connection")
+	}
+	timeout := n.Config.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = DefaultHandshakeTimeout
+	}
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return "", err
+	}
+	state := tlsConn.ConnectionState()
+	if state.Version != tls.VersionTLS13 {
+		return "", errors.New("peer did not negotiate TLS 1.3")
+	}
+	if len(state.PeerCertificates) == 0 {
+		return "", errors.New("peer did not present a certificate")
+	}
+	return PeerID(state.PeerCertificates[0]), nil
+}
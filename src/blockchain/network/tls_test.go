@@ -0,0 +1,234 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skybridge/crypto/ca"
+)
+
+func TestPeerID(t *testing.T) {
+	cert, _, err := GenerateTLS()
+	if err != nil {
+		t.Fatalf("Expected GenerateTLS to succeed, got %v", err)
+	}
+	block, _ := pem.Decode([]byte(cert))
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Expected the certificate to parse, got %v", err)
+	}
+	id := PeerID(parsed)
+	if len(id) != 64 || id != PeerID(parsed) {
+		t.Errorf("Expected PeerID to return a stable SHA-256 fingerprint, got %s", id)
+	}
+}
+
+func TestNetwork_TrustedPeers(t *testing.T) {
+	client := startTestNetwork(t)
+	stranger := startTestNetwork(t)
+	server := startTestNetwork(t, func(config *Config) {
+		config.TLS.TrustedPeers = []string{client.Config.ID}
+	})
+
+	_, err := client.Dial(Peer{Address: server.Listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("Expected a trusted peer to connect, got %v", err)
+	}
+	waitForPeer(t, server, client.Config.ID)
+
+	_, err = stranger.Dial(Peer{Address: server.Listener.Addr().String()})
+	if err == nil {
+		t.Errorf("Expected an untrusted peer to be rejected")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := server.Peer(stranger.Config.ID); ok {
+		t.Errorf("Expected an untrusted peer not to be added")
+	}
+}
+
+func TestNetwork_RejectsMismatchedID(t *testing.T) {
+	server := startTestNetwork(t)
+	cert, key, err := GenerateTLS()
+	if err != nil {
+		t.Fatalf("Expected GenerateTLS to succeed, got %v", err)
+	}
+	pair, err := tls.X509KeyPair([]byte(cert), []byte(key))
+	if err != nil {
+		t.Fatalf("Expected the key pair to load, got %v", err)
+	}
+
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{
+		Certificates:       []tls.Certificate{pair},
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		t.Fatalf("Expected the TLS connection to succeed, got %v", err)
+	}
+	defer conn.Close()
+
+	payload, _ := json.Marshal(Handshake{ID: server.Config.ID})
+	err = WriteFrame(conn, Frame{Type: MsgHandshake, Payload: payload}, DefaultMaxFrameSize)
+	if err != nil {
+		t.Fatalf("Expected WriteFrame to succeed, got %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	frame, err := ReadFrame(conn, DefaultMaxFrameSize)
+	if err != nil {
+		t.Fatalf("Expected a disconnect frame, got %v", err)
+	}
+	if frame.Type != MsgDisconnect || decodeDisconnect(frame.Payload).Reason != ReasonUnauthorized {
+		t.Errorf("Expected an unauthorized disconnect, got %s", frame.Type)
+	}
+	if _, ok := server.Peer(server.Config.ID); ok {
+		t.Errorf("Expected the impersonating peer not to be added")
+	}
+}
+
+func TestNetwork_HandshakeTimeout(t *testing.T) {
+	server := startTestNetwork(t, func(config *Config) {
+		config.Timeout = 0
+		config.HandshakeTimeout = 50 * time.Millisecond
+	})
+
+	// A connection that never starts the TLS handshake is dropped
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected the connection to succeed, got %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		t.Errorf("Expected the server to close a stalled connection")
+	}
+}
+
+func TestGenerateTLS(t *testing.T) {
+	first, _, err := GenerateTLS()
+	if err != nil {
+		t.Fatalf("Expected GenerateTLS to succeed, got %v", err)
+	}
+	second, _, err := GenerateTLS()
+	if err != nil {
+		t.Fatalf("Expected GenerateTLS to succeed, got %v", err)
+	}
+	a, err := ca.DecodeCertificate([]byte(first))
+	if err != nil {
+		t.Fatalf("Expected the certificate to parse, got %v", err)
+	}
+	b, err := ca.DecodeCertificate([]byte(second))
+	if err != nil {
+		t.Fatalf("Expected the certificate to parse, got %v", err)
+	}
+	if a.IsCA {
+		t.Errorf("Expected a node certificate not to be a CA")
+	}
+	if a.SerialNumber.Cmp(b.SerialNumber) == 0 {
+		t.Errorf("Expected certificates to have random serial numbers")
+	}
+}
+
+// startCATestNetwork starts a network with a certificate issued by root
+func startCATestNetwork(t *testing.T, root *ca.CA) (*Network, *ca.Issued) {
+	issued, err := root.Issue(ca.Request{CommonName: "node", Validity: time.Hour})
+	if err != nil {
+		t.Fatalf("Expected Issue to succeed, got %v", err)
+	}
+	network := startTestNetwork(t, func(config *Config) {
+		config.TLS = TLSConfig{
+			Cert: issued.CertPEM,
+			Key:  issued.KeyPEM,
+			CA:   root.CertificatePEM(),
+		}
+	})
+	return network, issued
+}
+
+func TestNetwork_CA(t *testing.T) {
+	root, err := ca.NewRootCA("Skybridge Root", ca.KeyECDSA, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	server, _ := startCATestNetwork(t, root)
+	client, _ := startCATestNetwork(t, root)
+	revoked, revokedCert := startCATestNetwork(t, root)
+	stranger := startTestNetwork(t)
+
+	connectTestNetworks(t, client, server)
+	if _, err := stranger.Dial(Peer{Address: server.Listener.Addr().String()}); err == nil {
+		t.Errorf("Expected a peer outside the CA to be rejected")
+	}
+
+	root.Revoke(revokedCert.Certificate.SerialNumber, "compromised")
+	crl, err := root.CRLPEM(time.Hour)
+	if err != nil {
+		t.Fatalf("Expected CRLPEM to succeed, got %v", err)
+	}
+	if err := server.SetCRL([]byte(crl)); err != nil {
+		t.Fatalf("Expected SetCRL to succeed, got %v", err)
+	}
+	if _, err := revoked.Dial(Peer{Address: server.Listener.Addr().String()}); err == nil {
+		t.Errorf("Expected a revoked peer to be rejected")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := server.Peer(revoked.Config.ID); ok {
+		t.Errorf("Expected a revoked peer not to be added")
+	}
+
+	other, err := ca.NewRootCA("Other Root", ca.KeyECDSA, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	forged, _ := other.CRL(time.Hour)
+	if err := server.SetCRL(forged); err == nil {
+		t.Errorf("Expected a CRL from another CA to be rejected")
+	}
+}
+
+func TestNetwork_SetCertificate(t *testing.T) {
+	root, err := ca.NewRootCA("Skybridge Root", ca.KeyEd25519, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	server, issued := startCATestNetwork(t, root)
+	client, _ := startCATestNetwork(t, root)
+
+	renewed, err := root.Renew(issued)
+	if err != nil {
+		t.Fatalf("Expected Renew to succeed, got %v", err)
+	}
+	if err := server.SetCertificate(renewed.CertPEM, renewed.KeyPEM); err != nil {
+		t.Fatalf("Expected SetCertificate to succeed, got %v", err)
+	}
+	connectTestNetworks(t, client, server)
+
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{
+		MinVersion:         tls.VersionTLS13,
+		InsecureSkipVerify: true,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return client.currentCertificate(), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected the TLS connection to succeed, got %v", err)
+	}
+	presented := conn.ConnectionState().PeerCertificates[0]
+	conn.Close()
+	if presented.SerialNumber.Cmp(renewed.Certificate.SerialNumber) != 0 {
+		t.Errorf("Expected the renewed certificate to be presented")
+	}
+
+	other, err := root.Issue(ca.Request{CommonName: "other"})
+	if err != nil {
+		t.Fatalf("Expected Issue to succeed, got %v", err)
+	}
+	if err := server.SetCertificate(other.CertPEM, other.KeyPEM); err == nil {
+		t.Errorf("Expected a certificate with a different key to be rejected")
+	}
+}
@@ -0,0 +1,110 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+// Transport creates the connections a network runs over
+type Transport interface {
+	// Listen listens for incoming connections on an address
+	Listen(address string) (net.Listener, error)
+
+	// Dial opens a connection to an address
+	Dial(ctx context.Context, address string) (net.Conn, error)
+
+	// LocalAddr returns the address the transport is listening on, or nil
+	// if it is not listening
+	LocalAddr() net.Addr
+}
+
+// TCPTransport is a transport over TCP
+type TCPTransport struct {
+	// Dialer is the dialer for outgoing connections
+	Dialer net.Dialer
+
+	mutex    sync.Mutex
+	listener net.Listener
+}
+
+// NewTCPTransport returns a TCP transport with the given dial timeout
+func NewTCPTransport(timeout time.Duration) *TCPTransport {
+	return &TCPTransport{
+		Dialer: net.Dialer{
+			Timeout: timeout,
+		},
+	}
+}
+
+// Listen listens for TCP connections on an address
+func (t *TCPTransport) Listen(address string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	t.mutex.Lock()
+	t.listener = listener
+	t.mutex.Unlock()
+	return listener, nil
+}
+
+// Dial opens a TCP connection to an address
+func (t *TCPTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	return t.Dialer.DialContext(ctx, "tcp", address)
+}
+
+// LocalAddr returns the address the transport is listening on
+func (t *TCPTransport) LocalAddr() net.Addr {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.listener == nil {
+		return nil
+	}
+	return t.listener.Addr()
+}
+
+// TLSTransport secures the connections of another transport with TLS
+type TLSTransport struct {
+	// Transport is the underlying transport
+	Transport Transport
+
+	// Config is the TLS configuration
+	Config *tls.Config
+}
+
+// NewTLSTransport returns a transport that secures the connections of
+// transport with TLS
+func NewTLSTransport(transport Transport, config *tls.Config) *TLSTransport {
+	return &TLSTransport{
+		Transport: transport,
+		Config:    config,
+	}
+}
+
+// Listen listens for TLS connections on an address. The TLS handshake
+// happens on the first read or write of an accepted connection.
+func (t *TLSTransport) Listen(address string) (net.Listener, error) {
+	listener, err := t.Transport.Listen(address)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(listener, t.Config), nil
+}
+
+// Dial opens a TLS connection to an address. The TLS handshake happens on
+// the first read or write.
+func (t *TLSTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	conn, err := t.Transport.Dial(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Client(conn, t.Config), nil
+}
+
+// LocalAddr returns the address the underlying transport is listening on
+func (t *TLSTransport) LocalAddr() net.Addr {
+	return t.Transport.LocalAddr()
+}
@@ -0,0 +1,84 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"testing"
+)
+
+// testTLSConfig returns a TLS configuration with a new certificate
+func testTLSConfig(t *testing.T) *tls.Config {
+	cert, key, err := GenerateTLS()
+	if err != nil {
+		t.Fatalf("Expected GenerateTLS to succeed, got %v", err)
+	}
+	network := NewNetwork(Config{TLS: TLSConfig{Cert: cert, Key: key}})
+	config, _, err := network.buildTLSConfig()
+	if err != nil {
+		t.Fatalf("Expected buildTLSConfig to succeed, got %v", err)
+	}
+	return config
+}
+
+// exchange writes a message from client to server over transport and
+// returns what the server read
+func exchange(t *testing.T, server Transport, client Transport, message string) string {
+	listener, err := server.Listen(":0")
+	if err != nil {
+		t.Fatalf("Expected Listen to succeed, got %v", err)
+	}
+	defer listener.Close()
+	if server.LocalAddr() == nil || server.LocalAddr().String() != listener.Addr().String() {
+		t.Errorf("Expected LocalAddr to return the listening address, got %v", server.LocalAddr())
+	}
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, len(message))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			received <- err.Error()
+			return
+		}
+		received <- string(buf)
+	}()
+
+	conn, err := client.Dial(context.Background(), listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected Dial to succeed, got %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(message)); err != nil {
+		t.Fatalf("Expected Write to succeed, got %v", err)
+	}
+	return <-received
+}
+
+func TestTCPTransport(t *testing.T) {
+	if got := exchange(t, NewTCPTransport(0), NewTCPTransport(0), "hello"); got != "hello" {
+		t.Errorf("Expected the server to read hello, got %s", got)
+	}
+}
+
+func TestTLSTransport(t *testing.T) {
+	server := NewTLSTransport(NewTCPTransport(0), testTLSConfig(t))
+	client := NewTLSTransport(NewTCPTransport(0), testTLSConfig(t))
+	if got := exchange(t, server, client, "hello"); got != "hello" {
+		t.Errorf("Expected the server to read hello, got %s", got)
+	}
+}
+
+func TestTLSTransport_Memory(t *testing.T) {
+	network := NewMemoryNetwork(1)
+	server := NewTLSTransport(network.Transport(), testTLSConfig(t))
+	client := NewTLSTransport(network.Transport(), testTLSConfig(t))
+	if got := exchange(t, server, client, "hello"); got != "hello" {
+		t.Errorf("Expected the server to read hello, got %s", got)
+	}
+}
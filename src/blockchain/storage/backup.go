@@ -0,0 +1,484 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// BackupVersion is the version of the backup archive format
+const BackupVersion = 1
+
+// DefaultChunkSize is the default maximum size of a backup chunk
+const DefaultChunkSize = 1 << 20
+
+// maxChunkSize bounds the chunk size accepted on restore
+const maxChunkSize = 64 << 20
+
+// maxManifestSize bounds the manifest size accepted on restore
+const maxManifestSize = 64 << 20
+
+// backupMagic identifies a backup archive
+var backupMagic = []byte("SKYBAK\x00\x01")
+
+// Signer signs backup manifests
+type Signer interface {
+	Sign(data []byte) ([]byte, error)
+}
+
+// Verifier verifies backup manifest signatures
+type Verifier interface {
+	Verify(data []byte, signature []byte) bool
+}
+
+// BackupOptions represents the options for a backup
+type BackupOptions struct {
+	// ChunkSize is the maximum size of a chunk payload
+	ChunkSize int
+
+	// CompressionLevel is the gzip compression level
+	CompressionLevel int
+
+	// Signer signs the manifest, if set
+	Signer Signer
+}
+
+// RestoreOptions represents the options for a restore
+type RestoreOptions struct {
+	// Verifier verifies the manifest signature, if set
+	Verifier Verifier
+
+	// RequireSignature rejects archives without a valid signature
+	RequireSignature bool
+
+	// Merge merges the archive into the existing data instead of replacing it
+	Merge bool
+}
+
+// Manifest describes the contents of a backup archive
+type Manifest struct {
+	// Version is the version of the archive format
+	Version int `json:"version"`
+
+	// CreatedAt is the time the backup was taken
+	CreatedAt time.Time `json:"created_at"`
+
+	// Entries is the number of key/value pairs in the archive
+	Entries int `json:"entries"`
+
+	// Bytes is the total size of the chunk payloads
+	Bytes int64 `json:"bytes"`
+
+	// Chunks describes each chunk in the archive
+	Chunks []ChunkInfo `json:"chunks"`
+
+	// RootHash is the SHA-256 of the concatenated chunk hashes
+	RootHash string `json:"root_hash"`
+
+	// Signature is the signature over the manifest without the signature
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// ChunkInfo describes a chunk in a backup archive
+type ChunkInfo struct {
+	// Index is the position of the chunk in the archive
+	Index int `json:"index"`
+
+	// Entries is the number of key/value pairs in the chunk
+	Entries int `json:"entries"`
+
+	// Size is the size of the chunk payload
+	Size int `json:"size"`
+
+	// SHA256 is the hex encoded SHA-256 of the chunk payload
+	SHA256 string `json:"sha256"`
+}
+
+// signedBytes returns the bytes covered by the manifest signature
+func (m *Manifest) signedBytes() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = nil
+	return json.Marshal(unsigned)
+}
+
+// Snapshot returns a point-in-time copy of the storage contents. Values are
+// copied, so callers may modify them without affecting the storage.
+func (s *Storage) Snapshot() map[string][]byte {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	snapshot := make(map[string][]byte, len(s.Data))
+	for key, value := range s.Data {
+		snapshot[key] = append([]byte(nil), value...)
+	}
+	return snapshot
+}
+
+// Backup writes a consistent backup of the storage to w. Writes may continue
+// while the backup is streamed; they are not included in it.
+func (s *Storage) Backup(w io.Writer, opts BackupOptions) (*Manifest, error) {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.ChunkSize > maxChunkSize {
+		return nil, fmt.Errorf("chunk size %d exceeds maximum %d", opts.ChunkSize, maxChunkSize)
+	}
+	if opts.CompressionLevel == 0 {
+		opts.CompressionLevel = gzip.DefaultCompression
+	}
+
+	snapshot := s.Snapshot()
+	keys := make([]string, 0, len(snapshot))
+	for key := range snapshot {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	zw, err := gzip.NewWriterLevel(w, opts.CompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(backupMagic); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Version:   BackupVersion,
+		CreatedAt: time.Now().UTC(),
+		Chunks:    make([]ChunkInfo, 0),
+	}
+	root := sha256.New()
+
+	var chunk bytes.Buffer
+	entries := 0
+	flush := func() error {
+		if entries == 0 {
+			return nil
+		}
+		sum := sha256.Sum256(chunk.Bytes())
+		if err := writeFrame(zw, chunk.Bytes()); err != nil {
+			return err
+		}
+		if _, err := zw.Write(sum[:]); err != nil {
+			return err
+		}
+		root.Write(sum[:])
+		manifest.Chunks = append(manifest.Chunks, ChunkInfo{
+			Index:   len(manifest.Chunks),
+			Entries: entries,
+			Size:    chunk.Len(),
+			SHA256:  hex.EncodeToString(sum[:]),
+		})
+		manifest.Entries += entries
+		manifest.Bytes += int64(chunk.Len())
+		chunk.Reset()
+		entries = 0
+		return nil
+	}
+
+	for _, key := range keys {
+		record := encodeRecord(key, snapshot[key])
+		if len(record) > opts.ChunkSize {
+			return nil, fmt.Errorf("entry %q is larger than the chunk size", key)
+		}
+		if chunk.Len()+len(record) > opts.ChunkSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		chunk.Write(record)
+		entries++
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	// A zero length frame ends the chunk stream
+	if err := writeFrame(zw, nil); err != nil {
+		return nil, err
+	}
+
+	manifest.RootHash = hex.EncodeToString(root.Sum(nil))
+	if opts.Signer != nil {
+		data, err := manifest.signedBytes()
+		if err != nil {
+			return nil, err
+		}
+		manifest.Signature, err = opts.Signer.Sign(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFrame(zw, data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Restore reads a backup from r and loads it into the storage. The archive is
+// fully verified before the storage is modified.
+func (s *Storage) Restore(r io.Reader, opts RestoreOptions) (*Manifest, error) {
+	manifest, data, err := readBackup(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if !opts.Merge || s.Data == nil {
+		s.Data = data
+		return manifest, nil
+	}
+	for key, value := range data {
+		s.Data[key] = value
+	}
+	return manifest, nil
+}
+
+// VerifyBackup checks the integrity and signature of a backup without
+// restoring it
+func VerifyBackup(r io.Reader, opts RestoreOptions) (*Manifest, error) {
+	manifest, _, err := readBackup(r, opts)
+	return manifest, err
+}
+
+// BackupToFile writes a backup of the storage to path. The file is replaced
+// atomically once the backup is complete.
+func (s *Storage) BackupToFile(path string, opts BackupOptions) (*Manifest, error) {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	w := bufio.NewWriter(file)
+	manifest, err := s.Backup(w, opts)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// RestoreFromFile restores the storage from the backup at path
+func (s *Storage) RestoreFromFile(path string, opts RestoreOptions) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return s.Restore(bufio.NewReader(file), opts)
+}
+
+// readBackup reads and verifies a backup archive
+func readBackup(r io.Reader, opts RestoreOptions) (*Manifest, map[string][]byte, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zr.Close()
+
+	magic := make([]byte, len(backupMagic))
+	if _, err := io.ReadFull(zr, magic); err != nil {
+		return nil, nil, fmt.Errorf("reading backup header: %w", err)
+	}
+	if !bytes.Equal(magic, backupMagic) {
+		return nil, nil, fmt.Errorf("not a backup archive")
+	}
+
+	data := make(map[string][]byte)
+	chunks := make([]ChunkInfo, 0)
+	root := sha256.New()
+	for {
+		payload, err := readFrame(zr, maxChunkSize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading chunk %d: %w", len(chunks), err)
+		}
+		if len(payload) == 0 {
+			break
+		}
+		sum := make([]byte, sha256.Size)
+		if _, err := io.ReadFull(zr, sum); err != nil {
+			return nil, nil, fmt.Errorf("reading chunk %d checksum: %w", len(chunks), err)
+		}
+		actual := sha256.Sum256(payload)
+		if !bytes.Equal(sum, actual[:]) {
+			return nil, nil, fmt.Errorf("chunk %d checksum mismatch", len(chunks))
+		}
+		entries, err := decodeRecords(payload, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding chunk %d: %w", len(chunks), err)
+		}
+		root.Write(actual[:])
+		chunks = append(chunks, ChunkInfo{
+			Index:   len(chunks),
+			Entries: entries,
+			Size:    len(payload),
+			SHA256:  hex.EncodeToString(actual[:]),
+		})
+	}
+
+	raw, err := readFrame(zr, maxManifestSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("decoding manifest: %w", err)
+	}
+
+	// Read to EOF so gzip verifies the trailer checksum and length
+	trailing, err := io.Copy(io.Discard, zr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading backup trailer: %w", err)
+	}
+	if trailing != 0 {
+		return nil, nil, fmt.Errorf("unexpected %d bytes after manifest", trailing)
+	}
+	if err := verifyManifest(&manifest, chunks, hex.EncodeToString(root.Sum(nil)), len(data), opts); err != nil {
+		return nil, nil, err
+	}
+	return &manifest, data, nil
+}
+
+// verifyManifest checks a manifest against the chunks that were read
+func verifyManifest(manifest *Manifest, chunks []ChunkInfo, rootHash string, entries int, opts RestoreOptions) error {
+	if manifest.Version != BackupVersion {
+		return fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	if len(manifest.Chunks) != len(chunks) {
+		return fmt.Errorf("manifest lists %d chunks, archive has %d", len(manifest.Chunks), len(chunks))
+	}
+	for i, chunk := range chunks {
+		if manifest.Chunks[i] != chunk {
+			return fmt.Errorf("chunk %d does not match manifest", i)
+		}
+	}
+	if manifest.RootHash != rootHash {
+		return fmt.Errorf("root hash mismatch")
+	}
+	if manifest.Entries != entries {
+		return fmt.Errorf("manifest lists %d entries, archive has %d", manifest.Entries, entries)
+	}
+
+	if len(manifest.Signature) == 0 {
+		if opts.RequireSignature {
+			return fmt.Errorf("backup is not signed")
+		}
+		return nil
+	}
+	if opts.Verifier == nil {
+		if opts.RequireSignature {
+			return fmt.Errorf("no verifier for signed backup")
+		}
+		return nil
+	}
+	data, err := manifest.signedBytes()
+	if err != nil {
+		return err
+	}
+	if !opts.Verifier.Verify(data, manifest.Signature) {
+		return fmt.Errorf("invalid manifest signature")
+	}
+	return nil
+}
+
+// encodeRecord encodes a key/value pair as length prefixed fields
+func encodeRecord(key string, value []byte) []byte {
+	record := make([]byte, 0, 2*binary.MaxVarintLen64+len(key)+len(value))
+	record = binary.AppendUvarint(record, uint64(len(key)))
+	record = append(record, key...)
+	record = binary.AppendUvarint(record, uint64(len(value)))
+	record = append(record, value...)
+	return record
+}
+
+// decodeRecords decodes the key/value pairs in a chunk payload into data
+func decodeRecords(payload []byte, data map[string][]byte) (int, error) {
+	entries := 0
+	for len(payload) > 0 {
+		key, rest, err := readField(payload)
+		if err != nil {
+			return 0, err
+		}
+		value, rest, err := readField(rest)
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := data[string(key)]; ok {
+			return 0, fmt.Errorf("duplicate key %q", key)
+		}
+		data[string(key)] = append([]byte(nil), value...)
+		payload = rest
+		entries++
+	}
+	return entries, nil
+}
+
+// readField reads a length prefixed field
+func readField(b []byte) ([]byte, []byte, error) {
+	length, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, nil, fmt.Errorf("invalid field length")
+	}
+	b = b[n:]
+	if length > uint64(len(b)) {
+		return nil, nil, fmt.Errorf("truncated field")
+	}
+	return b[:length], b[length:], nil
+}
+
+// writeFrame writes a uint32 length prefixed frame
+func writeFrame(w io.Writer, payload []byte) error {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(payload)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readFrame reads a uint32 length prefixed frame
+func readFrame(r io.Reader, max int) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if uint64(length) > uint64(max) {
+		return nil, fmt.Errorf("frame of %d bytes exceeds maximum %d", length, max)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
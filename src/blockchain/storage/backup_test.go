@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSigner struct {
+	key *rsa.PrivateKey
+}
+
+func (s *testSigner) Sign(data []byte) ([]byte, error) {
+	hash := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+}
+
+func (s *testSigner) Verify(data []byte, signature []byte) bool {
+	hash := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(&s.key.PublicKey, crypto.SHA256, hash[:], signature) == nil
+}
+
+func newTestStorage(t *testing.T, n int) *Storage {
+	storage := NewStorage(Config{
+		Path:    t.TempDir(),
+		Timeout: 10 * time.Second,
+	})
+	for i := 0; i < n; i++ {
+		err := storage.Put(fmt.Sprintf("key%04d", i), bytes.Repeat([]byte{byte(i)}, i%50))
+		if err != nil {
+			t.Fatalf("Expected Put to succeed, got %v", err)
+		}
+	}
+	return storage
+}
+
+func TestStorage_BackupRestore(t *testing.T) {
+	storage := newTestStorage(t, 500)
+	var archive bytes.Buffer
+	manifest, err := storage.Backup(&archive, BackupOptions{ChunkSize: 1024})
+	if err != nil {
+		t.Fatalf("Expected Backup to succeed, got %v", err)
+	}
+	if manifest.Entries != 500 || len(manifest.Chunks) < 2 {
+		t.Errorf("Expected 500 entries in several chunks, got %d in %d", manifest.Entries, len(manifest.Chunks))
+	}
+
+	restored := newTestStorage(t, 0)
+	restored.Put("stale", []byte("value"))
+	if _, err := restored.Restore(bytes.NewReader(archive.Bytes()), RestoreOptions{}); err != nil {
+		t.Fatalf("Expected Restore to succeed, got %v", err)
+	}
+	if _, err := restored.Get("stale"); err == nil {
+		t.Errorf("Expected Restore to replace existing data")
+	}
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("key%04d", i)
+		value, err := restored.Get(key)
+		if err != nil || !bytes.Equal(value, bytes.Repeat([]byte{byte(i)}, i%50)) {
+			t.Fatalf("Expected %s to be restored", key)
+		}
+	}
+}
+
+func TestStorage_RestoreMerge(t *testing.T) {
+	storage := newTestStorage(t, 10)
+	var archive bytes.Buffer
+	if _, err := storage.Backup(&archive, BackupOptions{}); err != nil {
+		t.Fatalf("Expected Backup to succeed, got %v", err)
+	}
+
+	restored := newTestStorage(t, 0)
+	restored.Put("existing", []byte("value"))
+	if _, err := restored.Restore(&archive, RestoreOptions{Merge: true}); err != nil {
+		t.Fatalf("Expected Restore to succeed, got %v", err)
+	}
+	if len(restored.Snapshot()) != 11 {
+		t.Errorf("Expected Restore to merge into existing data")
+	}
+}
+
+func TestStorage_RestoreDetectsCorruption(t *testing.T) {
+	storage := newTestStorage(t, 100)
+	var archive bytes.Buffer
+	if _, err := storage.Backup(&archive, BackupOptions{CompressionLevel: -2}); err != nil {
+		t.Fatalf("Expected Backup to succeed, got %v", err)
+	}
+
+	// Huffman-only compression keeps the payload mostly byte aligned, so
+	// flipping a byte in the middle corrupts a chunk rather than the header
+	data := archive.Bytes()
+	data[len(data)/2] ^= 0xff
+
+	restored := newTestStorage(t, 0)
+	restored.Put("existing", []byte("value"))
+	if _, err := restored.Restore(bytes.NewReader(data), RestoreOptions{}); err == nil {
+		t.Fatalf("Expected Restore to fail on a corrupted archive")
+	}
+	if _, err := restored.Get("existing"); err != nil {
+		t.Errorf("Expected a failed Restore to leave the storage untouched")
+	}
+}
+
+func TestStorage_RestoreChecksGzipTrailer(t *testing.T) {
+	storage := newTestStorage(t, 10)
+	var archive bytes.Buffer
+	if _, err := storage.Backup(&archive, BackupOptions{}); err != nil {
+		t.Fatalf("Expected Backup to succeed, got %v", err)
+	}
+
+	// The gzip trailer is the CRC-32 and length of the uncompressed stream
+	data := archive.Bytes()
+	data[len(data)-8] ^= 0xff
+
+	restored := newTestStorage(t, 0)
+	if _, err := restored.Restore(bytes.NewReader(data), RestoreOptions{}); err == nil {
+		t.Fatalf("Expected Restore to fail on a corrupted gzip trailer")
+	}
+}
+
+func TestStorage_SnapshotCopiesValues(t *testing.T) {
+	storage := newTestStorage(t, 0)
+	storage.Put("key", []byte("value"))
+	snapshot := storage.Snapshot()
+	snapshot["key"][0] = 'X'
+	if value, _ := storage.Get("key"); string(value) != "value" {
+		t.Errorf("Expected Snapshot values to be copies, storage now holds %q", value)
+	}
+}
+
+func TestStorage_BackupSignature(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Expected GenerateKey to succeed, got %v", err)
+	}
+	signer := &testSigner{key: key}
+
+	storage := newTestStorage(t, 20)
+	var archive bytes.Buffer
+	if _, err := storage.Backup(&archive, BackupOptions{Signer: signer}); err != nil {
+		t.Fatalf("Expected Backup to succeed, got %v", err)
+	}
+	if _, err := VerifyBackup(bytes.NewReader(archive.Bytes()), RestoreOptions{Verifier: signer, RequireSignature: true}); err != nil {
+		t.Errorf("Expected VerifyBackup to accept a signed backup, got %v", err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Expected GenerateKey to succeed, got %v", err)
+	}
+	if _, err := VerifyBackup(bytes.NewReader(archive.Bytes()), RestoreOptions{Verifier: &testSigner{key: other}}); err == nil {
+		t.Errorf("Expected VerifyBackup to reject a signature from another key")
+	}
+
+	var unsigned bytes.Buffer
+	if _, err := storage.Backup(&unsigned, BackupOptions{}); err != nil {
+		t.Fatalf("Expected Backup to succeed, got %v", err)
+	}
+	if _, err := VerifyBackup(&unsigned, RestoreOptions{RequireSignature: true}); err == nil {
+		t.Errorf("Expected VerifyBackup to reject an unsigned backup")
+	}
+}
+
+func TestStorage_BackupWhileWriting(t *testing.T) {
+	storage := newTestStorage(t, 1000)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			storage.Put(fmt.Sprintf("new%d", i), []byte("value"))
+			storage.Delete(fmt.Sprintf("key%04d", i%1000))
+		}
+	}()
+
+	var archive bytes.Buffer
+	manifest, err := storage.Backup(&archive, BackupOptions{ChunkSize: 4096})
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Expected Backup to succeed, got %v", err)
+	}
+	if _, err := VerifyBackup(&archive, RestoreOptions{}); err != nil {
+		t.Errorf("Expected a backup taken during writes to verify, got %v", err)
+	}
+	if manifest.Entries == 0 {
+		t.Errorf("Expected the backup to contain entries")
+	}
+}
+
+func TestStorage_BackupToFile(t *testing.T) {
+	storage := newTestStorage(t, 50)
+	path := filepath.Join(t.TempDir(), "storage.bak")
+	if _, err := storage.BackupToFile(path, BackupOptions{}); err != nil {
+		t.Fatalf("Expected BackupToFile to succeed, got %v", err)
+	}
+	restored := newTestStorage(t, 0)
+	manifest, err := restored.RestoreFromFile(path, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("Expected RestoreFromFile to succeed, got %v", err)
+	}
+	if manifest.Entries != 50 || len(restored.Snapshot()) != 50 {
+		t.Errorf("Expected RestoreFromFile to restore 50 entries")
+	}
+}
@@ -6,12 +6,16 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/skybridge/lib/errors"
 )
 
+// ErrKeyNotFound is returned when a key is not in the storage
+var ErrKeyNotFound = errors.NewError(404, "key not found")
+
 // Storage represents a storage system
 type Storage struct {
 	// Config is the configuration for the storage
@@ -55,7 +59,7 @@ func (s *Storage) Get(key string) ([]byte, error) {
 	defer s.Mutex.RUnlock()
 	value, ok := s.Data[key]
 	if !ok {
-		return nil, errors.New("key not found")
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
 	}
 	return value, nil
 }
@@ -72,26 +76,19 @@ func (s *Storage) Delete(key string) error {
 func (s *Storage) MarshalJSON() ([]byte, error) {
 	s.Mutex.RLock()
 	defer s.Mutex.RUnlock()
-	data := make(map[string]string)
-	for key, value := range s.Data {
-		data[key] = string(value)
-	}
-	return json.Marshal(data)
+	return json.Marshal(s.Data)
 }
 
 // UnmarshalJSON unmarshals JSON to the storage
 func (s *Storage) UnmarshalJSON(data []byte) error {
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
-	var storageData map[string]string
+	storageData := make(map[string][]byte)
 	err := json.Unmarshal(data, &storageData)
 	if err != nil {
 		return err
 	}
-	s.Data = make(map[string][]byte)
-	for key, value := range storageData {
-		s.Data[key] = []byte(value)
-	}
+	s.Data = storageData
 	return nil
 }
 
@@ -103,6 +100,10 @@ func (s *Storage) Persist() error {
 	if err != nil {
 		return err
 	}
+	err = os.MkdirAll(s.Config.Path, 0755)
+	if err != nil {
+		return err
+	}
 	err = ioutil.WriteFile(filepath.Join(s.Config.Path, "storage.json"), data, 0644)
 	if err != nil {
 		return err
@@ -124,3 +125,63 @@ func (s *Storage) Load() error {
 	}
 	return nil
 }
+
+// Close closes the storage. The storage is held in memory, so Close only
+// exists to satisfy callers that manage its lifecycle; call Persist first to
+// keep the data.
+func (s *Storage) Close() error {
+	return nil
+}
+
+// GetSize returns the number of keys in the storage
+func (s *Storage) GetSize() int {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return len(s.Data)
+}
+
+// GetKeys returns the keys in the storage in sorted order
+func (s *Storage) GetKeys() []string {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	return s.sortedKeys()
+}
+
+// GetValues returns the values in the storage in key order
+func (s *Storage) GetValues() [][]byte {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	keys := s.sortedKeys()
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = s.Data[key]
+	}
+	return values
+}
+
+// Iterate calls fn for each key/value pair in key order. fn must not modify
+// the storage.
+func (s *Storage) Iterate(fn func(key string, value []byte)) {
+	s.Mutex.RLock()
+	defer s.Mutex.RUnlock()
+	for _, key := range s.sortedKeys() {
+		fn(key, s.Data[key])
+	}
+}
+
+// Clear removes all keys from the storage
+func (s *Storage) Clear() {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	s.Data = make(map[string][]byte)
+}
+
+// sortedKeys returns the keys in sorted order. The caller must hold the mutex.
+func (s *Storage) sortedKeys() []string {
+	keys := make([]string, 0, len(s.Data))
+	for key := range s.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,20 +1,16 @@
 package storage
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"math/rand"
-	"os"
 	"testing"
 	"time"
 )
 
 func TestStorage_Put(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key", []byte("value"))
@@ -25,8 +21,8 @@ func TestStorage_Put(t *testing.T) {
 
 func TestStorage_Get(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key", []byte("value"))
@@ -44,8 +40,8 @@ func TestStorage_Get(t *testing.T) {
 
 func TestStorage_Delete(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key", []byte("value"))
@@ -64,8 +60,8 @@ func TestStorage_Delete(t *testing.T) {
 
 func TestStorage_MarshalJSON(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key", []byte("value"))
@@ -88,8 +84,8 @@ func TestStorage_MarshalJSON(t *testing.T) {
 
 func TestStorage_UnmarshalJSON(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	data, err := json.Marshal(map[string][]byte{"key": []byte("value")})
@@ -111,8 +107,8 @@ func TestStorage_UnmarshalJSON(t *testing.T) {
 
 func TestStorage_Persist(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    t.TempDir(),
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key", []byte("value"))
@@ -139,8 +135,8 @@ func TestStorage_Persist(t *testing.T) {
 
 func TestStorage_Load(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    t.TempDir(),
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key", []byte("value"))
@@ -167,8 +163,8 @@ func TestStorage_Load(t *testing.T) {
 
 func TestStorage_Close(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Close()
@@ -179,8 +175,8 @@ func TestStorage_Close(t *testing.T) {
 
 func TestStorage_GetSize(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key1", []byte("value1"))
@@ -199,8 +195,8 @@ func TestStorage_GetSize(t *testing.T) {
 
 func TestStorage_GetKeys(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key1", []byte("value1"))
@@ -219,8 +215,8 @@ func TestStorage_GetKeys(t *testing.T) {
 
 func TestStorage_GetValues(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key1", []byte("value1"))
@@ -239,8 +235,8 @@ func TestStorage_GetValues(t *testing.T) {
 
 func TestStorage_Iterate(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key1", []byte("value1"))
@@ -271,8 +267,8 @@ func TestStorage_Iterate(t *testing.T) {
 
 func TestStorage_Clear(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	err := storage.Put("key1", []byte("value1"))
@@ -292,8 +288,8 @@ func TestStorage_Clear(t *testing.T) {
 
 func TestStorage_Random(t *testing.T) {
 	config := Config{
-		Path:     "storage",
-		Timeout:  10 * time.Second,
+		Path:    "storage",
+		Timeout: 10 * time.Second,
 	}
 	storage := NewStorage(config)
 	for i := 0; i < 100; i++ {
@@ -0,0 +1,423 @@
+// Package ca implements a small certificate authority for peer certificates
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultRootValidity is the default validity of a root certificate
+const DefaultRootValidity = 10 * 365 * 24 * time.Hour
+
+// DefaultValidity is the default validity of an issued certificate
+const DefaultValidity = 90 * 24 * time.Hour
+
+// clockSkew backdates certificates to tolerate clocks that are slightly
+// behind
+const clockSkew = 5 * time.Minute
+
+// KeyType is the type of key to generate
+type KeyType string
+
+const (
+	// KeyECDSA is an ECDSA key on the P-256 curve
+	KeyECDSA KeyType = "ecdsa"
+
+	// KeyEd25519 is an Ed25519 key
+	KeyEd25519 KeyType = "ed25519"
+)
+
+// GenerateKey generates a private key of the given type
+func GenerateKey(keyType KeyType) (crypto.Signer, error) {
+	switch keyType {
+	case KeyECDSA, "":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+// EncodeKey encodes a private key as PKCS #8 PEM
+func EncodeKey(key crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// DecodeKey decodes a PEM encoded private key
+func DecodeKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	return signer, nil
+}
+
+// EncodeCertificate encodes a certificate as PEM
+func EncodeCertificate(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+// DecodeCertificate decodes a PEM encoded certificate
+func DecodeCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// serialNumber returns a random 128 bit serial number
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// subjectKeyID returns the subject key identifier of a public key
+func subjectKeyID(public crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(der)
+	return sum[:], nil
+}
+
+// Request describes a certificate to issue
+type Request struct {
+	// CommonName is the common name of the subject
+	CommonName string
+
+	// DNSNames are the DNS subject alternative names
+	DNSNames []string
+
+	// IPAddresses are the IP subject alternative names
+	IPAddresses []net.IP
+
+	// KeyType is the type of key to generate
+	KeyType KeyType
+
+	// Validity is how long the certificate is valid for
+	Validity time.Duration
+}
+
+// Issued is an issued certificate and its private key
+type Issued struct {
+	// Certificate is the certificate
+	Certificate *x509.Certificate
+
+	// Key is the private key
+	Key crypto.Signer
+
+	// CertPEM is the PEM encoded certificate
+	CertPEM string
+
+	// KeyPEM is the PEM encoded private key
+	KeyPEM string
+}
+
+// newIssued encodes a certificate and key
+func newIssued(der []byte, key crypto.Signer) (*Issued, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := EncodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Issued{
+		Certificate: cert,
+		Key:         key,
+		CertPEM:     EncodeCertificate(cert),
+		KeyPEM:      keyPEM,
+	}, nil
+}
+
+// leafTemplate returns the template for an end entity certificate
+func leafTemplate(request Request, public crypto.PublicKey) (*x509.Certificate, error) {
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := subjectKeyID(public)
+	if err != nil {
+		return nil, err
+	}
+	validity := request.Validity
+	if validity <= 0 {
+		validity = DefaultValidity
+	}
+	now := time.Now()
+	names := request.DNSNames
+	if len(names) == 0 && len(request.IPAddresses) == 0 && request.CommonName != "" {
+		names = []string{request.CommonName}
+	}
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Skybridge"},
+			CommonName:   request.CommonName,
+		},
+		DNSNames:              names,
+		IPAddresses:           request.IPAddresses,
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(validity),
+		SubjectKeyId:          keyID,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}, nil
+}
+
+// SelfSigned creates a self-signed end entity certificate, for nodes that
+// are identified by their key rather than by a CA
+func SelfSigned(request Request) (*Issued, error) {
+	key, err := GenerateKey(request.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	template, err := leafTemplate(request, key.Public())
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	return newIssued(der, key)
+}
+
+// CA is a certificate authority
+type CA struct {
+	// Certificate is the root certificate
+	Certificate *x509.Certificate
+
+	// Key is the private key of the root certificate
+	Key crypto.Signer
+
+	// Mutex is a mutex to protect access to the revocations
+	Mutex sync.RWMutex
+
+	// Revoked are the revoked certificates, keyed by hex serial number
+	Revoked map[string]Revocation
+
+	// CRLNumber is the number of the last CRL published
+	CRLNumber int64
+}
+
+// NewRootCA creates a certificate authority with a new root certificate
+func NewRootCA(name string, keyType KeyType, validity time.Duration) (*CA, error) {
+	key, err := GenerateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := subjectKeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	if validity <= 0 {
+		validity = DefaultRootValidity
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Skybridge"},
+			CommonName:   name,
+		},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(validity),
+		SubjectKeyId:          keyID,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{
+		Certificate: cert,
+		Key:         key,
+		Revoked:     make(map[string]Revocation),
+	}, nil
+}
+
+// CertificatePEM returns the PEM encoded root certificate
+func (c *CA) CertificatePEM() string {
+	return EncodeCertificate(c.Certificate)
+}
+
+// Issue issues a certificate with a new key
+func (c *CA) Issue(request Request) (*Issued, error) {
+	key, err := GenerateKey(request.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	return c.sign(request, key)
+}
+
+// sign issues a certificate for an existing key
+func (c *CA) sign(request Request, key crypto.Signer) (*Issued, error) {
+	template, err := leafTemplate(request, key.Public())
+	if err != nil {
+		return nil, err
+	}
+	if template.NotAfter.After(c.Certificate.NotAfter) {
+		template.NotAfter = c.Certificate.NotAfter
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.Certificate, key.Public(), c.Key)
+	if err != nil {
+		return nil, err
+	}
+	return newIssued(der, key)
+}
+
+// Renew issues a new certificate with the same key, names and validity as
+// an issued certificate. Keeping the key keeps the peer ID derived from it.
+func (c *CA) Renew(issued *Issued) (*Issued, error) {
+	cert := issued.Certificate
+	if err := cert.CheckSignatureFrom(c.Certificate); err != nil {
+		return nil, fmt.Errorf("certificate was not issued by this CA: %w", err)
+	}
+	if c.IsRevoked(cert.SerialNumber) {
+		return nil, ErrRevoked
+	}
+	return c.sign(Request{
+		CommonName:  cert.Subject.CommonName,
+		DNSNames:    cert.DNSNames,
+		IPAddresses: cert.IPAddresses,
+		Validity:    cert.NotAfter.Sub(cert.NotBefore) - clockSkew,
+	}, issued.Key)
+}
+
+// caState is the persisted state of a CA
+type caState struct {
+	Revoked   []Revocation `json:"revoked"`
+	CRLNumber int64        `json:"crl_number"`
+}
+
+// Save writes the root certificate, key and revocations to a directory
+func (c *CA) Save(dir string) error {
+	keyPEM, err := EncodeKey(c.Key)
+	if err != nil {
+		return err
+	}
+	c.Mutex.RLock()
+	state := caState{CRLNumber: c.CRLNumber, Revoked: c.revocations()}
+	c.Mutex.RUnlock()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	files := []struct {
+		name string
+		data []byte
+		mode os.FileMode
+	}{
+		{"ca.crt", []byte(c.CertificatePEM()), 0644},
+		{"ca.key", []byte(keyPEM), 0600},
+		{"state.json", data, 0644},
+	}
+	for _, file := range files {
+		path := filepath.Join(dir, file.name)
+		if err := os.WriteFile(path+".tmp", file.data, file.mode); err != nil {
+			return err
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load reads a CA saved with Save
+func Load(dir string) (*CA, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	cert, err := DecodeCertificate(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, "ca.key"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := DecodeKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	ca := &CA{
+		Certificate: cert,
+		Key:         key,
+		Revoked:     make(map[string]Revocation),
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	if os.IsNotExist(err) {
+		return ca, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state caState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	ca.CRLNumber = state.CRLNumber
+	for _, revocation := range state.Revoked {
+		ca.Revoked[revocation.Serial] = revocation
+	}
+	return ca, nil
+}
@@ -0,0 +1,176 @@
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGenerateKey(t *testing.T) {
+	key, err := GenerateKey(KeyECDSA)
+	if err != nil {
+		t.Fatalf("Expected GenerateKey to succeed, got %v", err)
+	}
+	if _, ok := key.(*ecdsa.PrivateKey); !ok {
+		t.Errorf("Expected an ECDSA key, got %T", key)
+	}
+	key, err = GenerateKey(KeyEd25519)
+	if err != nil {
+		t.Fatalf("Expected GenerateKey to succeed, got %v", err)
+	}
+	if _, ok := key.(ed25519.PrivateKey); !ok {
+		t.Errorf("Expected an Ed25519 key, got %T", key)
+	}
+	if _, err := GenerateKey("dsa"); err == nil {
+		t.Errorf("Expected an unsupported key type to fail")
+	}
+
+	encoded, err := EncodeKey(key)
+	if err != nil {
+		t.Fatalf("Expected EncodeKey to succeed, got %v", err)
+	}
+	decoded, err := DecodeKey([]byte(encoded))
+	if err != nil {
+		t.Fatalf("Expected DecodeKey to succeed, got %v", err)
+	}
+	if !key.(ed25519.PrivateKey).Equal(decoded) {
+		t.Errorf("Expected the decoded key to match")
+	}
+}
+
+func TestCA_Issue(t *testing.T) {
+	for _, keyType := range []KeyType{KeyECDSA, KeyEd25519} {
+		root, err := NewRootCA("Skybridge Root", keyType, 0)
+		if err != nil {
+			t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+		}
+		if !root.Certificate.IsCA {
+			t.Errorf("Expected the root certificate to be a CA")
+		}
+
+		issued, err := root.Issue(Request{
+			CommonName:  "node1",
+			DNSNames:    []string{"node1.skybridge.local"},
+			IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+			KeyType:     keyType,
+			Validity:    time.Hour,
+		})
+		if err != nil {
+			t.Fatalf("Expected Issue to succeed, got %v", err)
+		}
+		cert := issued.Certificate
+		if cert.IsCA {
+			t.Errorf("Expected an issued certificate not to be a CA")
+		}
+		if cert.SerialNumber.Cmp(root.Certificate.SerialNumber) == 0 || cert.SerialNumber.BitLen() < 64 {
+			t.Errorf("Expected a random serial number, got %s", cert.SerialNumber)
+		}
+		if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "node1.skybridge.local" || len(cert.IPAddresses) != 1 {
+			t.Errorf("Expected the subject alternative names to be set, got %v %v", cert.DNSNames, cert.IPAddresses)
+		}
+
+		roots := x509.NewCertPool()
+		roots.AddCert(root.Certificate)
+		_, err = cert.Verify(x509.VerifyOptions{
+			Roots:     roots,
+			DNSName:   "node1.skybridge.local",
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil {
+			t.Errorf("Expected the certificate to verify against the root, got %v", err)
+		}
+		if _, err := tls.X509KeyPair([]byte(issued.CertPEM), []byte(issued.KeyPEM)); err != nil {
+			t.Errorf("Expected the PEM encoded pair to load, got %v", err)
+		}
+	}
+}
+
+func TestCA_Renew(t *testing.T) {
+	root, err := NewRootCA("Skybridge Root", KeyECDSA, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	issued, err := root.Issue(Request{CommonName: "node1", Validity: time.Hour})
+	if err != nil {
+		t.Fatalf("Expected Issue to succeed, got %v", err)
+	}
+	renewed, err := root.Renew(issued)
+	if err != nil {
+		t.Fatalf("Expected Renew to succeed, got %v", err)
+	}
+	if renewed.Certificate.SerialNumber.Cmp(issued.Certificate.SerialNumber) == 0 {
+		t.Errorf("Expected the renewed certificate to have a new serial number")
+	}
+	if string(renewed.Certificate.RawSubjectPublicKeyInfo) != string(issued.Certificate.RawSubjectPublicKeyInfo) {
+		t.Errorf("Expected the renewed certificate to keep the key")
+	}
+	if renewed.Certificate.Subject.CommonName != "node1" {
+		t.Errorf("Expected the renewed certificate to keep the subject")
+	}
+
+	root.Revoke(issued.Certificate.SerialNumber, "compromised")
+	if _, err := root.Renew(issued); err != ErrRevoked {
+		t.Errorf("Expected renewing a revoked certificate to fail, got %v", err)
+	}
+
+	other, err := NewRootCA("Other Root", KeyECDSA, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	if _, err := other.Renew(renewed); err == nil {
+		t.Errorf("Expected renewing a certificate from another CA to fail")
+	}
+}
+
+func TestSelfSigned(t *testing.T) {
+	issued, err := SelfSigned(Request{CommonName: "node1"})
+	if err != nil {
+		t.Fatalf("Expected SelfSigned to succeed, got %v", err)
+	}
+	if issued.Certificate.IsCA {
+		t.Errorf("Expected a self-signed node certificate not to be a CA")
+	}
+	if err := issued.Certificate.CheckSignatureFrom(issued.Certificate); err == nil {
+		t.Errorf("Expected a non-CA certificate not to be usable as an issuer")
+	}
+}
+
+func TestCA_SaveLoad(t *testing.T) {
+	root, err := NewRootCA("Skybridge Root", KeyEd25519, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	issued, err := root.Issue(Request{CommonName: "node1"})
+	if err != nil {
+		t.Fatalf("Expected Issue to succeed, got %v", err)
+	}
+	root.Revoke(issued.Certificate.SerialNumber, "retired")
+	if _, err := root.CRL(0); err != nil {
+		t.Fatalf("Expected CRL to succeed, got %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := root.Save(dir); err != nil {
+		t.Fatalf("Expected Save to succeed, got %v", err)
+	}
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Expected Load to succeed, got %v", err)
+	}
+	if !loaded.Certificate.Equal(root.Certificate) {
+		t.Errorf("Expected the loaded root certificate to match")
+	}
+	if !loaded.IsRevoked(issued.Certificate.SerialNumber) {
+		t.Errorf("Expected revocations to be persisted")
+	}
+	if loaded.CRLNumber != 1 {
+		t.Errorf("Expected the CRL number to be persisted, got %d", loaded.CRLNumber)
+	}
+	if _, err := loaded.Issue(Request{CommonName: "node2"}); err != nil {
+		t.Errorf("Expected the loaded CA to issue certificates, got %v", err)
+	}
+}
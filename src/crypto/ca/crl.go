@@ -0,0 +1,212 @@
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultCRLValidity is how long a published CRL is valid for
+const DefaultCRLValidity = 24 * time.Hour
+
+// ErrRevoked is returned for revoked certificates
+var ErrRevoked = errors.New("certificate has been revoked")
+
+// Revocation records a revoked certificate
+type Revocation struct {
+	// Serial is the hex serial number of the certificate
+	Serial string `json:"serial"`
+
+	// RevokedAt is the time the certificate was revoked
+	RevokedAt time.Time `json:"revoked_at"`
+
+	// Reason is the reason the certificate was revoked
+	Reason string `json:"reason,omitempty"`
+}
+
+// Revoke revokes the certificate with a serial number
+func (c *CA) Revoke(serial *big.Int, reason string) {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	key := serial.Text(16)
+	if _, ok := c.Revoked[key]; ok {
+		return
+	}
+	c.Revoked[key] = Revocation{
+		Serial:    key,
+		RevokedAt: time.Now().UTC(),
+		Reason:    reason,
+	}
+}
+
+// IsRevoked reports whether the certificate with a serial number is revoked
+func (c *CA) IsRevoked(serial *big.Int) bool {
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+	_, ok := c.Revoked[serial.Text(16)]
+	return ok
+}
+
+// revocations returns the revocations sorted by serial. The caller must
+// hold the mutex.
+func (c *CA) revocations() []Revocation {
+	revocations := make([]Revocation, 0, len(c.Revoked))
+	for _, revocation := range c.Revoked {
+		revocations = append(revocations, revocation)
+	}
+	sort.Slice(revocations, func(i, j int) bool {
+		return revocations[i].Serial < revocations[j].Serial
+	})
+	return revocations
+}
+
+// CRL publishes a new DER encoded certificate revocation list valid for the
+// given duration
+func (c *CA) CRL(validity time.Duration) ([]byte, error) {
+	if validity <= 0 {
+		validity = DefaultCRLValidity
+	}
+	c.Mutex.Lock()
+	c.CRLNumber++
+	number := c.CRLNumber
+	revocations := c.revocations()
+	c.Mutex.Unlock()
+
+	revoked := make([]pkix.RevokedCertificate, 0, len(revocations))
+	for _, revocation := range revocations {
+		serial, ok := new(big.Int).SetString(revocation.Serial, 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial %s", revocation.Serial)
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   serial,
+			RevocationTime: revocation.RevokedAt,
+		})
+	}
+	now := time.Now()
+	template := &x509.RevocationList{
+		RevokedCertificates: revoked,
+		Number:              big.NewInt(number),
+		ThisUpdate:          now,
+		NextUpdate:          now.Add(validity),
+	}
+	return x509.CreateRevocationList(rand.Reader, template, c.Certificate, c.Key)
+}
+
+// CRLPEM publishes a new PEM encoded certificate revocation list
+func (c *CA) CRLPEM(validity time.Duration) (string, error) {
+	der, err := c.CRL(validity)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})), nil
+}
+
+// CRLHandler returns an HTTP handler that publishes the current CRL
+func (c *CA) CRLHandler(validity time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		der, err := c.CRL(validity)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(der)
+	})
+}
+
+// Verifier verifies certificates against a root certificate and its CRL
+type Verifier struct {
+	roots *x509.CertPool
+	root  *x509.Certificate
+
+	mutex   sync.RWMutex
+	crl     *x509.RevocationList
+	revoked map[string]bool
+}
+
+// NewVerifier returns a verifier for certificates issued by a PEM encoded
+// root certificate
+func NewVerifier(rootPEM string) (*Verifier, error) {
+	root, err := DecodeCertificate([]byte(rootPEM))
+	if err != nil {
+		return nil, err
+	}
+	if !root.IsCA {
+		return nil, errors.New("root certificate is not a CA")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &Verifier{
+		roots:   roots,
+		root:    root,
+		revoked: make(map[string]bool),
+	}, nil
+}
+
+// SetCRL replaces the revocation list with a PEM or DER encoded CRL signed
+// by the root. Older CRLs than the current one are rejected.
+func (v *Verifier) SetCRL(data []byte) error {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return err
+	}
+	if err := crl.CheckSignatureFrom(v.root); err != nil {
+		return fmt.Errorf("CRL was not signed by the CA: %w", err)
+	}
+	revoked := make(map[string]bool, len(crl.RevokedCertificates))
+	for _, entry := range crl.RevokedCertificates {
+		revoked[entry.SerialNumber.Text(16)] = true
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.crl != nil && v.crl.Number != nil && crl.Number != nil && crl.Number.Cmp(v.crl.Number) < 0 {
+		return errors.New("CRL is older than the current CRL")
+	}
+	v.crl = crl
+	v.revoked = revoked
+	return nil
+}
+
+// Verify checks that a certificate chain, leaf first, was issued by the
+// root and that the leaf has not been revoked
+func (v *Verifier) Verify(chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return errors.New("no certificate presented")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return err
+	}
+
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	if v.revoked[chain[0].SerialNumber.Text(16)] {
+		return ErrRevoked
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package ca
+
+import (
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	root, err := NewRootCA("Skybridge Root", KeyECDSA, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	node1, err := root.Issue(Request{CommonName: "node1"})
+	if err != nil {
+		t.Fatalf("Expected Issue to succeed, got %v", err)
+	}
+	node2, err := root.Issue(Request{CommonName: "node2"})
+	if err != nil {
+		t.Fatalf("Expected Issue to succeed, got %v", err)
+	}
+	verifier, err := NewVerifier(root.CertificatePEM())
+	if err != nil {
+		t.Fatalf("Expected NewVerifier to succeed, got %v", err)
+	}
+	if err := verifier.Verify([]*x509.Certificate{node1.Certificate}); err != nil {
+		t.Errorf("Expected an issued certificate to verify, got %v", err)
+	}
+
+	selfSigned, err := SelfSigned(Request{CommonName: "intruder"})
+	if err != nil {
+		t.Fatalf("Expected SelfSigned to succeed, got %v", err)
+	}
+	if err := verifier.Verify([]*x509.Certificate{selfSigned.Certificate}); err == nil {
+		t.Errorf("Expected a certificate from outside the CA to be rejected")
+	}
+
+	root.Revoke(node1.Certificate.SerialNumber, "compromised")
+	crl, err := root.CRLPEM(time.Hour)
+	if err != nil {
+		t.Fatalf("Expected CRLPEM to succeed, got %v", err)
+	}
+	if err := verifier.SetCRL([]byte(crl)); err != nil {
+		t.Fatalf("Expected SetCRL to succeed, got %v", err)
+	}
+	if err := verifier.Verify([]*x509.Certificate{node1.Certificate}); err != ErrRevoked {
+		t.Errorf("Expected a revoked certificate to be rejected, got %v", err)
+	}
+	if err := verifier.Verify([]*x509.Certificate{node2.Certificate}); err != nil {
+		t.Errorf("Expected an unrevoked certificate to verify, got %v", err)
+	}
+
+	other, err := NewRootCA("Other Root", KeyECDSA, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	forged, err := other.CRL(time.Hour)
+	if err != nil {
+		t.Fatalf("Expected CRL to succeed, got %v", err)
+	}
+	if err := verifier.SetCRL(forged); err == nil {
+		t.Errorf("Expected a CRL from another CA to be rejected")
+	}
+}
+
+func TestVerifier_SetCRLRejectsOlder(t *testing.T) {
+	root, err := NewRootCA("Skybridge Root", KeyECDSA, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	older, _ := root.CRL(time.Hour)
+	newer, _ := root.CRL(time.Hour)
+	verifier, err := NewVerifier(root.CertificatePEM())
+	if err != nil {
+		t.Fatalf("Expected NewVerifier to succeed, got %v", err)
+	}
+	if err := verifier.SetCRL(newer); err != nil {
+		t.Fatalf("Expected SetCRL to succeed, got %v", err)
+	}
+	if err := verifier.SetCRL(older); err == nil {
+		t.Errorf("Expected an older CRL to be rejected")
+	}
+}
+
+func TestCA_CRLHandler(t *testing.T) {
+	root, err := NewRootCA("Skybridge Root", KeyECDSA, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	issued, err := root.Issue(Request{CommonName: "node1"})
+	if err != nil {
+		t.Fatalf("Expected Issue to succeed, got %v", err)
+	}
+	root.Revoke(issued.Certificate.SerialNumber, "retired")
+
+	server := httptest.NewServer(root.CRLHandler(time.Hour))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the CRL request to succeed, got %v", err)
+	}
+	defer resp.Body.Close()
+	der, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Expected to read the CRL, got %v", err)
+	}
+	verifier, err := NewVerifier(root.CertificatePEM())
+	if err != nil {
+		t.Fatalf("Expected NewVerifier to succeed, got %v", err)
+	}
+	if err := verifier.SetCRL(der); err != nil {
+		t.Fatalf("Expected the published CRL to load, got %v", err)
+	}
+	if err := verifier.Verify([]*x509.Certificate{issued.Certificate}); err != ErrRevoked {
+		t.Errorf("Expected the published CRL to revoke the certificate, got %v", err)
+	}
+}
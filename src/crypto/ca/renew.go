@@ -0,0 +1,69 @@
+package ca
+
+import (
+	"context"
+	"crypto/x509"
+	"log"
+	"time"
+)
+
+// DefaultRenewalWindow is how long before expiry a certificate is renewed
+const DefaultRenewalWindow = 30 * 24 * time.Hour
+
+// NeedsRenewal reports whether a certificate expires within the window
+func NeedsRenewal(cert *x509.Certificate, window time.Duration, now time.Time) bool {
+	return !now.Add(window).Before(cert.NotAfter)
+}
+
+// Renewer renews a certificate before it expires
+type Renewer struct {
+	// CA is the certificate authority that renews the certificate
+	CA *CA
+
+	// Issued is the current certificate
+	Issued *Issued
+
+	// Window is how long before expiry the certificate is renewed
+	Window time.Duration
+
+	// OnRenew is called with each renewed certificate
+	OnRenew func(issued *Issued)
+}
+
+// Check renews the certificate if it is within the renewal window and
+// reports whether it was renewed
+func (r *Renewer) Check(now time.Time) (bool, error) {
+	window := r.Window
+	if window <= 0 {
+		window = DefaultRenewalWindow
+	}
+	if !NeedsRenewal(r.Issued.Certificate, window, now) {
+		return false, nil
+	}
+	renewed, err := r.CA.Renew(r.Issued)
+	if err != nil {
+		return false, err
+	}
+	r.Issued = renewed
+	if r.OnRenew != nil {
+		r.OnRenew(renewed)
+	}
+	return true, nil
+}
+
+// Run checks the certificate at the given interval until the context is
+// done
+func (r *Renewer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Check(time.Now()); err != nil {
+			log.Printf("failed to renew certificate: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package ca
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNeedsRenewal(t *testing.T) {
+	issued, err := SelfSigned(Request{CommonName: "node1", Validity: 10 * time.Hour})
+	if err != nil {
+		t.Fatalf("Expected SelfSigned to succeed, got %v", err)
+	}
+	now := time.Now()
+	if NeedsRenewal(issued.Certificate, time.Hour, now) {
+		t.Errorf("Expected a fresh certificate not to need renewal")
+	}
+	if !NeedsRenewal(issued.Certificate, time.Hour, now.Add(9*time.Hour+time.Minute)) {
+		t.Errorf("Expected a certificate within the window to need renewal")
+	}
+}
+
+func TestRenewer_Check(t *testing.T) {
+	root, err := NewRootCA("Skybridge Root", KeyECDSA, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	issued, err := root.Issue(Request{CommonName: "node1", Validity: 10 * time.Hour})
+	if err != nil {
+		t.Fatalf("Expected Issue to succeed, got %v", err)
+	}
+	var renewals []*Issued
+	renewer := &Renewer{
+		CA:     root,
+		Issued: issued,
+		Window: time.Hour,
+		OnRenew: func(renewed *Issued) {
+			renewals = append(renewals, renewed)
+		},
+	}
+	renewed, err := renewer.Check(time.Now())
+	if err != nil || renewed {
+		t.Errorf("Expected no renewal outside the window, got %v %v", renewed, err)
+	}
+	renewed, err = renewer.Check(time.Now().Add(9*time.Hour + time.Minute))
+	if err != nil || !renewed {
+		t.Fatalf("Expected a renewal within the window, got %v %v", renewed, err)
+	}
+	if len(renewals) != 1 || renewer.Issued != renewals[0] {
+		t.Errorf("Expected OnRenew to be called with the renewed certificate")
+	}
+}
+
+func TestRenewer_Run(t *testing.T) {
+	root, err := NewRootCA("Skybridge Root", KeyECDSA, 0)
+	if err != nil {
+		t.Fatalf("Expected NewRootCA to succeed, got %v", err)
+	}
+	issued, err := root.Issue(Request{CommonName: "node1", Validity: time.Hour})
+	if err != nil {
+		t.Fatalf("Expected Issue to succeed, got %v", err)
+	}
+	renewed := make(chan *Issued, 1)
+	renewer := &Renewer{
+		CA:     root,
+		Issued: issued,
+		Window: 2 * time.Hour,
+		OnRenew: func(issued *Issued) {
+			select {
+			case renewed <- issued:
+			default:
+			}
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		renewer.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-renewed:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected Run to renew the certificate")
+	}
+	cancel()
+	<-done
+}
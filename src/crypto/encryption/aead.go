@@ -0,0 +1,47 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+)
+
+// NonceSize is the length of the nonces Seal and Open take
+const NonceSize = 12
+
+// Overhead is the length of the authentication tag Seal appends
+const Overhead = 16
+
+// Seal encrypts and authenticates plaintext with an explicit nonce, also
+// authenticating additionalData, and returns the ciphertext followed by the
+// tag. A nonce must never be reused with the same key; protocols that count
+// their messages can derive it from the count.
+func (e *Encryption) Seal(nonce, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := e.aead(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+// Open authenticates and decrypts a ciphertext from Seal with the same
+// nonce and additional data
+func (e *Encryption) Open(nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := e.aead(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// aead returns AES-GCM with the key after checking the nonce length
+func (e *Encryption) aead(nonce []byte) (cipher.AEAD, error) {
+	if len(nonce) != NonceSize {
+		return nil, fmt.Errorf("nonce must be %d octets, got %d", NonceSize, len(nonce))
+	}
+	block, err := aes.NewCipher(e.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,49 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryption_SealOpen(t *testing.T) {
+	encryption, err := NewEncryption("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatalf("Expected NewEncryption to succeed, got %v", err)
+	}
+	nonce := make([]byte, NonceSize)
+	header := []byte("header")
+	ciphertext, err := encryption.Seal(nonce, []byte("command"), header)
+	if err != nil {
+		t.Fatalf("Expected Seal to succeed, got %v", err)
+	}
+	if len(ciphertext) != len("command")+Overhead {
+		t.Errorf("Expected the tag to be appended, got %d octets", len(ciphertext))
+	}
+	plaintext, err := encryption.Open(nonce, ciphertext, header)
+	if err != nil || !bytes.Equal(plaintext, []byte("command")) {
+		t.Errorf("Expected Open to recover the plaintext, got %q, %v", plaintext, err)
+	}
+
+	if _, err := encryption.Open(nonce, ciphertext, []byte("changed")); err == nil {
+		t.Errorf("Expected Open to reject changed additional data")
+	}
+	other := append([]byte(nil), nonce...)
+	other[0] = 1
+	if _, err := encryption.Open(other, ciphertext, header); err == nil {
+		t.Errorf("Expected Open to reject another nonce")
+	}
+	sealed, _ := encryption.Seal(nonce, []byte("command"), header)
+	if !bytes.Equal(sealed, ciphertext) {
+		t.Errorf("Expected the same nonce to give the same ciphertext")
+	}
+
+	if _, err := NewEncryption("seventeen-chars!!"); err == nil {
+		t.Errorf("Expected a key of an invalid AES length to fail")
+	}
+	if _, err := encryption.Seal(nonce[:8], []byte("command"), header); err == nil {
+		t.Errorf("Expected Seal to reject a short nonce")
+	}
+	if _, err := encryption.Open(nonce[:8], ciphertext, header); err == nil {
+		t.Errorf("Expected Open to reject a short nonce")
+	}
+}
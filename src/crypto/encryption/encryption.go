@@ -14,8 +14,10 @@ type Encryption struct {
 }
 
 func NewEncryption(key string) (*Encryption, error) {
-	if len(key) < 16 {
-		return nil, errors.New("key must be at least 16 characters long")
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, errors.New("key must be 16, 24 or 32 characters long")
 	}
 	return &Encryption{[]byte(key)}, nil
 }
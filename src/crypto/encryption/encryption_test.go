@@ -1,11 +1,12 @@
 package encryption
 
 import (
+	"fmt"
 	"testing"
 )
 
 func TestEncryption_EncryptDecrypt(t *testing.T) {
-	encryption, err := NewEncryption("my_secret_key")
+	encryption, err := NewEncryption("my_secret_key_16")
 	if err != nil {
 		t.Errorf("Expected NewEncryption to return a non-nil error")
 	}
@@ -24,7 +25,7 @@ func TestEncryption_EncryptDecrypt(t *testing.T) {
 }
 
 func TestEncryption_EncryptBase64DecryptBase64(t *testing.T) {
-	encryption, err := NewEncryption("my_secret_key")
+	encryption, err := NewEncryption("my_secret_key_16")
 	if err != nil {
 		t.Errorf("Expected NewEncryption to return a non-nil error")
 	}
@@ -47,10 +48,14 @@ func TestEncryption_InvalidKey(t *testing.T) {
 	if err == nil {
 		t.Errorf("Expected NewEncryption to return a non-nil error for short key")
 	}
+	_, err = NewEncryption("my_secret_key_of_20b")
+	if err == nil {
+		t.Errorf("Expected NewEncryption to return a non-nil error for a key that is not an AES key size")
+	}
 }
 
 func TestEncryption_Random(t *testing.T) {
-	encryption, err := NewEncryption("my_secret_key")
+	encryption, err := NewEncryption("my_secret_key_16")
 	if err != nil {
 		t.Errorf("Expected NewEncryption to return a non-nil error")
 	}
@@ -4,11 +4,17 @@ import (
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sh a256"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 )
 
+// MinKeySize is the smallest RSA modulus, in bits, accepted for signing
+const MinKeySize = 2048
+
+// ErrKeyTooSmall is returned when signing with a key below MinKeySize
+var ErrKeyTooSmall = errors.New("signature: RSA key is smaller than 2048 bits")
+
 type Signature struct {
 	privateKey *rsa.PrivateKey
 }
@@ -18,6 +24,9 @@ func NewSignature(privateKey *rsa.PrivateKey) *Signature {
 }
 
 func (s *Signature) Sign(data []byte) ([]byte, error) {
+	if s.privateKey.N.BitLen() < MinKeySize {
+		return nil, ErrKeyTooSmall
+	}
 	hash := sha256.Sum256(data)
 	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, hash[:])
 	if err != nil {
@@ -47,3 +56,25 @@ func (s *Signature) VerifyBase64(data []byte, signature string) bool {
 	}
 	return s.Verify(data, signatureBytes)
 }
+
+type Verifier struct {
+	publicKey *rsa.PublicKey
+}
+
+func NewVerifier(publicKey *rsa.PublicKey) *Verifier {
+	return &Verifier{publicKey}
+}
+
+func (v *Verifier) Verify(data []byte, signature []byte) bool {
+	hash := sha256.Sum256(data)
+	err := rsa.VerifyPKCS1v15(v.publicKey, crypto.SHA256, hash[:], signature)
+	return err == nil
+}
+
+func (v *Verifier) VerifyBase64(data []byte, signature string) bool {
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return v.Verify(data, signatureBytes)
+}
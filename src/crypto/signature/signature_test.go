@@ -3,6 +3,7 @@ package signature
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"fmt"
 	"testing"
 )
 
@@ -71,9 +72,12 @@ func TestSignature_VerifyBase64(t *testing.T) {
 }
 
 func TestSignature_InvalidKey(t *testing.T) {
-	_, err := rsa.GenerateKey(rand.Reader, 1024)
-	if err == nil {
-		t.Errorf("Expected GenerateKey to return a non-nil error for small key size")
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err := NewSignature(privateKey).Sign([]byte("Hello, World!")); err != ErrKeyTooSmall {
+		t.Errorf("Expected Sign to return ErrKeyTooSmall for small key size, got %v", err)
 	}
 }
 
@@ -94,3 +98,23 @@ func TestSignature_Random(t *testing.T) {
 		}
 	}
 }
+
+func TestVerifier_Verify(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Errorf("Expected GenerateKey to return a non-nil error")
+	}
+	signature := NewSignature(privateKey)
+	verifier := NewVerifier(&privateKey.PublicKey)
+	data := []byte("Hello, World!")
+	signed, err := signature.Sign(data)
+	if err != nil {
+		t.Errorf("Expected Sign to return a non-nil error")
+	}
+	if !verifier.Verify(data, signed) {
+		t.Errorf("Expected Verify to return true for a valid signature")
+	}
+	if verifier.Verify([]byte("Goodbye, World!"), signed) {
+		t.Errorf("Expected Verify to return false for different data")
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/skybridge/blockchain/storage"
+	"github.com/skybridge/crypto/signature"
+)
+
+// commands are the subcommands accepted before the server flags
+var commands = map[string]func(args []string) error{
+	"backup":        runBackup,
+	"restore":       runRestore,
+	"verify-backup": runVerifyBackup,
+}
+
+// runBackup writes a signed backup of the storage directory to an archive.
+// It reads the storage.json last persisted to the directory, not the state of
+// a running node, so writes the node has not yet persisted are not included.
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s backup -out FILE [flags]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Backs up the storage.json last persisted in the data directory. A running")
+		fmt.Fprintln(fs.Output(), "node's unpersisted writes are not included; persist the node's storage first.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	dataDir := fs.String("data", ".", "directory containing the persisted storage.json to back up")
+	out := fs.String("out", "", "path of the backup archive to write")
+	keyFile := fs.String("key", "", "PEM encoded RSA private key used to sign the manifest")
+	chunkSize := fs.Int("chunk-size", storage.DefaultChunkSize, "maximum size of a backup chunk in bytes")
+	fs.Parse(args)
+	if *out == "" {
+		return errors.New("backup: -out is required")
+	}
+
+	s := storage.NewStorage(storage.Config{Path: *dataDir})
+	if err := s.Load(); err != nil {
+		return fmt.Errorf("backup: loading storage: %w", err)
+	}
+
+	opts := storage.BackupOptions{ChunkSize: *chunkSize}
+	if *keyFile != "" {
+		key, err := loadPrivateKey(*keyFile)
+		if err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+		opts.Signer = signature.NewSignature(key)
+	}
+
+	manifest, err := s.BackupToFile(*out, opts)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	fmt.Printf("Backed up %d entries in %d chunks to %s (root %s)\n", manifest.Entries, len(manifest.Chunks), *out, manifest.RootHash)
+	return nil
+}
+
+// runRestore verifies a backup archive and restores it into a storage directory
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s restore -in FILE [flags]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Restores a backup into the storage.json in the data directory. Stop any node")
+		fmt.Fprintln(fs.Output(), "using the directory first; a running node overwrites the file when it persists.")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+	dataDir := fs.String("data", ".", "directory whose storage.json the backup is restored into")
+	in := fs.String("in", "", "path of the backup archive to read")
+	pubKeyFile := fs.String("pubkey", "", "PEM encoded RSA public key used to verify the manifest")
+	requireSignature := fs.Bool("require-signature", false, "reject archives without a valid signature")
+	merge := fs.Bool("merge", false, "merge into the existing storage instead of replacing it")
+	fs.Parse(args)
+	if *in == "" {
+		return errors.New("restore: -in is required")
+	}
+
+	opts, err := restoreOptions(*pubKeyFile, *requireSignature)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	opts.Merge = *merge
+
+	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	s := storage.NewStorage(storage.Config{Path: *dataDir})
+	if *merge {
+		if err := s.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("restore: loading storage: %w", err)
+		}
+	}
+
+	manifest, err := s.RestoreFromFile(*in, opts)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if err := s.Persist(); err != nil {
+		return fmt.Errorf("restore: persisting storage: %w", err)
+	}
+	fmt.Printf("Restored %d entries from %s taken at %s\n", manifest.Entries, *in, manifest.CreatedAt)
+	return nil
+}
+
+// runVerifyBackup checks the integrity and signature of a backup archive
+func runVerifyBackup(args []string) error {
+	fs := flag.NewFlagSet("verify-backup", flag.ExitOnError)
+	in := fs.String("in", "", "path of the backup archive to verify")
+	pubKeyFile := fs.String("pubkey", "", "PEM encoded RSA public key used to verify the manifest")
+	requireSignature := fs.Bool("require-signature", false, "reject archives without a valid signature")
+	fs.Parse(args)
+	if *in == "" {
+		return errors.New("verify-backup: -in is required")
+	}
+
+	opts, err := restoreOptions(*pubKeyFile, *requireSignature)
+	if err != nil {
+		return fmt.Errorf("verify-backup: %w", err)
+	}
+	file, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("verify-backup: %w", err)
+	}
+	defer file.Close()
+
+	manifest, err := storage.VerifyBackup(file, opts)
+	if err != nil {
+		return fmt.Errorf("verify-backup: %w", err)
+	}
+	fmt.Printf("Backup %s is valid: %d entries in %d chunks (root %s, signed: %t)\n", *in, manifest.Entries, len(manifest.Chunks), manifest.RootHash, len(manifest.Signature) > 0)
+	return nil
+}
+
+// restoreOptions builds the restore options for a public key file
+func restoreOptions(pubKeyFile string, requireSignature bool) (storage.RestoreOptions, error) {
+	opts := storage.RestoreOptions{RequireSignature: requireSignature}
+	if pubKeyFile == "" {
+		return opts, nil
+	}
+	key, err := loadPublicKey(pubKeyFile)
+	if err != nil {
+		return opts, err
+	}
+	opts.Verifier = signature.NewVerifier(key)
+	return opts, nil
+}
+
+// loadPrivateKey reads a PEM encoded RSA private key
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA private key", path)
+	}
+	return rsaKey, nil
+}
+
+// loadPublicKey reads a PEM encoded RSA public key
+func loadPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA public key", path)
+	}
+	return rsaKey, nil
+}
+
+// readPEM reads the first PEM block in a file
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", path)
+	}
+	return block, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skybridge/blockchain/storage"
+)
+
+func TestBackupCommands(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "data")
+	restoreDir := filepath.Join(dir, "restored")
+	archive := filepath.Join(dir, "storage.bak")
+	os.MkdirAll(dataDir, 0755)
+
+	s := storage.NewStorage(storage.Config{Path: dataDir})
+	s.Put("key", []byte("value"))
+	if err := s.Persist(); err != nil {
+		t.Fatalf("Failed to persist storage: %v", err)
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	pubKeyFile := filepath.Join(dir, "key.pub")
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}), 0600)
+	os.WriteFile(pubKeyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&privateKey.PublicKey)}), 0644)
+
+	if err := runBackup([]string{"-data", dataDir, "-out", archive, "-key", keyFile}); err != nil {
+		t.Fatalf("Failed to run backup: %v", err)
+	}
+	if err := runVerifyBackup([]string{"-in", archive, "-pubkey", pubKeyFile, "-require-signature"}); err != nil {
+		t.Errorf("Failed to verify backup: %v", err)
+	}
+	if err := runRestore([]string{"-data", restoreDir, "-in", archive, "-pubkey", pubKeyFile, "-require-signature"}); err != nil {
+		t.Fatalf("Failed to run restore: %v", err)
+	}
+
+	restored := storage.NewStorage(storage.Config{Path: restoreDir})
+	if err := restored.Load(); err != nil {
+		t.Fatalf("Failed to load restored storage: %v", err)
+	}
+	value, err := restored.Get("key")
+	if err != nil || string(value) != "value" {
+		t.Errorf("Expected restored value to be 'value', got %s", value)
+	}
+}
@@ -7,10 +7,6 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"runtime"
-	"time"
-
-	"github.com/your-username/utils"
 )
 
 var (
@@ -19,13 +15,25 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	flag.Parse()
 
 	fmt.Printf("Starting application %s (version %s) built at %s\n", filepath.Base(os.Args[0]), version, buildTime)
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, "Welcome to the main application!")
-	})
+	http.HandleFunc("/", mainHandler)
 
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
+
+// mainHandler serves the application index
+func mainHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "Welcome to the main application!")
+}
@@ -0,0 +1,309 @@
+package satellite
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StationCapability is what a ground station antenna can serve. Each
+// antenna tracks one satellite at a time.
+type StationCapability struct {
+	// Station is the id of the ground station
+	Station string `json:"station"`
+
+	// DataRate is the downlink rate in bits per second the station receives
+	DataRate float64 `json:"dataRate"`
+
+	// Bands are the frequency bands the station can receive, such as "S"
+	// or "X", or empty for any band
+	Bands []string `json:"bands,omitempty"`
+
+	// Turnaround is the time the antenna needs between two satellites to
+	// re-point and reconfigure
+	Turnaround time.Duration `json:"turnaround"`
+
+	// MinDuration is the shortest contact worth scheduling
+	MinDuration time.Duration `json:"minDuration"`
+}
+
+// receives returns whether the station can receive a band
+func (c StationCapability) receives(band string) bool {
+	if band == "" || len(c.Bands) == 0 {
+		return true
+	}
+	for _, b := range c.Bands {
+		if strings.EqualFold(b, band) {
+			return true
+		}
+	}
+	return false
+}
+
+// DownlinkRequest is the data a satellite needs to downlink
+type DownlinkRequest struct {
+	// Satellite is the id of the satellite
+	Satellite string `json:"satellite"`
+
+	// Demand is the number of bytes to downlink
+	Demand int64 `json:"demand"`
+
+	// Priority orders the requests. Requests are served in order of
+	// decreasing priority, so a request only gets station time that no
+	// request of higher priority could use.
+	Priority int `json:"priority"`
+
+	// Band is the frequency band of the satellite's downlink, or empty if
+	// any station can receive it
+	Band string `json:"band,omitempty"`
+}
+
+// Assignment is a contact allocated to a satellite on a ground station
+type Assignment struct {
+	Contact
+
+	// Satellite is the id of the satellite
+	Satellite string `json:"satellite"`
+
+	// Bytes is the data downlinked during the contact
+	Bytes int64 `json:"bytes"`
+}
+
+// UnservedReason explains why a request was not fully served
+type UnservedReason string
+
+// Reasons for requests not being fully served
+const (
+	// ReasonNoPasses is given when the satellite has no passes over a
+	// station that can receive it
+	ReasonNoPasses UnservedReason = "no passes over a capable station"
+
+	// ReasonStationsBusy is given when stations were serving other
+	// satellites during the satellite's passes
+	ReasonStationsBusy UnservedReason = "stations busy with other satellites"
+
+	// ReasonInsufficientContact is given when the satellite was allocated
+	// all of its passes and they are too short for its demand
+	ReasonInsufficientContact UnservedReason = "not enough contact time"
+)
+
+// Unserved is a request that could not be fully served
+type Unserved struct {
+	DownlinkRequest
+
+	// Allocated is the number of bytes allocated to the request
+	Allocated int64 `json:"allocated"`
+
+	// Reason explains why the rest of the demand was not allocated
+	Reason UnservedReason `json:"reason"`
+
+	// Conflicts are the satellites and stations, as satellite@station,
+	// that took the time of the request's passes
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// String returns a readable explanation of the unserved request
+func (u Unserved) String() string {
+	explanation := fmt.Sprintf("%s: %d of %d bytes allocated, %s", u.Satellite, u.Allocated, u.Demand, u.Reason)
+	if len(u.Conflicts) > 0 {
+		explanation += " (" + strings.Join(u.Conflicts, ", ") + ")"
+	}
+	return explanation
+}
+
+// Allocation is a conflict-free assignment of ground station time to
+// satellites
+type Allocation struct {
+	// Assignments are the allocated contacts ordered by start time
+	Assignments []Assignment `json:"assignments"`
+
+	// Unserved are the requests whose demand was not fully allocated
+	Unserved []Unserved `json:"unserved"`
+}
+
+// Allocated returns the number of bytes allocated to a satellite
+func (a *Allocation) Allocated(satellite string) int64 {
+	var bytes int64
+	for _, assignment := range a.Assignments {
+		if assignment.Satellite == satellite {
+			bytes += assignment.Bytes
+		}
+	}
+	return bytes
+}
+
+// ContactPlan returns the contact plan of a satellite, for its scheduler
+func (a *Allocation) ContactPlan(satellite string) ContactPlan {
+	var contacts []Contact
+	for _, assignment := range a.Assignments {
+		if assignment.Satellite == satellite {
+			contacts = append(contacts, assignment.Contact)
+		}
+	}
+	return NewContactPlan(contacts)
+}
+
+// Allocate assigns the passes of satellites over ground stations to the
+// requests of the satellites so that each station serves one satellite at
+// a time, allowing for its turnaround. Requests are served in order of
+// priority. Within a priority, the contact downlinking the most data is
+// allocated first, and contacts end once a satellite's demand is met so
+// the station is free for others. Passes of satellites without a request
+// or over stations without a capability are ignored.
+func Allocate(passes []Pass, stations []StationCapability, requests []DownlinkRequest) *Allocation {
+	capabilities := make(map[string]StationCapability, len(stations))
+	for _, station := range stations {
+		capabilities[station.Station] = station
+	}
+	requests = append([]DownlinkRequest(nil), requests...)
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].Priority > requests[j].Priority
+	})
+
+	allocation := &Allocation{}
+	busy := make(map[string][]Assignment)
+	remaining := make(map[int]int64, len(requests))
+	for i, request := range requests {
+		remaining[i] = request.Demand
+	}
+
+	for first := 0; first < len(requests); {
+		last := first
+		for last < len(requests) && requests[last].Priority == requests[first].Priority {
+			last++
+		}
+		for {
+			best, bestRequest := Assignment{}, -1
+			for i := first; i < last; i++ {
+				request := requests[i]
+				if remaining[i] <= 0 {
+					continue
+				}
+				for _, pass := range passes {
+					capability, ok := capabilities[pass.Station]
+					if pass.Satellite != request.Satellite || !ok || !capability.receives(request.Band) {
+						continue
+					}
+					candidate, ok := allocatePass(pass, capability, busy[pass.Station], remaining[i])
+					if ok && (bestRequest < 0 || candidate.Bytes > best.Bytes ||
+						candidate.Bytes == best.Bytes && candidate.Start.Before(best.Start)) {
+						best, bestRequest = candidate, i
+					}
+				}
+			}
+			if bestRequest < 0 {
+				break
+			}
+			remaining[bestRequest] -= best.Bytes
+			busy[best.Station] = append(busy[best.Station], best)
+			allocation.Assignments = append(allocation.Assignments, best)
+		}
+		first = last
+	}
+
+	sort.SliceStable(allocation.Assignments, func(i, j int) bool {
+		return allocation.Assignments[i].Start.Before(allocation.Assignments[j].Start)
+	})
+	for i, request := range requests {
+		if remaining[i] > 0 {
+			allocation.Unserved = append(allocation.Unserved, explainUnserved(request, request.Demand-remaining[i], passes, capabilities, busy))
+		}
+	}
+	return allocation
+}
+
+// allocatePass returns the contact for the longest time in a pass that the
+// station is free, shortened to the time needed to downlink demand bytes
+func allocatePass(pass Pass, capability StationCapability, busy []Assignment, demand int64) (Assignment, bool) {
+	if capability.DataRate <= 0 {
+		return Assignment{}, false
+	}
+	start, end := freeTime(busy, pass.AOS, pass.LOS, capability.Turnaround)
+	duration := end.Sub(start)
+	if duration <= 0 || duration < capability.MinDuration {
+		return Assignment{}, false
+	}
+
+	bytes := int64(duration.Seconds() * capability.DataRate / 8)
+	if bytes > demand {
+		needed := time.Duration(math.Ceil(float64(demand) * 8 / capability.DataRate * float64(time.Second)))
+		if needed < capability.MinDuration {
+			needed = capability.MinDuration
+		}
+		if needed < duration {
+			end = start.Add(needed)
+		}
+		bytes = demand
+	}
+	if bytes <= 0 {
+		return Assignment{}, false
+	}
+	return Assignment{
+		Contact:   Contact{Station: pass.Station, Start: start, End: end},
+		Satellite: pass.Satellite,
+		Bytes:     bytes,
+	}, true
+}
+
+// freeTime returns the longest interval between from and to in which a
+// station is not busy, keeping turnaround clear of its other contacts
+func freeTime(busy []Assignment, from, to time.Time, turnaround time.Duration) (time.Time, time.Time) {
+	sorted := append([]Assignment(nil), busy...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	var bestStart, bestEnd time.Time
+	cursor := from
+	consider := func(end time.Time) {
+		if end.After(to) {
+			end = to
+		}
+		if end.Sub(cursor) > bestEnd.Sub(bestStart) {
+			bestStart, bestEnd = cursor, end
+		}
+	}
+	for _, assignment := range sorted {
+		consider(assignment.Start.Add(-turnaround))
+		if next := assignment.End.Add(turnaround); next.After(cursor) {
+			cursor = next
+		}
+	}
+	consider(to)
+	return bestStart, bestEnd
+}
+
+// explainUnserved returns why a request was only allocated some of its
+// demand
+func explainUnserved(request DownlinkRequest, allocated int64, passes []Pass, capabilities map[string]StationCapability, busy map[string][]Assignment) Unserved {
+	unserved := Unserved{DownlinkRequest: request, Allocated: allocated, Reason: ReasonNoPasses}
+	seen := make(map[string]bool)
+	for _, pass := range passes {
+		capability, ok := capabilities[pass.Station]
+		if pass.Satellite != request.Satellite || !ok || !capability.receives(request.Band) || capability.DataRate <= 0 {
+			continue
+		}
+		if unserved.Reason == ReasonNoPasses {
+			unserved.Reason = ReasonInsufficientContact
+		}
+		for _, assignment := range busy[pass.Station] {
+			if assignment.Satellite == request.Satellite {
+				continue
+			}
+			if assignment.Start.Add(-capability.Turnaround).Before(pass.LOS) && assignment.End.Add(capability.Turnaround).After(pass.AOS) {
+				conflict := assignment.Satellite + "@" + assignment.Station
+				if !seen[conflict] {
+					seen[conflict] = true
+					unserved.Conflicts = append(unserved.Conflicts, conflict)
+				}
+			}
+		}
+	}
+	if len(unserved.Conflicts) > 0 {
+		unserved.Reason = ReasonStationsBusy
+		sort.Strings(unserved.Conflicts)
+	}
+	return unserved
+}
@@ -0,0 +1,233 @@
+package satellite
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// allocationStart is the start of the synthetic passes in the allocation
+// tests
+var allocationStart = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// testPass returns a pass from start to end minutes after allocationStart
+func testPass(satellite, station string, start, end int) Pass {
+	return Pass{
+		Satellite: satellite,
+		Station:   station,
+		AOS:       allocationStart.Add(time.Duration(start) * time.Minute),
+		LOS:       allocationStart.Add(time.Duration(end) * time.Minute),
+	}
+}
+
+// checkConflictFree fails the test if a station serves two satellites at
+// once or without its turnaround between them
+func checkConflictFree(t *testing.T, allocation *Allocation, stations []StationCapability) {
+	t.Helper()
+	for _, station := range stations {
+		var previous *Assignment
+		for i := range allocation.Assignments {
+			assignment := &allocation.Assignments[i]
+			if assignment.Station != station.Station {
+				continue
+			}
+			if previous != nil && assignment.Start.Before(previous.End.Add(station.Turnaround)) {
+				t.Errorf("Expected %s to be free between %s and %s, got %+v and %+v",
+					station.Station, previous.Satellite, assignment.Satellite, *previous, *assignment)
+			}
+			previous = assignment
+		}
+	}
+}
+
+func TestAllocate_Priority(t *testing.T) {
+	stations := []StationCapability{{Station: "gs1", DataRate: 8000}}
+	passes := []Pass{
+		testPass("sat1", "gs1", 0, 10),
+		testPass("sat2", "gs1", 0, 10),
+	}
+	requests := []DownlinkRequest{
+		{Satellite: "sat1", Demand: 1e6, Priority: 1},
+		{Satellite: "sat2", Demand: 1e6, Priority: 2},
+	}
+
+	allocation := Allocate(passes, stations, requests)
+	if len(allocation.Assignments) != 1 || allocation.Assignments[0].Satellite != "sat2" {
+		t.Fatalf("Expected the station to serve the higher priority satellite, got %+v", allocation.Assignments)
+	}
+	if bytes := allocation.Allocated("sat2"); bytes != 600000 {
+		t.Errorf("Expected 10 minutes at 1 kB/s, got %d bytes", bytes)
+	}
+
+	if len(allocation.Unserved) != 2 {
+		t.Fatalf("Expected both requests to be short, got %+v", allocation.Unserved)
+	}
+	for _, unserved := range allocation.Unserved {
+		switch unserved.Satellite {
+		case "sat1":
+			if unserved.Reason != ReasonStationsBusy || !reflect.DeepEqual(unserved.Conflicts, []string{"sat2@gs1"}) {
+				t.Errorf("Expected sat1 to lose its pass to sat2, got %+v", unserved)
+			}
+			if !strings.Contains(unserved.String(), "sat1: 0 of 1000000 bytes allocated") {
+				t.Errorf("Expected a readable explanation, got %q", unserved.String())
+			}
+		case "sat2":
+			if unserved.Reason != ReasonInsufficientContact || unserved.Allocated != 600000 {
+				t.Errorf("Expected sat2 to run out of contact time, got %+v", unserved)
+			}
+		}
+	}
+}
+
+func TestAllocate_SharesOverlappingPasses(t *testing.T) {
+	stations := []StationCapability{{Station: "gs1", DataRate: 8000, Turnaround: time.Minute}}
+	passes := []Pass{
+		testPass("sat1", "gs1", 0, 10),
+		testPass("sat2", "gs1", 5, 20),
+	}
+	requests := []DownlinkRequest{
+		{Satellite: "sat1", Demand: 1e6},
+		{Satellite: "sat2", Demand: 1e6},
+	}
+
+	allocation := Allocate(passes, stations, requests)
+	checkConflictFree(t, allocation, stations)
+	if len(allocation.Assignments) != 2 {
+		t.Fatalf("Expected both satellites to get part of their pass, got %+v", allocation.Assignments)
+	}
+	// sat2's 15 minutes are allocated first, leaving sat1 the 4 minutes
+	// before it and its turnaround
+	first, second := allocation.Assignments[0], allocation.Assignments[1]
+	if first.Satellite != "sat1" || first.End.Sub(first.Start) != 4*time.Minute {
+		t.Errorf("Expected sat1 to get 4 minutes, got %+v", first)
+	}
+	if second.Satellite != "sat2" || second.End.Sub(second.Start) != 15*time.Minute {
+		t.Errorf("Expected sat2 to get its whole pass, got %+v", second)
+	}
+}
+
+func TestAllocate_EndsContactsWhenDemandIsMet(t *testing.T) {
+	stations := []StationCapability{{Station: "gs1", DataRate: 8000, MinDuration: time.Minute}}
+	passes := []Pass{
+		testPass("sat1", "gs1", 0, 30),
+		testPass("sat2", "gs1", 0, 30),
+	}
+	requests := []DownlinkRequest{
+		{Satellite: "sat1", Demand: 120000, Priority: 1},
+		{Satellite: "sat2", Demand: 10000},
+	}
+
+	allocation := Allocate(passes, stations, requests)
+	checkConflictFree(t, allocation, stations)
+	if len(allocation.Unserved) != 0 {
+		t.Errorf("Expected both requests to be served, got %v", allocation.Unserved)
+	}
+	if allocation.Allocated("sat1") != 120000 || allocation.Allocated("sat2") != 10000 {
+		t.Errorf("Expected the demands to be allocated exactly, got %+v", allocation.Assignments)
+	}
+
+	// sat1 needs two minutes, sat2 ten seconds stretched to the minimum
+	plan := allocation.ContactPlan("sat2")
+	if len(plan) != 1 || plan[0].End.Sub(plan[0].Start) != time.Minute {
+		t.Errorf("Expected sat2 to get a one minute contact, got %+v", plan)
+	}
+	if plan := allocation.ContactPlan("sat1"); len(plan) != 1 || plan[0].End.Sub(plan[0].Start) != 2*time.Minute {
+		t.Errorf("Expected sat1 to get a two minute contact, got %+v", plan)
+	}
+}
+
+func TestAllocate_MaximizesData(t *testing.T) {
+	stations := []StationCapability{
+		{Station: "gs1", DataRate: 80000},
+		{Station: "gs2", DataRate: 8000},
+	}
+	// Both satellites see both stations at once, so the faster station
+	// goes to the satellite whose demand it carries most of
+	passes := []Pass{
+		testPass("sat1", "gs1", 0, 10),
+		testPass("sat1", "gs2", 0, 10),
+		testPass("sat2", "gs1", 0, 10),
+		testPass("sat2", "gs2", 0, 10),
+	}
+	requests := []DownlinkRequest{
+		{Satellite: "sat1", Demand: 5e6},
+		{Satellite: "sat2", Demand: 5e5},
+	}
+
+	allocation := Allocate(passes, stations, requests)
+	checkConflictFree(t, allocation, stations)
+	if allocation.Allocated("sat1") != 5e6 {
+		t.Errorf("Expected sat1 to get gs1, got %+v", allocation.Assignments)
+	}
+	if allocation.Allocated("sat2") != 5e5 {
+		t.Errorf("Expected sat2 to be served by gs2, got %+v", allocation.Assignments)
+	}
+}
+
+func TestAllocate_Capabilities(t *testing.T) {
+	stations := []StationCapability{
+		{Station: "gs1", DataRate: 8000, Bands: []string{"S"}},
+		{Station: "gs2", DataRate: 8000, Bands: []string{"X"}},
+	}
+	passes := []Pass{
+		testPass("sat1", "gs1", 0, 10),
+		testPass("sat1", "gs2", 20, 30),
+		testPass("sat2", "gs1", 40, 50),
+		testPass("sat3", "gs9", 0, 10),
+	}
+	requests := []DownlinkRequest{
+		{Satellite: "sat1", Demand: 1000, Band: "x"},
+		{Satellite: "sat2", Demand: 1000, Band: "X"},
+		{Satellite: "sat3", Demand: 1000},
+		{Satellite: "sat4", Demand: 1000},
+	}
+
+	allocation := Allocate(passes, stations, requests)
+	if len(allocation.Assignments) != 1 || allocation.Assignments[0].Station != "gs2" {
+		t.Errorf("Expected sat1 to be served by the X band station, got %+v", allocation.Assignments)
+	}
+	if len(allocation.Unserved) != 3 {
+		t.Fatalf("Expected three unserved requests, got %+v", allocation.Unserved)
+	}
+	for _, unserved := range allocation.Unserved {
+		if unserved.Reason != ReasonNoPasses || unserved.Allocated != 0 {
+			t.Errorf("Expected %s to have no usable passes, got %+v", unserved.Satellite, unserved)
+		}
+	}
+}
+
+func TestAllocate_PredictedPasses(t *testing.T) {
+	station := NewGroundStationAt("station", Geodetic{Latitude: 28.5, Longitude: -80.6}, 10)
+	start := newTestSatellite(t).TLE().Epoch
+	var passes []Pass
+	var requests []DownlinkRequest
+	for i, anomaly := range []float64{0, 5, 10} {
+		satellite := newTestSatelliteAt(t, []string{"a", "b", "c"}[i], anomaly)
+		satellitePasses, err := satellite.Passes(station, start, start.Add(24*time.Hour), 0)
+		if err != nil {
+			t.Fatalf("Expected Passes to succeed, got %v", err)
+		}
+		passes = append(passes, satellitePasses...)
+		requests = append(requests, DownlinkRequest{Satellite: satellite.id, Demand: 1e9, Priority: i})
+	}
+	stations := []StationCapability{{Station: "station", DataRate: 1e6, Turnaround: 2 * time.Minute, MinDuration: time.Minute}}
+
+	allocation := Allocate(passes, stations, requests)
+	checkConflictFree(t, allocation, stations)
+	if len(allocation.Assignments) == 0 {
+		t.Fatalf("Expected contacts to be allocated")
+	}
+	for _, assignment := range allocation.Assignments {
+		if assignment.End.Sub(assignment.Start) < time.Minute {
+			t.Errorf("Expected contacts of at least a minute, got %+v", assignment)
+		}
+	}
+	// The satellites trail each other closely, so the lower priorities lose
+	// time to the higher
+	for _, unserved := range allocation.Unserved {
+		if unserved.Satellite != "c" && unserved.Reason != ReasonStationsBusy {
+			t.Errorf("Expected %s to lose time to other satellites, got %v", unserved.Satellite, unserved)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package ccsds
+
+// CRC16 computes the CRC-16-CCITT of a frame error control field, with
+// polynomial 0x1021 and all ones preset, as CCSDS 131.0-B and 231.0-B
+// specify
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
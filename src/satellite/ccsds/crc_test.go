@@ -0,0 +1,14 @@
+package ccsds
+
+import (
+	"testing"
+)
+
+func TestCRC16(t *testing.T) {
+	if crc := CRC16([]byte("123456789")); crc != 0x29b1 {
+		t.Errorf("Expected the CRC-16-CCITT check value 0x29b1, got %#04x", crc)
+	}
+	if crc := CRC16(nil); crc != 0xffff {
+		t.Errorf("Expected the preset for no data, got %#04x", crc)
+	}
+}
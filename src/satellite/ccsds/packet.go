@@ -0,0 +1,216 @@
+// Package ccsds encodes and decodes CCSDS Space Packets (CCSDS 133.0-B-2)
+// and the TM and TC Space Data Link Protocol transfer frames (CCSDS
+// 132.0-B-3 and 232.0-B-4) that carry them between a spacecraft and the
+// ground.
+package ccsds
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Space Packet limits
+const (
+	// PacketHeaderLength is the length of the packet primary header
+	PacketHeaderLength = 6
+
+	// MaxPacketDataLength is the longest packet data field, holding the
+	// secondary header and user data
+	MaxPacketDataLength = 65536
+
+	// MaxAPID is the largest application process identifier
+	MaxAPID = 0x7ff
+
+	// IdleAPID is the application process identifier of idle packets
+	IdleAPID = 0x7ff
+
+	// sequenceCountModulus is the modulus of the 14 bit sequence count
+	sequenceCountModulus = 1 << 14
+)
+
+var (
+	// ErrTruncated is returned when data ends before a packet or frame
+	ErrTruncated = errors.New("ccsds: truncated data")
+
+	// ErrInvalidPacket is returned when a packet cannot be encoded or its
+	// header is not valid
+	ErrInvalidPacket = errors.New("ccsds: invalid space packet")
+)
+
+// PacketType is the type of a Space Packet
+type PacketType uint8
+
+// Packet types
+const (
+	Telemetry   PacketType = 0
+	Telecommand PacketType = 1
+)
+
+// SequenceFlags mark whether a packet holds a whole user data unit or a
+// segment of one
+type SequenceFlags uint8
+
+// Sequence flags
+const (
+	SegmentContinuation SequenceFlags = 0
+	SegmentFirst        SequenceFlags = 1
+	SegmentLast         SequenceFlags = 2
+	Unsegmented         SequenceFlags = 3
+)
+
+// SpacePacket is a CCSDS Space Packet
+type SpacePacket struct {
+	// Type is whether the packet is telemetry or a telecommand
+	Type PacketType
+
+	// APID is the application process identifier
+	APID uint16
+
+	// SequenceFlags mark segmented user data, Unsegmented for most packets
+	SequenceFlags SequenceFlags
+
+	// SequenceCount counts the packets of the APID modulo 16384
+	SequenceCount uint16
+
+	// HasSecondaryHeader is set if the packet has a secondary header. It is
+	// implied by a non-empty SecondaryHeader.
+	HasSecondaryHeader bool
+
+	// SecondaryHeader is the mission-defined secondary header, such as a
+	// CUC time code
+	SecondaryHeader []byte
+
+	// Data is the user data
+	Data []byte
+}
+
+// NewSpacePacket returns an unsegmented packet of user data
+func NewSpacePacket(packetType PacketType, apid uint16, data []byte) *SpacePacket {
+	return &SpacePacket{
+		Type:          packetType,
+		APID:          apid,
+		SequenceFlags: Unsegmented,
+		Data:          data,
+	}
+}
+
+// IsIdle returns whether the packet is an idle packet used as fill
+func (p *SpacePacket) IsIdle() bool {
+	return p.APID == IdleAPID
+}
+
+// Length returns the encoded length of the packet
+func (p *SpacePacket) Length() int {
+	return PacketHeaderLength + len(p.SecondaryHeader) + len(p.Data)
+}
+
+// Encode returns the encoded packet
+func (p *SpacePacket) Encode() ([]byte, error) {
+	dataLength := len(p.SecondaryHeader) + len(p.Data)
+	switch {
+	case p.APID > MaxAPID:
+		return nil, fmt.Errorf("%w: APID %d", ErrInvalidPacket, p.APID)
+	case p.Type > Telecommand || p.SequenceFlags > Unsegmented:
+		return nil, fmt.Errorf("%w: type %d, sequence flags %d", ErrInvalidPacket, p.Type, p.SequenceFlags)
+	case dataLength == 0:
+		return nil, fmt.Errorf("%w: empty data field", ErrInvalidPacket)
+	case dataLength > MaxPacketDataLength:
+		return nil, fmt.Errorf("%w: data field of %d octets", ErrInvalidPacket, dataLength)
+	}
+
+	buf := make([]byte, PacketHeaderLength, PacketHeaderLength+dataLength)
+	identification := uint16(p.Type)<<12 | p.APID
+	if p.HasSecondaryHeader || len(p.SecondaryHeader) > 0 {
+		identification |= 1 << 11
+	}
+	binary.BigEndian.PutUint16(buf[0:], identification)
+	binary.BigEndian.PutUint16(buf[2:], uint16(p.SequenceFlags)<<14|p.SequenceCount%sequenceCountModulus)
+	binary.BigEndian.PutUint16(buf[4:], uint16(dataLength-1))
+	buf = append(buf, p.SecondaryHeader...)
+	return append(buf, p.Data...), nil
+}
+
+// DecodeSpacePacket decodes the packet at the start of data, returning it
+// and its encoded length. The secondary header of packets that have one is
+// the first secondaryHeaderLength octets of the data field; the rest is
+// user data.
+func DecodeSpacePacket(data []byte, secondaryHeaderLength int) (*SpacePacket, int, error) {
+	length, err := packetLength(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) < length {
+		return nil, 0, fmt.Errorf("%w: packet of %d octets, have %d", ErrTruncated, length, len(data))
+	}
+
+	identification := binary.BigEndian.Uint16(data[0:])
+	sequence := binary.BigEndian.Uint16(data[2:])
+	packet := &SpacePacket{
+		Type:               PacketType(identification >> 12 & 1),
+		APID:               identification & MaxAPID,
+		SequenceFlags:      SequenceFlags(sequence >> 14),
+		SequenceCount:      sequence % sequenceCountModulus,
+		HasSecondaryHeader: identification&(1<<11) != 0,
+	}
+	field := data[PacketHeaderLength:length]
+	if packet.HasSecondaryHeader {
+		if secondaryHeaderLength > len(field) {
+			return nil, 0, fmt.Errorf("%w: secondary header of %d octets in a data field of %d", ErrInvalidPacket, secondaryHeaderLength, len(field))
+		}
+		packet.SecondaryHeader = append([]byte(nil), field[:secondaryHeaderLength]...)
+		field = field[secondaryHeaderLength:]
+	}
+	packet.Data = append([]byte(nil), field...)
+	return packet, length, nil
+}
+
+// packetLength returns the encoded length of the packet starting at data
+// from its primary header
+func packetLength(data []byte) (int, error) {
+	if len(data) < PacketHeaderLength {
+		return 0, fmt.Errorf("%w: packet header of %d octets", ErrTruncated, len(data))
+	}
+	if version := data[0] >> 5; version != 0 {
+		return 0, fmt.Errorf("%w: version %d", ErrInvalidPacket, version)
+	}
+	return PacketHeaderLength + int(binary.BigEndian.Uint16(data[4:])) + 1, nil
+}
+
+// idlePacket returns an encoded idle packet of a length of at least
+// PacketHeaderLength+1 octets
+func idlePacket(length int) []byte {
+	buf := make([]byte, length)
+	binary.BigEndian.PutUint16(buf[0:], IdleAPID)
+	binary.BigEndian.PutUint16(buf[2:], uint16(Unsegmented)<<14)
+	binary.BigEndian.PutUint16(buf[4:], uint16(length-PacketHeaderLength-1))
+	return buf
+}
+
+// SequenceCounter assigns packet sequence counts for each APID
+type SequenceCounter struct {
+	mutex  sync.Mutex
+	counts map[uint16]uint16
+}
+
+// NewSequenceCounter returns a new SequenceCounter instance
+func NewSequenceCounter() *SequenceCounter {
+	return &SequenceCounter{
+		counts: make(map[uint16]uint16),
+	}
+}
+
+// Next returns the next sequence count of an APID, wrapping after 16383
+func (c *SequenceCounter) Next(apid uint16) uint16 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	count := c.counts[apid]
+	c.counts[apid] = (count + 1) % sequenceCountModulus
+	return count
+}
+
+// Assign sets the sequence count of a packet to the next count of its APID
+func (c *SequenceCounter) Assign(packet *SpacePacket) {
+	packet.SequenceCount = c.Next(packet.APID)
+}
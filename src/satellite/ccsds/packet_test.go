@@ -0,0 +1,120 @@
+package ccsds
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSpacePacket_Encode(t *testing.T) {
+	packet := &SpacePacket{
+		Type:            Telecommand,
+		APID:            0x123,
+		SequenceFlags:   Unsegmented,
+		SequenceCount:   0x2345,
+		SecondaryHeader: []byte{0xaa},
+		Data:            []byte{1, 2, 3},
+	}
+	encoded, err := packet.Encode()
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+	expected := []byte{0x19, 0x23, 0xe3, 0x45, 0x00, 0x03, 0xaa, 1, 2, 3}
+	if !bytes.Equal(encoded, expected) {
+		t.Errorf("Expected %x, got %x", expected, encoded)
+	}
+	if packet.Length() != len(expected) {
+		t.Errorf("Expected Length %d, got %d", len(expected), packet.Length())
+	}
+
+	invalid := []*SpacePacket{
+		{APID: MaxAPID + 1, Data: []byte{1}},
+		{Type: 2, Data: []byte{1}},
+		{SequenceFlags: 4, Data: []byte{1}},
+		{APID: 1},
+		{APID: 1, Data: make([]byte, MaxPacketDataLength+1)},
+	}
+	for i, packet := range invalid {
+		if _, err := packet.Encode(); !errors.Is(err, ErrInvalidPacket) {
+			t.Errorf("Expected packet %d to be invalid, got %v", i, err)
+		}
+	}
+}
+
+func TestDecodeSpacePacket(t *testing.T) {
+	packet := NewSpacePacket(Telemetry, 42, []byte("housekeeping"))
+	packet.SecondaryHeader = []byte{1, 2, 3, 4, 5, 6}
+	packet.SequenceCount = 7
+	encoded, err := packet.Encode()
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+
+	// Trailing data belongs to the next packet
+	decoded, length, err := DecodeSpacePacket(append(encoded, 0xff), CUCLength)
+	if err != nil {
+		t.Fatalf("Expected DecodeSpacePacket to succeed, got %v", err)
+	}
+	if length != len(encoded) {
+		t.Errorf("Expected length %d, got %d", len(encoded), length)
+	}
+	if decoded.Type != Telemetry || decoded.APID != 42 || decoded.SequenceCount != 7 || decoded.SequenceFlags != Unsegmented {
+		t.Errorf("Expected the header to round trip, got %+v", decoded)
+	}
+	if !decoded.HasSecondaryHeader || !bytes.Equal(decoded.SecondaryHeader, packet.SecondaryHeader) || string(decoded.Data) != "housekeeping" {
+		t.Errorf("Expected the data field to round trip, got %+v", decoded)
+	}
+
+	// Without a known secondary header length it stays in the user data
+	decoded, _, err = DecodeSpacePacket(encoded, 0)
+	if err != nil || !decoded.HasSecondaryHeader || len(decoded.Data) != 6+len("housekeeping") {
+		t.Errorf("Expected the whole data field as user data, got %+v, %v", decoded, err)
+	}
+
+	if _, _, err := DecodeSpacePacket(encoded[:5], 0); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected a short header to fail, got %v", err)
+	}
+	if _, _, err := DecodeSpacePacket(encoded[:len(encoded)-1], 0); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected a short data field to fail, got %v", err)
+	}
+	if _, _, err := DecodeSpacePacket(encoded, 100); !errors.Is(err, ErrInvalidPacket) {
+		t.Errorf("Expected an oversized secondary header to fail, got %v", err)
+	}
+	versioned := append([]byte(nil), encoded...)
+	versioned[0] |= 0x20
+	if _, _, err := DecodeSpacePacket(versioned, 0); !errors.Is(err, ErrInvalidPacket) {
+		t.Errorf("Expected an unknown version to fail, got %v", err)
+	}
+}
+
+func TestSpacePacket_IsIdle(t *testing.T) {
+	idle, _, err := DecodeSpacePacket(idlePacket(10), 0)
+	if err != nil {
+		t.Fatalf("Expected the idle packet to decode, got %v", err)
+	}
+	if !idle.IsIdle() || len(idle.Data) != 4 {
+		t.Errorf("Expected an idle packet of 10 octets, got %+v", idle)
+	}
+	if NewSpacePacket(Telemetry, 1, []byte{1}).IsIdle() {
+		t.Errorf("Expected an application packet not to be idle")
+	}
+}
+
+func TestSequenceCounter_Next(t *testing.T) {
+	counter := NewSequenceCounter()
+	if counter.Next(1) != 0 || counter.Next(1) != 1 || counter.Next(2) != 0 {
+		t.Errorf("Expected separate counts for each APID")
+	}
+	for i := 2; i < 1<<14; i++ {
+		counter.Next(1)
+	}
+	if count := counter.Next(1); count != 0 {
+		t.Errorf("Expected the count to wrap after 16383, got %d", count)
+	}
+
+	packet := NewSpacePacket(Telemetry, 2, []byte{1})
+	counter.Assign(packet)
+	if packet.SequenceCount != 1 {
+		t.Errorf("Expected Assign to set the next count, got %d", packet.SequenceCount)
+	}
+}
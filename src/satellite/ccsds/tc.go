@@ -0,0 +1,210 @@
+package ccsds
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// TC transfer frame constants
+const (
+	// TCHeaderLength is the length of the TC frame primary header
+	TCHeaderLength = 5
+
+	// MaxTCFrameLength is the longest TC transfer frame
+	MaxTCFrameLength = 1024
+
+	// MaxTCVirtualChannel is the largest TC virtual channel identifier
+	MaxTCVirtualChannel = 63
+)
+
+// TCFrame is a TC transfer frame
+type TCFrame struct {
+	// Bypass sends the frame with the expedited service, skipping the
+	// sequence checks of the frame acceptance and reporting mechanism
+	Bypass bool
+
+	// ControlCommand marks frames carrying control commands rather than
+	// data
+	ControlCommand bool
+
+	// SpacecraftID identifies the spacecraft
+	SpacecraftID uint16
+
+	// VirtualChannel is the virtual channel identifier
+	VirtualChannel uint8
+
+	// Sequence is the frame sequence number modulo 256
+	Sequence uint8
+
+	// Data is the frame data field
+	Data []byte
+}
+
+// Encode returns the encoded frame, followed by the frame error control
+// field if fecf is set
+func (f *TCFrame) Encode(fecf bool) ([]byte, error) {
+	length := TCHeaderLength + len(f.Data)
+	if fecf {
+		length += fecfLength
+	}
+	switch {
+	case f.SpacecraftID > MaxSpacecraftID:
+		return nil, fmt.Errorf("%w: spacecraft %d", ErrInvalidFrame, f.SpacecraftID)
+	case f.VirtualChannel > MaxTCVirtualChannel:
+		return nil, fmt.Errorf("%w: virtual channel %d", ErrInvalidFrame, f.VirtualChannel)
+	case len(f.Data) == 0:
+		return nil, fmt.Errorf("%w: empty data field", ErrInvalidFrame)
+	case length > MaxTCFrameLength:
+		return nil, fmt.Errorf("%w: frame of %d octets", ErrInvalidFrame, length)
+	}
+
+	buf := make([]byte, TCHeaderLength, length)
+	identification := f.SpacecraftID
+	if f.Bypass {
+		identification |= 1 << 13
+	}
+	if f.ControlCommand {
+		identification |= 1 << 12
+	}
+	binary.BigEndian.PutUint16(buf[0:], identification)
+	binary.BigEndian.PutUint16(buf[2:], uint16(f.VirtualChannel)<<10|uint16(length-1))
+	buf[4] = f.Sequence
+	buf = append(buf, f.Data...)
+	if fecf {
+		buf = binary.BigEndian.AppendUint16(buf, CRC16(buf))
+	}
+	return buf, nil
+}
+
+// DecodeTCFrame decodes the TC transfer frame at the start of data,
+// returning it and its encoded length. The frame error control field is
+// checked if fecf is set.
+func DecodeTCFrame(data []byte, fecf bool) (*TCFrame, int, error) {
+	if len(data) < TCHeaderLength {
+		return nil, 0, fmt.Errorf("%w: frame of %d octets", ErrTruncated, len(data))
+	}
+	identification := binary.BigEndian.Uint16(data[0:])
+	if version := identification >> 14; version != 0 {
+		return nil, 0, fmt.Errorf("%w: version %d", ErrInvalidFrame, version)
+	}
+	channel := binary.BigEndian.Uint16(data[2:])
+	length := int(channel&0x3ff) + 1
+	trailer := 0
+	if fecf {
+		trailer = fecfLength
+	}
+	if length <= TCHeaderLength+trailer {
+		return nil, 0, fmt.Errorf("%w: frame length %d", ErrInvalidFrame, length)
+	}
+	if len(data) < length {
+		return nil, 0, fmt.Errorf("%w: frame of %d octets, have %d", ErrTruncated, length, len(data))
+	}
+	if fecf && CRC16(data[:length-fecfLength]) != binary.BigEndian.Uint16(data[length-fecfLength:]) {
+		return nil, 0, ErrFrameCRC
+	}
+
+	frame := &TCFrame{
+		Bypass:         identification&(1<<13) != 0,
+		ControlCommand: identification&(1<<12) != 0,
+		SpacecraftID:   identification & MaxSpacecraftID,
+		VirtualChannel: uint8(channel >> 10),
+		Sequence:       data[4],
+		Data:           append([]byte(nil), data[TCHeaderLength:length-trailer]...),
+	}
+	return frame, length, nil
+}
+
+// TCConfig is the configuration of a TC physical channel
+type TCConfig struct {
+	// SpacecraftID identifies the spacecraft
+	SpacecraftID uint16
+
+	// FECF adds a frame error control field to each frame
+	FECF bool
+
+	// SecondaryHeaderLength is the length of the packet secondary headers
+	// the decoder splits from the user data
+	SecondaryHeaderLength int
+}
+
+// TCEncoder puts telecommand packets into TC frames, one packet per frame,
+// keeping the frame sequence number of each virtual channel
+type TCEncoder struct {
+	config TCConfig
+
+	mutex     sync.Mutex
+	sequences [MaxTCVirtualChannel + 1]uint8
+}
+
+// NewTCEncoder returns a new TCEncoder instance
+func NewTCEncoder(config TCConfig) (*TCEncoder, error) {
+	if config.SpacecraftID > MaxSpacecraftID {
+		return nil, fmt.Errorf("%w: spacecraft %d", ErrInvalidFrame, config.SpacecraftID)
+	}
+	return &TCEncoder{
+		config: config,
+	}, nil
+}
+
+// Encode returns the frames carrying packets on a virtual channel. Bypass
+// frames use the expedited service and do not advance the sequence number.
+func (e *TCEncoder) Encode(virtualChannel uint8, bypass bool, packets ...*SpacePacket) ([][]byte, error) {
+	if virtualChannel > MaxTCVirtualChannel {
+		return nil, fmt.Errorf("%w: virtual channel %d", ErrInvalidFrame, virtualChannel)
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	var frames [][]byte
+	for _, packet := range packets {
+		data, err := packet.Encode()
+		if err != nil {
+			return frames, err
+		}
+		frame := &TCFrame{
+			Bypass:         bypass,
+			SpacecraftID:   e.config.SpacecraftID,
+			VirtualChannel: virtualChannel,
+			Data:           data,
+		}
+		if !bypass {
+			frame.Sequence = e.sequences[virtualChannel]
+		}
+		encoded, err := frame.Encode(e.config.FECF)
+		if err != nil {
+			return frames, err
+		}
+		if !bypass {
+			e.sequences[virtualChannel]++
+		}
+		frames = append(frames, encoded)
+	}
+	return frames, nil
+}
+
+// DecodeTC decodes a TC frame for a spacecraft and returns the packets in
+// its data field
+func DecodeTC(data []byte, config TCConfig) (*TCFrame, []*SpacePacket, error) {
+	frame, _, err := DecodeTCFrame(data, config.FECF)
+	if err != nil {
+		return nil, nil, err
+	}
+	if frame.SpacecraftID != config.SpacecraftID {
+		return frame, nil, fmt.Errorf("%w: spacecraft %d", ErrWrongSpacecraft, frame.SpacecraftID)
+	}
+	if frame.ControlCommand {
+		return frame, nil, nil
+	}
+	var packets []*SpacePacket
+	for field := frame.Data; len(field) > 0; {
+		packet, length, err := DecodeSpacePacket(field, config.SecondaryHeaderLength)
+		if err != nil {
+			return frame, packets, err
+		}
+		field = field[length:]
+		if !packet.IsIdle() {
+			packets = append(packets, packet)
+		}
+	}
+	return frame, packets, nil
+}
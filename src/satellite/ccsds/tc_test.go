@@ -0,0 +1,131 @@
+package ccsds
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestTCFrame_Encode(t *testing.T) {
+	frame := &TCFrame{
+		Bypass:         true,
+		SpacecraftID:   0x2ab,
+		VirtualChannel: 3,
+		Sequence:       200,
+		Data:           []byte{1, 2, 3},
+	}
+	encoded, err := frame.Encode(true)
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+	header := []byte{0x22, 0xab, 0x0c, 0x09, 200}
+	if !bytes.Equal(encoded[:TCHeaderLength], header) {
+		t.Errorf("Expected header %x, got %x", header, encoded[:TCHeaderLength])
+	}
+
+	decoded, length, err := DecodeTCFrame(append(encoded, 0, 0), true)
+	if err != nil {
+		t.Fatalf("Expected DecodeTCFrame to succeed, got %v", err)
+	}
+	if length != len(encoded) {
+		t.Errorf("Expected length %d, got %d", len(encoded), length)
+	}
+	if !decoded.Bypass || decoded.ControlCommand || decoded.SpacecraftID != 0x2ab || decoded.VirtualChannel != 3 ||
+		decoded.Sequence != 200 || !bytes.Equal(decoded.Data, frame.Data) {
+		t.Errorf("Expected the frame to round trip, got %+v", decoded)
+	}
+
+	invalid := []*TCFrame{
+		{SpacecraftID: MaxSpacecraftID + 1, Data: []byte{1}},
+		{VirtualChannel: MaxTCVirtualChannel + 1, Data: []byte{1}},
+		{},
+		{Data: make([]byte, MaxTCFrameLength)},
+	}
+	for i, frame := range invalid {
+		if _, err := frame.Encode(false); !errors.Is(err, ErrInvalidFrame) {
+			t.Errorf("Expected frame %d to be invalid, got %v", i, err)
+		}
+	}
+}
+
+func TestDecodeTCFrame(t *testing.T) {
+	frame := &TCFrame{SpacecraftID: 1, Data: []byte{1, 2, 3}}
+	encoded, _ := frame.Encode(true)
+
+	corrupted := append([]byte(nil), encoded...)
+	corrupted[TCHeaderLength] ^= 1
+	if _, _, err := DecodeTCFrame(corrupted, true); err != ErrFrameCRC {
+		t.Errorf("Expected a corrupted frame to fail the CRC, got %v", err)
+	}
+	if _, _, err := DecodeTCFrame(encoded[:len(encoded)-1], true); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected a short frame to fail, got %v", err)
+	}
+	if _, _, err := DecodeTCFrame(encoded[:3], true); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected a short header to fail, got %v", err)
+	}
+}
+
+func TestTCEncoder_Encode(t *testing.T) {
+	config := TCConfig{SpacecraftID: 42, FECF: true}
+	encoder, err := NewTCEncoder(config)
+	if err != nil {
+		t.Fatalf("Expected NewTCEncoder to succeed, got %v", err)
+	}
+	frames, err := encoder.Encode(1, false,
+		NewSpacePacket(Telecommand, 5, []byte("reboot")),
+		NewSpacePacket(Telecommand, 6, []byte("deploy")))
+	if err != nil || len(frames) != 2 {
+		t.Fatalf("Expected a frame for each packet, got %d, %v", len(frames), err)
+	}
+	for i, data := range frames {
+		frame, packets, err := DecodeTC(data, config)
+		if err != nil {
+			t.Fatalf("Expected DecodeTC to succeed, got %v", err)
+		}
+		if frame.Sequence != uint8(i) || len(packets) != 1 || packets[0].Type != Telecommand {
+			t.Errorf("Expected frame %d to carry a sequenced telecommand, got %+v", i, frame)
+		}
+	}
+
+	// Expedited frames do not use the sequence
+	frames, _ = encoder.Encode(1, true, NewSpacePacket(Telecommand, 5, []byte("abort")))
+	if frame, _, _ := DecodeTCFrame(frames[0], true); !frame.Bypass || frame.Sequence != 0 {
+		t.Errorf("Expected an unsequenced bypass frame, got %+v", frame)
+	}
+	frames, _ = encoder.Encode(1, false, NewSpacePacket(Telecommand, 5, []byte("next")))
+	if frame, _, _ := DecodeTCFrame(frames[0], true); frame.Sequence != 2 {
+		t.Errorf("Expected the sequence to continue, got %d", frame.Sequence)
+	}
+
+	if _, err := NewTCEncoder(TCConfig{SpacecraftID: MaxSpacecraftID + 1}); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Expected an invalid spacecraft to fail, got %v", err)
+	}
+	if _, err := encoder.Encode(MaxTCVirtualChannel+1, false); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Expected an invalid virtual channel to fail, got %v", err)
+	}
+}
+
+func TestDecodeTC(t *testing.T) {
+	config := TCConfig{SpacecraftID: 42}
+	first, _ := NewSpacePacket(Telecommand, 5, []byte("one")).Encode()
+	second, _ := NewSpacePacket(Telecommand, 6, []byte("two")).Encode()
+	frame := &TCFrame{SpacecraftID: 42, Data: append(append(first, second...), idlePacket(8)...)}
+	data, _ := frame.Encode(false)
+
+	_, packets, err := DecodeTC(data, config)
+	if err != nil {
+		t.Fatalf("Expected DecodeTC to succeed, got %v", err)
+	}
+	if len(packets) != 2 || string(packets[0].Data) != "one" || string(packets[1].Data) != "two" {
+		t.Errorf("Expected both packets without the idle packet, got %+v", packets)
+	}
+
+	if _, _, err := DecodeTC(data, TCConfig{SpacecraftID: 43}); !errors.Is(err, ErrWrongSpacecraft) {
+		t.Errorf("Expected a frame for another spacecraft to fail, got %v", err)
+	}
+	control := &TCFrame{SpacecraftID: 42, ControlCommand: true, Data: []byte{0}}
+	data, _ = control.Encode(false)
+	if _, packets, err := DecodeTC(data, config); err != nil || packets != nil {
+		t.Errorf("Expected a control command to carry no packets, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package ccsds
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// CUCLength is the length of the CUC time codes of this package, four
+// octets of seconds and two of fraction with an implicit P-field
+const CUCLength = 6
+
+// Epoch is the CCSDS recommended epoch, 1958-01-01 00:00:00. Time codes
+// count seconds from it without leap seconds, so they trail TAI by the
+// leap seconds accumulated since.
+var Epoch = time.Date(1958, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// EncodeCUC returns the CCSDS Unsegmented Code of t, suitable as a packet
+// secondary header
+func EncodeCUC(t time.Time) []byte {
+	elapsed := t.Sub(Epoch)
+	seconds := elapsed / time.Second
+	fraction := (elapsed - seconds*time.Second) * 65536 / time.Second
+	buf := make([]byte, CUCLength)
+	binary.BigEndian.PutUint32(buf[0:], uint32(seconds))
+	binary.BigEndian.PutUint16(buf[4:], uint16(fraction))
+	return buf
+}
+
+// DecodeCUC returns the time of a CCSDS Unsegmented Code written by
+// EncodeCUC
+func DecodeCUC(data []byte) (time.Time, error) {
+	if len(data) < CUCLength {
+		return time.Time{}, fmt.Errorf("%w: time code of %d octets", ErrTruncated, len(data))
+	}
+	seconds := time.Duration(binary.BigEndian.Uint32(data[0:])) * time.Second
+	fraction := time.Duration(binary.BigEndian.Uint16(data[4:])) * time.Second / 65536
+	return Epoch.Add(seconds + fraction).UTC(), nil
+}
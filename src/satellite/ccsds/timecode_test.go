@@ -0,0 +1,34 @@
+package ccsds
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEncodeCUC(t *testing.T) {
+	code := EncodeCUC(Epoch.Add(258*time.Second + 500*time.Millisecond))
+	expected := []byte{0, 0, 1, 2, 0x80, 0}
+	if string(code) != string(expected) {
+		t.Errorf("Expected %x, got %x", expected, code)
+	}
+
+	now := time.Date(2024, 3, 1, 12, 30, 15, 250000000, time.UTC)
+	decoded, err := DecodeCUC(EncodeCUC(now))
+	if err != nil {
+		t.Fatalf("Expected DecodeCUC to succeed, got %v", err)
+	}
+	if difference := decoded.Sub(now); difference < -20*time.Microsecond || difference > 20*time.Microsecond {
+		t.Errorf("Expected the time to round trip, got %v", decoded)
+	}
+}
+
+func TestDecodeCUC(t *testing.T) {
+	if _, err := DecodeCUC([]byte{0, 0, 1}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected a short time code to fail, got %v", err)
+	}
+	decoded, err := DecodeCUC([]byte{0, 0, 0, 60, 0x40, 0})
+	if err != nil || !decoded.Equal(Epoch.Add(60*time.Second+250*time.Millisecond)) {
+		t.Errorf("Expected a quarter second past a minute, got %v, %v", decoded, err)
+	}
+}
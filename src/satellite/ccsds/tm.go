@@ -0,0 +1,368 @@
+package ccsds
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// TM transfer frame constants
+const (
+	// TMHeaderLength is the length of the TM frame primary header
+	TMHeaderLength = 6
+
+	// DefaultTMFrameLength is the frame length used when none is given,
+	// the length of a Reed-Solomon (255,223) codeblock with interleaving
+	// depth 5 less its check symbols
+	DefaultTMFrameLength = 1115
+
+	// MaxTMFrameLength is the longest TM transfer frame
+	MaxTMFrameLength = 2048
+
+	// MaxSpacecraftID is the largest spacecraft identifier of TM frames
+	MaxSpacecraftID = 0x3ff
+
+	// MaxTMVirtualChannel is the largest TM virtual channel identifier
+	MaxTMVirtualChannel = 7
+
+	// NoPacketStart is the first header pointer of a frame in which no
+	// packet starts
+	NoPacketStart = 0x7ff
+
+	// IdleData is the first header pointer of a frame holding only idle
+	// data
+	IdleData = 0x7fe
+
+	// ocfLength is the length of the operational control field
+	ocfLength = 4
+
+	// fecfLength is the length of the frame error control field
+	fecfLength = 2
+)
+
+var (
+	// ErrInvalidFrame is returned when a frame cannot be encoded or its
+	// header is not valid
+	ErrInvalidFrame = errors.New("ccsds: invalid transfer frame")
+
+	// ErrFrameCRC is returned when a frame error control field does not
+	// match
+	ErrFrameCRC = errors.New("ccsds: frame error control field mismatch")
+
+	// ErrWrongSpacecraft is returned when a frame is for another
+	// spacecraft
+	ErrWrongSpacecraft = errors.New("ccsds: frame for another spacecraft")
+)
+
+// TMFrame is a TM transfer frame
+type TMFrame struct {
+	// SpacecraftID identifies the spacecraft
+	SpacecraftID uint16
+
+	// VirtualChannel is the virtual channel identifier
+	VirtualChannel uint8
+
+	// MasterFrameCount counts the frames of all virtual channels modulo 256
+	MasterFrameCount uint8
+
+	// VirtualFrameCount counts the frames of the virtual channel modulo 256
+	VirtualFrameCount uint8
+
+	// FirstHeaderPointer is the offset in Data of the first packet header,
+	// NoPacketStart or IdleData
+	FirstHeaderPointer uint16
+
+	// Data is the frame data field
+	Data []byte
+
+	// OCF is the operational control field, such as a CLCW, or nil
+	OCF []byte
+}
+
+// Encode returns the encoded frame, followed by the frame error control
+// field if fecf is set
+func (f *TMFrame) Encode(fecf bool) ([]byte, error) {
+	switch {
+	case f.SpacecraftID > MaxSpacecraftID:
+		return nil, fmt.Errorf("%w: spacecraft %d", ErrInvalidFrame, f.SpacecraftID)
+	case f.VirtualChannel > MaxTMVirtualChannel:
+		return nil, fmt.Errorf("%w: virtual channel %d", ErrInvalidFrame, f.VirtualChannel)
+	case f.FirstHeaderPointer > NoPacketStart:
+		return nil, fmt.Errorf("%w: first header pointer %d", ErrInvalidFrame, f.FirstHeaderPointer)
+	case f.OCF != nil && len(f.OCF) != ocfLength:
+		return nil, fmt.Errorf("%w: operational control field of %d octets", ErrInvalidFrame, len(f.OCF))
+	}
+	length := TMHeaderLength + len(f.Data) + len(f.OCF)
+	if fecf {
+		length += fecfLength
+	}
+	if length > MaxTMFrameLength {
+		return nil, fmt.Errorf("%w: frame of %d octets", ErrInvalidFrame, length)
+	}
+
+	buf := make([]byte, TMHeaderLength, length)
+	identification := f.SpacecraftID<<4 | uint16(f.VirtualChannel)<<1
+	if f.OCF != nil {
+		identification |= 1
+	}
+	binary.BigEndian.PutUint16(buf[0:], identification)
+	buf[2] = f.MasterFrameCount
+	buf[3] = f.VirtualFrameCount
+	// No secondary header, synchronous packets in order, segment length
+	// identifier 0b11
+	binary.BigEndian.PutUint16(buf[4:], 3<<11|f.FirstHeaderPointer)
+	buf = append(buf, f.Data...)
+	buf = append(buf, f.OCF...)
+	if fecf {
+		buf = binary.BigEndian.AppendUint16(buf, CRC16(buf))
+	}
+	return buf, nil
+}
+
+// DecodeTMFrame decodes a TM transfer frame, checking the frame error
+// control field at its end if fecf is set
+func DecodeTMFrame(data []byte, fecf bool) (*TMFrame, error) {
+	trailer := 0
+	if fecf {
+		trailer = fecfLength
+	}
+	if len(data) < TMHeaderLength+trailer {
+		return nil, fmt.Errorf("%w: frame of %d octets", ErrTruncated, len(data))
+	}
+	if fecf {
+		end := len(data) - fecfLength
+		if CRC16(data[:end]) != binary.BigEndian.Uint16(data[end:]) {
+			return nil, ErrFrameCRC
+		}
+		data = data[:end]
+	}
+
+	identification := binary.BigEndian.Uint16(data[0:])
+	if version := identification >> 14; version != 0 {
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidFrame, version)
+	}
+	status := binary.BigEndian.Uint16(data[4:])
+	if status&(1<<15) != 0 {
+		return nil, fmt.Errorf("%w: secondary headers are not supported", ErrInvalidFrame)
+	}
+	frame := &TMFrame{
+		SpacecraftID:       identification >> 4 & MaxSpacecraftID,
+		VirtualChannel:     uint8(identification >> 1 & MaxTMVirtualChannel),
+		MasterFrameCount:   data[2],
+		VirtualFrameCount:  data[3],
+		FirstHeaderPointer: status & NoPacketStart,
+	}
+	field := data[TMHeaderLength:]
+	if identification&1 != 0 {
+		if len(field) < ocfLength {
+			return nil, fmt.Errorf("%w: operational control field", ErrTruncated)
+		}
+		frame.OCF = append([]byte(nil), field[len(field)-ocfLength:]...)
+		field = field[:len(field)-ocfLength]
+	}
+	frame.Data = append([]byte(nil), field...)
+	return frame, nil
+}
+
+// TMConfig is the configuration of a TM physical channel shared by the
+// encoder on the spacecraft and the decoder on the ground
+type TMConfig struct {
+	// SpacecraftID identifies the spacecraft
+	SpacecraftID uint16
+
+	// FrameLength is the fixed length of each frame including the frame
+	// error control field, DefaultTMFrameLength if zero
+	FrameLength int
+
+	// FECF adds a frame error control field to each frame
+	FECF bool
+
+	// SecondaryHeaderLength is the length of the packet secondary headers
+	// the decoder splits from the user data
+	SecondaryHeaderLength int
+}
+
+// dataLength returns the length of the frame data field
+func (c TMConfig) dataLength() int {
+	length := c.FrameLength - TMHeaderLength
+	if c.FECF {
+		length -= fecfLength
+	}
+	return length
+}
+
+// TMEncoder multiplexes packets into fixed-length TM frames on virtual
+// channels, keeping the master and virtual channel frame counts
+type TMEncoder struct {
+	config TMConfig
+
+	mutex         sync.Mutex
+	masterCount   uint8
+	virtualCounts [MaxTMVirtualChannel + 1]uint8
+}
+
+// NewTMEncoder returns a new TMEncoder instance
+func NewTMEncoder(config TMConfig) (*TMEncoder, error) {
+	if config.FrameLength == 0 {
+		config.FrameLength = DefaultTMFrameLength
+	}
+	if config.SpacecraftID > MaxSpacecraftID {
+		return nil, fmt.Errorf("%w: spacecraft %d", ErrInvalidFrame, config.SpacecraftID)
+	}
+	if config.FrameLength > MaxTMFrameLength || config.dataLength() < PacketHeaderLength+1 {
+		return nil, fmt.Errorf("%w: frame length %d", ErrInvalidFrame, config.FrameLength)
+	}
+	return &TMEncoder{
+		config: config,
+	}, nil
+}
+
+// Encode returns the frames carrying packets on a virtual channel. Packets
+// span frames as needed and the last frame is filled with an idle packet,
+// so every frame is complete.
+func (e *TMEncoder) Encode(virtualChannel uint8, packets ...*SpacePacket) ([][]byte, error) {
+	if virtualChannel > MaxTMVirtualChannel {
+		return nil, fmt.Errorf("%w: virtual channel %d", ErrInvalidFrame, virtualChannel)
+	}
+	var stream []byte
+	var starts []int
+	for _, packet := range packets {
+		encoded, err := packet.Encode()
+		if err != nil {
+			return nil, err
+		}
+		starts = append(starts, len(stream))
+		stream = append(stream, encoded...)
+	}
+
+	// Fill the last frame, spilling into another if too little room is
+	// left for the smallest idle packet
+	size := e.config.dataLength()
+	if fill := (size - len(stream)%size) % size; fill > 0 {
+		if fill < PacketHeaderLength+1 {
+			fill += size
+		}
+		starts = append(starts, len(stream))
+		stream = append(stream, idlePacket(fill)...)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	var frames [][]byte
+	for offset := 0; offset < len(stream); offset += size {
+		frame := &TMFrame{
+			SpacecraftID:       e.config.SpacecraftID,
+			VirtualChannel:     virtualChannel,
+			MasterFrameCount:   e.masterCount,
+			VirtualFrameCount:  e.virtualCounts[virtualChannel],
+			FirstHeaderPointer: NoPacketStart,
+			Data:               stream[offset : offset+size],
+		}
+		for _, start := range starts {
+			if start >= offset && start < offset+size {
+				frame.FirstHeaderPointer = uint16(start - offset)
+				break
+			}
+		}
+		encoded, err := frame.Encode(e.config.FECF)
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, encoded)
+		e.masterCount++
+		e.virtualCounts[virtualChannel]++
+	}
+	return frames, nil
+}
+
+// TMDecoder extracts packets from TM frames, reassembling packets that
+// span frames on each virtual channel
+type TMDecoder struct {
+	config TMConfig
+
+	mutex    sync.Mutex
+	channels map[uint8]*tmChannel
+}
+
+// tmChannel is the reassembly state of a virtual channel
+type tmChannel struct {
+	next    uint8
+	synced  bool
+	partial []byte
+}
+
+// NewTMDecoder returns a new TMDecoder instance
+func NewTMDecoder(config TMConfig) *TMDecoder {
+	return &TMDecoder{
+		config:   config,
+		channels: make(map[uint8]*tmChannel),
+	}
+}
+
+// Decode decodes a frame and returns the packets it completes, leaving out
+// idle packets. When a virtual channel frame is lost, the packet in
+// progress is dropped and extraction resumes at the next packet header.
+func (d *TMDecoder) Decode(data []byte) (*TMFrame, []*SpacePacket, error) {
+	frame, err := DecodeTMFrame(data, d.config.FECF)
+	if err != nil {
+		return nil, nil, err
+	}
+	if frame.SpacecraftID != d.config.SpacecraftID {
+		return frame, nil, fmt.Errorf("%w: spacecraft %d", ErrWrongSpacecraft, frame.SpacecraftID)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	channel, ok := d.channels[frame.VirtualChannel]
+	if !ok {
+		channel = &tmChannel{}
+		d.channels[frame.VirtualChannel] = channel
+	}
+	if channel.synced && frame.VirtualFrameCount != channel.next {
+		channel.synced = false
+		channel.partial = nil
+	}
+	channel.next = frame.VirtualFrameCount + 1
+
+	field := frame.Data
+	switch {
+	case frame.FirstHeaderPointer == IdleData:
+		return frame, nil, nil
+	case !channel.synced:
+		if frame.FirstHeaderPointer == NoPacketStart {
+			return frame, nil, nil
+		}
+		if int(frame.FirstHeaderPointer) >= len(field) {
+			return frame, nil, fmt.Errorf("%w: first header pointer %d", ErrInvalidFrame, frame.FirstHeaderPointer)
+		}
+		field = field[frame.FirstHeaderPointer:]
+		channel.synced = true
+	}
+	channel.partial = append(channel.partial, field...)
+
+	var packets []*SpacePacket
+	for len(channel.partial) >= PacketHeaderLength {
+		length, err := packetLength(channel.partial)
+		if err != nil {
+			channel.synced = false
+			channel.partial = nil
+			return frame, packets, err
+		}
+		if len(channel.partial) < length {
+			break
+		}
+		packet, _, err := DecodeSpacePacket(channel.partial, d.config.SecondaryHeaderLength)
+		channel.partial = channel.partial[length:]
+		if err != nil {
+			return frame, packets, err
+		}
+		if !packet.IsIdle() {
+			packets = append(packets, packet)
+		}
+	}
+	if len(channel.partial) == 0 {
+		channel.partial = nil
+	}
+	return frame, packets, nil
+}
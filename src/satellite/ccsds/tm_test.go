@@ -0,0 +1,215 @@
+package ccsds
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTMFrame_Encode(t *testing.T) {
+	frame := &TMFrame{
+		SpacecraftID:       0x2ab,
+		VirtualChannel:     5,
+		MasterFrameCount:   9,
+		VirtualFrameCount:  3,
+		FirstHeaderPointer: 2,
+		Data:               []byte{1, 2, 3, 4},
+		OCF:                []byte{0xa, 0xb, 0xc, 0xd},
+	}
+	encoded, err := frame.Encode(true)
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+	header := []byte{0x2a, 0xbb, 9, 3, 0x18, 0x02}
+	if !bytes.Equal(encoded[:TMHeaderLength], header) {
+		t.Errorf("Expected header %x, got %x", header, encoded[:TMHeaderLength])
+	}
+	if len(encoded) != TMHeaderLength+4+4+2 {
+		t.Errorf("Expected the data, OCF and FECF to follow, got %d octets", len(encoded))
+	}
+
+	decoded, err := DecodeTMFrame(encoded, true)
+	if err != nil {
+		t.Fatalf("Expected DecodeTMFrame to succeed, got %v", err)
+	}
+	if fmt.Sprint(decoded) != fmt.Sprint(frame) {
+		t.Errorf("Expected the frame to round trip, got %+v", decoded)
+	}
+
+	invalid := []*TMFrame{
+		{SpacecraftID: MaxSpacecraftID + 1},
+		{VirtualChannel: MaxTMVirtualChannel + 1},
+		{FirstHeaderPointer: NoPacketStart + 1},
+		{OCF: []byte{1}},
+		{Data: make([]byte, MaxTMFrameLength)},
+	}
+	for i, frame := range invalid {
+		if _, err := frame.Encode(false); !errors.Is(err, ErrInvalidFrame) {
+			t.Errorf("Expected frame %d to be invalid, got %v", i, err)
+		}
+	}
+}
+
+func TestDecodeTMFrame(t *testing.T) {
+	frame := &TMFrame{SpacecraftID: 1, FirstHeaderPointer: NoPacketStart, Data: []byte{1, 2}}
+	encoded, _ := frame.Encode(true)
+
+	corrupted := append([]byte(nil), encoded...)
+	corrupted[TMHeaderLength] ^= 1
+	if _, err := DecodeTMFrame(corrupted, true); err != ErrFrameCRC {
+		t.Errorf("Expected a corrupted frame to fail the CRC, got %v", err)
+	}
+	if _, err := DecodeTMFrame(encoded[:4], false); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected a short frame to fail, got %v", err)
+	}
+	decoded, err := DecodeTMFrame(encoded, false)
+	if err != nil || len(decoded.Data) != 4 {
+		t.Errorf("Expected the FECF to be data without checking, got %+v, %v", decoded, err)
+	}
+	versioned := append([]byte(nil), encoded...)
+	versioned[0] |= 0x40
+	if _, err := DecodeTMFrame(versioned, false); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Expected an unknown version to fail, got %v", err)
+	}
+}
+
+func TestNewTMEncoder(t *testing.T) {
+	if _, err := NewTMEncoder(TMConfig{SpacecraftID: MaxSpacecraftID + 1}); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Expected an invalid spacecraft to fail, got %v", err)
+	}
+	if _, err := NewTMEncoder(TMConfig{FrameLength: 10, FECF: true}); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Expected a frame too short for a packet to fail, got %v", err)
+	}
+	if _, err := NewTMEncoder(TMConfig{FrameLength: MaxTMFrameLength + 1}); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Expected a frame too long to fail, got %v", err)
+	}
+}
+
+func TestTMEncoder_Encode(t *testing.T) {
+	config := TMConfig{SpacecraftID: 100, FrameLength: 64, FECF: true, SecondaryHeaderLength: CUCLength}
+	encoder, err := NewTMEncoder(config)
+	if err != nil {
+		t.Fatalf("Expected NewTMEncoder to succeed, got %v", err)
+	}
+
+	var packets []*SpacePacket
+	for i := 0; i < 5; i++ {
+		packet := NewSpacePacket(Telemetry, uint16(10+i), bytes.Repeat([]byte{byte(i)}, 20+i*15))
+		packet.SecondaryHeader = EncodeCUC(Epoch)
+		packet.SequenceCount = uint16(i)
+		packets = append(packets, packet)
+	}
+	frames, err := encoder.Encode(2, packets...)
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+
+	decoder := NewTMDecoder(config)
+	var received []*SpacePacket
+	for i, data := range frames {
+		if len(data) != config.FrameLength {
+			t.Errorf("Expected frame %d to have the fixed length, got %d", i, len(data))
+		}
+		frame, decoded, err := decoder.Decode(data)
+		if err != nil {
+			t.Fatalf("Expected Decode to succeed, got %v", err)
+		}
+		if frame.VirtualChannel != 2 || frame.VirtualFrameCount != uint8(i) || frame.MasterFrameCount != uint8(i) {
+			t.Errorf("Expected frame %d to be counted, got %+v", i, frame)
+		}
+		received = append(received, decoded...)
+	}
+	if len(received) != len(packets) {
+		t.Fatalf("Expected %d packets, got %d", len(packets), len(received))
+	}
+	for i, packet := range received {
+		if packet.APID != packets[i].APID || packet.SequenceCount != uint16(i) ||
+			!bytes.Equal(packet.Data, packets[i].Data) || !bytes.Equal(packet.SecondaryHeader, packets[i].SecondaryHeader) {
+			t.Errorf("Expected packet %d to round trip, got %+v", i, packet)
+		}
+	}
+
+	// Counts continue across calls and channels
+	sent := len(frames)
+	frames, err = encoder.Encode(3, NewSpacePacket(Telemetry, 1, []byte{1}))
+	if err != nil || len(frames) != 1 {
+		t.Fatalf("Expected a single frame, got %d, %v", len(frames), err)
+	}
+	frame, _ := DecodeTMFrame(frames[0], true)
+	if frame.VirtualFrameCount != 0 || int(frame.MasterFrameCount) != sent {
+		t.Errorf("Expected a new virtual channel count and the next master count, got %+v", frame)
+	}
+
+	if _, err := encoder.Encode(MaxTMVirtualChannel+1, packets[0]); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("Expected an invalid virtual channel to fail, got %v", err)
+	}
+	if _, err := encoder.Encode(0, &SpacePacket{}); !errors.Is(err, ErrInvalidPacket) {
+		t.Errorf("Expected an invalid packet to fail, got %v", err)
+	}
+}
+
+func TestTMEncoder_EncodeFill(t *testing.T) {
+	config := TMConfig{FrameLength: 32}
+	encoder, _ := NewTMEncoder(config)
+	size := config.dataLength()
+
+	// Leaving room for less than an idle packet spills the fill into
+	// another frame
+	packet := NewSpacePacket(Telemetry, 1, make([]byte, size-PacketHeaderLength-3))
+	frames, err := encoder.Encode(0, packet)
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("Expected the idle packet to need a second frame, got %d", len(frames))
+	}
+	second, _ := DecodeTMFrame(frames[1], false)
+	if second.FirstHeaderPointer != NoPacketStart {
+		t.Errorf("Expected no packet to start in the second frame, got %d", second.FirstHeaderPointer)
+	}
+
+	// A packet filling the frame exactly needs no idle packet
+	frames, _ = encoder.Encode(0, NewSpacePacket(Telemetry, 1, make([]byte, size-PacketHeaderLength)))
+	if len(frames) != 1 {
+		t.Errorf("Expected one frame, got %d", len(frames))
+	}
+}
+
+func TestTMDecoder_Decode(t *testing.T) {
+	config := TMConfig{SpacecraftID: 7, FrameLength: 40}
+	encoder, _ := NewTMEncoder(config)
+	decoder := NewTMDecoder(config)
+
+	// A packet spanning three frames is lost with its middle frame, but
+	// the next packet is recovered from its first header pointer
+	frames, _ := encoder.Encode(1,
+		NewSpacePacket(Telemetry, 1, make([]byte, 80)),
+		NewSpacePacket(Telemetry, 2, []byte("recovered")))
+	var received []*SpacePacket
+	for i, data := range frames {
+		if i == 1 {
+			continue
+		}
+		_, packets, err := decoder.Decode(data)
+		if err != nil {
+			t.Fatalf("Expected Decode to succeed, got %v", err)
+		}
+		received = append(received, packets...)
+	}
+	if len(received) != 1 || string(received[0].Data) != "recovered" {
+		t.Errorf("Expected only the packet after the gap, got %+v", received)
+	}
+
+	other, _ := NewTMEncoder(TMConfig{SpacecraftID: 8, FrameLength: 40})
+	frames, _ = other.Encode(1, NewSpacePacket(Telemetry, 1, []byte{1}))
+	if _, _, err := decoder.Decode(frames[0]); !errors.Is(err, ErrWrongSpacecraft) {
+		t.Errorf("Expected a frame for another spacecraft to fail, got %v", err)
+	}
+
+	idle := &TMFrame{SpacecraftID: 7, FirstHeaderPointer: IdleData, Data: make([]byte, 34)}
+	data, _ := idle.Encode(false)
+	if _, packets, err := decoder.Decode(data); err != nil || len(packets) != 0 {
+		t.Errorf("Expected an idle frame to carry nothing, got %d, %v", len(packets), err)
+	}
+}
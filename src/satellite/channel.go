@@ -0,0 +1,302 @@
+package satellite
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skybridge/satellite/fec"
+)
+
+// ErrNotVisible is returned when a channel that requires visibility is used
+// while the ground station cannot see the satellite
+var ErrNotVisible = errors.New("ground station cannot see the satellite")
+
+// Channel carries data between a satellite and its ground stations.
+// Satellites without a channel exchange data with the stations directly.
+type Channel interface {
+	// Transmit sends data from the satellite to the ground station
+	Transmit(satellite *Satellite, station *GroundStation, data []byte) error
+
+	// Uplink sends data from the ground station to the satellite
+	Uplink(station *GroundStation, satellite *Satellite, data []byte) error
+}
+
+// GilbertElliott is a two-state burst loss model. The channel moves between
+// a good and a bad state before each transmission and loses data with the
+// probability of its state.
+type GilbertElliott struct {
+	// P is the probability of moving from the good state to the bad state
+	P float64
+
+	// R is the probability of moving from the bad state to the good state
+	R float64
+
+	// LossGood is the probability of losing data in the good state
+	LossGood float64
+
+	// LossBad is the probability of losing data in the bad state
+	LossBad float64
+}
+
+// LossRate returns the long-run fraction of transmissions lost
+func (g GilbertElliott) LossRate() float64 {
+	if g.P+g.R == 0 {
+		return g.LossGood
+	}
+	bad := g.P / (g.P + g.R)
+	return (1-bad)*g.LossGood + bad*g.LossBad
+}
+
+// ChannelConfig is the configuration of a ChannelSimulator
+type ChannelConfig struct {
+	// Clock times deliveries, SystemClock if nil
+	Clock Clock
+
+	// BitErrorRate is the probability of each bit being flipped
+	BitErrorRate float64
+
+	// Loss is the burst loss model
+	Loss GilbertElliott
+
+	// Bandwidth is the link rate in bits per second, or zero for no limit.
+	// Transmissions to a station queue behind each other, whichever
+	// satellite sends them, and so do a station's uplinks.
+	Bandwidth float64
+
+	// RequireVisibility rejects transmissions while the station cannot see
+	// the satellite, which needs the satellite's TLE
+	RequireVisibility bool
+
+	// Seed seeds the random errors and losses, so runs are repeatable
+	Seed int64
+}
+
+// ChannelStats counts what a ChannelSimulator did with transmissions
+type ChannelStats struct {
+	Sent      int `json:"sent"`
+	Lost      int `json:"lost"`
+	Corrupted int `json:"corrupted"`
+	Delivered int `json:"delivered"`
+	Rejected  int `json:"rejected"`
+	BitErrors int `json:"bitErrors"`
+
+	// Failed counts deliveries the receiver returned an error for
+	Failed int `json:"failed"`
+}
+
+// ChannelSimulator is a Channel that imitates an RF link. It delays data by
+// the propagation time from the orbit geometry and the transmission time at
+// the link's bandwidth, flips bits and loses data in bursts.
+type ChannelSimulator struct {
+	config  ChannelConfig
+	clock   Clock
+	bits    *fec.BinarySymmetricChannel
+	waiting sync.WaitGroup
+
+	mutex   sync.Mutex
+	rand    *rand.Rand
+	bad     bool
+	busy    map[link]time.Time
+	pending []delivery
+	stats   ChannelStats
+
+	// delivering is set while a goroutine hands data to the ground
+	// stations, and again asks it to look for more data when it is done
+	delivering bool
+	again      bool
+}
+
+// link is one direction of a ground station's radio
+type link struct {
+	station string
+	uplink  bool
+}
+
+// delivery is data waiting to arrive at a ground station or satellite
+type delivery struct {
+	at      time.Time
+	order   int
+	receive func(data []byte) error
+	data    []byte
+}
+
+// NewChannelSimulator returns a new ChannelSimulator instance
+func NewChannelSimulator(config ChannelConfig) *ChannelSimulator {
+	clock := config.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &ChannelSimulator{
+		config: config,
+		clock:  clock,
+		bits:   fec.NewBinarySymmetricChannel(config.BitErrorRate, config.Seed),
+		rand:   rand.New(rand.NewSource(config.Seed + 1)),
+		busy:   make(map[link]time.Time),
+	}
+}
+
+// Transmit sends data to the ground station. Like a radio, it does not
+// report data lost or corrupted on the way; ground station errors are only
+// counted in the stats.
+func (c *ChannelSimulator) Transmit(satellite *Satellite, station *GroundStation, data []byte) error {
+	return c.send(satellite, station, link{station: station.id}, station.ReceiveData, data)
+}
+
+// Uplink sends data to the satellite, such as telecommand frames. Like
+// Transmit, it does not report data lost or corrupted on the way, and
+// satellite errors are only counted in the stats.
+func (c *ChannelSimulator) Uplink(station *GroundStation, satellite *Satellite, data []byte) error {
+	return c.send(satellite, station, link{station: station.id, uplink: true}, satellite.ReceiveTelecommand, data)
+}
+
+// send carries data over one direction of the link between a satellite and
+// a ground station and hands it to receive when it arrives
+func (c *ChannelSimulator) send(satellite *Satellite, station *GroundStation, l link, receive func(data []byte) error, data []byte) error {
+	now := c.clock.Now()
+	var propagation time.Duration
+	point, err := satellite.look(station, now)
+	switch {
+	case err == nil:
+		propagation = time.Duration(point.Range * 1000 / SpeedOfLight * float64(time.Second))
+		if c.config.RequireVisibility && !station.Visible(point.LookAngles) {
+			c.count(func(stats *ChannelStats) { stats.Rejected++ })
+			return ErrNotVisible
+		}
+	case err != ErrNoTLE || c.config.RequireVisibility:
+		return err
+	}
+
+	c.mutex.Lock()
+	c.stats.Sent++
+
+	// The link is occupied for the transmission time even if the data is
+	// lost
+	start := now
+	if busy := c.busy[l]; busy.After(start) {
+		start = busy
+	}
+	if c.config.Bandwidth > 0 {
+		start = start.Add(time.Duration(float64(8*len(data)) / c.config.Bandwidth * float64(time.Second)))
+	}
+	c.busy[l] = start
+
+	if c.lose() {
+		c.stats.Lost++
+		c.mutex.Unlock()
+		return nil
+	}
+	received, flipped := c.bits.Transmit(data)
+	if flipped > 0 {
+		c.stats.Corrupted++
+		c.stats.BitErrors += flipped
+	}
+	at := start.Add(propagation)
+	c.pending = append(c.pending, delivery{at: at, order: c.stats.Sent, receive: receive, data: received})
+	c.mutex.Unlock()
+
+	if !at.After(now) {
+		c.deliver()
+		return nil
+	}
+	arrived := c.clock.After(at.Sub(now))
+	c.waiting.Add(1)
+	go func() {
+		defer c.waiting.Done()
+		<-arrived
+		c.deliver()
+	}()
+	return nil
+}
+
+// lose moves the burst loss model to its next state and returns whether
+// the transmission is lost. The mutex must be held.
+func (c *ChannelSimulator) lose() bool {
+	loss := c.config.Loss
+	if c.bad {
+		c.bad = c.rand.Float64() >= loss.R
+	} else {
+		c.bad = c.rand.Float64() < loss.P
+	}
+	probability := loss.LossGood
+	if c.bad {
+		probability = loss.LossBad
+	}
+	return c.rand.Float64() < probability
+}
+
+// deliver hands the data that has arrived to its receivers in order of
+// arrival. Only one goroutine delivers at a time; a call made while
+// another is delivering leaves the data to that goroutine, which also keeps
+// a ground station that transmits from its handler from deadlocking.
+func (c *ChannelSimulator) deliver() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.delivering {
+		c.again = true
+		return
+	}
+	c.delivering = true
+	defer func() { c.delivering = false }()
+
+	for {
+		c.again = false
+		now := c.clock.Now()
+		sort.SliceStable(c.pending, func(i, j int) bool {
+			if !c.pending[i].at.Equal(c.pending[j].at) {
+				return c.pending[i].at.Before(c.pending[j].at)
+			}
+			return c.pending[i].order < c.pending[j].order
+		})
+		var due []delivery
+		for len(c.pending) > 0 && !c.pending[0].at.After(now) {
+			due = append(due, c.pending[0])
+			c.pending = c.pending[1:]
+		}
+		c.mutex.Unlock()
+
+		for _, d := range due {
+			err := d.receive(d.data)
+			c.count(func(stats *ChannelStats) {
+				stats.Delivered++
+				if err != nil {
+					stats.Failed++
+				}
+			})
+		}
+
+		c.mutex.Lock()
+		if !c.again {
+			return
+		}
+	}
+}
+
+// count updates the stats with the mutex held
+func (c *ChannelSimulator) count(update func(stats *ChannelStats)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	update(&c.stats)
+}
+
+// Wait blocks until all data in flight has been delivered. With a
+// SimulatedClock the clock must be advanced past the arrivals.
+func (c *ChannelSimulator) Wait() {
+	c.waiting.Wait()
+}
+
+// InFlight returns the number of transmissions yet to arrive
+func (c *ChannelSimulator) InFlight() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.pending)
+}
+
+// Stats returns what the channel has done so far
+func (c *ChannelSimulator) Stats() ChannelStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
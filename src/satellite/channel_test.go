@@ -0,0 +1,306 @@
+package satellite
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skybridge/satellite/ccsds"
+	"github.com/skybridge/satellite/sdls"
+)
+
+// newChannelTest returns Vanguard 1, a recording ground station that can see
+// it and a simulated clock at the middle of a pass
+func newChannelTest(t *testing.T) (*Satellite, *GroundStation, *recorder, *SimulatedClock, TrackPoint) {
+	satellite := newTestSatellite(t)
+	station := NewGroundStationAt("station", Geodetic{Latitude: 28.5, Longitude: -80.6}, 10)
+	received := &recorder{}
+	station.Handler = received.handle
+	satellite.AddGroundStation(station)
+
+	start := satellite.TLE().Epoch
+	passes, err := satellite.Passes(station, start, start.Add(24*time.Hour), 0)
+	if err != nil || len(passes) == 0 {
+		t.Fatalf("Expected a pass of Vanguard 1, got %v", err)
+	}
+	point := passes[0].Track[len(passes[0].Track)/2]
+	return satellite, station, received, NewSimulatedClock(point.Time), point
+}
+
+func TestChannelSimulator_Transmit(t *testing.T) {
+	satellite, _, first, _ := newSchedulerTest(time.Now(), ContactPlan{})
+	channel := NewChannelSimulator(ChannelConfig{})
+	satellite.SetChannel(channel)
+
+	// Without a TLE there is no propagation delay, so data arrives at once
+	if err := satellite.CommunicateWithGroundStation(satellite.groundStation("gs1"), []byte("hello")); err != nil {
+		t.Fatalf("Expected CommunicateWithGroundStation to succeed, got %v", err)
+	}
+	if first.count() != 1 || first.received[0] != "hello" {
+		t.Errorf("Expected the ground station to receive the data, got %v", first.received)
+	}
+	if stats := channel.Stats(); stats != (ChannelStats{Sent: 1, Delivered: 1}) {
+		t.Errorf("Expected one delivery, got %+v", stats)
+	}
+
+	satellite.SetChannel(nil)
+	if err := satellite.CommunicateWithGroundStation(satellite.groundStation("gs1"), []byte("direct")); err != nil {
+		t.Fatalf("Expected CommunicateWithGroundStation to succeed, got %v", err)
+	}
+	if first.count() != 2 || channel.Stats().Sent != 1 {
+		t.Errorf("Expected data to bypass a removed channel")
+	}
+}
+
+func TestChannelSimulator_PropagationDelay(t *testing.T) {
+	satellite, station, received, clock, point := newChannelTest(t)
+	channel := NewChannelSimulator(ChannelConfig{Clock: clock})
+	satellite.SetChannel(channel)
+
+	if err := satellite.CommunicateWithGroundStation(station, []byte("telemetry")); err != nil {
+		t.Fatalf("Expected CommunicateWithGroundStation to succeed, got %v", err)
+	}
+	if received.count() != 0 || channel.InFlight() != 1 {
+		t.Fatalf("Expected the data to be in flight")
+	}
+
+	delay := time.Duration(point.Range * 1000 / SpeedOfLight * float64(time.Second))
+	if delay < time.Millisecond || delay > 50*time.Millisecond {
+		t.Fatalf("Expected a light time of milliseconds, got %v", delay)
+	}
+	clock.Advance(delay - time.Millisecond)
+	if received.count() != 0 {
+		t.Errorf("Expected no delivery before the light time")
+	}
+	clock.Advance(time.Millisecond)
+	channel.Wait()
+	if received.count() != 1 || channel.InFlight() != 0 {
+		t.Errorf("Expected delivery after the light time")
+	}
+}
+
+func TestChannelSimulator_Bandwidth(t *testing.T) {
+	satellite, _, first, _ := newSchedulerTest(time.Now(), ContactPlan{})
+	clock := NewSimulatedClock(time.Now())
+	channel := NewChannelSimulator(ChannelConfig{Clock: clock, Bandwidth: 8000})
+	satellite.SetChannel(channel)
+	station := satellite.groundStation("gs1")
+
+	data := make([]byte, 1000)
+	for i := 0; i < 3; i++ {
+		if err := satellite.CommunicateWithGroundStation(station, data); err != nil {
+			t.Fatalf("Expected CommunicateWithGroundStation to succeed, got %v", err)
+		}
+	}
+
+	// Each kilobyte takes a second at 8 kbit/s and waits for the previous
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Second)
+		deadline := time.Now().Add(time.Second)
+		for first.count() < i && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if first.count() != i {
+			t.Errorf("Expected %d deliveries after %d seconds, got %d", i, i, first.count())
+		}
+	}
+	channel.Wait()
+	if stats := channel.Stats(); stats.Delivered != 3 {
+		t.Errorf("Expected 3 deliveries, got %+v", stats)
+	}
+}
+
+func TestChannelSimulator_SharedStation(t *testing.T) {
+	satellite, _, first, _ := newSchedulerTest(time.Now(), ContactPlan{})
+	clock := NewSimulatedClock(time.Now())
+	channel := NewChannelSimulator(ChannelConfig{Clock: clock, Bandwidth: 8000})
+	station := satellite.groundStation("gs1")
+
+	// Two satellites sending to one station share its bandwidth
+	data := make([]byte, 1000)
+	for _, sender := range []*Satellite{satellite, NewSatellite("sat2")} {
+		if err := channel.Transmit(sender, station, data); err != nil {
+			t.Fatalf("Expected Transmit to succeed, got %v", err)
+		}
+	}
+	clock.Advance(time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if first.count() != 1 {
+		t.Errorf("Expected one delivery after a second, got %d", first.count())
+	}
+	clock.Advance(time.Second)
+	channel.Wait()
+	if first.count() != 2 {
+		t.Errorf("Expected both deliveries after two seconds, got %d", first.count())
+	}
+}
+
+func TestChannelSimulator_BitErrors(t *testing.T) {
+	satellite, _, first, _ := newSchedulerTest(time.Now(), ContactPlan{})
+	channel := NewChannelSimulator(ChannelConfig{BitErrorRate: 1e-3, Seed: 1})
+	station := satellite.groundStation("gs1")
+
+	data := make([]byte, 100)
+	for i := 0; i < 1000; i++ {
+		if err := channel.Transmit(satellite, station, data); err != nil {
+			t.Fatalf("Expected Transmit to succeed, got %v", err)
+		}
+	}
+
+	stats := channel.Stats()
+	if stats.Delivered != 1000 || first.count() != 1000 {
+		t.Errorf("Expected corrupted data to still be delivered, got %+v", stats)
+	}
+	// 800 kbit at a rate of 1e-3 flips about 800 bits
+	if stats.BitErrors < 700 || stats.BitErrors > 900 {
+		t.Errorf("Expected about 800 bit errors, got %d", stats.BitErrors)
+	}
+	corrupted := 0
+	for _, message := range first.received {
+		if message != string(data) {
+			corrupted++
+		}
+	}
+	if corrupted != stats.Corrupted {
+		t.Errorf("Expected %d corrupted messages, got %d", stats.Corrupted, corrupted)
+	}
+}
+
+func TestChannelSimulator_BurstLoss(t *testing.T) {
+	satellite, _, _, _ := newSchedulerTest(time.Now(), ContactPlan{})
+	loss := GilbertElliott{P: 0.05, R: 0.25, LossBad: 1}
+	channel := NewChannelSimulator(ChannelConfig{Loss: loss, Seed: 7})
+	station := satellite.groundStation("gs1")
+
+	const sent = 20000
+	bursts, previous := 0, false
+	for i := 0; i < sent; i++ {
+		before := channel.Stats().Lost
+		if err := channel.Transmit(satellite, station, []byte{byte(i)}); err != nil {
+			t.Fatalf("Expected Transmit to succeed, got %v", err)
+		}
+		lost := channel.Stats().Lost > before
+		if lost && !previous {
+			bursts++
+		}
+		previous = lost
+	}
+
+	stats := channel.Stats()
+	if stats.Lost+stats.Delivered != sent {
+		t.Errorf("Expected every transmission to be lost or delivered, got %+v", stats)
+	}
+	rate := float64(stats.Lost) / sent
+	if math.Abs(rate-loss.LossRate()) > 0.02 {
+		t.Errorf("Expected a loss rate near %.3f, got %.3f", loss.LossRate(), rate)
+	}
+	// Bad states last 1/R transmissions on average, so losses come in bursts
+	if mean := float64(stats.Lost) / float64(bursts); mean < 3 || mean > 5 {
+		t.Errorf("Expected bursts of about 4 losses, got %.2f", mean)
+	}
+}
+
+func TestChannelSimulator_RequireVisibility(t *testing.T) {
+	satellite, station, received, clock, _ := newChannelTest(t)
+	channel := NewChannelSimulator(ChannelConfig{Clock: clock, RequireVisibility: true})
+	satellite.SetChannel(channel)
+
+	if err := satellite.CommunicateWithGroundStation(station, []byte("in view")); err != nil {
+		t.Fatalf("Expected CommunicateWithGroundStation to succeed in view, got %v", err)
+	}
+	clock.Advance(time.Second)
+	channel.Wait()
+
+	clock.Advance(6 * time.Hour)
+	for {
+		point, err := satellite.look(station, clock.Now())
+		if err != nil {
+			t.Fatalf("Expected look to succeed, got %v", err)
+		}
+		if !station.Visible(point.LookAngles) {
+			break
+		}
+		clock.Advance(time.Hour)
+	}
+	if err := satellite.CommunicateWithGroundStation(station, []byte("hidden")); err != ErrNotVisible {
+		t.Errorf("Expected ErrNotVisible, got %v", err)
+	}
+	if stats := channel.Stats(); stats.Delivered != 1 || stats.Rejected != 1 || received.count() != 1 {
+		t.Errorf("Expected one delivery and one rejection, got %+v", stats)
+	}
+
+	other := NewSatellite("other")
+	if err := channel.Transmit(other, station, []byte("no orbit")); err != ErrNoTLE {
+		t.Errorf("Expected ErrNoTLE without an orbit, got %v", err)
+	}
+}
+
+func TestGilbertElliott_LossRate(t *testing.T) {
+	tests := []struct {
+		loss     GilbertElliott
+		expected float64
+	}{
+		{GilbertElliott{}, 0},
+		{GilbertElliott{LossGood: 0.1}, 0.1},
+		{GilbertElliott{P: 0.1, R: 0.3, LossBad: 1}, 0.25},
+		{GilbertElliott{P: 0.5, R: 0.5, LossGood: 0.2, LossBad: 0.6}, 0.4},
+	}
+	for _, test := range tests {
+		if rate := test.loss.LossRate(); math.Abs(rate-test.expected) > 1e-9 {
+			t.Errorf("Expected loss rate %v for %+v, got %v", test.expected, test.loss, rate)
+		}
+	}
+}
+
+func TestChannelSimulator_Uplink(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	satellite, _, _, _ := newSchedulerTest(start, ContactPlan{})
+	station := satellite.groundStation("gs1")
+	channel := NewChannelSimulator(ChannelConfig{Loss: GilbertElliott{LossGood: 0.3}, Seed: 3})
+	satellite.SetChannel(channel)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	newEndpoint := func() *sdls.Endpoint {
+		endpoint := sdls.NewEndpoint(sdls.Config{
+			SpacecraftID: 77,
+			FECF:         true,
+			StatePath:    filepath.Join(t.TempDir(), "sdls.json"),
+		})
+		if err := endpoint.Load(); err != nil {
+			t.Fatalf("Expected Load to succeed, got %v", err)
+		}
+		if err := endpoint.AddKey(1, key); err != nil {
+			t.Fatalf("Expected AddKey to succeed, got %v", err)
+		}
+		if err := endpoint.AddSecurityAssociation(1, 0, 1); err != nil {
+			t.Fatalf("Expected AddSecurityAssociation to succeed, got %v", err)
+		}
+		return endpoint
+	}
+	var commands []string
+	satellite.SetTelecommand(ccsds.TCConfig{SpacecraftID: 77, FECF: true}, func(packet *ccsds.SpacePacket) error {
+		commands = append(commands, string(packet.Data))
+		return nil
+	})
+	satellite.SetSecurity(newEndpoint())
+	station.Security = newEndpoint()
+
+	const sent = 100
+	for i := 0; i < sent; i++ {
+		command := ccsds.NewSpacePacket(ccsds.Telecommand, 20, []byte{byte(i)})
+		if err := station.SendSecureTelecommand(satellite, 1, command); err != nil {
+			t.Fatalf("Expected a lost command not to be reported, got %v", err)
+		}
+	}
+
+	// Lost frames leave gaps in the sequence numbers, which the satellite
+	// accepts, so every frame that arrives is handled
+	stats := channel.Stats()
+	if stats.Sent != sent || stats.Lost == 0 || stats.Lost+stats.Delivered != sent {
+		t.Errorf("Expected some of the uplink to be lost, got %+v", stats)
+	}
+	if stats.Failed != 0 || len(commands) != stats.Delivered {
+		t.Errorf("Expected the %d delivered commands to be handled, got %d and %+v", stats.Delivered, len(commands), stats)
+	}
+}
@@ -0,0 +1,121 @@
+package satellite
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a source of time, so that schedules can be simulated faster than
+// real time
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// After returns a channel that receives the time once d has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the real time clock
+var SystemClock Clock = systemClock{}
+
+// systemClock implements Clock with the time package
+type systemClock struct{}
+
+// Now returns the current time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns a channel that receives the time once d has elapsed
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SimulatedClock is a Clock that only moves when it is advanced
+type SimulatedClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []simulatedTimer
+}
+
+// simulatedTimer is a channel waiting for the simulated time to reach at
+type simulatedTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewSimulatedClock returns a new SimulatedClock starting at start
+func NewSimulatedClock(start time.Time) *SimulatedClock {
+	return &SimulatedClock{
+		now: start,
+	}
+}
+
+// Now returns the simulated time
+func (c *SimulatedClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the simulated time once the clock
+// has been advanced by d
+func (c *SimulatedClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timer := simulatedTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		timer.c <- c.now
+		return timer.c
+	}
+	c.timers = append(c.timers, timer)
+	return timer.c
+}
+
+// Advance moves the clock forward by d, firing any timers that expire
+func (c *SimulatedClock) Advance(d time.Duration) {
+	c.AdvanceTo(c.Now().Add(d))
+}
+
+// AdvanceTo moves the clock forward to t, firing any timers that expire. The
+// clock never moves backwards.
+func (c *SimulatedClock) AdvanceTo(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.c <- c.now
+	}
+	c.timers = pending
+}
+
+// Next returns the time of the earliest pending timer
+func (c *SimulatedClock) Next() (time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var next time.Time
+	for _, timer := range c.timers {
+		if next.IsZero() || timer.at.Before(next) {
+			next = timer.at
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// Pending returns the number of timers waiting for the clock to advance
+func (c *SimulatedClock) Pending() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
@@ -0,0 +1,49 @@
+package satellite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulatedClock(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+
+	late := clock.After(time.Hour)
+	early := clock.After(time.Minute)
+	if next, ok := clock.Next(); !ok || !next.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the next timer to be in a minute, got %v", next)
+	}
+
+	clock.Advance(30 * time.Second)
+	select {
+	case <-early:
+		t.Fatalf("Expected the timer not to fire before it expires")
+	default:
+	}
+
+	clock.AdvanceTo(start.Add(2 * time.Minute))
+	if at := <-early; !at.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("Expected the timer to receive the simulated time, got %v", at)
+	}
+	if clock.Pending() != 1 {
+		t.Errorf("Expected one pending timer, got %d", clock.Pending())
+	}
+
+	// The clock never moves backwards
+	clock.AdvanceTo(start)
+	if !clock.Now().Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("Expected the clock not to move backwards, got %v", clock.Now())
+	}
+
+	clock.Advance(time.Hour)
+	<-late
+	if _, ok := clock.Next(); ok {
+		t.Errorf("Expected no pending timers")
+	}
+	select {
+	case <-clock.After(0):
+	default:
+		t.Errorf("Expected a zero duration timer to fire immediately")
+	}
+}
@@ -0,0 +1,116 @@
+package satellite
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidContactPlan is returned when an imported contact plan is malformed
+var ErrInvalidContactPlan = errors.New("invalid contact plan")
+
+// Contact is a window in which the satellite can communicate with a ground
+// station
+type Contact struct {
+	// Station is the id of the ground station
+	Station string `json:"station"`
+
+	// Start is when the contact begins
+	Start time.Time `json:"start"`
+
+	// End is when the contact ends
+	End time.Time `json:"end"`
+}
+
+// Active returns whether the contact is in progress at t
+func (c Contact) Active(t time.Time) bool {
+	return !t.Before(c.Start) && t.Before(c.End)
+}
+
+// ContactPlan is a schedule of contacts ordered by start time
+type ContactPlan []Contact
+
+// NewContactPlan returns a contact plan of the contacts sorted by start time
+func NewContactPlan(contacts []Contact) ContactPlan {
+	plan := make(ContactPlan, len(contacts))
+	copy(plan, contacts)
+	sort.SliceStable(plan, func(i, j int) bool {
+		return plan[i].Start.Before(plan[j].Start)
+	})
+	return plan
+}
+
+// ContactPlanFromPasses returns a contact plan with a contact for each pass
+func ContactPlanFromPasses(passes []Pass) ContactPlan {
+	contacts := make([]Contact, len(passes))
+	for i, pass := range passes {
+		contacts[i] = Contact{Station: pass.Station, Start: pass.AOS, End: pass.LOS}
+	}
+	return NewContactPlan(contacts)
+}
+
+// ParseContactPlan reads a contact plan exported as CSV, one contact per
+// row as station,start,end with RFC 3339 times. A header row starting with
+// "station" is skipped.
+func ParseContactPlan(r io.Reader) (ContactPlan, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var contacts []Contact
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidContactPlan, err)
+		}
+		if line == 1 && strings.EqualFold(record[0], "station") {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, record[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: start: %v", ErrInvalidContactPlan, line, err)
+		}
+		end, err := time.Parse(time.RFC3339, record[2])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: end: %v", ErrInvalidContactPlan, line, err)
+		}
+		if record[0] == "" || !end.After(start) {
+			return nil, fmt.Errorf("%w: line %d: empty contact", ErrInvalidContactPlan, line)
+		}
+		contacts = append(contacts, Contact{Station: record[0], Start: start, End: end})
+	}
+	return NewContactPlan(contacts), nil
+}
+
+// Active returns the contacts in progress at t
+func (p ContactPlan) Active(t time.Time) []Contact {
+	var active []Contact
+	for _, contact := range p {
+		if contact.Start.After(t) {
+			break
+		}
+		if contact.Active(t) {
+			active = append(active, contact)
+		}
+	}
+	return active
+}
+
+// Next returns the first contact starting after t
+func (p ContactPlan) Next(t time.Time) (Contact, bool) {
+	i := sort.Search(len(p), func(i int) bool {
+		return p[i].Start.After(t)
+	})
+	if i == len(p) {
+		return Contact{}, false
+	}
+	return p[i], true
+}
@@ -0,0 +1,68 @@
+package satellite
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseContactPlan(t *testing.T) {
+	input := strings.Join([]string{
+		"station,start,end",
+		"# svalbard is booked for the whole day",
+		"gs2, 2024-01-01T12:00:00Z, 2024-01-01T12:10:00Z",
+		"gs1, 2024-01-01T10:00:00Z, 2024-01-01T10:08:30Z",
+	}, "\n")
+	plan, err := ParseContactPlan(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Expected ParseContactPlan to succeed, got %v", err)
+	}
+	if len(plan) != 2 || plan[0].Station != "gs1" || plan[1].Station != "gs2" {
+		t.Fatalf("Expected two contacts sorted by start, got %+v", plan)
+	}
+	if plan[0].End.Sub(plan[0].Start) != 8*time.Minute+30*time.Second {
+		t.Errorf("Expected the contact to last 8m30s, got %v", plan[0].End.Sub(plan[0].Start))
+	}
+
+	invalid := []string{
+		"gs1,2024-01-01T10:00:00Z",
+		"gs1,yesterday,2024-01-01T10:00:00Z",
+		"gs1,2024-01-01T10:00:00Z,2024-01-01T09:00:00Z",
+		",2024-01-01T10:00:00Z,2024-01-01T11:00:00Z",
+	}
+	for _, line := range invalid {
+		if _, err := ParseContactPlan(strings.NewReader(line)); !errors.Is(err, ErrInvalidContactPlan) {
+			t.Errorf("Expected %q to be rejected, got %v", line, err)
+		}
+	}
+}
+
+func TestContactPlan(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	plan := ContactPlanFromPasses([]Pass{
+		{Station: "gs2", AOS: start.Add(2 * time.Hour), LOS: start.Add(3 * time.Hour)},
+		{Station: "gs1", AOS: start.Add(time.Hour), LOS: start.Add(150 * time.Minute)},
+	})
+
+	if active := plan.Active(start); len(active) != 0 {
+		t.Errorf("Expected no active contacts, got %+v", active)
+	}
+	if active := plan.Active(start.Add(130 * time.Minute)); len(active) != 2 {
+		t.Errorf("Expected overlapping contacts to both be active, got %+v", active)
+	}
+	if active := plan.Active(start.Add(150 * time.Minute)); len(active) != 1 || active[0].Station != "gs2" {
+		t.Errorf("Expected a contact to end at LOS, got %+v", active)
+	}
+
+	next, ok := plan.Next(start)
+	if !ok || next.Station != "gs1" {
+		t.Errorf("Expected gs1 to be the next contact, got %+v", next)
+	}
+	if next, ok = plan.Next(start.Add(time.Hour)); !ok || next.Station != "gs2" {
+		t.Errorf("Expected contacts starting now not to be next, got %+v", next)
+	}
+	if _, ok = plan.Next(start.Add(2 * time.Hour)); ok {
+		t.Errorf("Expected no contacts after the last start")
+	}
+}
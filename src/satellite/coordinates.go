@@ -0,0 +1,199 @@
+package satellite
+
+import (
+	"math"
+	"time"
+)
+
+// WGS-84 ellipsoid, used for geodetic coordinates
+const (
+	// EarthRadius is the equatorial radius of the Earth in kilometres
+	EarthRadius = 6378.137
+
+	// EarthFlattening is the flattening of the Earth
+	EarthFlattening = 1 / 298.257223563
+
+	// EarthRotationRate is the rotation rate of the Earth in radians per second
+	EarthRotationRate = 7.292115146706979e-5
+)
+
+// julianDateUnixEpoch is the Julian date of the Unix epoch
+const julianDateUnixEpoch = 2440587.5
+
+// Vector is a Cartesian vector. Positions are in kilometres and velocities
+// in kilometres per second.
+type Vector struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Add returns v + w
+func (v Vector) Add(w Vector) Vector {
+	return Vector{v.X + w.X, v.Y + w.Y, v.Z + w.Z}
+}
+
+// Sub returns v - w
+func (v Vector) Sub(w Vector) Vector {
+	return Vector{v.X - w.X, v.Y - w.Y, v.Z - w.Z}
+}
+
+// Scale returns v multiplied by k
+func (v Vector) Scale(k float64) Vector {
+	return Vector{v.X * k, v.Y * k, v.Z * k}
+}
+
+// Dot returns the dot product of v and w
+func (v Vector) Dot(w Vector) float64 {
+	return v.X*w.X + v.Y*w.Y + v.Z*w.Z
+}
+
+// Cross returns the cross product of v and w
+func (v Vector) Cross(w Vector) Vector {
+	return Vector{
+		v.Y*w.Z - v.Z*w.Y,
+		v.Z*w.X - v.X*w.Z,
+		v.X*w.Y - v.Y*w.X,
+	}
+}
+
+// Norm returns the length of v
+func (v Vector) Norm() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// StateVector is the position and velocity of a satellite at a time
+type StateVector struct {
+	// Time is the time of the state
+	Time time.Time `json:"time"`
+
+	// Position is the position in kilometres
+	Position Vector `json:"position"`
+
+	// Velocity is the velocity in kilometres per second
+	Velocity Vector `json:"velocity"`
+}
+
+// Geodetic is a position relative to the WGS-84 ellipsoid
+type Geodetic struct {
+	// Latitude is the geodetic latitude in degrees
+	Latitude float64 `json:"latitude"`
+
+	// Longitude is the longitude in degrees, between -180 and 180
+	Longitude float64 `json:"longitude"`
+
+	// Altitude is the height above the ellipsoid in kilometres
+	Altitude float64 `json:"altitude"`
+}
+
+// JulianDate returns the Julian date of t
+func JulianDate(t time.Time) float64 {
+	seconds := float64(t.Unix()) + float64(t.Nanosecond())/1e9
+	return seconds/86400 + julianDateUnixEpoch
+}
+
+// GMST returns the Greenwich mean sidereal time at t in radians, using the
+// IAU 1982 model with UTC as an approximation of UT1
+func GMST(t time.Time) float64 {
+	return gstime(JulianDate(t))
+}
+
+// gstime returns the Greenwich mean sidereal time at a Julian date in radians
+func gstime(jd float64) float64 {
+	tut1 := (jd - 2451545.0) / 36525.0
+	temp := -6.2e-6*tut1*tut1*tut1 + 0.093104*tut1*tut1 +
+		(876600.0*3600+8640184.812866)*tut1 + 67310.54841
+	temp = math.Mod(temp*deg2rad/240.0, twoPi)
+	if temp < 0 {
+		temp += twoPi
+	}
+	return temp
+}
+
+// TEMEToECEF converts a position and velocity from the true equator, mean
+// equinox frame used by SGP4 to the Earth-fixed frame at t. Polar motion is
+// ignored.
+func TEMEToECEF(position, velocity Vector, t time.Time) (Vector, Vector) {
+	gmst := GMST(t)
+	sin, cos := math.Sincos(gmst)
+	r := Vector{
+		cos*position.X + sin*position.Y,
+		-sin*position.X + cos*position.Y,
+		position.Z,
+	}
+	v := Vector{
+		cos*velocity.X + sin*velocity.Y + EarthRotationRate*r.Y,
+		-sin*velocity.X + cos*velocity.Y - EarthRotationRate*r.X,
+		velocity.Z,
+	}
+	return r, v
+}
+
+// ECEFToTEME converts a position and velocity from the Earth-fixed frame to
+// the true equator, mean equinox frame at t
+func ECEFToTEME(position, velocity Vector, t time.Time) (Vector, Vector) {
+	gmst := GMST(t)
+	sin, cos := math.Sincos(gmst)
+	inertial := Vector{
+		velocity.X - EarthRotationRate*position.Y,
+		velocity.Y + EarthRotationRate*position.X,
+		velocity.Z,
+	}
+	r := Vector{
+		cos*position.X - sin*position.Y,
+		sin*position.X + cos*position.Y,
+		position.Z,
+	}
+	v := Vector{
+		cos*inertial.X - sin*inertial.Y,
+		sin*inertial.X + cos*inertial.Y,
+		inertial.Z,
+	}
+	return r, v
+}
+
+// ECEFToGeodetic converts an Earth-fixed position to geodetic coordinates
+func ECEFToGeodetic(position Vector) Geodetic {
+	e2 := EarthFlattening * (2 - EarthFlattening)
+	p := math.Hypot(position.X, position.Y)
+	longitude := math.Atan2(position.Y, position.X)
+
+	// Iterate on the latitude, which converges to well under a millimetre
+	latitude := math.Atan2(position.Z, p*(1-e2))
+	var n, altitude float64
+	for i := 0; i < 10; i++ {
+		sin := math.Sin(latitude)
+		n = EarthRadius / math.Sqrt(1-e2*sin*sin)
+		next := math.Atan2(position.Z+n*e2*sin, p)
+		if math.Abs(next-latitude) < 1e-12 {
+			latitude = next
+			break
+		}
+		latitude = next
+	}
+	sin, cos := math.Sincos(latitude)
+	n = EarthRadius / math.Sqrt(1-e2*sin*sin)
+	if math.Abs(cos) > 1e-10 {
+		altitude = p/cos - n
+	} else {
+		altitude = math.Abs(position.Z) - n*(1-e2)
+	}
+	return Geodetic{
+		Latitude:  latitude / deg2rad,
+		Longitude: longitude / deg2rad,
+		Altitude:  altitude,
+	}
+}
+
+// GeodeticToECEF converts geodetic coordinates to an Earth-fixed position
+func GeodeticToECEF(g Geodetic) Vector {
+	e2 := EarthFlattening * (2 - EarthFlattening)
+	sinLat, cosLat := math.Sincos(g.Latitude * deg2rad)
+	sinLon, cosLon := math.Sincos(g.Longitude * deg2rad)
+	n := EarthRadius / math.Sqrt(1-e2*sinLat*sinLat)
+	return Vector{
+		(n + g.Altitude) * cosLat * cosLon,
+		(n + g.Altitude) * cosLat * sinLon,
+		(n*(1-e2) + g.Altitude) * sinLat,
+	}
+}
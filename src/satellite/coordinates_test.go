@@ -0,0 +1,81 @@
+package satellite
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestJulianDate(t *testing.T) {
+	j2000 := time.Date(2000, time.January, 1, 12, 0, 0, 0, time.UTC)
+	if jd := JulianDate(j2000); jd != 2451545.0 {
+		t.Errorf("Expected J2000 to be Julian date 2451545.0, got %f", jd)
+	}
+}
+
+func TestGMST(t *testing.T) {
+	// GMST at J2000 is 18h 41m 50.54841s
+	j2000 := time.Date(2000, time.January, 1, 12, 0, 0, 0, time.UTC)
+	expected := 280.46061837 * deg2rad
+	if gmst := GMST(j2000); math.Abs(gmst-expected) > 1e-8 {
+		t.Errorf("Expected GMST at J2000 to be %f, got %f", expected, gmst)
+	}
+}
+
+func TestTEMEToECEF(t *testing.T) {
+	at := time.Date(2024, time.March, 20, 3, 6, 0, 0, time.UTC)
+	position := Vector{6524.834, 6862.875, 6448.296}
+	velocity := Vector{4.901327, 5.533756, -1.976341}
+
+	ecefPosition, ecefVelocity := TEMEToECEF(position, velocity, at)
+	if math.Abs(ecefPosition.Norm()-position.Norm()) > 1e-9 || ecefPosition.Z != position.Z {
+		t.Errorf("Expected the conversion to rotate about the pole, got %+v", ecefPosition)
+	}
+	tPosition, tVelocity := ECEFToTEME(ecefPosition, ecefVelocity, at)
+	if tPosition.Sub(position).Norm() > 1e-9 || tVelocity.Sub(velocity).Norm() > 1e-12 {
+		t.Errorf("Expected ECEFToTEME to invert TEMEToECEF, got %+v %+v", tPosition, tVelocity)
+	}
+
+	// A point fixed on the equator has no velocity in the Earth-fixed frame
+	ground := Vector{EarthRadius, 0, 0}
+	inertialPosition, inertialVelocity := ECEFToTEME(ground, Vector{}, at)
+	if math.Abs(inertialVelocity.Norm()-EarthRadius*EarthRotationRate) > 1e-9 {
+		t.Errorf("Expected the equator to move at %f km/s, got %f", EarthRadius*EarthRotationRate, inertialVelocity.Norm())
+	}
+	if _, v := TEMEToECEF(inertialPosition, inertialVelocity, at); v.Norm() > 1e-12 {
+		t.Errorf("Expected a ground point to be at rest in the Earth-fixed frame, got %+v", v)
+	}
+}
+
+func TestGeodetic(t *testing.T) {
+	equator := ECEFToGeodetic(Vector{EarthRadius, 0, 0})
+	if math.Abs(equator.Latitude) > 1e-9 || math.Abs(equator.Longitude) > 1e-9 || math.Abs(equator.Altitude) > 1e-9 {
+		t.Errorf("Expected a point on the equator at 0, 0, 0, got %+v", equator)
+	}
+	pole := ECEFToGeodetic(Vector{0, 0, 6356.752314245})
+	if math.Abs(pole.Latitude-90) > 1e-9 || math.Abs(pole.Altitude) > 1e-6 {
+		t.Errorf("Expected the north pole at 90 degrees, got %+v", pole)
+	}
+
+	for _, g := range []Geodetic{
+		{Latitude: 51.4769, Longitude: -0.0005, Altitude: 0.046},
+		{Latitude: -33.8688, Longitude: 151.2093, Altitude: 0.058},
+		{Latitude: 64.8378, Longitude: -147.7164, Altitude: 35786},
+	} {
+		back := ECEFToGeodetic(GeodeticToECEF(g))
+		if math.Abs(back.Latitude-g.Latitude) > 1e-9 || math.Abs(back.Longitude-g.Longitude) > 1e-9 || math.Abs(back.Altitude-g.Altitude) > 1e-6 {
+			t.Errorf("Expected %+v to survive a round trip, got %+v", g, back)
+		}
+	}
+}
+
+func TestVector(t *testing.T) {
+	x := Vector{1, 0, 0}
+	y := Vector{0, 1, 0}
+	if x.Cross(y) != (Vector{0, 0, 1}) || x.Dot(y) != 0 {
+		t.Errorf("Expected x cross y to be z")
+	}
+	if v := x.Add(y).Scale(3).Sub(Vector{0, 0, 4}); v.Norm() != math.Sqrt(34) {
+		t.Errorf("Expected the norm of %+v to be sqrt(34), got %f", v, v.Norm())
+	}
+}
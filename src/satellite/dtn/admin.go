@@ -0,0 +1,113 @@
+package dtn
+
+// Administrative record types
+const (
+	// AdminStatusReport is the record type of bundle status reports
+	AdminStatusReport = 1
+
+	// AdminCustodySignal is the record type of custody signals. BPv7 leaves
+	// custody transfer to extensions; this uses the record type of the
+	// bundle-in-bundle custody signal with a simpler content.
+	AdminCustodySignal = 4
+)
+
+// Status report reason codes
+const (
+	ReasonNoInformation    = 0
+	ReasonLifetimeExpired  = 1
+	ReasonDepletedStorage  = 4
+	ReasonNoRoute          = 6
+	ReasonHopLimitExceeded = 9
+)
+
+// CustodySignal tells the previous node whether a bundle was accepted, so it
+// can stop retransmitting it
+type CustodySignal struct {
+	// Accepted is true if the node took custody of the bundle
+	Accepted bool
+
+	// Reason is the reason code when custody was refused
+	Reason uint64
+
+	// Bundle identifies the bundle or fragment
+	Bundle BundleID
+}
+
+// encode returns the administrative record payload of the signal,
+// [record type, [accepted, reason, [source, [time, sequence], offset, length]]]
+func (c CustodySignal) encode() ([]byte, error) {
+	w := &cborWriter{}
+	w.array(2)
+	w.uint(AdminCustodySignal)
+	w.array(3)
+	w.bool(c.Accepted)
+	w.uint(c.Reason)
+	if c.Bundle.Fragment {
+		w.array(4)
+	} else {
+		w.array(2)
+	}
+	if err := c.Bundle.Source.encode(w); err != nil {
+		return nil, err
+	}
+	w.array(2)
+	w.uint(c.Bundle.Created.Time)
+	w.uint(c.Bundle.Created.Sequence)
+	if c.Bundle.Fragment {
+		w.uint(c.Bundle.FragmentOffset)
+		w.uint(c.Bundle.FragmentLength)
+	}
+	return w.buf, nil
+}
+
+// decodeCustodySignal decodes an administrative record payload, returning
+// false if it is not a custody signal
+func decodeCustodySignal(payload []byte) (CustodySignal, bool, error) {
+	var signal CustodySignal
+	r := &cborReader{data: payload}
+	if _, err := r.arrayOf(2, 2); err != nil {
+		return signal, false, err
+	}
+	recordType, err := r.uint()
+	if err != nil || recordType != AdminCustodySignal {
+		return signal, false, err
+	}
+	if _, err := r.arrayOf(3, 3); err != nil {
+		return signal, false, err
+	}
+	if signal.Accepted, err = r.bool(); err != nil {
+		return signal, false, err
+	}
+	if signal.Reason, err = r.uint(); err != nil {
+		return signal, false, err
+	}
+	items, err := r.arrayOf(2, 4)
+	if err != nil {
+		return signal, false, err
+	}
+	if items == 3 {
+		return signal, false, r.malformed("bundle ID with %d items", items)
+	}
+	if signal.Bundle.Source, err = decodeEndpoint(r); err != nil {
+		return signal, false, err
+	}
+	if _, err := r.arrayOf(2, 2); err != nil {
+		return signal, false, err
+	}
+	if signal.Bundle.Created.Time, err = r.uint(); err != nil {
+		return signal, false, err
+	}
+	if signal.Bundle.Created.Sequence, err = r.uint(); err != nil {
+		return signal, false, err
+	}
+	if items == 4 {
+		signal.Bundle.Fragment = true
+		if signal.Bundle.FragmentOffset, err = r.uint(); err != nil {
+			return signal, false, err
+		}
+		if signal.Bundle.FragmentLength, err = r.uint(); err != nil {
+			return signal, false, err
+		}
+	}
+	return signal, true, nil
+}
@@ -0,0 +1,30 @@
+package dtn
+
+import (
+	"testing"
+)
+
+func TestCustodySignal(t *testing.T) {
+	for _, signal := range []CustodySignal{
+		{Accepted: true, Bundle: newTestBundle().ID()},
+		{Reason: ReasonDepletedStorage, Bundle: BundleID{Source: "ipn:1.1", Created: CreationTimestamp{Time: 5, Sequence: 1}, Fragment: true, FragmentOffset: 100, FragmentLength: 50}},
+	} {
+		payload, err := signal.encode()
+		if err != nil {
+			t.Fatalf("Expected encode to succeed, got %v", err)
+		}
+		decoded, ok, err := decodeCustodySignal(payload)
+		if err != nil || !ok || decoded != signal {
+			t.Errorf("Expected %+v to round trip, got %+v, %v", signal, decoded, err)
+		}
+	}
+
+	// Other administrative records are not custody signals
+	w := &cborWriter{}
+	w.array(2)
+	w.uint(AdminStatusReport)
+	w.array(0)
+	if _, ok, err := decodeCustodySignal(w.buf); ok || err != nil {
+		t.Errorf("Expected a status report to be ignored, got %v, %v", ok, err)
+	}
+}
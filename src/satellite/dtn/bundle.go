@@ -0,0 +1,622 @@
+package dtn
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+// Version is the bundle protocol version implemented by this package
+const Version = 7
+
+// Bundle processing control flags
+const (
+	FlagFragment         uint64 = 0x000001
+	FlagAdminRecord      uint64 = 0x000002
+	FlagMustNotFragment  uint64 = 0x000004
+	FlagAckRequested     uint64 = 0x000020
+	FlagStatusTime       uint64 = 0x000040
+	FlagReportReception  uint64 = 0x004000
+	FlagReportForwarding uint64 = 0x010000
+	FlagReportDelivery   uint64 = 0x020000
+	FlagReportDeletion   uint64 = 0x040000
+)
+
+// Block processing control flags
+const (
+	BlockReplicate            uint64 = 0x01
+	BlockReportUnprocessable  uint64 = 0x02
+	BlockDeleteUnprocessable  uint64 = 0x04
+	BlockDiscardUnprocessable uint64 = 0x10
+)
+
+// Block type codes
+const (
+	BlockPayload      uint64 = 1
+	BlockPreviousNode uint64 = 6
+	BlockBundleAge    uint64 = 7
+	BlockHopCount     uint64 = 10
+)
+
+// CRCType is the type of CRC protecting a block
+type CRCType uint64
+
+// CRC types
+const (
+	CRCNone CRCType = 0
+	CRC16   CRCType = 1
+	CRC32C  CRCType = 2
+)
+
+// valid returns whether the CRC type is known
+func (c CRCType) valid() bool {
+	return c <= CRC32C
+}
+
+// dtnEpoch is the DTN epoch, 2000-01-01 00:00:00 UTC
+var dtnEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// castagnoli is the CRC-32C table
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// DTNTime returns t as milliseconds since the DTN epoch
+func DTNTime(t time.Time) uint64 {
+	if t.Before(dtnEpoch) {
+		return 0
+	}
+	return uint64(t.Sub(dtnEpoch) / time.Millisecond)
+}
+
+// FromDTNTime returns the time of a DTN timestamp
+func FromDTNTime(ms uint64) time.Time {
+	return dtnEpoch.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// CreationTimestamp is the time a bundle was created and a sequence number
+// distinguishing bundles created by a node at the same time
+type CreationTimestamp struct {
+	// Time is the creation time in milliseconds since the DTN epoch, or zero
+	// if the node has no accurate clock
+	Time uint64 `json:"time"`
+
+	// Sequence is the sequence number
+	Sequence uint64 `json:"sequence"`
+}
+
+// PrimaryBlock is the primary block of a bundle
+type PrimaryBlock struct {
+	// Flags are the bundle processing control flags
+	Flags uint64
+
+	// CRCType is the CRC protecting the primary block
+	CRCType CRCType
+
+	// Destination is the endpoint the bundle is for
+	Destination EndpointID
+
+	// Source is the endpoint that created the bundle
+	Source EndpointID
+
+	// ReportTo is the endpoint status reports are sent to
+	ReportTo EndpointID
+
+	// Created is the creation timestamp
+	Created CreationTimestamp
+
+	// Lifetime is how long after creation the bundle expires, with
+	// millisecond precision
+	Lifetime time.Duration
+
+	// FragmentOffset is the offset of the payload in the original
+	// application data unit, if the bundle is a fragment
+	FragmentOffset uint64
+
+	// TotalLength is the length of the original application data unit, if
+	// the bundle is a fragment
+	TotalLength uint64
+}
+
+// Block is a canonical bundle block
+type Block struct {
+	// Type is the block type code
+	Type uint64
+
+	// Number is unique within the bundle. The payload block is number 1.
+	Number uint64
+
+	// Flags are the block processing control flags
+	Flags uint64
+
+	// CRCType is the CRC protecting the block
+	CRCType CRCType
+
+	// Data is the block-type-specific data
+	Data []byte
+}
+
+// Bundle is a bundle protocol version 7 bundle
+type Bundle struct {
+	// Primary is the primary block
+	Primary PrimaryBlock
+
+	// Blocks are the canonical blocks, ending with the payload block
+	Blocks []Block
+}
+
+// BundleID uniquely identifies a bundle or a fragment of one
+type BundleID struct {
+	Source  EndpointID
+	Created CreationTimestamp
+
+	// Fragment is set for fragments, which are further identified by their
+	// offset and payload length
+	Fragment       bool
+	FragmentOffset uint64
+	FragmentLength uint64
+}
+
+// String returns the ID in a form usable as a map key or file name
+func (id BundleID) String() string {
+	s := fmt.Sprintf("%s/%d.%d", id.Source, id.Created.Time, id.Created.Sequence)
+	if id.Fragment {
+		s += fmt.Sprintf("/%d+%d", id.FragmentOffset, id.FragmentLength)
+	}
+	return s
+}
+
+// NewBundle returns a bundle carrying a payload. The payload block is
+// protected with CRC-32C.
+func NewBundle(source, destination EndpointID, created CreationTimestamp, lifetime time.Duration, payload []byte) *Bundle {
+	return &Bundle{
+		Primary: PrimaryBlock{
+			CRCType:     CRC32C,
+			Destination: destination,
+			Source:      source,
+			ReportTo:    source,
+			Created:     created,
+			Lifetime:    lifetime,
+		},
+		Blocks: []Block{{
+			Type:    BlockPayload,
+			Number:  1,
+			CRCType: CRC32C,
+			Data:    payload,
+		}},
+	}
+}
+
+// ID returns the bundle's ID
+func (b *Bundle) ID() BundleID {
+	id := BundleID{Source: b.Primary.Source, Created: b.Primary.Created}
+	if b.IsFragment() {
+		id.Fragment = true
+		id.FragmentOffset = b.Primary.FragmentOffset
+		id.FragmentLength = uint64(len(b.Payload()))
+	}
+	return id
+}
+
+// IsFragment returns whether the bundle is a fragment
+func (b *Bundle) IsFragment() bool {
+	return b.Primary.Flags&FlagFragment != 0
+}
+
+// IsAdminRecord returns whether the payload is an administrative record
+func (b *Bundle) IsAdminRecord() bool {
+	return b.Primary.Flags&FlagAdminRecord != 0
+}
+
+// Payload returns the data of the payload block
+func (b *Bundle) Payload() []byte {
+	if block := b.Block(BlockPayload); block != nil {
+		return block.Data
+	}
+	return nil
+}
+
+// Block returns the first block of a type, or nil
+func (b *Bundle) Block(blockType uint64) *Block {
+	for i := range b.Blocks {
+		if b.Blocks[i].Type == blockType {
+			return &b.Blocks[i]
+		}
+	}
+	return nil
+}
+
+// SetBlock replaces the first block of the block's type, or adds it before
+// the payload block with the next free block number
+func (b *Bundle) SetBlock(block Block) {
+	if existing := b.Block(block.Type); existing != nil {
+		block.Number = existing.Number
+		*existing = block
+		return
+	}
+	block.Number = 2
+	for _, existing := range b.Blocks {
+		if existing.Number >= block.Number {
+			block.Number = existing.Number + 1
+		}
+	}
+	n := len(b.Blocks)
+	if n > 0 && b.Blocks[n-1].Type == BlockPayload {
+		b.Blocks = append(b.Blocks[:n-1], block, b.Blocks[n-1])
+		return
+	}
+	b.Blocks = append(b.Blocks, block)
+}
+
+// Expires returns when the bundle expires. Bundles created without a clock
+// expire their lifetime after the given receipt time less their age.
+func (b *Bundle) Expires(received time.Time) time.Time {
+	if b.Primary.Created.Time != 0 {
+		return FromDTNTime(b.Primary.Created.Time).Add(b.Primary.Lifetime)
+	}
+	age, _ := b.Age()
+	return received.Add(b.Primary.Lifetime - age)
+}
+
+// Age returns the value of the bundle age block
+func (b *Bundle) Age() (time.Duration, bool) {
+	block := b.Block(BlockBundleAge)
+	if block == nil {
+		return 0, false
+	}
+	r := &cborReader{data: block.Data}
+	ms, err := r.uint()
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// SetAge sets the bundle age block
+func (b *Bundle) SetAge(age time.Duration) {
+	w := &cborWriter{}
+	w.uint(uint64(age / time.Millisecond))
+	b.SetBlock(Block{Type: BlockBundleAge, Flags: BlockReplicate, CRCType: CRC32C, Data: w.buf})
+}
+
+// HopCount returns the hop limit and count of the hop count block
+func (b *Bundle) HopCount() (uint64, uint64, bool) {
+	block := b.Block(BlockHopCount)
+	if block == nil {
+		return 0, 0, false
+	}
+	r := &cborReader{data: block.Data}
+	if _, err := r.arrayOf(2, 2); err != nil {
+		return 0, 0, false
+	}
+	limit, err := r.uint()
+	if err != nil {
+		return 0, 0, false
+	}
+	count, err := r.uint()
+	if err != nil {
+		return 0, 0, false
+	}
+	return limit, count, true
+}
+
+// SetHopCount sets the hop count block
+func (b *Bundle) SetHopCount(limit, count uint64) {
+	w := &cborWriter{}
+	w.array(2)
+	w.uint(limit)
+	w.uint(count)
+	b.SetBlock(Block{Type: BlockHopCount, Flags: BlockReplicate, CRCType: CRC32C, Data: w.buf})
+}
+
+// PreviousNode returns the node that forwarded the bundle, from the previous
+// node block
+func (b *Bundle) PreviousNode() (EndpointID, bool) {
+	block := b.Block(BlockPreviousNode)
+	if block == nil {
+		return "", false
+	}
+	eid, err := decodeEndpoint(&cborReader{data: block.Data})
+	return eid, err == nil
+}
+
+// SetPreviousNode sets the previous node block. It is replicated in every
+// fragment, so each fragment's custody can be signalled.
+func (b *Bundle) SetPreviousNode(node EndpointID) error {
+	w := &cborWriter{}
+	if err := node.encode(w); err != nil {
+		return err
+	}
+	b.SetBlock(Block{Type: BlockPreviousNode, Flags: BlockReplicate, CRCType: CRC32C, Data: w.buf})
+	return nil
+}
+
+// Clone returns a deep copy of the bundle
+func (b *Bundle) Clone() *Bundle {
+	clone := &Bundle{Primary: b.Primary, Blocks: make([]Block, len(b.Blocks))}
+	for i, block := range b.Blocks {
+		block.Data = append([]byte(nil), block.Data...)
+		clone.Blocks[i] = block
+	}
+	return clone
+}
+
+// Encode returns the CBOR encoding of the bundle
+func (b *Bundle) Encode() ([]byte, error) {
+	if n := len(b.Blocks); n == 0 || b.Blocks[n-1].Type != BlockPayload || b.Blocks[n-1].Number != 1 {
+		return nil, fmt.Errorf("%w: the last block must be the payload block", ErrMalformed)
+	}
+	w := &cborWriter{}
+	w.buf = append(w.buf, cborIndefArray)
+	if err := b.Primary.encode(w); err != nil {
+		return nil, err
+	}
+	for _, block := range b.Blocks {
+		if err := block.encode(w); err != nil {
+			return nil, err
+		}
+	}
+	w.buf = append(w.buf, cborBreak)
+	return w.buf, nil
+}
+
+// encode writes the primary block
+func (p *PrimaryBlock) encode(w *cborWriter) error {
+	if !p.CRCType.valid() {
+		return fmt.Errorf("%w: unknown CRC type %d", ErrMalformed, p.CRCType)
+	}
+	start := len(w.buf)
+	items := 8
+	if p.Flags&FlagFragment != 0 {
+		items += 2
+	}
+	if p.CRCType != CRCNone {
+		items++
+	}
+	w.array(items)
+	w.uint(Version)
+	w.uint(p.Flags)
+	w.uint(uint64(p.CRCType))
+	for _, eid := range []EndpointID{p.Destination, p.Source, p.ReportTo} {
+		if err := eid.encode(w); err != nil {
+			return err
+		}
+	}
+	w.array(2)
+	w.uint(p.Created.Time)
+	w.uint(p.Created.Sequence)
+	w.uint(uint64(p.Lifetime / time.Millisecond))
+	if p.Flags&FlagFragment != 0 {
+		w.uint(p.FragmentOffset)
+		w.uint(p.TotalLength)
+	}
+	writeCRC(w, start, p.CRCType)
+	return nil
+}
+
+// encode writes a canonical block
+func (b *Block) encode(w *cborWriter) error {
+	if !b.CRCType.valid() {
+		return fmt.Errorf("%w: unknown CRC type %d", ErrMalformed, b.CRCType)
+	}
+	start := len(w.buf)
+	items := 5
+	if b.CRCType != CRCNone {
+		items++
+	}
+	w.array(items)
+	w.uint(b.Type)
+	w.uint(b.Number)
+	w.uint(b.Flags)
+	w.uint(uint64(b.CRCType))
+	w.bytes(b.Data)
+	writeCRC(w, start, b.CRCType)
+	return nil
+}
+
+// DecodeBundle decodes a bundle, checking its CRCs
+func DecodeBundle(data []byte) (*Bundle, error) {
+	r := &cborReader{data: data}
+	if b, ok := r.peek(); !ok || b != cborIndefArray {
+		return nil, r.malformed("a bundle must be an indefinite length array")
+	}
+	r.pos++
+
+	bundle := &Bundle{}
+	if err := bundle.Primary.decode(r); err != nil {
+		return nil, err
+	}
+	numbers := make(map[uint64]bool)
+	for {
+		next, ok := r.peek()
+		if !ok {
+			return nil, r.malformed("unexpected end of data")
+		}
+		if next == cborBreak {
+			r.pos++
+			break
+		}
+		var block Block
+		if err := block.decode(r); err != nil {
+			return nil, err
+		}
+		if numbers[block.Number] || block.Number == 0 {
+			return nil, r.malformed("duplicate block number %d", block.Number)
+		}
+		numbers[block.Number] = true
+		bundle.Blocks = append(bundle.Blocks, block)
+	}
+	if !r.done() {
+		return nil, r.malformed("trailing data")
+	}
+	n := len(bundle.Blocks)
+	if n == 0 || bundle.Blocks[n-1].Type != BlockPayload || bundle.Blocks[n-1].Number != 1 {
+		return nil, fmt.Errorf("%w: the last block must be the payload block", ErrMalformed)
+	}
+	return bundle, nil
+}
+
+// decode reads a primary block
+func (p *PrimaryBlock) decode(r *cborReader) error {
+	start := r.pos
+	items, err := r.arrayOf(8, 11)
+	if err != nil {
+		return err
+	}
+	version, err := r.uint()
+	if err != nil {
+		return err
+	}
+	if version != Version {
+		return r.malformed("unsupported bundle protocol version %d", version)
+	}
+	if p.Flags, err = r.uint(); err != nil {
+		return err
+	}
+	crcType, err := r.uint()
+	if err != nil {
+		return err
+	}
+	p.CRCType = CRCType(crcType)
+	expected := 8
+	if p.Flags&FlagFragment != 0 {
+		expected += 2
+	}
+	if p.CRCType != CRCNone {
+		expected++
+	}
+	if items != expected || !p.CRCType.valid() {
+		return r.malformed("primary block has %d items for flags %#x and CRC type %d", items, p.Flags, crcType)
+	}
+	for _, eid := range []*EndpointID{&p.Destination, &p.Source, &p.ReportTo} {
+		if *eid, err = decodeEndpoint(r); err != nil {
+			return err
+		}
+	}
+	if _, err := r.arrayOf(2, 2); err != nil {
+		return err
+	}
+	if p.Created.Time, err = r.uint(); err != nil {
+		return err
+	}
+	if p.Created.Sequence, err = r.uint(); err != nil {
+		return err
+	}
+	lifetime, err := r.uint()
+	if err != nil {
+		return err
+	}
+	p.Lifetime = time.Duration(lifetime) * time.Millisecond
+	if p.Flags&FlagFragment != 0 {
+		if p.FragmentOffset, err = r.uint(); err != nil {
+			return err
+		}
+		if p.TotalLength, err = r.uint(); err != nil {
+			return err
+		}
+	}
+	return checkCRC(r, start, p.CRCType)
+}
+
+// decode reads a canonical block
+func (b *Block) decode(r *cborReader) error {
+	start := r.pos
+	items, err := r.arrayOf(5, 6)
+	if err != nil {
+		return err
+	}
+	if b.Type, err = r.uint(); err != nil {
+		return err
+	}
+	if b.Number, err = r.uint(); err != nil {
+		return err
+	}
+	if b.Flags, err = r.uint(); err != nil {
+		return err
+	}
+	crcType, err := r.uint()
+	if err != nil {
+		return err
+	}
+	b.CRCType = CRCType(crcType)
+	if (items == 6) != (b.CRCType != CRCNone) || !b.CRCType.valid() {
+		return r.malformed("block %d has %d items for CRC type %d", b.Number, items, crcType)
+	}
+	data, err := r.bytes()
+	if err != nil {
+		return err
+	}
+	b.Data = append([]byte(nil), data...)
+	return checkCRC(r, start, b.CRCType)
+}
+
+// crcLength returns the length of a CRC value
+func crcLength(crcType CRCType) int {
+	switch crcType {
+	case CRC16:
+		return 2
+	case CRC32C:
+		return 4
+	}
+	return 0
+}
+
+// computeCRC returns the CRC of a block encoded with a zeroed CRC value
+func computeCRC(data []byte, crcType CRCType) []byte {
+	value := make([]byte, crcLength(crcType))
+	switch crcType {
+	case CRC16:
+		binary.BigEndian.PutUint16(value, crc16(data))
+	case CRC32C:
+		binary.BigEndian.PutUint32(value, crc32.Checksum(data, castagnoli))
+	}
+	return value
+}
+
+// writeCRC appends the CRC of the block starting at start
+func writeCRC(w *cborWriter, start int, crcType CRCType) {
+	n := crcLength(crcType)
+	if n == 0 {
+		return
+	}
+	w.bytes(make([]byte, n))
+	copy(w.buf[len(w.buf)-n:], computeCRC(w.buf[start:], crcType))
+}
+
+// checkCRC reads and checks the CRC of the block starting at start
+func checkCRC(r *cborReader, start int, crcType CRCType) error {
+	n := crcLength(crcType)
+	if n == 0 {
+		return nil
+	}
+	value, err := r.bytes()
+	if err != nil {
+		return err
+	}
+	if len(value) != n {
+		return r.malformed("CRC of length %d for CRC type %d", len(value), crcType)
+	}
+	block := append([]byte(nil), r.data[start:r.pos]...)
+	for i := len(block) - n; i < len(block); i++ {
+		block[i] = 0
+	}
+	if string(computeCRC(block, crcType)) != string(value) {
+		return r.malformed("CRC mismatch")
+	}
+	return nil
+}
+
+// crc16 computes the CRC-16/X-25 used by the bundle protocol
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0x8408
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
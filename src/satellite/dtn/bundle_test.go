@@ -0,0 +1,142 @@
+package dtn
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestBundle returns a bundle with every block type the package knows
+func newTestBundle() *Bundle {
+	created := CreationTimestamp{Time: DTNTime(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)), Sequence: 7}
+	bundle := NewBundle("dtn://sat1/telemetry", "ipn:2.1", created, time.Hour, []byte("housekeeping frame"))
+	bundle.Primary.Flags = FlagReportDelivery
+	bundle.Primary.CRCType = CRC16
+	bundle.SetHopCount(8, 1)
+	bundle.SetAge(1500 * time.Millisecond)
+	bundle.SetPreviousNode("dtn://gs1/")
+	return bundle
+}
+
+func TestBundle_Encode(t *testing.T) {
+	bundle := newTestBundle()
+	data, err := bundle.Encode()
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+	if data[0] != 0x9f || data[len(data)-1] != 0xff {
+		t.Errorf("Expected an indefinite length array, got %x", data)
+	}
+	decoded, err := DecodeBundle(data)
+	if err != nil {
+		t.Fatalf("Expected DecodeBundle to succeed, got %v", err)
+	}
+	if !reflect.DeepEqual(decoded, bundle) {
+		t.Errorf("Expected the bundle to round trip, got %+v", decoded)
+	}
+
+	if limit, count, ok := decoded.HopCount(); !ok || limit != 8 || count != 1 {
+		t.Errorf("Expected a hop count of 1 of 8, got %d of %d", count, limit)
+	}
+	if age, ok := decoded.Age(); !ok || age != 1500*time.Millisecond {
+		t.Errorf("Expected an age of 1.5s, got %v", age)
+	}
+	if previous, ok := decoded.PreviousNode(); !ok || previous != "dtn://gs1/" {
+		t.Errorf("Expected the previous node to be gs1, got %s", previous)
+	}
+	numbers := []uint64{}
+	for _, block := range decoded.Blocks {
+		numbers = append(numbers, block.Number)
+	}
+	if !reflect.DeepEqual(numbers, []uint64{2, 3, 4, 1}) {
+		t.Errorf("Expected extension blocks before the payload, got numbers %v", numbers)
+	}
+}
+
+func TestBundle_Fragment(t *testing.T) {
+	bundle := newTestBundle()
+	bundle.Primary.Flags |= FlagFragment
+	bundle.Primary.FragmentOffset = 100
+	bundle.Primary.TotalLength = 1000
+	data, err := bundle.Encode()
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+	decoded, err := DecodeBundle(data)
+	if err != nil || decoded.Primary.FragmentOffset != 100 || decoded.Primary.TotalLength != 1000 {
+		t.Fatalf("Expected the fragment fields to round trip, got %+v, %v", decoded, err)
+	}
+	if id := decoded.ID(); !id.Fragment || id.FragmentOffset != 100 || id.FragmentLength != 18 {
+		t.Errorf("Expected the ID to include the fragment, got %+v", id)
+	}
+}
+
+func TestDecodeBundle_Invalid(t *testing.T) {
+	data, err := newTestBundle().Encode()
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+
+	// Flipping any bit of the payload breaks its CRC
+	corrupt := append([]byte(nil), data...)
+	i := bytes.Index(corrupt, []byte("housekeeping"))
+	corrupt[i] ^= 0x01
+	if _, err := DecodeBundle(corrupt); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected a corrupt payload to be rejected, got %v", err)
+	}
+
+	// The primary block is protected too
+	corrupt = append([]byte(nil), data...)
+	i = bytes.Index(corrupt, []byte("//sat1"))
+	corrupt[i+2] = 'z'
+	if _, err := DecodeBundle(corrupt); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected a corrupt primary block to be rejected, got %v", err)
+	}
+
+	for name, bad := range map[string][]byte{
+		"definite array": append([]byte{0x82}, data[1:]...),
+		"truncated":      data[:len(data)-10],
+		"trailing data":  append(append([]byte(nil), data...), 0),
+		"empty":          {},
+	} {
+		if _, err := DecodeBundle(bad); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Expected %s to be rejected, got %v", name, err)
+		}
+	}
+
+	noPayload := newTestBundle()
+	noPayload.Blocks = noPayload.Blocks[:len(noPayload.Blocks)-1]
+	if _, err := noPayload.Encode(); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected a bundle without a payload to be rejected, got %v", err)
+	}
+}
+
+func TestCRC(t *testing.T) {
+	check := []byte("123456789")
+	if crc := crc16(check); crc != 0x906e {
+		t.Errorf("Expected the CRC-16/X-25 check value 0x906e, got %#x", crc)
+	}
+	if crc := computeCRC(check, CRC32C); !bytes.Equal(crc, []byte{0xe3, 0x06, 0x92, 0x83}) {
+		t.Errorf("Expected the CRC-32C check value e3069283, got %x", crc)
+	}
+}
+
+func TestBundle_Expires(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 30, 0, 0, time.UTC)
+	bundle := newTestBundle()
+	if expires := bundle.Expires(now); !expires.Equal(time.Date(2024, time.January, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the bundle to expire an hour after creation, got %v", expires)
+	}
+
+	// Without a creation time the age block counts against the lifetime
+	bundle.Primary.Created.Time = 0
+	if expires := bundle.Expires(now); !expires.Equal(now.Add(time.Hour - 1500*time.Millisecond)) {
+		t.Errorf("Expected the age to shorten the lifetime, got %v", expires)
+	}
+
+	if FromDTNTime(DTNTime(now)) != now || DTNTime(time.Unix(0, 0)) != 0 {
+		t.Errorf("Expected DTN times to round trip")
+	}
+}
@@ -0,0 +1,235 @@
+package dtn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrMalformed is returned when bundle data is not valid CBOR or does not
+// follow the bundle protocol encoding
+var ErrMalformed = errors.New("dtn: malformed bundle")
+
+// CBOR major types used by the bundle protocol
+const (
+	cborUnsigned = 0
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+)
+
+// CBOR simple values
+const (
+	cborFalse      = 0xf4
+	cborTrue       = 0xf5
+	cborIndefArray = 0x9f
+	cborBreak      = 0xff
+)
+
+// maxCBORLength bounds the length of decoded strings and arrays
+const maxCBORLength = 1 << 30
+
+// cborWriter encodes the subset of CBOR used by the bundle protocol
+type cborWriter struct {
+	buf []byte
+}
+
+// head writes an item head with a major type and argument
+func (w *cborWriter) head(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		w.buf = append(w.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		w.buf = append(w.buf, major|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	default:
+		w.buf = append(w.buf, major|27,
+			byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32),
+			byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+// uint writes an unsigned integer
+func (w *cborWriter) uint(n uint64) {
+	w.head(cborUnsigned, n)
+}
+
+// bytes writes a byte string
+func (w *cborWriter) bytes(b []byte) {
+	w.head(cborBytes, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// text writes a text string
+func (w *cborWriter) text(s string) {
+	w.head(cborText, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// array writes the head of a definite length array
+func (w *cborWriter) array(n int) {
+	w.head(cborArray, uint64(n))
+}
+
+// bool writes a boolean
+func (w *cborWriter) bool(b bool) {
+	if b {
+		w.buf = append(w.buf, cborTrue)
+	} else {
+		w.buf = append(w.buf, cborFalse)
+	}
+}
+
+// cborReader decodes the subset of CBOR used by the bundle protocol
+type cborReader struct {
+	data []byte
+	pos  int
+}
+
+// malformed returns an ErrMalformed error describing the problem at the
+// current position
+func (r *cborReader) malformed(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: offset %d: %s", ErrMalformed, r.pos, fmt.Sprintf(format, args...))
+}
+
+// head reads an item head, returning its major type and argument
+func (r *cborReader) head() (byte, uint64, error) {
+	if r.pos >= len(r.data) {
+		return 0, 0, r.malformed("unexpected end of data")
+	}
+	initial := r.data[r.pos]
+	r.pos++
+	major, info := initial>>5, initial&0x1f
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+	size := 0
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		r.pos--
+		return 0, 0, r.malformed("unsupported additional information %d", info)
+	}
+	if r.pos+size > len(r.data) {
+		return 0, 0, r.malformed("unexpected end of data")
+	}
+	var n uint64
+	for _, b := range r.data[r.pos : r.pos+size] {
+		n = n<<8 | uint64(b)
+	}
+	r.pos += size
+	return major, n, nil
+}
+
+// expect reads an item head of a major type
+func (r *cborReader) expect(major byte) (uint64, error) {
+	start := r.pos
+	m, n, err := r.head()
+	if err != nil {
+		return 0, err
+	}
+	if m != major {
+		r.pos = start
+		return 0, r.malformed("expected major type %d, got %d", major, m)
+	}
+	return n, nil
+}
+
+// uint reads an unsigned integer
+func (r *cborReader) uint() (uint64, error) {
+	return r.expect(cborUnsigned)
+}
+
+// bytes reads a byte string
+func (r *cborReader) bytes() ([]byte, error) {
+	n, err := r.expect(cborBytes)
+	if err != nil {
+		return nil, err
+	}
+	return r.take(n)
+}
+
+// text reads a text string
+func (r *cborReader) text() (string, error) {
+	n, err := r.expect(cborText)
+	if err != nil {
+		return "", err
+	}
+	b, err := r.take(n)
+	return string(b), err
+}
+
+// take returns the next n bytes
+func (r *cborReader) take(n uint64) ([]byte, error) {
+	if n > maxCBORLength || uint64(len(r.data)-r.pos) < n {
+		return nil, r.malformed("string of length %d exceeds the data", n)
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// array reads the head of a definite length array
+func (r *cborReader) array() (int, error) {
+	n, err := r.expect(cborArray)
+	if err != nil {
+		return 0, err
+	}
+	if n > maxCBORLength || n > uint64(len(r.data)-r.pos) {
+		return 0, r.malformed("array of length %d exceeds the data", n)
+	}
+	return int(n), nil
+}
+
+// arrayOf reads the head of a definite length array with between min and
+// max items
+func (r *cborReader) arrayOf(min, max int) (int, error) {
+	n, err := r.array()
+	if err != nil {
+		return 0, err
+	}
+	if n < min || n > max {
+		return 0, r.malformed("expected an array of %d to %d items, got %d", min, max, n)
+	}
+	return n, nil
+}
+
+// bool reads a boolean
+func (r *cborReader) bool() (bool, error) {
+	if r.pos >= len(r.data) {
+		return false, r.malformed("unexpected end of data")
+	}
+	switch r.data[r.pos] {
+	case cborTrue:
+		r.pos++
+		return true, nil
+	case cborFalse:
+		r.pos++
+		return false, nil
+	}
+	return false, r.malformed("expected a boolean")
+}
+
+// peek returns the next byte without consuming it
+func (r *cborReader) peek() (byte, bool) {
+	if r.pos >= len(r.data) {
+		return 0, false
+	}
+	return r.data[r.pos], true
+}
+
+// done returns whether all the data has been read
+func (r *cborReader) done() bool {
+	return r.pos == len(r.data)
+}
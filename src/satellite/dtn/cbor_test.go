@@ -0,0 +1,70 @@
+package dtn
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCBOR(t *testing.T) {
+	w := &cborWriter{}
+	w.array(6)
+	w.uint(23)
+	w.uint(500)
+	w.uint(1 << 40)
+	w.text("dtn")
+	w.bytes([]byte{1, 2})
+	w.bool(true)
+	expected := []byte{
+		0x86, 0x17, 0x19, 0x01, 0xf4,
+		0x1b, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x63, 'd', 't', 'n', 0x42, 1, 2, 0xf5,
+	}
+	if !bytes.Equal(w.buf, expected) {
+		t.Fatalf("Expected the RFC 8949 encoding %x, got %x", expected, w.buf)
+	}
+
+	r := &cborReader{data: w.buf}
+	n, err := r.array()
+	if err != nil || n != 6 {
+		t.Fatalf("Expected an array of 6, got %d, %v", n, err)
+	}
+	for _, expected := range []uint64{23, 500, 1 << 40} {
+		if v, err := r.uint(); err != nil || v != expected {
+			t.Errorf("Expected %d, got %d, %v", expected, v, err)
+		}
+	}
+	if s, err := r.text(); err != nil || s != "dtn" {
+		t.Errorf("Expected dtn, got %q, %v", s, err)
+	}
+	if b, err := r.bytes(); err != nil || !bytes.Equal(b, []byte{1, 2}) {
+		t.Errorf("Expected bytes 1 2, got %x, %v", b, err)
+	}
+	if b, err := r.bool(); err != nil || !b || !r.done() {
+		t.Errorf("Expected true at the end of the data, got %v, %v", b, err)
+	}
+}
+
+func TestCBOR_Malformed(t *testing.T) {
+	cases := map[string][]byte{
+		"truncated head":   {0x19, 0x01},
+		"truncated string": {0x45, 1, 2},
+		"wrong type":       {0x61, 'a'},
+		"indefinite":       {0x1f},
+		"huge array":       {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	for name, data := range cases {
+		r := &cborReader{data: data}
+		var err error
+		if data[0]>>5 == cborArray {
+			_, err = r.array()
+		} else if data[0]>>5 == cborBytes {
+			_, err = r.bytes()
+		} else {
+			_, err = r.uint()
+		}
+		if !errors.Is(err, ErrMalformed) {
+			t.Errorf("Expected a %s item to be malformed, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package dtn
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidEndpoint is returned for endpoint IDs that are not valid dtn or
+// ipn URIs
+var ErrInvalidEndpoint = errors.New("dtn: invalid endpoint ID")
+
+// URI scheme codes of endpoint IDs
+const (
+	schemeDTN = 1
+	schemeIPN = 2
+)
+
+// NullEndpoint is the null endpoint, used as the source of anonymous bundles
+// and the report-to of bundles that request no reports
+const NullEndpoint EndpointID = "dtn:none"
+
+// EndpointID identifies a bundle endpoint, either as a dtn URI such as
+// dtn://sat1/telemetry or an ipn URI such as ipn:12.1
+type EndpointID string
+
+// ParseEndpoint checks and returns an endpoint ID
+func ParseEndpoint(s string) (EndpointID, error) {
+	eid := EndpointID(s)
+	switch {
+	case eid == NullEndpoint:
+		return eid, nil
+	case strings.HasPrefix(s, "dtn://") && eid.dtnNode() != "":
+		return eid, nil
+	case strings.HasPrefix(s, "ipn:"):
+		if _, _, err := eid.ipn(); err != nil {
+			return "", err
+		}
+		return eid, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidEndpoint, s)
+}
+
+// Node returns the administrative endpoint of the node the endpoint is on,
+// dtn://node/ or ipn:node.0, which bundles are routed by
+func (e EndpointID) Node() EndpointID {
+	if node := e.dtnNode(); node != "" {
+		return EndpointID("dtn://" + node + "/")
+	}
+	if node, _, err := e.ipn(); err == nil {
+		return EndpointID(fmt.Sprintf("ipn:%d.0", node))
+	}
+	return e
+}
+
+// String returns the endpoint URI
+func (e EndpointID) String() string {
+	return string(e)
+}
+
+// dtnNode returns the node name of a dtn URI, or "" if e is not one
+func (e EndpointID) dtnNode() string {
+	rest := strings.TrimPrefix(string(e), "dtn://")
+	if len(rest) == len(e) {
+		return ""
+	}
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
+
+// ipn returns the node and service numbers of an ipn URI
+func (e EndpointID) ipn() (uint64, uint64, error) {
+	rest := strings.TrimPrefix(string(e), "ipn:")
+	parts := strings.Split(rest, ".")
+	if len(rest) == len(e) || len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidEndpoint, string(e))
+	}
+	node, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidEndpoint, string(e))
+	}
+	service, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q", ErrInvalidEndpoint, string(e))
+	}
+	return node, service, nil
+}
+
+// encode writes the endpoint as [scheme, SSP]
+func (e EndpointID) encode(w *cborWriter) error {
+	w.array(2)
+	switch {
+	case e == NullEndpoint:
+		w.uint(schemeDTN)
+		w.uint(0)
+	case strings.HasPrefix(string(e), "dtn:"):
+		w.uint(schemeDTN)
+		w.text(strings.TrimPrefix(string(e), "dtn:"))
+	default:
+		node, service, err := e.ipn()
+		if err != nil {
+			return err
+		}
+		w.uint(schemeIPN)
+		w.array(2)
+		w.uint(node)
+		w.uint(service)
+	}
+	return nil
+}
+
+// decodeEndpoint reads an endpoint written by encode
+func decodeEndpoint(r *cborReader) (EndpointID, error) {
+	if _, err := r.arrayOf(2, 2); err != nil {
+		return "", err
+	}
+	scheme, err := r.uint()
+	if err != nil {
+		return "", err
+	}
+	switch scheme {
+	case schemeDTN:
+		if b, ok := r.peek(); ok && b>>5 == cborUnsigned {
+			if n, err := r.uint(); err != nil || n != 0 {
+				return "", r.malformed("invalid dtn scheme-specific part")
+			}
+			return NullEndpoint, nil
+		}
+		ssp, err := r.text()
+		if err != nil {
+			return "", err
+		}
+		return ParseEndpoint("dtn:" + ssp)
+	case schemeIPN:
+		if _, err := r.arrayOf(2, 2); err != nil {
+			return "", err
+		}
+		node, err := r.uint()
+		if err != nil {
+			return "", err
+		}
+		service, err := r.uint()
+		if err != nil {
+			return "", err
+		}
+		return EndpointID(fmt.Sprintf("ipn:%d.%d", node, service)), nil
+	}
+	return "", r.malformed("unknown endpoint scheme %d", scheme)
+}
@@ -0,0 +1,58 @@
+package dtn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	valid := map[string]EndpointID{
+		"dtn:none":                   "dtn:none",
+		"dtn://sat1/telemetry":       "dtn://sat1/",
+		"dtn://ground-station-1/":    "dtn://ground-station-1/",
+		"dtn://gs1":                  "dtn://gs1/",
+		"ipn:12.1":                   "ipn:12.0",
+		"ipn:18446744073709551615.0": "ipn:18446744073709551615.0",
+	}
+	for s, node := range valid {
+		eid, err := ParseEndpoint(s)
+		if err != nil {
+			t.Errorf("Expected %s to parse, got %v", s, err)
+			continue
+		}
+		if eid != NullEndpoint && eid.Node() != node {
+			t.Errorf("Expected the node of %s to be %s, got %s", s, node, eid.Node())
+		}
+	}
+
+	for _, s := range []string{"", "http://sat1/", "dtn:", "dtn:///x", "ipn:1", "ipn:a.b", "ipn:1.2.3"} {
+		if _, err := ParseEndpoint(s); !errors.Is(err, ErrInvalidEndpoint) {
+			t.Errorf("Expected %q to be rejected, got %v", s, err)
+		}
+	}
+}
+
+func TestEndpointID_Encode(t *testing.T) {
+	for _, eid := range []EndpointID{NullEndpoint, "dtn://sat1/telemetry", "ipn:977.1"} {
+		w := &cborWriter{}
+		if err := eid.encode(w); err != nil {
+			t.Fatalf("Expected %s to encode, got %v", eid, err)
+		}
+		decoded, err := decodeEndpoint(&cborReader{data: w.buf})
+		if err != nil || decoded != eid {
+			t.Errorf("Expected %s to round trip, got %s, %v", eid, decoded, err)
+		}
+	}
+
+	// dtn:none is [1, 0] and ipn:977.1 is [2, [977, 1]]
+	w := &cborWriter{}
+	NullEndpoint.encode(w)
+	if string(w.buf) != "\x82\x01\x00" {
+		t.Errorf("Expected dtn:none to encode as 820100, got %x", w.buf)
+	}
+	w = &cborWriter{}
+	EndpointID("ipn:977.1").encode(w)
+	if string(w.buf) != "\x82\x02\x82\x19\x03\xd1\x01" {
+		t.Errorf("Expected ipn:977.1 to encode as 82028219 03d101, got %x", w.buf)
+	}
+}
@@ -0,0 +1,158 @@
+package dtn
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrMustNotFragment is returned when a bundle that must not be
+	// fragmented is too large for a link
+	ErrMustNotFragment = errors.New("dtn: bundle must not be fragmented")
+
+	// ErrFragmentTooSmall is returned when a link cannot carry even the
+	// blocks of a fragment without any payload
+	ErrFragmentTooSmall = errors.New("dtn: maximum bundle size too small to fragment")
+)
+
+// Fragment splits a bundle into fragments whose encodings are at most
+// maxSize bytes. The first fragment carries every extension block and the
+// others only those flagged BlockReplicate. A bundle that already fits is
+// returned unchanged.
+func Fragment(bundle *Bundle, maxSize int) ([]*Bundle, error) {
+	encoded, err := bundle.Encode()
+	if err != nil {
+		return nil, err
+	}
+	if len(encoded) <= maxSize {
+		return []*Bundle{bundle}, nil
+	}
+	if bundle.Primary.Flags&FlagMustNotFragment != 0 {
+		return nil, ErrMustNotFragment
+	}
+
+	payload := bundle.Payload()
+	offset := bundle.Primary.FragmentOffset
+	total := bundle.Primary.TotalLength
+	if !bundle.IsFragment() {
+		total = uint64(len(payload))
+	}
+
+	var fragments []*Bundle
+	for start := 0; start < len(payload); {
+		fragment := &Bundle{Primary: bundle.Primary}
+		fragment.Primary.Flags |= FlagFragment
+		fragment.Primary.FragmentOffset = offset + uint64(start)
+		fragment.Primary.TotalLength = total
+		for _, block := range bundle.Blocks {
+			if block.Type != BlockPayload && (start == 0 || block.Flags&BlockReplicate != 0) {
+				fragment.Blocks = append(fragment.Blocks, block)
+			}
+		}
+		payloadBlock := *bundle.Block(BlockPayload)
+		payloadBlock.Data = nil
+		fragment.Blocks = append(fragment.Blocks, payloadBlock)
+
+		// The room left for payload, allowing for the byte string head
+		// growing as the payload does
+		empty, err := fragment.Encode()
+		if err != nil {
+			return nil, err
+		}
+		room := maxSize - len(empty) - 8
+		if room <= 0 {
+			return nil, ErrFragmentTooSmall
+		}
+		end := start + room
+		if end > len(payload) {
+			end = len(payload)
+		}
+		fragment.Blocks[len(fragment.Blocks)-1].Data = append([]byte(nil), payload[start:end]...)
+		fragments = append(fragments, fragment)
+		start = end
+	}
+	return fragments, nil
+}
+
+// Reassembler collects fragments until the whole bundle can be rebuilt
+type Reassembler struct {
+	mutex   sync.Mutex
+	pending map[string][]*Bundle
+}
+
+// NewReassembler returns a new Reassembler
+func NewReassembler() *Reassembler {
+	return &Reassembler{
+		pending: make(map[string][]*Bundle),
+	}
+}
+
+// Add adds a fragment, returning the reassembled bundle once every part of
+// the payload has arrived
+func (r *Reassembler) Add(fragment *Bundle) (*Bundle, bool) {
+	if !fragment.IsFragment() {
+		return fragment, true
+	}
+	if fragment.Primary.FragmentOffset+uint64(len(fragment.Payload())) > fragment.Primary.TotalLength {
+		return nil, false
+	}
+	key := BundleID{Source: fragment.Primary.Source, Created: fragment.Primary.Created}.String()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	fragments := r.pending[key]
+	if len(fragments) > 0 && fragments[0].Primary.TotalLength != fragment.Primary.TotalLength {
+		return nil, false
+	}
+	fragments = append(fragments, fragment)
+	sort.SliceStable(fragments, func(i, j int) bool {
+		return fragments[i].Primary.FragmentOffset < fragments[j].Primary.FragmentOffset
+	})
+	r.pending[key] = fragments
+
+	// Check the fragments cover the whole payload
+	total := fragment.Primary.TotalLength
+	var covered uint64
+	for _, f := range fragments {
+		if f.Primary.FragmentOffset > covered {
+			return nil, false
+		}
+		if end := f.Primary.FragmentOffset + uint64(len(f.Payload())); end > covered {
+			covered = end
+		}
+	}
+	if covered < total {
+		return nil, false
+	}
+	delete(r.pending, key)
+
+	payload := make([]byte, total)
+	for _, f := range fragments {
+		copy(payload[f.Primary.FragmentOffset:], f.Payload())
+	}
+	whole := fragments[0].Clone()
+	whole.Primary.Flags &^= FlagFragment
+	whole.Primary.FragmentOffset = 0
+	whole.Primary.TotalLength = 0
+	whole.Block(BlockPayload).Data = payload
+	return whole, true
+}
+
+// Pending returns the number of bundles waiting for fragments
+func (r *Reassembler) Pending() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.pending)
+}
+
+// Discard drops the fragments of bundles for which keep returns false
+func (r *Reassembler) Discard(keep func(fragment *Bundle) bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for key, fragments := range r.pending {
+		if !keep(fragments[0]) {
+			delete(r.pending, key)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package dtn
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFragment(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 100)
+	created := CreationTimestamp{Time: DTNTime(time.Now()), Sequence: 1}
+	bundle := NewBundle("dtn://sat1/images", "dtn://gs1/images", created, time.Hour, payload)
+	bundle.SetHopCount(8, 0)
+	bundle.SetBlock(Block{Type: 192, CRCType: CRC16, Data: []byte("mission specific")})
+
+	fragments, err := Fragment(bundle, 200)
+	if err != nil {
+		t.Fatalf("Expected Fragment to succeed, got %v", err)
+	}
+	if len(fragments) < 6 {
+		t.Fatalf("Expected a 1000 byte payload to need several fragments, got %d", len(fragments))
+	}
+	for i, fragment := range fragments {
+		data, err := fragment.Encode()
+		if err != nil || len(data) > 200 {
+			t.Errorf("Expected fragment %d to fit in 200 bytes, got %d, %v", i, len(data), err)
+		}
+		if !fragment.IsFragment() || fragment.Primary.TotalLength != 1000 {
+			t.Errorf("Expected fragment %d to be marked as a fragment of 1000 bytes", i)
+		}
+		// Only the replicated hop count block is in every fragment
+		if _, _, ok := fragment.HopCount(); !ok {
+			t.Errorf("Expected fragment %d to carry the hop count", i)
+		}
+		if (fragment.Block(192) != nil) != (i == 0) {
+			t.Errorf("Expected only the first fragment to carry the unreplicated block")
+		}
+	}
+
+	// Reassemble out of order, with a duplicate
+	reassembler := NewReassembler()
+	order := append([]*Bundle{fragments[2]}, fragments...)
+	order[1], order[len(order)-1] = order[len(order)-1], order[1]
+	var whole *Bundle
+	for i, fragment := range order {
+		var ok bool
+		whole, ok = reassembler.Add(fragment)
+		if ok != (i == len(order)-1) {
+			t.Fatalf("Expected reassembly to finish with the last fragment, finished at %d", i)
+		}
+	}
+	if whole.IsFragment() || !bytes.Equal(whole.Payload(), payload) || whole.ID() != bundle.ID() {
+		t.Errorf("Expected the original bundle to be reassembled")
+	}
+	if whole.Block(192) == nil {
+		t.Errorf("Expected the reassembled bundle to have the first fragment's blocks")
+	}
+	if reassembler.Pending() != 0 {
+		t.Errorf("Expected no pending reassemblies")
+	}
+
+	// Fragments can be fragmented again
+	refragments, err := Fragment(fragments[1], 120)
+	if err != nil || len(refragments) < 2 {
+		t.Fatalf("Expected a fragment to be fragmented again, got %d, %v", len(refragments), err)
+	}
+	if refragments[0].Primary.FragmentOffset != fragments[1].Primary.FragmentOffset {
+		t.Errorf("Expected offsets relative to the original payload")
+	}
+}
+
+func TestFragment_Errors(t *testing.T) {
+	bundle := NewBundle("dtn://sat1/", "dtn://gs1/", CreationTimestamp{Time: 1}, time.Hour, make([]byte, 500))
+	if fragments, err := Fragment(bundle, 1000); err != nil || len(fragments) != 1 || fragments[0] != bundle {
+		t.Errorf("Expected a bundle that fits not to be fragmented")
+	}
+	if _, err := Fragment(bundle, 40); !errors.Is(err, ErrFragmentTooSmall) {
+		t.Errorf("Expected a tiny maximum size to fail, got %v", err)
+	}
+	bundle.Primary.Flags |= FlagMustNotFragment
+	if _, err := Fragment(bundle, 200); !errors.Is(err, ErrMustNotFragment) {
+		t.Errorf("Expected a must not fragment bundle to fail, got %v", err)
+	}
+}
+
+func TestReassembler_Discard(t *testing.T) {
+	bundle := NewBundle("dtn://sat1/", "dtn://gs1/", CreationTimestamp{Time: 1}, time.Hour, make([]byte, 500))
+	fragments, err := Fragment(bundle, 200)
+	if err != nil {
+		t.Fatalf("Expected Fragment to succeed, got %v", err)
+	}
+	reassembler := NewReassembler()
+	reassembler.Add(fragments[0])
+	if reassembler.Pending() != 1 {
+		t.Fatalf("Expected one pending reassembly")
+	}
+	reassembler.Discard(func(fragment *Bundle) bool { return false })
+	if reassembler.Pending() != 0 {
+		t.Errorf("Expected the reassembly to be discarded")
+	}
+
+	bad := fragments[1].Clone()
+	bad.Primary.FragmentOffset = 490
+	if _, ok := reassembler.Add(bad); ok || reassembler.Pending() != 0 {
+		t.Errorf("Expected a fragment beyond the total length to be ignored")
+	}
+}
@@ -0,0 +1,117 @@
+package dtn
+
+import (
+	"errors"
+
+	"github.com/skybridge/satellite"
+)
+
+// ErrLinkDown is returned when sending over a link that is not up
+var ErrLinkDown = errors.New("dtn: link is down")
+
+// Link is a convergence layer connection to a neighbouring node
+type Link interface {
+	// Peer returns the endpoint of the neighbouring node
+	Peer() EndpointID
+
+	// Up returns whether bundles can be sent now
+	Up() bool
+
+	// MaxBundleSize returns the largest encoded bundle the link carries, or
+	// zero for no limit. Larger bundles are fragmented.
+	MaxBundleSize() int
+
+	// Send transmits an encoded bundle
+	Send(data []byte) error
+}
+
+// FuncLink is a Link that sends with a function
+type FuncLink struct {
+	// PeerID is the endpoint of the neighbouring node
+	PeerID EndpointID
+
+	// MaxSize is the largest encoded bundle, or zero for no limit
+	MaxSize int
+
+	// SendFunc transmits an encoded bundle
+	SendFunc func(data []byte) error
+
+	// UpFunc reports whether the link is up. The link is always up if nil.
+	UpFunc func() bool
+}
+
+// Peer returns the endpoint of the neighbouring node
+func (l *FuncLink) Peer() EndpointID {
+	return l.PeerID
+}
+
+// Up returns whether bundles can be sent now
+func (l *FuncLink) Up() bool {
+	return l.UpFunc == nil || l.UpFunc()
+}
+
+// MaxBundleSize returns the largest encoded bundle the link carries
+func (l *FuncLink) MaxBundleSize() int {
+	return l.MaxSize
+}
+
+// Send transmits an encoded bundle
+func (l *FuncLink) Send(data []byte) error {
+	if !l.Up() {
+		return ErrLinkDown
+	}
+	return l.SendFunc(data)
+}
+
+// StationLink is a Link from a satellite to one of its ground stations. It
+// is up while the satellite's contact plan has the station in view and
+// sends through Satellite.CommunicateWithGroundStation.
+type StationLink struct {
+	// Satellite is the sending satellite
+	Satellite *satellite.Satellite
+
+	// Station is the receiving ground station
+	Station *satellite.GroundStation
+
+	// PeerID is the endpoint of the node at the ground station
+	PeerID EndpointID
+
+	// MaxSize is the largest encoded bundle, or zero for no limit
+	MaxSize int
+}
+
+// Peer returns the endpoint of the node at the ground station
+func (l *StationLink) Peer() EndpointID {
+	return l.PeerID
+}
+
+// Up returns whether the station is in contact with the satellite
+func (l *StationLink) Up() bool {
+	return InContact(l.Satellite, l.Station.ID())
+}
+
+// MaxBundleSize returns the largest encoded bundle the link carries
+func (l *StationLink) MaxBundleSize() int {
+	return l.MaxSize
+}
+
+// Send transmits an encoded bundle to the ground station
+func (l *StationLink) Send(data []byte) error {
+	if !l.Up() {
+		return ErrLinkDown
+	}
+	return l.Satellite.CommunicateWithGroundStation(l.Station, data)
+}
+
+// InContact returns whether a satellite's contact plan has a ground station
+// in view at the time of the satellite's scheduler clock. It suits the
+// UpFunc of uplinks from a station to its satellite.
+func InContact(sat *satellite.Satellite, station string) bool {
+	scheduler := sat.Scheduler()
+	for _, contact := range scheduler.Plan().Active(scheduler.Clock().Now()) {
+		if contact.Station == station {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package dtn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/skybridge/satellite"
+)
+
+func TestStationLink(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := satellite.NewSimulatedClock(start)
+	sat := satellite.NewSatellite("sat1")
+	station := satellite.NewGroundStation("gs1")
+	sat.AddGroundStation(station)
+	sat.SetScheduler(satellite.SchedulerConfig{Clock: clock, Plan: satellite.ContactPlan{
+		{Station: "gs1", Start: start.Add(time.Hour), End: start.Add(70 * time.Minute)},
+	}})
+
+	satNode := newTestNode(t, "dtn://sat1/", clock, true)
+	groundNode := newTestNode(t, "dtn://gs1/", clock, true)
+	downlink := &StationLink{Satellite: sat, Station: station, PeerID: groundNode.ID()}
+	satNode.AddLink(downlink)
+	station.Handler = groundNode.Receive
+	groundNode.AddLink(&FuncLink{
+		PeerID:   satNode.ID(),
+		SendFunc: satNode.Receive,
+		UpFunc:   func() bool { return InContact(sat, "gs1") },
+	})
+	delivered := make(chan *Bundle, 1)
+	groundNode.Register("dtn://gs1/telemetry", func(bundle *Bundle) {
+		delivered <- bundle
+	})
+
+	if downlink.Up() || !errors.Is(downlink.Send([]byte{}), ErrLinkDown) {
+		t.Errorf("Expected the link to be down outside the contact")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go satNode.Run(ctx)
+	go groundNode.Run(ctx)
+
+	if _, err := satNode.Send("dtn://gs1/telemetry", []byte("frame 1"), 2*time.Hour); err != nil {
+		t.Fatalf("Expected Send to succeed, got %v", err)
+	}
+	select {
+	case <-delivered:
+		t.Fatalf("Expected no delivery before the contact")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.AdvanceTo(start.Add(time.Hour))
+	select {
+	case bundle := <-delivered:
+		if string(bundle.Payload()) != "frame 1" {
+			t.Errorf("Expected frame 1, got %q", bundle.Payload())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the bundle to be delivered during the contact")
+	}
+
+	// The custody signal comes back over the uplink during the contact
+	deadline := time.Now().Add(5 * time.Second)
+	for satNode.Stored() != 0 && time.Now().Before(deadline) {
+		clock.Advance(time.Second)
+		time.Sleep(time.Millisecond)
+	}
+	if satNode.Stored() != 0 {
+		t.Errorf("Expected the satellite to release custody")
+	}
+}
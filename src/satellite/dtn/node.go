@@ -0,0 +1,538 @@
+package dtn
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skybridge/satellite"
+)
+
+const (
+	// DefaultCustodyTimeout is how long a node waits for a custody signal
+	// before retransmitting a bundle
+	DefaultCustodyTimeout = time.Minute
+
+	// DefaultHopLimit is the hop limit of bundles created by a node
+	DefaultHopLimit = 32
+
+	// DefaultTickInterval is how often a running node retries forwarding
+	DefaultTickInterval = time.Second
+
+	// minimumSignalLifetime is the shortest lifetime given to a custody
+	// signal
+	minimumSignalLifetime = time.Minute
+)
+
+// ErrNoNodeID is returned when a node is configured without an endpoint ID
+var ErrNoNodeID = errors.New("dtn: node has no endpoint ID")
+
+// NodeConfig is the configuration of a Node
+type NodeConfig struct {
+	// ID is the node's endpoint, such as dtn://sat1/ or ipn:12.0
+	ID EndpointID
+
+	// Store holds bundles awaiting forwarding, a MemoryStore if nil. Bundles
+	// in a persistent store are forwarded again when the node restarts.
+	Store Store
+
+	// Clock is the source of time, satellite.SystemClock if nil
+	Clock satellite.Clock
+
+	// Custody enables hop-by-hop custody. The node keeps each bundle it
+	// forwards until the next hop signals that it has taken custody,
+	// retransmitting it every CustodyTimeout, and signals custody of the
+	// bundles it accepts to the node they came from.
+	Custody bool
+
+	// CustodyTimeout is DefaultCustodyTimeout if zero
+	CustodyTimeout time.Duration
+
+	// HopLimit is DefaultHopLimit if zero
+	HopLimit uint64
+
+	// TickInterval is DefaultTickInterval if zero
+	TickInterval time.Duration
+}
+
+// Handler is called with the bundles delivered to a registered endpoint
+type Handler func(bundle *Bundle)
+
+// Node is a bundle protocol agent. It stores bundles until a link towards
+// their destination is up, then forwards them.
+type Node struct {
+	id             EndpointID
+	store          Store
+	clock          satellite.Clock
+	custody        bool
+	custodyTimeout time.Duration
+	hopLimit       uint64
+	tickInterval   time.Duration
+	reassembler    *Reassembler
+	wake           chan struct{}
+
+	mutex        sync.Mutex
+	links        map[EndpointID]Link
+	routes       map[EndpointID]EndpointID
+	defaultRoute EndpointID
+	handlers     map[EndpointID]Handler
+	pending      map[string]*storedBundle
+	awaiting     map[string]string
+	delivered    map[string]time.Time
+	sequence     uint64
+
+	processMutex sync.Mutex
+}
+
+// storedBundle is a bundle held by a node and its forwarding state
+type storedBundle struct {
+	bundle   *Bundle
+	received time.Time
+	expires  time.Time
+
+	// custody is the set of forwarded bundle and fragment IDs with no custody
+	// signal yet, and retransmit when they are sent again
+	custody    map[string]bool
+	retransmit time.Time
+}
+
+// NewNode returns a new Node, loading any bundles left in its store
+func NewNode(config NodeConfig) (*Node, error) {
+	if config.ID == "" {
+		return nil, ErrNoNodeID
+	}
+	id, err := ParseEndpoint(string(config.ID))
+	if err != nil {
+		return nil, err
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.Clock == nil {
+		config.Clock = satellite.SystemClock
+	}
+	if config.CustodyTimeout <= 0 {
+		config.CustodyTimeout = DefaultCustodyTimeout
+	}
+	if config.HopLimit == 0 {
+		config.HopLimit = DefaultHopLimit
+	}
+	if config.TickInterval <= 0 {
+		config.TickInterval = DefaultTickInterval
+	}
+
+	n := &Node{
+		id:             id.Node(),
+		store:          config.Store,
+		clock:          config.Clock,
+		custody:        config.Custody,
+		custodyTimeout: config.CustodyTimeout,
+		hopLimit:       config.HopLimit,
+		tickInterval:   config.TickInterval,
+		reassembler:    NewReassembler(),
+		wake:           make(chan struct{}, 1),
+		links:          make(map[EndpointID]Link),
+		routes:         make(map[EndpointID]EndpointID),
+		handlers:       make(map[EndpointID]Handler),
+		pending:        make(map[string]*storedBundle),
+		awaiting:       make(map[string]string),
+		delivered:      make(map[string]time.Time),
+	}
+
+	bundles, err := n.store.List()
+	if err != nil {
+		return nil, err
+	}
+	now := n.clock.Now()
+	for _, bundle := range bundles {
+		n.pending[bundle.ID().String()] = &storedBundle{bundle: bundle, received: now, expires: bundle.Expires(now)}
+	}
+	return n, nil
+}
+
+// ID returns the node's administrative endpoint
+func (n *Node) ID() EndpointID {
+	return n.id
+}
+
+// AddLink adds a link to a neighbouring node, replacing any link to the same
+// node
+func (n *Node) AddLink(link Link) {
+	n.mutex.Lock()
+	n.links[link.Peer().Node()] = link
+	n.mutex.Unlock()
+	n.signal()
+}
+
+// RemoveLink removes the link to a node
+func (n *Node) RemoveLink(peer EndpointID) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	delete(n.links, peer.Node())
+}
+
+// AddRoute forwards bundles for the node of destination through the
+// neighbouring node nextHop
+func (n *Node) AddRoute(destination, nextHop EndpointID) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.routes[destination.Node()] = nextHop.Node()
+}
+
+// SetDefaultRoute forwards bundles with no other route through nextHop
+func (n *Node) SetDefaultRoute(nextHop EndpointID) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.defaultRoute = nextHop.Node()
+}
+
+// Register delivers bundles for an endpoint on this node to a handler.
+// Bundles that arrived before the endpoint was registered are delivered at
+// the next Process.
+func (n *Node) Register(endpoint EndpointID, handler Handler) {
+	n.mutex.Lock()
+	n.handlers[endpoint] = handler
+	n.mutex.Unlock()
+	n.signal()
+}
+
+// Stored returns the number of bundles the node holds
+func (n *Node) Stored() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return len(n.pending)
+}
+
+// Send creates a bundle from this node's endpoint and queues it for
+// forwarding
+func (n *Node) Send(destination EndpointID, payload []byte, lifetime time.Duration) (BundleID, error) {
+	if _, err := ParseEndpoint(string(destination)); err != nil {
+		return BundleID{}, err
+	}
+	bundle := n.newBundle(destination, payload, lifetime)
+	if err := n.enqueue(bundle); err != nil {
+		return BundleID{}, err
+	}
+	return bundle.ID(), nil
+}
+
+// newBundle returns a bundle from this node with the next creation timestamp
+func (n *Node) newBundle(destination EndpointID, payload []byte, lifetime time.Duration) *Bundle {
+	n.mutex.Lock()
+	n.sequence++
+	sequence := n.sequence
+	n.mutex.Unlock()
+	created := CreationTimestamp{Time: DTNTime(n.clock.Now()), Sequence: sequence}
+	bundle := NewBundle(n.id, destination, created, lifetime, payload)
+	bundle.SetHopCount(n.hopLimit, 0)
+	return bundle
+}
+
+// Receive decodes a bundle arriving over a convergence layer and stores it
+// for delivery or forwarding. It has the signature of a ground station
+// Handler, so a node can be attached to a station directly.
+func (n *Node) Receive(data []byte) error {
+	bundle, err := DecodeBundle(data)
+	if err != nil {
+		return err
+	}
+	n.accept(bundle)
+	return nil
+}
+
+// accept processes a received bundle
+func (n *Node) accept(bundle *Bundle) {
+	now := n.clock.Now()
+	if !now.Before(bundle.Expires(now)) {
+		return
+	}
+	if limit, count, ok := bundle.HopCount(); ok && count > limit {
+		n.signalCustody(bundle, false, ReasonHopLimitExceeded)
+		return
+	}
+
+	if bundle.Primary.Destination.Node() == n.id && bundle.IsAdminRecord() {
+		n.handleAdminRecord(bundle)
+		return
+	}
+
+	key := bundle.ID().String()
+	n.mutex.Lock()
+	_, stored := n.pending[key]
+	_, delivered := n.delivered[key]
+	n.mutex.Unlock()
+	if stored || delivered {
+		// A retransmission, so the custody signal was probably lost
+		n.signalCustody(bundle, true, ReasonNoInformation)
+		return
+	}
+
+	received := bundle
+	if bundle.Primary.Destination.Node() == n.id && bundle.IsFragment() {
+		whole, ok := n.reassembler.Add(bundle)
+		n.mutex.Lock()
+		n.delivered[key] = bundle.Expires(now)
+		n.mutex.Unlock()
+		n.signalCustody(received, true, ReasonNoInformation)
+		if !ok {
+			return
+		}
+		bundle = whole
+	}
+	if err := n.enqueue(bundle); err != nil {
+		log.Printf("Failed to store bundle %s: %v", bundle.ID(), err)
+		n.signalCustody(received, false, ReasonDepletedStorage)
+		return
+	}
+	if received == bundle {
+		n.signalCustody(bundle, true, ReasonNoInformation)
+	}
+}
+
+// enqueue stores a bundle and wakes the node to forward or deliver it
+func (n *Node) enqueue(bundle *Bundle) error {
+	if err := n.store.Put(bundle); err != nil {
+		return err
+	}
+	now := n.clock.Now()
+	n.mutex.Lock()
+	n.pending[bundle.ID().String()] = &storedBundle{bundle: bundle, received: now, expires: bundle.Expires(now)}
+	n.mutex.Unlock()
+	n.signal()
+	return nil
+}
+
+// remove deletes a bundle the node no longer needs to hold
+func (n *Node) remove(key string) {
+	n.mutex.Lock()
+	stored, ok := n.pending[key]
+	if ok {
+		delete(n.pending, key)
+		for id := range stored.custody {
+			delete(n.awaiting, id)
+		}
+	}
+	n.mutex.Unlock()
+	if ok {
+		if err := n.store.Delete(stored.bundle.ID()); err != nil {
+			log.Printf("Failed to delete bundle %s: %v", key, err)
+		}
+	}
+}
+
+// signalCustody sends a custody signal for a bundle to the node it came from
+func (n *Node) signalCustody(bundle *Bundle, accepted bool, reason uint64) {
+	previous, ok := bundle.PreviousNode()
+	if !n.custody || !ok || bundle.IsAdminRecord() || previous.Node() == n.id {
+		return
+	}
+	payload, err := CustodySignal{Accepted: accepted, Reason: reason, Bundle: bundle.ID()}.encode()
+	if err != nil {
+		return
+	}
+	lifetime := bundle.Expires(n.clock.Now()).Sub(n.clock.Now())
+	if lifetime < minimumSignalLifetime {
+		lifetime = minimumSignalLifetime
+	}
+	signal := n.newBundle(previous.Node(), payload, lifetime)
+	signal.Primary.Flags |= FlagAdminRecord
+	signal.Primary.ReportTo = NullEndpoint
+	if err := n.enqueue(signal); err != nil {
+		log.Printf("Failed to queue custody signal: %v", err)
+	}
+}
+
+// handleAdminRecord processes an administrative record sent to this node
+func (n *Node) handleAdminRecord(bundle *Bundle) {
+	signal, ok, err := decodeCustodySignal(bundle.Payload())
+	if err != nil || !ok {
+		return
+	}
+	id := signal.Bundle.String()
+	if !signal.Accepted {
+		log.Printf("Custody of bundle %s refused with reason %d", id, signal.Reason)
+		return
+	}
+
+	n.mutex.Lock()
+	key, ok := n.awaiting[id]
+	stored := n.pending[key]
+	if ok {
+		delete(n.awaiting, id)
+		if stored != nil {
+			delete(stored.custody, id)
+		}
+	}
+	done := stored != nil && stored.custody != nil && len(stored.custody) == 0
+	n.mutex.Unlock()
+	if done {
+		n.remove(key)
+	}
+}
+
+// signal wakes the node's loop
+func (n *Node) signal() {
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// route returns the link towards a destination, or nil
+func (n *Node) route(destination EndpointID) Link {
+	node := destination.Node()
+	if link, ok := n.links[node]; ok {
+		return link
+	}
+	if next, ok := n.routes[node]; ok {
+		return n.links[next]
+	}
+	return n.links[n.defaultRoute]
+}
+
+// task is a stored bundle ready to deliver or forward
+type task struct {
+	key     string
+	stored  *storedBundle
+	link    Link
+	handler Handler
+}
+
+// Process drops expired bundles, delivers bundles for registered local
+// endpoints and forwards bundles over the links that are up
+func (n *Node) Process() {
+	n.processMutex.Lock()
+	defer n.processMutex.Unlock()
+	now := n.clock.Now()
+
+	var expired []string
+	var tasks []task
+	n.mutex.Lock()
+	for key, until := range n.delivered {
+		if !now.Before(until) {
+			delete(n.delivered, key)
+		}
+	}
+	for key, stored := range n.pending {
+		if !now.Before(stored.expires) {
+			expired = append(expired, key)
+			continue
+		}
+		if stored.custody != nil && now.Before(stored.retransmit) {
+			continue
+		}
+		destination := stored.bundle.Primary.Destination
+		if destination.Node() == n.id {
+			if handler := n.handlers[destination]; handler != nil {
+				tasks = append(tasks, task{key: key, stored: stored, handler: handler})
+			}
+			continue
+		}
+		if link := n.route(destination); link != nil {
+			tasks = append(tasks, task{key: key, stored: stored, link: link})
+		}
+	}
+	n.mutex.Unlock()
+
+	for _, key := range expired {
+		n.remove(key)
+	}
+	n.reassembler.Discard(func(fragment *Bundle) bool {
+		return now.Before(fragment.Expires(now))
+	})
+
+	// Forward in the order bundles were received
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].stored.received.Before(tasks[j].stored.received)
+	})
+	for _, t := range tasks {
+		if t.handler != nil {
+			n.mutex.Lock()
+			n.delivered[t.key] = t.stored.expires
+			n.mutex.Unlock()
+			n.remove(t.key)
+			t.handler(t.stored.bundle)
+			continue
+		}
+		if t.link.Up() {
+			n.forward(t, now)
+		}
+	}
+}
+
+// forward sends a bundle over a link, fragmenting it if it is too large
+func (n *Node) forward(t task, now time.Time) {
+	bundle := t.stored.bundle.Clone()
+	if err := bundle.SetPreviousNode(n.id); err != nil {
+		return
+	}
+	if limit, count, ok := bundle.HopCount(); ok {
+		bundle.SetHopCount(limit, count+1)
+	}
+	if age, ok := bundle.Age(); ok {
+		bundle.SetAge(age + now.Sub(t.stored.received))
+	}
+
+	parts := []*Bundle{bundle}
+	if size := t.link.MaxBundleSize(); size > 0 {
+		var err error
+		if parts, err = Fragment(bundle, size); err != nil {
+			log.Printf("Dropping bundle %s: %v", t.key, err)
+			n.remove(t.key)
+			return
+		}
+	}
+	// Register the expected custody signals before sending, as they can
+	// arrive before Send returns
+	custody := n.custody && !bundle.IsAdminRecord()
+	if custody {
+		n.mutex.Lock()
+		for id := range t.stored.custody {
+			delete(n.awaiting, id)
+		}
+		t.stored.custody = make(map[string]bool)
+		t.stored.retransmit = now.Add(n.custodyTimeout)
+		for _, part := range parts {
+			id := part.ID().String()
+			t.stored.custody[id] = true
+			n.awaiting[id] = t.key
+		}
+		n.mutex.Unlock()
+	}
+
+	for _, part := range parts {
+		data, err := part.Encode()
+		if err == nil {
+			err = t.link.Send(data)
+		}
+		if err != nil {
+			log.Printf("Failed to forward bundle %s to %s: %v", t.key, t.link.Peer(), err)
+			if custody {
+				n.mutex.Lock()
+				t.stored.retransmit = time.Time{}
+				n.mutex.Unlock()
+			}
+			return
+		}
+	}
+	if !custody {
+		n.remove(t.key)
+	}
+}
+
+// Run processes bundles every tick interval and whenever new bundles, links
+// or endpoints are added, until ctx is done
+func (n *Node) Run(ctx context.Context) {
+	for {
+		n.Process()
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.clock.After(n.tickInterval):
+		case <-n.wake:
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package dtn
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skybridge/satellite"
+)
+
+// testLink is a link between two nodes in memory that can be taken down or
+// made to lose bundles
+type testLink struct {
+	FuncLink
+	mutex sync.Mutex
+	up    bool
+	lose  int
+	sent  int
+}
+
+// connect adds a link from one node to another
+func connect(from, to *Node) *testLink {
+	link := &testLink{up: true}
+	link.PeerID = to.ID()
+	link.UpFunc = func() bool {
+		link.mutex.Lock()
+		defer link.mutex.Unlock()
+		return link.up
+	}
+	link.SendFunc = func(data []byte) error {
+		link.mutex.Lock()
+		link.sent++
+		lost := link.lose > 0
+		if lost {
+			link.lose--
+		}
+		link.mutex.Unlock()
+		if lost {
+			return nil
+		}
+		return to.Receive(data)
+	}
+	from.AddLink(link)
+	return link
+}
+
+// setUp sets whether the link is up
+func (l *testLink) setUp(up bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.up = up
+}
+
+// inbox collects the bundles delivered to an endpoint
+type inbox struct {
+	mutex   sync.Mutex
+	bundles []*Bundle
+}
+
+// deliver is a Handler appending to the inbox
+func (i *inbox) deliver(bundle *Bundle) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.bundles = append(i.bundles, bundle)
+}
+
+// count returns the number of bundles delivered
+func (i *inbox) count() int {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return len(i.bundles)
+}
+
+// newTestNode returns a node on a simulated clock
+func newTestNode(t *testing.T, id EndpointID, clock satellite.Clock, custody bool) *Node {
+	node, err := NewNode(NodeConfig{ID: id, Clock: clock, Custody: custody, CustodyTimeout: time.Minute})
+	if err != nil {
+		t.Fatalf("Expected NewNode to succeed, got %v", err)
+	}
+	return node
+}
+
+// process runs Process on the nodes until none of them hold bundles that
+// can move, up to a bound
+func process(nodes ...*Node) {
+	for i := 0; i < 10; i++ {
+		for _, node := range nodes {
+			node.Process()
+		}
+	}
+}
+
+func TestNewNode(t *testing.T) {
+	if _, err := NewNode(NodeConfig{}); !errors.Is(err, ErrNoNodeID) {
+		t.Errorf("Expected a node without an ID to be rejected, got %v", err)
+	}
+	if _, err := NewNode(NodeConfig{ID: "sat1"}); !errors.Is(err, ErrInvalidEndpoint) {
+		t.Errorf("Expected an invalid ID to be rejected, got %v", err)
+	}
+	node, err := NewNode(NodeConfig{ID: "dtn://sat1/telemetry"})
+	if err != nil || node.ID() != "dtn://sat1/" {
+		t.Errorf("Expected the node ID to be the administrative endpoint, got %s, %v", node.ID(), err)
+	}
+}
+
+func TestNode_StoreAndForward(t *testing.T) {
+	clock := satellite.NewSimulatedClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	sat := newTestNode(t, "dtn://sat1/", clock, true)
+	ground := newTestNode(t, "dtn://gs1/", clock, true)
+	downlink := connect(sat, ground)
+	connect(ground, sat)
+	received := &inbox{}
+	ground.Register("dtn://gs1/telemetry", received.deliver)
+
+	downlink.setUp(false)
+	if _, err := sat.Send("dtn://gs1/telemetry", []byte("frame 1"), time.Hour); err != nil {
+		t.Fatalf("Expected Send to succeed, got %v", err)
+	}
+	process(sat, ground)
+	if received.count() != 0 || sat.Stored() != 1 {
+		t.Fatalf("Expected the bundle to be stored while the link is down")
+	}
+
+	downlink.setUp(true)
+	process(sat, ground)
+	if received.count() != 1 || string(received.bundles[0].Payload()) != "frame 1" {
+		t.Fatalf("Expected the bundle to be delivered once the link is up")
+	}
+	if source := received.bundles[0].Primary.Source; source != "dtn://sat1/" {
+		t.Errorf("Expected the bundle to come from sat1, got %s", source)
+	}
+	if sat.Stored() != 0 || ground.Stored() != 0 {
+		t.Errorf("Expected custody to be released, got %d and %d stored", sat.Stored(), ground.Stored())
+	}
+}
+
+func TestNode_CustodyRetransmission(t *testing.T) {
+	clock := satellite.NewSimulatedClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	sat := newTestNode(t, "dtn://sat1/", clock, true)
+	ground := newTestNode(t, "dtn://gs1/", clock, true)
+	downlink := connect(sat, ground)
+	connect(ground, sat)
+	received := &inbox{}
+	ground.Register("dtn://gs1/telemetry", received.deliver)
+
+	downlink.lose = 1
+	sat.Send("dtn://gs1/telemetry", []byte("frame 1"), time.Hour)
+	process(sat, ground)
+	if received.count() != 0 || sat.Stored() != 1 || downlink.sent != 1 {
+		t.Fatalf("Expected the lost bundle to be held for custody")
+	}
+
+	// Nothing is resent until the custody timeout
+	clock.Advance(30 * time.Second)
+	process(sat, ground)
+	if downlink.sent != 1 {
+		t.Errorf("Expected no retransmission before the timeout, got %d sends", downlink.sent)
+	}
+
+	clock.Advance(31 * time.Second)
+	process(sat, ground)
+	if received.count() != 1 || sat.Stored() != 0 || downlink.sent != 2 {
+		t.Errorf("Expected the bundle to be retransmitted and custody released, got %d sends", downlink.sent)
+	}
+
+	// A retransmission after a lost custody signal is not delivered twice
+	clock.Advance(2 * time.Minute)
+	bundle := NewBundle("dtn://sat1/", "dtn://gs1/telemetry", received.bundles[0].Primary.Created, time.Hour, []byte("frame 1"))
+	bundle.SetPreviousNode("dtn://sat1/")
+	data, _ := bundle.Encode()
+	ground.Receive(data)
+	process(sat, ground)
+	if received.count() != 1 {
+		t.Errorf("Expected a duplicate bundle not to be delivered again")
+	}
+}
+
+func TestNode_MultiHop(t *testing.T) {
+	clock := satellite.NewSimulatedClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	sat := newTestNode(t, "ipn:10.0", clock, true)
+	ground := newTestNode(t, "ipn:20.0", clock, true)
+	ops := newTestNode(t, "ipn:30.0", clock, true)
+	downlink := connect(sat, ground)
+	downlink.MaxSize = 300
+	connect(ground, sat)
+	connect(ground, ops)
+	connect(ops, ground)
+	sat.AddRoute("ipn:30.1", "ipn:20.0")
+	ops.SetDefaultRoute("ipn:20.0")
+
+	received := &inbox{}
+	ops.Register("ipn:30.1", received.deliver)
+	image := bytes.Repeat([]byte("pixels"), 500)
+	if _, err := sat.Send("ipn:30.1", image, time.Hour); err != nil {
+		t.Fatalf("Expected Send to succeed, got %v", err)
+	}
+	process(sat, ground, ops)
+
+	if received.count() != 1 || !bytes.Equal(received.bundles[0].Payload(), image) {
+		t.Fatalf("Expected the image to be fragmented, forwarded and reassembled")
+	}
+	if downlink.sent < 10 {
+		t.Errorf("Expected the image to be fragmented on the downlink, got %d sends", downlink.sent)
+	}
+	if _, count, _ := received.bundles[0].HopCount(); count != 2 {
+		t.Errorf("Expected two hops, got %d", count)
+	}
+	if sat.Stored()+ground.Stored()+ops.Stored() != 0 {
+		t.Errorf("Expected every custody to be released")
+	}
+}
+
+func TestNode_Expiry(t *testing.T) {
+	clock := satellite.NewSimulatedClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	sat := newTestNode(t, "dtn://sat1/", clock, false)
+	ground := newTestNode(t, "dtn://gs1/", clock, false)
+	downlink := connect(sat, ground)
+	received := &inbox{}
+	ground.Register("dtn://gs1/telemetry", received.deliver)
+
+	downlink.setUp(false)
+	sat.Send("dtn://gs1/telemetry", []byte("stale"), time.Minute)
+	sat.Send("dtn://gs1/telemetry", []byte("fresh"), time.Hour)
+	clock.Advance(2 * time.Minute)
+	process(sat, ground)
+	if sat.Stored() != 1 {
+		t.Errorf("Expected the expired bundle to be dropped, got %d stored", sat.Stored())
+	}
+
+	downlink.setUp(true)
+	process(sat, ground)
+	if received.count() != 1 || string(received.bundles[0].Payload()) != "fresh" {
+		t.Errorf("Expected only the unexpired bundle to be delivered")
+	}
+}
+
+func TestNode_HopLimit(t *testing.T) {
+	clock := satellite.NewSimulatedClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	first, err := NewNode(NodeConfig{ID: "dtn://a/", Clock: clock, HopLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := newTestNode(t, "dtn://b/", clock, false)
+	third := newTestNode(t, "dtn://c/", clock, false)
+	connect(first, second)
+	connect(second, third)
+	first.SetDefaultRoute("dtn://b/")
+	received := &inbox{}
+	third.Register("dtn://c/x", received.deliver)
+
+	first.Send("dtn://c/x", []byte("too far"), time.Hour)
+	process(first, second, third)
+	if received.count() != 0 || third.Stored() != 0 {
+		t.Errorf("Expected the bundle to be dropped at the hop limit")
+	}
+}
+
+func TestNode_Persistence(t *testing.T) {
+	clock := satellite.NewSimulatedClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sat, err := NewNode(NodeConfig{ID: "dtn://sat1/", Clock: clock, Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sat.Send("dtn://gs1/telemetry", []byte("survives"), time.Hour)
+
+	// Restart the node on the same directory
+	store, _ = NewFileStore(dir)
+	restarted, err := NewNode(NodeConfig{ID: "dtn://sat1/", Clock: clock, Store: store})
+	if err != nil || restarted.Stored() != 1 {
+		t.Fatalf("Expected the bundle to be reloaded, got %d, %v", restarted.Stored(), err)
+	}
+	ground := newTestNode(t, "dtn://gs1/", clock, false)
+	connect(restarted, ground)
+	received := &inbox{}
+	ground.Register("dtn://gs1/telemetry", received.deliver)
+	process(restarted, ground)
+	if received.count() != 1 {
+		t.Errorf("Expected the reloaded bundle to be delivered")
+	}
+	if bundles, _ := store.List(); len(bundles) != 0 {
+		t.Errorf("Expected the delivered bundle to be removed from the store")
+	}
+}
+
+func TestNode_Register(t *testing.T) {
+	clock := satellite.NewSimulatedClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	node := newTestNode(t, "dtn://gs1/", clock, false)
+	node.Send("dtn://gs1/late", []byte("waiting"), time.Hour)
+	node.Process()
+	if node.Stored() != 1 {
+		t.Fatalf("Expected a bundle for an unregistered endpoint to be kept")
+	}
+	received := &inbox{}
+	node.Register("dtn://gs1/late", received.deliver)
+	node.Process()
+	if received.count() != 1 || node.Stored() != 0 {
+		t.Errorf("Expected the bundle to be delivered once the endpoint registered")
+	}
+}
@@ -0,0 +1,181 @@
+package dtn
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrBundleNotFound is returned when a bundle is not in a store
+var ErrBundleNotFound = errors.New("dtn: bundle not found")
+
+// bundleFileExtension is the extension of stored bundle files
+const bundleFileExtension = ".bundle"
+
+// Store holds bundles awaiting forwarding
+type Store interface {
+	// Put stores a bundle, replacing any bundle with the same ID
+	Put(bundle *Bundle) error
+
+	// Get returns a stored bundle
+	Get(id BundleID) (*Bundle, error)
+
+	// Delete removes a bundle. Deleting a missing bundle is not an error.
+	Delete(id BundleID) error
+
+	// List returns every stored bundle
+	List() ([]*Bundle, error)
+}
+
+// MemoryStore is a Store that keeps bundles in memory
+type MemoryStore struct {
+	mutex   sync.RWMutex
+	bundles map[string]*Bundle
+}
+
+// NewMemoryStore returns a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		bundles: make(map[string]*Bundle),
+	}
+}
+
+// Put stores a copy of a bundle
+func (s *MemoryStore) Put(bundle *Bundle) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.bundles[bundle.ID().String()] = bundle.Clone()
+	return nil
+}
+
+// Get returns a copy of a stored bundle
+func (s *MemoryStore) Get(id BundleID) (*Bundle, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	bundle, ok := s.bundles[id.String()]
+	if !ok {
+		return nil, ErrBundleNotFound
+	}
+	return bundle.Clone(), nil
+}
+
+// Delete removes a bundle
+func (s *MemoryStore) Delete(id BundleID) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.bundles, id.String())
+	return nil
+}
+
+// List returns copies of the stored bundles ordered by ID
+func (s *MemoryStore) List() ([]*Bundle, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := make([]string, 0, len(s.bundles))
+	for key := range s.bundles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	bundles := make([]*Bundle, len(keys))
+	for i, key := range keys {
+		bundles[i] = s.bundles[key].Clone()
+	}
+	return bundles, nil
+}
+
+// FileStore is a Store that keeps each bundle in its own file in a
+// directory, so bundles survive restarts
+type FileStore struct {
+	// Path is the directory bundles are stored in
+	Path string
+
+	mutex sync.Mutex
+}
+
+// NewFileStore returns a new FileStore in a directory, creating it if needed
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{
+		Path: path,
+	}, nil
+}
+
+// file returns the path of a bundle's file
+func (s *FileStore) file(id BundleID) string {
+	sum := sha256.Sum256([]byte(id.String()))
+	return filepath.Join(s.Path, hex.EncodeToString(sum[:16])+bundleFileExtension)
+}
+
+// Put writes a bundle to its file
+func (s *FileStore) Put(bundle *Bundle) error {
+	data, err := bundle.Encode()
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	path := s.file(bundle.ID())
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get reads a bundle from its file
+func (s *FileStore) Get(id BundleID) (*Bundle, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, err := os.ReadFile(s.file(id))
+	if os.IsNotExist(err) {
+		return nil, ErrBundleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return DecodeBundle(data)
+}
+
+// Delete removes a bundle's file
+func (s *FileStore) Delete(id BundleID) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := os.Remove(s.file(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List reads every stored bundle. Files that no longer decode, for example
+// after a crash mid-write, are skipped.
+func (s *FileStore) List() ([]*Bundle, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entries, err := os.ReadDir(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	var bundles []*Bundle
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), bundleFileExtension) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.Path, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		bundle, err := DecodeBundle(data)
+		if err != nil {
+			continue
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
@@ -0,0 +1,70 @@
+package dtn
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testStore checks the behaviour shared by every Store
+func testStore(t *testing.T, store Store) {
+	first := newTestBundle()
+	second := NewBundle("dtn://sat1/", "dtn://gs1/", CreationTimestamp{Time: 1, Sequence: 2}, time.Hour, []byte("second"))
+	for _, bundle := range []*Bundle{first, second} {
+		if err := store.Put(bundle); err != nil {
+			t.Fatalf("Expected Put to succeed, got %v", err)
+		}
+	}
+
+	got, err := store.Get(first.ID())
+	if err != nil || string(got.Payload()) != "housekeeping frame" {
+		t.Errorf("Expected Get to return the bundle, got %v", err)
+	}
+	got.Blocks[len(got.Blocks)-1].Data[0] = 'X'
+	if again, _ := store.Get(first.ID()); string(again.Payload()) != "housekeeping frame" {
+		t.Errorf("Expected stored bundles not to share memory with callers")
+	}
+
+	bundles, err := store.List()
+	if err != nil || len(bundles) != 2 {
+		t.Errorf("Expected two stored bundles, got %d, %v", len(bundles), err)
+	}
+	if err := store.Delete(first.ID()); err != nil {
+		t.Errorf("Expected Delete to succeed, got %v", err)
+	}
+	if err := store.Delete(first.ID()); err != nil {
+		t.Errorf("Expected deleting a missing bundle to succeed, got %v", err)
+	}
+	if _, err := store.Get(first.ID()); !errors.Is(err, ErrBundleNotFound) {
+		t.Errorf("Expected a deleted bundle to be missing, got %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bundles")
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Expected NewFileStore to succeed, got %v", err)
+	}
+	testStore(t, store)
+
+	// A new store on the same directory sees the remaining bundle, and
+	// skips files that do not decode
+	if err := os.WriteFile(filepath.Join(dir, "broken"+bundleFileExtension), []byte{0x9f}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Expected NewFileStore to succeed, got %v", err)
+	}
+	bundles, err := reopened.List()
+	if err != nil || len(bundles) != 1 || string(bundles[0].Payload()) != "second" {
+		t.Errorf("Expected the bundle to persist, got %d, %v", len(bundles), err)
+	}
+}
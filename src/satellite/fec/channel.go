@@ -0,0 +1,141 @@
+package fec
+
+import (
+	"bytes"
+	"math"
+	"math/bits"
+	"math/rand"
+)
+
+// BinarySymmetricChannel flips each bit independently with a probability
+type BinarySymmetricChannel struct {
+	// BitErrorRate is the probability of each bit being flipped
+	BitErrorRate float64
+
+	rand *rand.Rand
+}
+
+// NewBinarySymmetricChannel returns a new BinarySymmetricChannel instance
+// drawing errors from a seeded source, so runs are repeatable
+func NewBinarySymmetricChannel(bitErrorRate float64, seed int64) *BinarySymmetricChannel {
+	return &BinarySymmetricChannel{
+		BitErrorRate: bitErrorRate,
+		rand:         rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Transmit returns a copy of data with bit errors and the number of bits
+// flipped
+func (c *BinarySymmetricChannel) Transmit(data []byte) ([]byte, int) {
+	received := append([]byte(nil), data...)
+	if c.BitErrorRate <= 0 {
+		return received, 0
+	}
+	total := 8 * len(data)
+	flipped := 0
+
+	// Skip ahead by geometrically distributed gaps between errors rather
+	// than drawing for every bit
+	position := -1
+	for {
+		if c.BitErrorRate >= 1 {
+			position++
+		} else {
+			position += 1 + int(math.Log(1-c.rand.Float64())/math.Log(1-c.BitErrorRate))
+		}
+		if position >= total || position < 0 {
+			return received, flipped
+		}
+		received[position/8] ^= 1 << (7 - position%8)
+		flipped++
+	}
+}
+
+// Burst flips every bit of length octets of data from an offset, as a
+// fade or interference would
+func Burst(data []byte, offset, length int) {
+	for i := offset; i < offset+length && i < len(data); i++ {
+		data[i] ^= 0xff
+	}
+}
+
+// Measurement is the outcome of sending frames through a codec and channel
+type Measurement struct {
+	// Frames is the number of frames sent
+	Frames int `json:"frames"`
+
+	// Failed is the number of frames the codec reported it could not
+	// decode
+	Failed int `json:"failed"`
+
+	// Undetected is the number of frames decoded without error but wrong
+	Undetected int `json:"undetected"`
+
+	// Bits is the number of data bits sent
+	Bits int `json:"bits"`
+
+	// ChannelErrors is the number of bits the channel flipped
+	ChannelErrors int `json:"channelErrors"`
+
+	// ResidualErrors is the number of data bits wrong after decoding,
+	// counting every bit of failed frames
+	ResidualErrors int `json:"residualErrors"`
+}
+
+// FrameErrorRate returns the fraction of frames not delivered intact
+func (m Measurement) FrameErrorRate() float64 {
+	if m.Frames == 0 {
+		return 0
+	}
+	return float64(m.Failed+m.Undetected) / float64(m.Frames)
+}
+
+// BitErrorRate returns the fraction of data bits wrong after decoding
+func (m Measurement) BitErrorRate() float64 {
+	if m.Bits == 0 {
+		return 0
+	}
+	return float64(m.ResidualErrors) / float64(m.Bits)
+}
+
+// Measure sends frames of random data of a size through a codec and a
+// channel and counts the errors left after decoding
+func Measure(codec Codec, channel *BinarySymmetricChannel, frames, size int) (Measurement, error) {
+	var m Measurement
+	data := make([]byte, size)
+	for i := 0; i < frames; i++ {
+		channel.rand.Read(data)
+		encoded, err := codec.Encode(data)
+		if err != nil {
+			return m, err
+		}
+		received, flipped := channel.Transmit(encoded)
+		m.Frames++
+		m.Bits += 8 * size
+		m.ChannelErrors += flipped
+
+		decoded, err := codec.Decode(received)
+		switch {
+		case err != nil:
+			m.Failed++
+			m.ResidualErrors += 8 * size
+		case !bytes.Equal(decoded, data):
+			m.Undetected++
+			m.ResidualErrors += bitErrors(decoded, data)
+		}
+	}
+	return m, nil
+}
+
+// bitErrors returns the number of bits that differ between two slices,
+// counting missing octets as wholly wrong
+func bitErrors(a, b []byte) int {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	count := 8 * (len(b) - len(a))
+	for i := range a {
+		count += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return count
+}
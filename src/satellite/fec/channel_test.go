@@ -0,0 +1,75 @@
+package fec
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBinarySymmetricChannel_Transmit(t *testing.T) {
+	data := make([]byte, 100000)
+	received, flipped := NewBinarySymmetricChannel(0.01, 1).Transmit(data)
+	if got := bitErrors(received, data); got != flipped {
+		t.Errorf("Expected %d flipped bits, counted %d", flipped, got)
+	}
+	if rate := float64(flipped) / float64(8*len(data)); math.Abs(rate-0.01) > 0.001 {
+		t.Errorf("Expected a bit error rate near 0.01, got %f", rate)
+	}
+	if data[0] != 0 || len(received) != len(data) {
+		t.Errorf("Expected the input to be left alone")
+	}
+
+	again, _ := NewBinarySymmetricChannel(0.01, 1).Transmit(data)
+	if bitErrors(again, received) != 0 {
+		t.Errorf("Expected the same seed to give the same errors")
+	}
+	if _, flipped := NewBinarySymmetricChannel(0, 1).Transmit(data); flipped != 0 {
+		t.Errorf("Expected no errors on a perfect channel, got %d", flipped)
+	}
+	if _, flipped := NewBinarySymmetricChannel(1, 1).Transmit(data[:10]); flipped != 80 {
+		t.Errorf("Expected every bit flipped, got %d", flipped)
+	}
+}
+
+func TestBurst(t *testing.T) {
+	data := make([]byte, 10)
+	Burst(data, 8, 5)
+	if data[7] != 0 || data[8] != 0xff || data[9] != 0xff {
+		t.Errorf("Expected the burst to stop at the end of the data, got %x", data)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	channel := NewBinarySymmetricChannel(2e-3, 4)
+
+	// Unprotected frames are almost all hit at this error rate
+	uncoded, err := Measure(CRC16{}, channel, 50, 223)
+	if err != nil {
+		t.Fatalf("Expected Measure to succeed, got %v", err)
+	}
+	if uncoded.Frames != 50 || uncoded.Bits != 50*223*8 || uncoded.ChannelErrors == 0 {
+		t.Errorf("Expected the frames to be counted, got %+v", uncoded)
+	}
+	if uncoded.FrameErrorRate() < 0.9 || uncoded.Undetected != 0 {
+		t.Errorf("Expected the CRC to catch most frames as failed, got %+v", uncoded)
+	}
+
+	// The concatenated code delivers them
+	rs, _ := NewReedSolomon(1)
+	coded, err := Measure(Chain{rs, NewConvolutional()}, channel, 50, 223)
+	if err != nil {
+		t.Fatalf("Expected Measure to succeed, got %v", err)
+	}
+	if coded.FrameErrorRate() != 0 || coded.BitErrorRate() != 0 {
+		t.Errorf("Expected the concatenated code to correct all errors, got %+v", coded)
+	}
+	if coded.ChannelErrors == 0 {
+		t.Errorf("Expected the channel to have introduced errors")
+	}
+
+	if (Measurement{}).FrameErrorRate() != 0 || (Measurement{}).BitErrorRate() != 0 {
+		t.Errorf("Expected zero rates without frames")
+	}
+	if _, err := Measure(rs, channel, 1, 300); err == nil {
+		t.Errorf("Expected an encoding error to be returned")
+	}
+}
@@ -0,0 +1,85 @@
+// Package fec provides the forward error correction of the CCSDS TM
+// synchronization and channel coding recommendation (CCSDS 131.0-B): a
+// Reed-Solomon (255,223) code with interleaving, the rate 1/2 constraint
+// length 7 convolutional code with a Viterbi decoder and a CRC check, along
+// with a binary symmetric channel to measure the residual error rates.
+package fec
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/skybridge/satellite/ccsds"
+)
+
+var (
+	// ErrUncorrectable is returned when a codeword has more errors than the
+	// code can correct
+	ErrUncorrectable = errors.New("fec: uncorrectable errors")
+
+	// ErrCRC is returned when a CRC does not match
+	ErrCRC = errors.New("fec: CRC mismatch")
+
+	// ErrLength is returned when data does not have a length the codec
+	// accepts
+	ErrLength = errors.New("fec: invalid length")
+)
+
+// Codec encodes data for a noisy channel and decodes what arrives
+type Codec interface {
+	// Encode returns the encoded data
+	Encode(data []byte) ([]byte, error)
+
+	// Decode returns the data, correcting errors where possible
+	Decode(data []byte) ([]byte, error)
+}
+
+// Chain is a concatenated code. Encoding applies the codecs in order, so
+// the first is the inner code closest to the data, and decoding applies
+// them in reverse.
+type Chain []Codec
+
+// Encode returns the data encoded with each codec in turn
+func (c Chain) Encode(data []byte) ([]byte, error) {
+	for _, codec := range c {
+		var err error
+		if data, err = codec.Encode(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// Decode returns the data decoded with each codec in reverse
+func (c Chain) Decode(data []byte) ([]byte, error) {
+	for i := len(c) - 1; i >= 0; i-- {
+		var err error
+		if data, err = c[i].Decode(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// CRC16 is a codec appending the CRC-16-CCITT of the frame error control
+// field, detecting the errors the other codes leave
+type CRC16 struct{}
+
+// Encode returns the data followed by its CRC
+func (CRC16) Encode(data []byte) ([]byte, error) {
+	encoded := make([]byte, len(data), len(data)+2)
+	copy(encoded, data)
+	return binary.BigEndian.AppendUint16(encoded, ccsds.CRC16(data)), nil
+}
+
+// Decode checks and removes the CRC
+func (CRC16) Decode(data []byte) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, ErrLength
+	}
+	end := len(data) - 2
+	if ccsds.CRC16(data[:end]) != binary.BigEndian.Uint16(data[end:]) {
+		return nil, ErrCRC
+	}
+	return data[:end:end], nil
+}
@@ -0,0 +1,52 @@
+package fec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCRC16_Decode(t *testing.T) {
+	var codec CRC16
+	encoded, _ := codec.Encode([]byte("123456789"))
+	if len(encoded) != 11 || encoded[9] != 0x29 || encoded[10] != 0xb1 {
+		t.Errorf("Expected the CRC-16-CCITT to be appended, got %x", encoded)
+	}
+	decoded, err := codec.Decode(encoded)
+	if err != nil || string(decoded) != "123456789" {
+		t.Errorf("Expected the data back, got %q, %v", decoded, err)
+	}
+
+	encoded[0] ^= 1
+	if _, err := codec.Decode(encoded); err != ErrCRC {
+		t.Errorf("Expected a corrupted frame to fail the CRC, got %v", err)
+	}
+	if _, err := codec.Decode([]byte{1}); err != ErrLength {
+		t.Errorf("Expected a short frame to fail, got %v", err)
+	}
+}
+
+func TestChain_Decode(t *testing.T) {
+	rs, _ := NewReedSolomon(1)
+	chain := Chain{CRC16{}, rs, NewConvolutional()}
+	data := bytes.Repeat([]byte("telemetry "), 23)[:221]
+
+	encoded, err := chain.Encode(data)
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+	if len(encoded) != 2*(221+2+RSParityLength)+2 {
+		t.Errorf("Expected each code to add its overhead, got %d octets", len(encoded))
+	}
+	Burst(encoded, 40, 3)
+	decoded, err := chain.Decode(encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("Expected the chain to correct a burst, got %v", err)
+	}
+
+	if _, err := chain.Encode(make([]byte, 300)); err == nil {
+		t.Errorf("Expected data too long for the Reed-Solomon code to fail")
+	}
+	if _, err := chain.Decode(encoded[:3]); err == nil {
+		t.Errorf("Expected a truncated block to fail")
+	}
+}
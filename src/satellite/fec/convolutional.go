@@ -0,0 +1,130 @@
+package fec
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Convolutional code parameters of CCSDS 131.0-B, rate 1/2 with constraint
+// length 7
+const (
+	// ConstraintLength is the number of input bits each output depends on
+	ConstraintLength = 7
+
+	// convPolynomial1 and convPolynomial2 are the connection vectors 171
+	// and 133 octal, the current input bit first
+	convPolynomial1 = 0x79
+	convPolynomial2 = 0x5b
+
+	// convStates is the number of encoder states
+	convStates = 1 << (ConstraintLength - 1)
+
+	// convTail is the number of zero bits that return the encoder to the
+	// zero state
+	convTail = ConstraintLength - 1
+)
+
+// Convolutional is the rate 1/2, constraint length 7 convolutional code
+// with the second output inverted, decoded with a hard decision Viterbi
+// decoder. Each block is terminated with six zero bits, so n octets encode
+// to 2n+2 octets.
+type Convolutional struct{}
+
+// NewConvolutional returns a new Convolutional instance
+func NewConvolutional() *Convolutional {
+	return &Convolutional{}
+}
+
+// convOutputs are the two output symbols, first in bit 1, of each encoder
+// register holding the current input bit in bit 6 and the six before it
+// below
+var convOutputs [2 * convStates]uint8
+
+func init() {
+	for register := range convOutputs {
+		first := bits.OnesCount8(uint8(register&convPolynomial1)) & 1
+		second := bits.OnesCount8(uint8(register&convPolynomial2))&1 ^ 1
+		convOutputs[register] = uint8(first<<1 | second)
+	}
+}
+
+// Encode returns the encoded symbols packed into octets, most significant
+// bit first
+func (c *Convolutional) Encode(data []byte) ([]byte, error) {
+	encoded := make([]byte, 2*len(data)+2)
+	state := 0
+	position := 0
+	put := func(symbol byte) {
+		encoded[position/8] |= symbol << (7 - position%8)
+		position++
+	}
+	for i := 0; i < 8*len(data)+convTail; i++ {
+		bit := 0
+		if i < 8*len(data) {
+			bit = int(data[i/8]>>(7-i%8)) & 1
+		}
+		register := bit<<(ConstraintLength-1) | state
+		put(convOutputs[register] >> 1)
+		put(convOutputs[register] & 1)
+		state = register >> 1
+	}
+	return encoded, nil
+}
+
+// Decode returns the most likely data for received symbols from Encode
+func (c *Convolutional) Decode(encoded []byte) ([]byte, error) {
+	if len(encoded) < 2 || len(encoded)%2 != 0 {
+		return nil, fmt.Errorf("%w: %d octets of convolutional code", ErrLength, len(encoded))
+	}
+	n := len(encoded)/2 - 1
+	steps := 8*n + convTail
+	symbol := func(position int) byte {
+		return encoded[position/8] >> (7 - position%8) & 1
+	}
+
+	// Path metrics are Hamming distances, with unreachable states at a
+	// distance no path reaches
+	const unreachable = 1 << 30
+	var metrics, next [convStates]int
+	for i := range metrics {
+		metrics[i] = unreachable
+	}
+	metrics[0] = 0
+	predecessors := make([][convStates]uint8, steps)
+
+	for step := 0; step < steps; step++ {
+		received := symbol(2*step)<<1 | symbol(2*step+1)
+		for i := range next {
+			next[i] = unreachable
+		}
+		for state, metric := range metrics {
+			if metric == unreachable {
+				continue
+			}
+			for bit := 0; bit < 2; bit++ {
+				if step >= 8*n && bit == 1 {
+					continue
+				}
+				register := bit<<(ConstraintLength-1) | state
+				distance := metric + bits.OnesCount8(convOutputs[register]^received)
+				successor := register >> 1
+				if distance < next[successor] {
+					next[successor] = distance
+					predecessors[step][successor] = uint8(state)
+				}
+			}
+		}
+		metrics = next
+	}
+
+	// Trace back from the zero state the tail leaves the encoder in
+	data := make([]byte, n)
+	state := 0
+	for step := steps - 1; step >= 0; step-- {
+		if step < 8*n && state>>(ConstraintLength-2)&1 == 1 {
+			data[step/8] |= 1 << (7 - step%8)
+		}
+		state = int(predecessors[step][state])
+	}
+	return data, nil
+}
@@ -0,0 +1,78 @@
+package fec
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestConvolutional_Encode(t *testing.T) {
+	codec := NewConvolutional()
+
+	// Zero data leaves the first output zero and the inverted second one
+	encoded, _ := codec.Encode([]byte{0})
+	if !bytes.Equal(encoded, []byte{0x55, 0x55, 0x55, 0x50}) {
+		t.Errorf("Expected alternating symbols for zero data, got %x", encoded)
+	}
+
+	// A single one bit gives the impulse response of both polynomials,
+	// 1111001 and the inverse of 1011011, interleaved
+	encoded, _ = codec.Encode([]byte{0x80})
+	if !bytes.Equal(encoded, []byte{0xba, 0x49, 0x55, 0x50}) {
+		t.Errorf("Expected the impulse response, got %x", encoded)
+	}
+
+	if encoded, _ := codec.Encode(nil); len(encoded) != 2 {
+		t.Errorf("Expected only the tail for no data, got %d octets", len(encoded))
+	}
+}
+
+func TestConvolutional_Decode(t *testing.T) {
+	codec := NewConvolutional()
+	data := make([]byte, 100)
+	random := rand.New(rand.NewSource(3))
+	random.Read(data)
+	encoded, _ := codec.Encode(data)
+
+	decoded, err := codec.Decode(encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("Expected clean symbols to decode, got %v", err)
+	}
+
+	// Scattered symbol errors, well apart, are corrected
+	for i := 0; i < 8*len(encoded); i += 50 {
+		encoded[i/8] ^= 1 << (i % 8)
+	}
+	decoded, err = codec.Decode(encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("Expected scattered errors to be corrected, got %v", err)
+	}
+
+	for _, length := range []int{0, 3} {
+		if _, err := codec.Decode(make([]byte, length)); !errors.Is(err, ErrLength) {
+			t.Errorf("Expected %d octets to fail, got %v", length, err)
+		}
+	}
+}
+
+func BenchmarkConvolutional_Encode(b *testing.B) {
+	codec := NewConvolutional()
+	data := make([]byte, 1115)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		codec.Encode(data)
+	}
+}
+
+func BenchmarkConvolutional_Decode(b *testing.B) {
+	codec := NewConvolutional()
+	data := make([]byte, 1115)
+	rand.New(rand.NewSource(1)).Read(data)
+	encoded, _ := codec.Encode(data)
+	received, _ := NewBinarySymmetricChannel(1e-3, 1).Transmit(encoded)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		codec.Decode(received)
+	}
+}
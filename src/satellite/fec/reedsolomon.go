@@ -0,0 +1,357 @@
+package fec
+
+import (
+	"fmt"
+)
+
+// Reed-Solomon (255,223) parameters of CCSDS 131.0-B, over GF(2^8) with
+// field polynomial x^8+x^7+x^2+x+1, code generator roots α^(11j) for j from
+// 112 to 143. Codeblocks carry their symbols in Berlekamp's dual basis, as
+// the recommendation requires; the arithmetic is done in the conventional
+// representation and symbols are converted at the codec boundary.
+const (
+	// RSCodewordLength is the length of a codeword in symbols
+	RSCodewordLength = 255
+
+	// RSDataLength is the length of the data in a codeword
+	RSDataLength = 223
+
+	// RSParityLength is the length of the check symbols of a codeword
+	RSParityLength = RSCodewordLength - RSDataLength
+
+	// MaxInterleave is the largest interleaving depth
+	MaxInterleave = 8
+
+	rsFieldPolynomial = 0x187
+	rsFirstRoot       = 112
+	rsPrimitive       = 11
+
+	// rsInversePrimitive is the inverse of rsPrimitive modulo 255
+	rsInversePrimitive = 116
+
+	// rsZero is the logarithm used for zero
+	rsZero = RSCodewordLength
+)
+
+// rsAlpha and rsLog are the antilogarithms and logarithms of GF(2^8)
+var rsAlpha, rsLog [RSCodewordLength + 1]int
+
+// rsGenerator is the code generator polynomial in logarithm form
+var rsGenerator [RSParityLength + 1]int
+
+// rsDualBasis is the matrix of CCSDS 131.0-B that takes a symbol from the
+// conventional representation to Berlekamp's dual basis, one row for each
+// bit from the most significant
+var rsDualBasis = [8]byte{0x8d, 0xef, 0xec, 0x86, 0xfa, 0x99, 0xaf, 0x7b}
+
+// rsToDual and rsFromDual convert symbols between the conventional
+// representation and the dual basis
+var rsToDual, rsFromDual [RSCodewordLength + 1]byte
+
+func init() {
+	rsLog[0] = rsZero
+	rsAlpha[rsZero] = 0
+	sr := 1
+	for i := 0; i < RSCodewordLength; i++ {
+		rsLog[sr] = i
+		rsAlpha[i] = sr
+		sr <<= 1
+		if sr&0x100 != 0 {
+			sr ^= rsFieldPolynomial
+		}
+	}
+
+	var generator [RSParityLength + 1]int
+	generator[0] = 1
+	for i, root := 0, rsFirstRoot*rsPrimitive; i < RSParityLength; i, root = i+1, root+rsPrimitive {
+		generator[i+1] = 1
+		for j := i; j > 0; j-- {
+			if generator[j] != 0 {
+				generator[j] = generator[j-1] ^ rsAlpha[modnn(rsLog[generator[j]]+root)]
+			} else {
+				generator[j] = generator[j-1]
+			}
+		}
+		generator[0] = rsAlpha[modnn(rsLog[generator[0]]+root)]
+	}
+	for i, coefficient := range generator {
+		rsGenerator[i] = rsLog[coefficient]
+	}
+
+	for i := range rsToDual {
+		var dual byte
+		for bit, row := range rsDualBasis {
+			if i&(0x80>>bit) != 0 {
+				dual ^= row
+			}
+		}
+		rsToDual[i] = dual
+		rsFromDual[dual] = byte(i)
+	}
+}
+
+// modnn reduces a non-negative logarithm modulo 255
+func modnn(x int) int {
+	return x % RSCodewordLength
+}
+
+// ReedSolomon is the Reed-Solomon (255,223) code, correcting up to 16
+// symbol errors in each codeword. Interleaving spreads a codeblock over
+// several codewords so a burst of up to 16 times the depth octets is
+// corrected.
+type ReedSolomon struct {
+	interleave int
+}
+
+// NewReedSolomon returns a new ReedSolomon instance with an interleaving
+// depth from 1 to 8
+func NewReedSolomon(interleave int) (*ReedSolomon, error) {
+	if interleave < 1 || interleave > MaxInterleave {
+		return nil, fmt.Errorf("%w: interleaving depth %d", ErrLength, interleave)
+	}
+	return &ReedSolomon{
+		interleave: interleave,
+	}, nil
+}
+
+// Interleave returns the interleaving depth
+func (r *ReedSolomon) Interleave() int {
+	return r.interleave
+}
+
+// Encode returns the codeblock of data followed by the interleaved check
+// symbols. The data may be shortened from the full 223 octets per codeword
+// but its length must be a multiple of the interleaving depth.
+func (r *ReedSolomon) Encode(data []byte) ([]byte, error) {
+	k, err := r.dataLength(len(data))
+	if err != nil {
+		return nil, err
+	}
+	block := make([]byte, len(data)+RSParityLength*r.interleave)
+	copy(block, data)
+	codeword := make([]byte, k)
+	for i := 0; i < r.interleave; i++ {
+		for j := range codeword {
+			codeword[j] = rsFromDual[data[j*r.interleave+i]]
+		}
+		parity := rsEncode(codeword)
+		for j, symbol := range parity {
+			block[(k+j)*r.interleave+i] = rsToDual[symbol]
+		}
+	}
+	return block, nil
+}
+
+// Decode corrects a codeblock and returns its data
+func (r *ReedSolomon) Decode(block []byte) ([]byte, error) {
+	data, _, err := r.Correct(block)
+	return data, err
+}
+
+// Correct corrects a codeblock, returning its data and the number of
+// symbols corrected
+func (r *ReedSolomon) Correct(block []byte) ([]byte, int, error) {
+	if len(block) < RSParityLength*r.interleave {
+		return nil, 0, fmt.Errorf("%w: codeblock of %d octets", ErrLength, len(block))
+	}
+	k, err := r.dataLength(len(block) - RSParityLength*r.interleave)
+	if err != nil {
+		return nil, 0, err
+	}
+	corrected := append([]byte(nil), block...)
+	codeword := make([]byte, k+RSParityLength)
+	total := 0
+	for i := 0; i < r.interleave; i++ {
+		for j := range codeword {
+			codeword[j] = rsFromDual[corrected[j*r.interleave+i]]
+		}
+		count, err := rsDecode(codeword)
+		if err != nil {
+			return nil, total, err
+		}
+		total += count
+		for j, symbol := range codeword {
+			corrected[j*r.interleave+i] = rsToDual[symbol]
+		}
+	}
+	data := corrected[:k*r.interleave]
+	return data[:len(data):len(data)], total, nil
+}
+
+// dataLength returns the data length of each codeword for a codeblock with
+// a data length
+func (r *ReedSolomon) dataLength(length int) (int, error) {
+	if length == 0 || length%r.interleave != 0 || length > RSDataLength*r.interleave {
+		return 0, fmt.Errorf("%w: %d octets of data at interleaving depth %d", ErrLength, length, r.interleave)
+	}
+	return length / r.interleave, nil
+}
+
+// rsEncode returns the check symbols of a possibly shortened codeword in the
+// conventional representation
+func rsEncode(data []byte) []byte {
+	parity := make([]byte, RSParityLength)
+	for _, symbol := range data {
+		feedback := rsLog[symbol^parity[0]]
+		if feedback != rsZero {
+			for j := 1; j < RSParityLength; j++ {
+				parity[j] ^= byte(rsAlpha[modnn(feedback+rsGenerator[RSParityLength-j])])
+			}
+		}
+		copy(parity, parity[1:])
+		if feedback != rsZero {
+			parity[RSParityLength-1] = byte(rsAlpha[modnn(feedback+rsGenerator[0])])
+		} else {
+			parity[RSParityLength-1] = 0
+		}
+	}
+	return parity
+}
+
+// rsDecode corrects a possibly shortened codeword in place with the
+// Berlekamp-Massey algorithm, a Chien search and Forney's algorithm,
+// returning the number of symbols corrected
+func rsDecode(codeword []byte) (int, error) {
+	pad := RSCodewordLength - len(codeword)
+
+	// Syndromes, in logarithm form
+	var syndromes [RSParityLength]int
+	for i := range syndromes {
+		syndromes[i] = int(codeword[0])
+	}
+	for _, symbol := range codeword[1:] {
+		for i, s := range syndromes {
+			if s == 0 {
+				syndromes[i] = int(symbol)
+			} else {
+				syndromes[i] = int(symbol) ^ rsAlpha[modnn(rsLog[s]+(rsFirstRoot+i)*rsPrimitive)]
+			}
+		}
+	}
+	failed := false
+	for i, s := range syndromes {
+		failed = failed || s != 0
+		syndromes[i] = rsLog[s]
+	}
+	if !failed {
+		return 0, nil
+	}
+
+	// Error locator polynomial
+	var lambda, b, t [RSParityLength + 1]int
+	lambda[0] = 1
+	for i := range b {
+		b[i] = rsLog[lambda[i]]
+	}
+	degree := 0
+	for r := 1; r <= RSParityLength; r++ {
+		discrepancy := 0
+		for i := 0; i < r; i++ {
+			if lambda[i] != 0 && syndromes[r-i-1] != rsZero {
+				discrepancy ^= rsAlpha[modnn(rsLog[lambda[i]]+syndromes[r-i-1])]
+			}
+		}
+		discrepancy = rsLog[discrepancy]
+		if discrepancy == rsZero {
+			copy(b[1:], b[:RSParityLength])
+			b[0] = rsZero
+			continue
+		}
+		t[0] = lambda[0]
+		for i := 0; i < RSParityLength; i++ {
+			if b[i] != rsZero {
+				t[i+1] = lambda[i+1] ^ rsAlpha[modnn(discrepancy+b[i])]
+			} else {
+				t[i+1] = lambda[i+1]
+			}
+		}
+		if 2*degree <= r-1 {
+			degree = r - degree
+			for i := range b {
+				if lambda[i] == 0 {
+					b[i] = rsZero
+				} else {
+					b[i] = modnn(rsLog[lambda[i]] - discrepancy + RSCodewordLength)
+				}
+			}
+		} else {
+			copy(b[1:], b[:RSParityLength])
+			b[0] = rsZero
+		}
+		lambda = t
+	}
+
+	lambdaDegree := 0
+	for i := range lambda {
+		lambda[i] = rsLog[lambda[i]]
+		if lambda[i] != rsZero {
+			lambdaDegree = i
+		}
+	}
+
+	// Chien search for the roots of the locator
+	var register [RSParityLength + 1]int
+	copy(register[1:], lambda[1:])
+	var roots, locations []int
+	for i, k := 1, rsInversePrimitive-1; i <= RSCodewordLength; i, k = i+1, modnn(k+rsInversePrimitive) {
+		q := 1
+		for j := lambdaDegree; j > 0; j-- {
+			if register[j] != rsZero {
+				register[j] = modnn(register[j] + j)
+				q ^= rsAlpha[register[j]]
+			}
+		}
+		if q != 0 {
+			continue
+		}
+		roots = append(roots, i)
+		locations = append(locations, k)
+		if len(roots) == lambdaDegree {
+			break
+		}
+	}
+	if len(roots) != lambdaDegree {
+		return 0, ErrUncorrectable
+	}
+
+	// Error evaluator polynomial
+	omegaDegree := lambdaDegree - 1
+	var omega [RSParityLength + 1]int
+	for i := 0; i <= omegaDegree; i++ {
+		sum := 0
+		for j := i; j >= 0; j-- {
+			if syndromes[i-j] != rsZero && lambda[j] != rsZero {
+				sum ^= rsAlpha[modnn(syndromes[i-j]+lambda[j])]
+			}
+		}
+		omega[i] = rsLog[sum]
+	}
+
+	// Error values by Forney's algorithm
+	for j := len(roots) - 1; j >= 0; j-- {
+		numerator := 0
+		for i := omegaDegree; i >= 0; i-- {
+			if omega[i] != rsZero {
+				numerator ^= rsAlpha[modnn(omega[i]+i*roots[j])]
+			}
+		}
+		scale := rsAlpha[modnn(roots[j]*(rsFirstRoot-1)+RSCodewordLength)]
+		denominator := 0
+		top := lambdaDegree
+		if top > RSParityLength-1 {
+			top = RSParityLength - 1
+		}
+		for i := top &^ 1; i >= 0; i -= 2 {
+			if lambda[i+1] != rsZero {
+				denominator ^= rsAlpha[modnn(lambda[i+1]+i*roots[j])]
+			}
+		}
+		if denominator == 0 || locations[j] < pad {
+			return 0, ErrUncorrectable
+		}
+		if numerator != 0 {
+			codeword[locations[j]-pad] ^= byte(rsAlpha[modnn(rsLog[numerator]+rsLog[scale]+RSCodewordLength-rsLog[denominator])])
+		}
+	}
+	return len(roots), nil
+}
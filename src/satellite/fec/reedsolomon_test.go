@@ -0,0 +1,188 @@
+package fec
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestReedSolomon_Encode(t *testing.T) {
+	rs, err := NewReedSolomon(1)
+	if err != nil {
+		t.Fatalf("Expected NewReedSolomon to succeed, got %v", err)
+	}
+	data := make([]byte, RSDataLength)
+	rand.New(rand.NewSource(1)).Read(data)
+	block, err := rs.Encode(data)
+	if err != nil {
+		t.Fatalf("Expected Encode to succeed, got %v", err)
+	}
+	if len(block) != RSCodewordLength || !bytes.Equal(block[:RSDataLength], data) {
+		t.Errorf("Expected a systematic codeword of 255 octets")
+	}
+
+	// Every codeword has zero syndromes, so decoding changes nothing
+	corrected, count, err := rs.Correct(block)
+	if err != nil || count != 0 || !bytes.Equal(corrected, data) {
+		t.Errorf("Expected a clean codeword to decode unchanged, got %d, %v", count, err)
+	}
+
+	// The code is linear: the sum of codewords is a codeword
+	other := make([]byte, RSDataLength)
+	other[0] = 1
+	otherBlock, _ := rs.Encode(other)
+	for i := range data {
+		data[i] ^= other[i]
+	}
+	sum, _ := rs.Encode(data)
+	for i := range block {
+		if block[i]^otherBlock[i] != sum[i] {
+			t.Fatalf("Expected the code to be linear at %d", i)
+		}
+	}
+
+	for _, length := range []int{0, RSDataLength + 1} {
+		if _, err := rs.Encode(make([]byte, length)); !errors.Is(err, ErrLength) {
+			t.Errorf("Expected %d octets to fail, got %v", length, err)
+		}
+	}
+	for _, depth := range []int{0, MaxInterleave + 1} {
+		if _, err := NewReedSolomon(depth); !errors.Is(err, ErrLength) {
+			t.Errorf("Expected interleaving depth %d to fail, got %v", depth, err)
+		}
+	}
+}
+
+func TestReedSolomon_DualBasis(t *testing.T) {
+	// The inverse transformation matrix of CCSDS 131.0-B, from Berlekamp's
+	// dual basis to the conventional representation
+	inverse := [8]byte{0xc5, 0x42, 0x2e, 0xfd, 0xf0, 0x79, 0xac, 0xcc}
+	for i := 0; i <= 0xff; i++ {
+		var conventional byte
+		for bit, row := range inverse {
+			if i&(0x80>>bit) != 0 {
+				conventional ^= row
+			}
+		}
+		if rsFromDual[i] != conventional || rsToDual[conventional] != byte(i) {
+			t.Fatalf("Expected dual basis symbol %#02x to be %#02x, got %#02x", i, conventional, rsFromDual[i])
+		}
+	}
+	// α^0 is 01111011 in the dual basis
+	if rsToDual[1] != 0x7b {
+		t.Errorf("Expected one to be 0x7b in the dual basis, got %#02x", rsToDual[1])
+	}
+
+	// A codeblock is a codeword once its symbols are converted, and not
+	// before
+	rs, _ := NewReedSolomon(1)
+	data := make([]byte, RSDataLength)
+	rand.New(rand.NewSource(3)).Read(data)
+	block, _ := rs.Encode(data)
+	codeword := make([]byte, len(block))
+	for i, symbol := range block {
+		codeword[i] = rsFromDual[symbol]
+	}
+	if count, err := rsDecode(codeword); count != 0 || err != nil {
+		t.Errorf("Expected the converted codeblock to be a codeword, got %d, %v", count, err)
+	}
+	if count, err := rsDecode(append([]byte(nil), block...)); count == 0 && err == nil {
+		t.Errorf("Expected the unconverted codeblock not to be a codeword")
+	}
+}
+
+func TestReedSolomon_Correct(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	for _, test := range []struct {
+		interleave, length, errors int
+	}{
+		{1, RSDataLength, 1},
+		{1, RSDataLength, 16},
+		{1, 100, 16},
+		{5, 5 * RSDataLength, 16},
+		{4, 40, 10},
+	} {
+		rs, _ := NewReedSolomon(test.interleave)
+		data := make([]byte, test.length)
+		random.Read(data)
+		block, _ := rs.Encode(data)
+
+		// Corrupt distinct symbols of each codeword
+		for i := 0; i < test.interleave; i++ {
+			n := len(block) / test.interleave
+			for _, j := range random.Perm(n)[:test.errors] {
+				block[j*test.interleave+i] ^= byte(1 + random.Intn(255))
+			}
+		}
+		corrected, count, err := rs.Correct(block)
+		if err != nil {
+			t.Errorf("Expected %d errors per codeword at depth %d to be corrected, got %v", test.errors, test.interleave, err)
+			continue
+		}
+		if count != test.errors*test.interleave || !bytes.Equal(corrected, data) {
+			t.Errorf("Expected the data back after %d corrections, got %d", test.errors*test.interleave, count)
+		}
+	}
+}
+
+func TestReedSolomon_CorrectUncorrectable(t *testing.T) {
+	rs, _ := NewReedSolomon(1)
+	data := make([]byte, RSDataLength)
+	block, _ := rs.Encode(data)
+	for i := 0; i < 40; i++ {
+		block[i*6] ^= 0x55
+	}
+	if _, _, err := rs.Correct(block); err != ErrUncorrectable {
+		t.Errorf("Expected 40 errors to be uncorrectable, got %v", err)
+	}
+	if _, _, err := rs.Correct(block[:10]); !errors.Is(err, ErrLength) {
+		t.Errorf("Expected a short codeblock to fail, got %v", err)
+	}
+}
+
+func TestReedSolomon_Interleave(t *testing.T) {
+	// A burst of 80 octets is 16 symbols in each of 5 codewords
+	rs, _ := NewReedSolomon(5)
+	if rs.Interleave() != 5 {
+		t.Errorf("Expected interleaving depth 5, got %d", rs.Interleave())
+	}
+	data := bytes.Repeat([]byte{0xa5}, 5*RSDataLength)
+	block, _ := rs.Encode(data)
+	Burst(block, 300, 80)
+	if decoded, err := rs.Decode(block); err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("Expected the burst to be corrected, got %v", err)
+	}
+
+	plain, _ := NewReedSolomon(1)
+	block, _ = plain.Encode(data[:RSDataLength])
+	Burst(block, 100, 80)
+	if _, err := plain.Decode(block); err == nil {
+		t.Errorf("Expected the burst to defeat a single codeword")
+	}
+}
+
+func BenchmarkReedSolomon_Encode(b *testing.B) {
+	rs, _ := NewReedSolomon(5)
+	data := make([]byte, 5*RSDataLength)
+	rand.New(rand.NewSource(1)).Read(data)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		rs.Encode(data)
+	}
+}
+
+func BenchmarkReedSolomon_Decode(b *testing.B) {
+	rs, _ := NewReedSolomon(5)
+	data := make([]byte, 5*RSDataLength)
+	random := rand.New(rand.NewSource(1))
+	random.Read(data)
+	block, _ := rs.Encode(data)
+	for i := 0; i < 40; i++ {
+		block[random.Intn(len(block))] ^= 0xff
+	}
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		rs.Decode(block)
+	}
+}
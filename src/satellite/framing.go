@@ -0,0 +1,113 @@
+package satellite
+
+import (
+	"errors"
+	"log"
+
+	"github.com/skybridge/satellite/ccsds"
+	"github.com/skybridge/satellite/sdls"
+)
+
+// ErrNoFraming is returned when sending or receiving CCSDS frames without a
+// frame configuration
+var ErrNoFraming = errors.New("no CCSDS frame configuration")
+
+// SetTelemetry configures the TM frames the satellite sends packets in
+func (s *Satellite) SetTelemetry(config ccsds.TMConfig) error {
+	encoder, err := ccsds.NewTMEncoder(config)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.telemetry = encoder
+	s.sequence = ccsds.NewSequenceCounter()
+	return nil
+}
+
+// SendPackets assigns sequence counts to telemetry packets, frames them on
+// a virtual channel and queues the frames for a ground station until the
+// satellite is in contact with it
+func (s *Satellite) SendPackets(station string, virtualChannel uint8, packets ...*ccsds.SpacePacket) error {
+	s.mutex.RLock()
+	encoder, sequence := s.telemetry, s.sequence
+	s.mutex.RUnlock()
+	if encoder == nil {
+		return ErrNoFraming
+	}
+	for _, packet := range packets {
+		sequence.Assign(packet)
+	}
+	frames, err := encoder.Encode(virtualChannel, packets...)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		s.Send(station, frame)
+	}
+	return nil
+}
+
+// SetTelecommand configures the TC frames the satellite accepts and the
+// handler of the telecommand packets they carry
+func (s *Satellite) SetTelecommand(config ccsds.TCConfig, handler func(packet *ccsds.SpacePacket) error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.telecommand = &config
+	s.commandHandler = handler
+}
+
+// SetSecurity secures the telecommand uplink. Once set, the satellite only
+// accepts frames that the endpoint authenticates, and passes their packets
+// to the handler given to SetTelecommand.
+func (s *Satellite) SetSecurity(endpoint *sdls.Endpoint) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.security = endpoint
+}
+
+// SendSecurityReport queues a telemetry packet with the secured uplink's
+// counters of accepted and rejected frames for a ground station
+func (s *Satellite) SendSecurityReport(station string, virtualChannel uint8) error {
+	s.mutex.RLock()
+	security := s.security
+	s.mutex.RUnlock()
+	if security == nil {
+		return ErrNoFraming
+	}
+	return s.SendPackets(station, virtualChannel, security.Report())
+}
+
+// ReceiveTelecommand receives a TC frame from a ground station
+func (s *Satellite) ReceiveTelecommand(data []byte) error {
+	s.mutex.RLock()
+	config, security, handler := s.telecommand, s.security, s.commandHandler
+	s.mutex.RUnlock()
+
+	var frame *ccsds.TCFrame
+	var packets []*ccsds.SpacePacket
+	var err error
+	switch {
+	case security != nil:
+		frame, packets, err = security.Process(data)
+	case config != nil:
+		frame, packets, err = ccsds.DecodeTC(data, *config)
+	default:
+		return ErrNoFraming
+	}
+	if err != nil {
+		log.Printf("Failed to decode telecommand at satellite %s: %v", s.id, err)
+		return err
+	}
+	log.Printf("Received telecommand frame %d on virtual channel %d with %d packets",
+		frame.Sequence, frame.VirtualChannel, len(packets))
+	if handler == nil {
+		return nil
+	}
+	for _, packet := range packets {
+		if err := handler(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package satellite
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skybridge/satellite/ccsds"
+	"github.com/skybridge/satellite/sdls"
+)
+
+func TestSatellite_SendPackets(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	plan := NewContactPlan([]Contact{{Station: "gs1", Start: start, End: start.Add(time.Hour)}})
+	satellite, _, _, _ := newSchedulerTest(start, plan)
+
+	packet := ccsds.NewSpacePacket(ccsds.Telemetry, 10, []byte("battery 98%"))
+	if err := satellite.SendPackets("gs1", 0, packet); err != ErrNoFraming {
+		t.Errorf("Expected sending packets without framing to fail, got %v", err)
+	}
+
+	config := ccsds.TMConfig{SpacecraftID: 77, FrameLength: 64, FECF: true, SecondaryHeaderLength: ccsds.CUCLength}
+	if err := satellite.SetTelemetry(config); err != nil {
+		t.Fatalf("Expected SetTelemetry to succeed, got %v", err)
+	}
+	station := satellite.groundStation("gs1")
+	station.Telemetry = ccsds.NewTMDecoder(config)
+	var received []*ccsds.SpacePacket
+	station.PacketHandler = func(packet *ccsds.SpacePacket) error {
+		received = append(received, packet)
+		return nil
+	}
+
+	packet.SecondaryHeader = ccsds.EncodeCUC(start)
+	long := ccsds.NewSpacePacket(ccsds.Telemetry, 11, make([]byte, 100))
+	if err := satellite.SendPackets("gs1", 1, packet, long); err != nil {
+		t.Fatalf("Expected SendPackets to succeed, got %v", err)
+	}
+	if queued := satellite.Scheduler().Queued("gs1"); queued < 2 {
+		t.Errorf("Expected the packets to be framed across frames, got %d", queued)
+	}
+	satellite.Scheduler().Tick()
+
+	if len(received) != 2 {
+		t.Fatalf("Expected both packets at the station, got %d", len(received))
+	}
+	if string(received[0].Data) != "battery 98%" || received[0].APID != 10 || received[1].APID != 11 {
+		t.Errorf("Expected the packets in order, got %+v", received)
+	}
+	if stamp, err := ccsds.DecodeCUC(received[0].SecondaryHeader); err != nil || !stamp.Equal(start) {
+		t.Errorf("Expected the time code secondary header, got %v, %v", stamp, err)
+	}
+
+	// Each APID counts its own packets
+	if err := satellite.SendPackets("gs1", 1, ccsds.NewSpacePacket(ccsds.Telemetry, 10, []byte("again"))); err != nil {
+		t.Fatalf("Expected SendPackets to succeed, got %v", err)
+	}
+	satellite.Scheduler().Tick()
+	if len(received) != 3 || received[2].SequenceCount != 1 {
+		t.Errorf("Expected the second packet of APID 10 to have count 1, got %+v", received)
+	}
+
+	if err := satellite.SetTelemetry(ccsds.TMConfig{SpacecraftID: 5000}); err == nil {
+		t.Errorf("Expected an invalid configuration to fail")
+	}
+}
+
+func TestGroundStation_ReceiveDataFrame(t *testing.T) {
+	station := NewGroundStation("gs1")
+	station.Telemetry = ccsds.NewTMDecoder(ccsds.TMConfig{FECF: true})
+	if err := station.ReceiveData([]byte("not a frame")); !errors.Is(err, ccsds.ErrFrameCRC) {
+		t.Errorf("Expected raw data to fail as a frame, got %v", err)
+	}
+
+	handled := errors.New("handled")
+	station.PacketHandler = func(packet *ccsds.SpacePacket) error {
+		return handled
+	}
+	encoder, _ := ccsds.NewTMEncoder(ccsds.TMConfig{FECF: true})
+	frames, _ := encoder.Encode(0, ccsds.NewSpacePacket(ccsds.Telemetry, 1, []byte{1}))
+	if err := station.ReceiveData(frames[0]); err != handled {
+		t.Errorf("Expected the packet handler's error, got %v", err)
+	}
+}
+
+func TestGroundStation_SendTelecommand(t *testing.T) {
+	satellite := NewSatellite("sat1")
+	station := NewGroundStation("gs1")
+	command := ccsds.NewSpacePacket(ccsds.Telecommand, 20, []byte("deploy panels"))
+
+	if err := station.SendTelecommand(satellite, 0, command); err != ErrNoFraming {
+		t.Errorf("Expected sending without framing to fail, got %v", err)
+	}
+	config := ccsds.TCConfig{SpacecraftID: 77, FECF: true}
+	station.Telecommand, _ = ccsds.NewTCEncoder(config)
+	if err := station.SendTelecommand(satellite, 0, command); err != ErrNoFraming {
+		t.Errorf("Expected a satellite without framing to refuse, got %v", err)
+	}
+
+	var commands []string
+	satellite.SetTelecommand(config, func(packet *ccsds.SpacePacket) error {
+		commands = append(commands, string(packet.Data))
+		return nil
+	})
+	if err := station.SendTelecommand(satellite, 0, command); err != nil {
+		t.Fatalf("Expected SendTelecommand to succeed, got %v", err)
+	}
+	if len(commands) != 1 || commands[0] != "deploy panels" {
+		t.Errorf("Expected the satellite to handle the command, got %v", commands)
+	}
+
+	satellite.SetTelecommand(ccsds.TCConfig{SpacecraftID: 78, FECF: true}, nil)
+	if err := station.SendTelecommand(satellite, 0, command); !errors.Is(err, ccsds.ErrWrongSpacecraft) {
+		t.Errorf("Expected another spacecraft's command to fail, got %v", err)
+	}
+}
+
+func TestGroundStation_SendSecureTelecommand(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	config := sdls.Config{SpacecraftID: 77, FECF: true}
+	newEndpoint := func() *sdls.Endpoint {
+		config := config
+		config.StatePath = filepath.Join(t.TempDir(), "sdls.json")
+		endpoint := sdls.NewEndpoint(config)
+		if err := endpoint.Load(); err != nil {
+			t.Fatalf("Expected Load to succeed, got %v", err)
+		}
+		if err := endpoint.AddKey(1, key); err != nil {
+			t.Fatalf("Expected AddKey to succeed, got %v", err)
+		}
+		if err := endpoint.AddSecurityAssociation(1, 0, 1); err != nil {
+			t.Fatalf("Expected AddSecurityAssociation to succeed, got %v", err)
+		}
+		return endpoint
+	}
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	plan := NewContactPlan([]Contact{{Station: "gs1", Start: start, End: start.Add(time.Hour)}})
+	satellite, _, _, _ := newSchedulerTest(start, plan)
+	station := satellite.groundStation("gs1")
+	command := ccsds.NewSpacePacket(ccsds.Telecommand, 20, []byte("deploy panels"))
+	if err := station.SendSecureTelecommand(satellite, 1, command); err != ErrNoFraming {
+		t.Errorf("Expected sending without security to fail, got %v", err)
+	}
+	if err := satellite.SendSecurityReport("gs1", 0); err != ErrNoFraming {
+		t.Errorf("Expected a report without security to fail, got %v", err)
+	}
+
+	var commands []string
+	satellite.SetTelecommand(ccsds.TCConfig{SpacecraftID: 77, FECF: true}, func(packet *ccsds.SpacePacket) error {
+		commands = append(commands, string(packet.Data))
+		return nil
+	})
+	satellite.SetSecurity(newEndpoint())
+	station.Security = newEndpoint()
+	if err := station.SendSecureTelecommand(satellite, 1, command); err != nil {
+		t.Fatalf("Expected SendSecureTelecommand to succeed, got %v", err)
+	}
+	if len(commands) != 1 || commands[0] != "deploy panels" {
+		t.Errorf("Expected the satellite to handle the command, got %v", commands)
+	}
+
+	// The unsecured path no longer reaches the satellite
+	station.Telecommand, _ = ccsds.NewTCEncoder(ccsds.TCConfig{SpacecraftID: 77, FECF: true})
+	if err := station.SendTelecommand(satellite, 0, command); !errors.Is(err, sdls.ErrMalformed) {
+		t.Errorf("Expected an unsecured command to be rejected, got %v", err)
+	}
+	if len(commands) != 1 {
+		t.Errorf("Expected the unsecured command not to be handled")
+	}
+
+	// The rejection is reported in telemetry
+	tm := ccsds.TMConfig{SpacecraftID: 77}
+	if err := satellite.SetTelemetry(tm); err != nil {
+		t.Fatalf("Expected SetTelemetry to succeed, got %v", err)
+	}
+	station.Telemetry = ccsds.NewTMDecoder(tm)
+	var reports []sdls.Report
+	station.PacketHandler = func(packet *ccsds.SpacePacket) error {
+		report, err := sdls.DecodeReport(packet)
+		reports = append(reports, report)
+		return err
+	}
+	if err := satellite.SendSecurityReport("gs1", 0); err != nil {
+		t.Fatalf("Expected SendSecurityReport to succeed, got %v", err)
+	}
+	satellite.Scheduler().Tick()
+	if len(reports) != 1 || reports[0].Accepted != 1 || reports[0].Malformed != 1 {
+		t.Errorf("Expected a report of one accepted and one rejected frame, got %+v", reports)
+	}
+}
@@ -0,0 +1,260 @@
+package satellite
+
+import (
+	"log"
+	"math"
+	"sort"
+
+	"github.com/skybridge/satellite/ccsds"
+	"github.com/skybridge/satellite/sdls"
+)
+
+// GroundStation represents a ground station
+type GroundStation struct {
+	id string
+
+	// Location is the geodetic location of the antenna
+	Location Geodetic
+
+	// ElevationMask is the minimum elevation in degrees at which the
+	// station can track a satellite
+	ElevationMask float64
+
+	// Horizon is the terrain profile around the station, sorted by azimuth.
+	// Between points the horizon elevation is interpolated linearly,
+	// wrapping around north. A satellite is only visible above both the
+	// horizon and the elevation mask.
+	Horizon []HorizonPoint
+
+	// Handler, if set, is called with the data the station receives from
+	// the satellite
+	Handler func(data []byte) error
+
+	// Telemetry, if set, decodes the data the station receives as TM
+	// transfer frames, passing the packets they carry to PacketHandler
+	// instead of calling Handler
+	Telemetry *ccsds.TMDecoder
+
+	// PacketHandler, if set, is called with each telemetry packet
+	PacketHandler func(packet *ccsds.SpacePacket) error
+
+	// Telecommand, if set, frames the packets the station sends with
+	// SendTelecommand
+	Telecommand *ccsds.TCEncoder
+
+	// Security, if set, protects the packets the station sends with
+	// SendSecureTelecommand
+	Security *sdls.Endpoint
+}
+
+// HorizonPoint is the elevation of the local horizon at an azimuth
+type HorizonPoint struct {
+	// Azimuth is the azimuth in degrees clockwise from north
+	Azimuth float64 `json:"azimuth"`
+
+	// Elevation is the elevation of the horizon in degrees
+	Elevation float64 `json:"elevation"`
+}
+
+// LookAngles is the direction and distance from a ground station to a
+// satellite
+type LookAngles struct {
+	// Azimuth is the azimuth in degrees clockwise from north
+	Azimuth float64 `json:"azimuth"`
+
+	// Elevation is the elevation in degrees above the local horizontal
+	Elevation float64 `json:"elevation"`
+
+	// Range is the distance to the satellite in kilometres
+	Range float64 `json:"range"`
+
+	// RangeRate is the rate of change of the range in kilometres per
+	// second, positive when the satellite is moving away
+	RangeRate float64 `json:"rangeRate"`
+}
+
+// NewGroundStation returns a new GroundStation instance
+func NewGroundStation(id string) *GroundStation {
+	return &GroundStation{
+		id: id,
+	}
+}
+
+// NewGroundStationAt returns a new GroundStation at a location with an
+// elevation mask in degrees
+func NewGroundStationAt(id string, location Geodetic, elevationMask float64) *GroundStation {
+	return &GroundStation{
+		id:            id,
+		Location:      location,
+		ElevationMask: elevationMask,
+	}
+}
+
+// ID returns the ground station's id
+func (g *GroundStation) ID() string {
+	return g.id
+}
+
+// SetHorizon sets the horizon profile, sorting it by azimuth
+func (g *GroundStation) SetHorizon(horizon []HorizonPoint) {
+	points := make([]HorizonPoint, len(horizon))
+	for i, point := range horizon {
+		point.Azimuth = normalizeAzimuth(point.Azimuth)
+		points[i] = point
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Azimuth < points[j].Azimuth
+	})
+	g.Horizon = points
+}
+
+// Position returns the station's position in the Earth-fixed frame
+func (g *GroundStation) Position() Vector {
+	return GeodeticToECEF(g.Location)
+}
+
+// Look returns the look angles from the station to a satellite with the
+// given Earth-fixed position and velocity
+func (g *GroundStation) Look(position, velocity Vector) LookAngles {
+	lat := g.Location.Latitude * deg2rad
+	lon := g.Location.Longitude * deg2rad
+	sinLat, cosLat := math.Sincos(lat)
+	sinLon, cosLon := math.Sincos(lon)
+
+	r := position.Sub(g.Position())
+	east := -sinLon*r.X + cosLon*r.Y
+	north := -sinLat*cosLon*r.X - sinLat*sinLon*r.Y + cosLat*r.Z
+	up := cosLat*cosLon*r.X + cosLat*sinLon*r.Y + sinLat*r.Z
+
+	distance := r.Norm()
+	look := LookAngles{
+		Azimuth: normalizeAzimuth(math.Atan2(east, north) / deg2rad),
+		Range:   distance,
+	}
+	if distance > 0 {
+		// Clamped, as rounding can put a satellite at the zenith just over
+		look.Elevation = math.Asin(math.Max(-1, math.Min(1, up/distance))) / deg2rad
+		look.RangeRate = r.Dot(velocity) / distance
+	}
+	return look
+}
+
+// MinimumElevation returns the lowest elevation in degrees at which a
+// satellite at an azimuth is visible
+func (g *GroundStation) MinimumElevation(azimuth float64) float64 {
+	horizon := g.horizonAt(normalizeAzimuth(azimuth))
+	if horizon > g.ElevationMask {
+		return horizon
+	}
+	return g.ElevationMask
+}
+
+// Visible returns whether a satellite at the look angles is visible
+func (g *GroundStation) Visible(look LookAngles) bool {
+	return look.Elevation >= g.MinimumElevation(look.Azimuth)
+}
+
+// horizonAt interpolates the horizon profile at an azimuth
+func (g *GroundStation) horizonAt(azimuth float64) float64 {
+	n := len(g.Horizon)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	if n == 1 {
+		return g.Horizon[0].Elevation
+	}
+	i := sort.Search(n, func(i int) bool {
+		return g.Horizon[i].Azimuth >= azimuth
+	})
+	// Interpolate between the points either side, wrapping around north
+	before := g.Horizon[(i+n-1)%n]
+	after := g.Horizon[i%n]
+	span := after.Azimuth - before.Azimuth
+	offset := azimuth - before.Azimuth
+	if span <= 0 {
+		span += 360
+	}
+	if offset < 0 {
+		offset += 360
+	}
+	return before.Elevation + (after.Elevation-before.Elevation)*offset/span
+}
+
+// ReceiveData receives data from the satellite
+func (g *GroundStation) ReceiveData(data []byte) error {
+	if g.Telemetry != nil {
+		return g.receiveFrame(data)
+	}
+	log.Printf("Received data from satellite: %s", data)
+	if g.Handler != nil {
+		return g.Handler(data)
+	}
+	return nil
+}
+
+// receiveFrame decodes a TM frame and handles the packets it completes
+func (g *GroundStation) receiveFrame(data []byte) error {
+	frame, packets, err := g.Telemetry.Decode(data)
+	if err != nil {
+		log.Printf("Failed to decode frame at ground station %s: %v", g.id, err)
+		return err
+	}
+	log.Printf("Received frame %d on virtual channel %d from spacecraft %d with %d packets",
+		frame.VirtualFrameCount, frame.VirtualChannel, frame.SpacecraftID, len(packets))
+	if g.PacketHandler == nil {
+		return nil
+	}
+	for _, packet := range packets {
+		if err := g.PacketHandler(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendTelecommand frames telecommand packets on a virtual channel and sends
+// them to a satellite, through the satellite's channel if it has one. Like
+// downlinked data, frames lost or rejected on a channel are not reported.
+func (g *GroundStation) SendTelecommand(satellite *Satellite, virtualChannel uint8, packets ...*ccsds.SpacePacket) error {
+	if g.Telecommand == nil {
+		return ErrNoFraming
+	}
+	frames, err := g.Telecommand.Encode(virtualChannel, false, packets...)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if err := satellite.uplink(g, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendSecureTelecommand protects telecommand packets with a security
+// association and sends them to a satellite, through the satellite's channel
+// if it has one
+func (g *GroundStation) SendSecureTelecommand(satellite *Satellite, spi uint16, packets ...*ccsds.SpacePacket) error {
+	if g.Security == nil {
+		return ErrNoFraming
+	}
+	frames, err := g.Security.Protect(spi, packets...)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if err := satellite.uplink(g, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeAzimuth returns an azimuth in [0, 360)
+func normalizeAzimuth(azimuth float64) float64 {
+	azimuth = math.Mod(azimuth, 360)
+	if azimuth < 0 {
+		azimuth += 360
+	}
+	return azimuth
+}
@@ -0,0 +1,76 @@
+package satellite
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGroundStation_Look(t *testing.T) {
+	station := NewGroundStationAt("equator", Geodetic{}, 5)
+	if station.ID() != "equator" {
+		t.Errorf("Expected the id to be equator, got %s", station.ID())
+	}
+
+	overhead := station.Look(Vector{EarthRadius + 500, 0, 0}, Vector{1, 0, 0})
+	if math.Abs(overhead.Elevation-90) > 1e-9 || math.Abs(overhead.Range-500) > 1e-9 || math.Abs(overhead.RangeRate-1) > 1e-9 {
+		t.Errorf("Expected a satellite overhead at 500 km moving away, got %+v", overhead)
+	}
+
+	// Rounding must not push a satellite at the zenith past it
+	for latitude := -80.0; latitude <= 80; latitude += 0.37 {
+		location := Geodetic{Latitude: latitude, Longitude: latitude * 2.3}
+		above := GeodeticToECEF(Geodetic{Latitude: location.Latitude, Longitude: location.Longitude, Altitude: 3857})
+		look := NewGroundStationAt("zenith", location, 5).Look(above, Vector{})
+		if math.IsNaN(look.Elevation) || math.Abs(look.Elevation-90) > 1e-5 {
+			t.Errorf("Expected a satellite at the zenith at latitude %v, got %+v", latitude, look)
+		}
+	}
+
+	cases := map[float64]Vector{
+		0:   {EarthRadius, 0, 1000},
+		90:  {EarthRadius, 1000, 0},
+		180: {EarthRadius, 0, -1000},
+		270: {EarthRadius, -1000, 0},
+	}
+	for azimuth, position := range cases {
+		look := station.Look(position, Vector{})
+		if math.Abs(look.Azimuth-azimuth) > 1e-9 || math.Abs(look.Elevation) > 1e-9 {
+			t.Errorf("Expected a satellite on the horizon at azimuth %v, got %+v", azimuth, look)
+		}
+		if station.Visible(look) {
+			t.Errorf("Expected a satellite on the horizon to be below the mask")
+		}
+	}
+}
+
+func TestGroundStation_Horizon(t *testing.T) {
+	station := NewGroundStationAt("valley", Geodetic{Latitude: 46.5, Longitude: 7.9, Altitude: 1}, 5)
+	station.SetHorizon([]HorizonPoint{
+		{Azimuth: 270, Elevation: 20},
+		{Azimuth: 90, Elevation: 0},
+		{Azimuth: -10, Elevation: 30},
+	})
+	if station.Horizon[0].Azimuth != 90 || station.Horizon[2].Azimuth != 350 {
+		t.Fatalf("Expected the horizon to be normalized and sorted, got %+v", station.Horizon)
+	}
+
+	cases := map[float64]float64{
+		90:  5,
+		180: 10,
+		270: 20,
+		310: 25,
+		350: 30,
+		0:   27,
+		40:  15,
+		60:  9,
+	}
+	for azimuth, expected := range cases {
+		if minimum := station.MinimumElevation(azimuth); math.Abs(minimum-expected) > 1e-9 {
+			t.Errorf("Expected the minimum elevation at azimuth %v to be %v, got %v", azimuth, expected, minimum)
+		}
+	}
+
+	if !station.Visible(LookAngles{Azimuth: 180, Elevation: 10}) || station.Visible(LookAngles{Azimuth: 350, Elevation: 29}) {
+		t.Errorf("Expected visibility to follow the horizon profile")
+	}
+}
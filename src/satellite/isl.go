@@ -0,0 +1,198 @@
+package satellite
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultGrazingAltitude is the height in kilometres above the Earth's
+// surface that the line of sight of an inter-satellite link must clear when
+// none is given, keeping the beam out of the denser atmosphere
+const DefaultGrazingAltitude = 100.0
+
+// InterSatelliteLink is a radio or optical link between two satellites. The
+// link is usable while the satellites are within range and the Earth does
+// not block the line of sight between them.
+type InterSatelliteLink struct {
+	a, b *Satellite
+
+	// MaxRange is the longest distance in kilometres the link closes over,
+	// or zero for no limit
+	MaxRange float64
+
+	// GrazingAltitude is the height in kilometres above the Earth's surface
+	// the line of sight must clear, DefaultGrazingAltitude if zero
+	GrazingAltitude float64
+}
+
+// NewInterSatelliteLink returns a new InterSatelliteLink instance between
+// two satellites with a maximum range in kilometres
+func NewInterSatelliteLink(a, b *Satellite, maxRange float64) *InterSatelliteLink {
+	return &InterSatelliteLink{
+		a:        a,
+		b:        b,
+		MaxRange: maxRange,
+	}
+}
+
+// Satellites returns the satellites at each end of the link
+func (l *InterSatelliteLink) Satellites() (*Satellite, *Satellite) {
+	return l.a, l.b
+}
+
+// Peer returns the satellite at the other end of the link from s, or nil if
+// s is at neither end
+func (l *InterSatelliteLink) Peer(s *Satellite) *Satellite {
+	switch s {
+	case l.a:
+		return l.b
+	case l.b:
+		return l.a
+	}
+	return nil
+}
+
+// Range returns the distance in kilometres between the satellites at t
+func (l *InterSatelliteLink) Range(t time.Time) (float64, error) {
+	a, b, err := l.positions(t)
+	if err != nil {
+		return 0, err
+	}
+	return b.Sub(a).Norm(), nil
+}
+
+// Visible returns whether the link can be used at t
+func (l *InterSatelliteLink) Visible(t time.Time) (bool, error) {
+	point, err := l.sample(t)
+	return point.visible, err
+}
+
+// Windows returns the windows between start and end in which the link can
+// be used, from the first satellite to the second. Like Passes, the orbits
+// are sampled every step, or DefaultPassStep if step is zero, and windows in
+// progress at start or end are cut short at those times.
+func (l *InterSatelliteLink) Windows(start, end time.Time, step time.Duration) ([]ContactWindow, error) {
+	if step <= 0 {
+		step = DefaultPassStep
+	}
+
+	var windows []ContactWindow
+	var current *ContactWindow
+	var previous linkSample
+	for t := start; !t.After(end); t = t.Add(step) {
+		point, err := l.sample(t)
+		if err != nil {
+			return windows, err
+		}
+
+		switch {
+		case point.visible && current == nil:
+			current = &ContactWindow{From: l.a.id, To: l.b.id, Start: start}
+			if t.After(start) {
+				edge, err := l.crossing(point, previous.time)
+				if err != nil {
+					return windows, err
+				}
+				current.Start = edge.time
+				current.Range = edge.rangeKm
+			}
+		case !point.visible && current != nil:
+			edge, err := l.crossing(previous, t)
+			if err != nil {
+				return windows, err
+			}
+			current.End = edge.time
+			current.Range = math.Max(current.Range, edge.rangeKm)
+			windows = append(windows, *current)
+			current = nil
+		}
+		if point.visible {
+			current.Range = math.Max(current.Range, point.rangeKm)
+		}
+		previous = point
+
+		// Always sample the end of the range
+		if t.Before(end) && t.Add(step).After(end) {
+			step = end.Sub(t)
+		}
+	}
+
+	if current != nil {
+		current.End = end
+		windows = append(windows, *current)
+	}
+	return windows, nil
+}
+
+// linkSample is the geometry of a link at a time
+type linkSample struct {
+	time    time.Time
+	rangeKm float64
+	visible bool
+}
+
+// positions returns the positions of the satellites in the TEME frame at t
+func (l *InterSatelliteLink) positions(t time.Time) (Vector, Vector, error) {
+	a, err := l.a.Position(t)
+	if err != nil {
+		return Vector{}, Vector{}, err
+	}
+	b, err := l.b.Position(t)
+	if err != nil {
+		return Vector{}, Vector{}, err
+	}
+	return a.Position, b.Position, nil
+}
+
+// sample returns the range and visibility of the link at t
+func (l *InterSatelliteLink) sample(t time.Time) (linkSample, error) {
+	a, b, err := l.positions(t)
+	if err != nil {
+		return linkSample{time: t}, err
+	}
+	grazing := l.GrazingAltitude
+	if grazing == 0 {
+		grazing = DefaultGrazingAltitude
+	}
+	point := linkSample{time: t, rangeKm: b.Sub(a).Norm()}
+	point.visible = (l.MaxRange <= 0 || point.rangeKm <= l.MaxRange) &&
+		lineOfSightClearance(a, b) >= EarthRadius+grazing
+	return point, nil
+}
+
+// crossing bisects between a visible sample and a time at which the link is
+// not usable, returning the visible sample closest to the change
+func (l *InterSatelliteLink) crossing(visible linkSample, hidden time.Time) (linkSample, error) {
+	for {
+		gap := hidden.Sub(visible.time)
+		if gap <= passTolerance && gap >= -passTolerance {
+			return visible, nil
+		}
+		point, err := l.sample(visible.time.Add(gap / 2))
+		if err != nil {
+			return point, err
+		}
+		if point.visible {
+			visible = point
+		} else {
+			hidden = point.time
+		}
+	}
+}
+
+// lineOfSightClearance returns the closest distance in kilometres from the
+// centre of the Earth to the segment between two positions
+func lineOfSightClearance(a, b Vector) float64 {
+	d := b.Sub(a)
+	length := d.Dot(d)
+	if length == 0 {
+		return a.Norm()
+	}
+	fraction := -a.Dot(d) / length
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	return a.Add(d.Scale(fraction)).Norm()
+}
@@ -0,0 +1,151 @@
+package satellite
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// newTestSatelliteAt returns a satellite on the Vanguard 1 orbit shifted by
+// a mean anomaly in degrees
+func newTestSatelliteAt(t *testing.T, id string, anomaly float64) *Satellite {
+	line2 := withChecksum(fmt.Sprintf("%s%8.4f%s", vanguardLine2[:43], math.Mod(19.3264+anomaly, 360), vanguardLine2[51:68]))
+	tle, err := ParseTLE(id, vanguardLine1, line2)
+	if err != nil {
+		t.Fatalf("Expected ParseTLE to succeed, got %v", err)
+	}
+	satellite := NewSatellite(id)
+	if err := satellite.SetTLE(tle); err != nil {
+		t.Fatalf("Expected SetTLE to succeed, got %v", err)
+	}
+	return satellite
+}
+
+func TestInterSatelliteLink_Peer(t *testing.T) {
+	a := NewSatellite("a")
+	b := NewSatellite("b")
+	link := a.AddInterSatelliteLink(b, 1000)
+
+	if link.Peer(a) != b || link.Peer(b) != a || link.Peer(NewSatellite("c")) != nil {
+		t.Errorf("Expected Peer to return the other end of the link")
+	}
+	if first, second := link.Satellites(); first != a || second != b {
+		t.Errorf("Expected Satellites to return both ends")
+	}
+	if len(a.GetInterSatelliteLinks()) != 1 || len(b.GetInterSatelliteLinks()) != 1 {
+		t.Errorf("Expected the link to be added to both satellites")
+	}
+	if _, err := link.Range(time.Now()); err != ErrNoTLE {
+		t.Errorf("Expected Range to fail without a TLE, got %v", err)
+	}
+}
+
+func TestInterSatelliteLink_Visible(t *testing.T) {
+	leader := newTestSatelliteAt(t, "leader", 0)
+	epoch := leader.TLE().Epoch
+
+	// A satellite a few degrees behind on the same orbit is always in view
+	nearby := NewInterSatelliteLink(leader, newTestSatelliteAt(t, "nearby", -5), 0)
+	if visible, err := nearby.Visible(epoch); err != nil || !visible {
+		t.Errorf("Expected a nearby satellite to be visible, got %v, %v", visible, err)
+	}
+	rangeKm, err := nearby.Range(epoch)
+	if err != nil || rangeKm <= 0 || rangeKm > 2000 {
+		t.Errorf("Expected a short range, got %f, %v", rangeKm, err)
+	}
+
+	// The maximum range limits the link
+	nearby.MaxRange = rangeKm / 2
+	if visible, _ := nearby.Visible(epoch); visible {
+		t.Errorf("Expected the link to be out of range")
+	}
+
+	// The Earth blocks a satellite on the other side of the orbit
+	opposite := NewInterSatelliteLink(leader, newTestSatelliteAt(t, "opposite", 180), 0)
+	if visible, err := opposite.Visible(epoch); err != nil || visible {
+		t.Errorf("Expected the Earth to block the link, got %v, %v", visible, err)
+	}
+}
+
+func TestInterSatelliteLink_Windows(t *testing.T) {
+	leader := newTestSatelliteAt(t, "leader", 0)
+	follower := newTestSatelliteAt(t, "follower", -60)
+	link := NewInterSatelliteLink(leader, follower, 0)
+	start := leader.TLE().Epoch
+	end := start.Add(12 * time.Hour)
+
+	windows, err := link.Windows(start, end, 0)
+	if err != nil {
+		t.Fatalf("Expected Windows to succeed, got %v", err)
+	}
+	if len(windows) < 2 {
+		t.Fatalf("Expected the eccentric orbit to open and nearby the link, got %d windows", len(windows))
+	}
+
+	var total time.Duration
+	var longest float64
+	for i, window := range windows {
+		if window.From != "leader" || window.To != "follower" {
+			t.Errorf("Expected the window to name the satellites, got %+v", window)
+		}
+		if !window.End.After(window.Start) || window.Start.Before(start) || window.End.After(end) {
+			t.Errorf("Expected window %d to be within the range, got %v to %v", i, window.Start, window.End)
+		}
+		if i > 0 && !window.Start.After(windows[i-1].End) {
+			t.Errorf("Expected windows to be in order")
+		}
+		middle := window.Start.Add(window.End.Sub(window.Start) / 2)
+		if visible, _ := link.Visible(middle); !visible {
+			t.Errorf("Expected the link to be visible during window %d", i)
+		}
+		if rangeKm, _ := link.Range(middle); rangeKm > window.Range {
+			t.Errorf("Expected the window range to bound the range, got %f > %f", rangeKm, window.Range)
+		}
+		for _, at := range []time.Time{window.Start.Add(-time.Second), window.End.Add(time.Second)} {
+			if at.Before(start) || at.After(end) {
+				continue
+			}
+			if visible, _ := link.Visible(at); visible {
+				t.Errorf("Expected the link to be blocked outside the window at %v", at)
+			}
+		}
+		total += window.End.Sub(window.Start)
+		longest = math.Max(longest, window.Range)
+	}
+	if total <= 0 || total >= end.Sub(start) {
+		t.Errorf("Expected the link to be usable for part of the range, got %v", total)
+	}
+
+	// A range limit shortens the windows
+	link.MaxRange = longest - 500
+	limited, err := link.Windows(start, end, 0)
+	if err != nil {
+		t.Fatalf("Expected Windows to succeed, got %v", err)
+	}
+	var limitedTotal time.Duration
+	for _, window := range limited {
+		limitedTotal += window.End.Sub(window.Start)
+		if window.Range > link.MaxRange {
+			t.Errorf("Expected the range to stay within the limit, got %f", window.Range)
+		}
+	}
+	if limitedTotal >= total {
+		t.Errorf("Expected a range limit to reduce the usable time, got %v and %v", total, limitedTotal)
+	}
+}
+
+func TestLineOfSightClearance(t *testing.T) {
+	a := Vector{X: 7000, Y: 7000}
+	b := Vector{X: 7000, Y: -7000}
+	if clearance := lineOfSightClearance(a, b); math.Abs(clearance-7000) > 1e-9 {
+		t.Errorf("Expected the closest point to be mid-segment, got %f", clearance)
+	}
+	c := Vector{X: 8000}
+	if clearance := lineOfSightClearance(Vector{X: 7000}, c); clearance != 7000 {
+		t.Errorf("Expected the closest point to be an end, got %f", clearance)
+	}
+	if clearance := lineOfSightClearance(c, c); clearance != 8000 {
+		t.Errorf("Expected the distance to a point, got %f", clearance)
+	}
+}
@@ -0,0 +1,194 @@
+package satellite
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// SpeedOfLight is the speed of light in metres per second
+	SpeedOfLight = 299792458.0
+
+	// boltzmann is Boltzmann's constant in dBW/K/Hz
+	boltzmann = -228.5991672
+
+	// minimumPathElevation is the elevation in degrees below which the
+	// atmospheric path length is no longer increased
+	minimumPathElevation = 3.0
+)
+
+// zenithAttenuation is the clear sky gaseous attenuation at zenith in dB by
+// frequency in GHz, approximating ITU-R P.676 for a standard atmosphere at
+// sea level
+var zenithAttenuation = []struct {
+	frequency   float64
+	attenuation float64
+}{
+	{1, 0.035},
+	{2, 0.038},
+	{4, 0.043},
+	{8, 0.060},
+	{12, 0.085},
+	{18, 0.20},
+	{22.2, 0.60},
+	{26, 0.35},
+	{30, 0.33},
+	{40, 0.45},
+}
+
+// PathLoss returns the free-space path loss in dB over a range in
+// kilometres at a frequency in Hz
+func PathLoss(rangeKm, frequency float64) float64 {
+	return 20 * math.Log10(4*math.Pi*rangeKm*1000*frequency/SpeedOfLight)
+}
+
+// AtmosphericLoss returns the clear sky attenuation in dB at a frequency in
+// Hz along a path at an elevation in degrees
+func AtmosphericLoss(frequency, elevation float64) float64 {
+	ghz := frequency / 1e9
+	zenith := zenithAttenuation[0].attenuation
+	for i, point := range zenithAttenuation {
+		if ghz <= point.frequency {
+			if i > 0 {
+				previous := zenithAttenuation[i-1]
+				fraction := (ghz - previous.frequency) / (point.frequency - previous.frequency)
+				zenith = previous.attenuation + fraction*(point.attenuation-previous.attenuation)
+			}
+			break
+		}
+		zenith = point.attenuation
+	}
+	return zenith * pathFactor(elevation)
+}
+
+// pathFactor returns the length of an atmospheric path at an elevation in
+// degrees relative to the zenith path
+func pathFactor(elevation float64) float64 {
+	if elevation < minimumPathElevation {
+		elevation = minimumPathElevation
+	}
+	return 1 / math.Sin(elevation*deg2rad)
+}
+
+// RadioLink is a one-way radio link between two transceivers
+type RadioLink struct {
+	// Transmitter sets the frequency, power, symbol rate and schemes
+	Transmitter *Transceiver
+
+	// Receiver sets the receiving antenna gain and noise temperature
+	Receiver *Transceiver
+
+	// Margin is the Eb/N0 in dB required above a scheme's threshold
+	Margin float64
+
+	// RainAttenuation is the additional attenuation at zenith in dB allowed
+	// for rain, scaled with the path length like the clear sky loss
+	RainAttenuation float64
+}
+
+// LinkBudget is the budget of a radio link at one point in a pass
+type LinkBudget struct {
+	// Time is the time of the budget
+	Time time.Time `json:"time"`
+
+	// Range is the slant range in kilometres
+	Range float64 `json:"range"`
+
+	// Elevation is the elevation in degrees
+	Elevation float64 `json:"elevation"`
+
+	// EIRP is the transmitter's EIRP in dBW
+	EIRP float64 `json:"eirp"`
+
+	// PathLoss is the free-space path loss in dB
+	PathLoss float64 `json:"pathLoss"`
+
+	// AtmosphericLoss is the clear sky and rain attenuation in dB
+	AtmosphericLoss float64 `json:"atmosphericLoss"`
+
+	// FigureOfMerit is the receiver's G/T in dB/K
+	FigureOfMerit float64 `json:"figureOfMerit"`
+
+	// CN0 is the carrier to noise density ratio in dBHz
+	CN0 float64 `json:"cn0"`
+
+	// ModCod is the most efficient scheme that closes the link, or empty if
+	// none does
+	ModCod string `json:"modcod,omitempty"`
+
+	// EbN0 is the energy per bit to noise density ratio in dB at the
+	// selected scheme's data rate, or the first scheme's if none closes the
+	// link
+	EbN0 float64 `json:"ebn0"`
+
+	// Margin is EbN0 less the scheme's threshold in dB
+	Margin float64 `json:"margin"`
+
+	// DataRate is the achievable information rate in bits per second
+	DataRate float64 `json:"dataRate"`
+}
+
+// Budget returns the link budget at a slant range in kilometres and an
+// elevation in degrees
+func (l *RadioLink) Budget(rangeKm, elevation float64) LinkBudget {
+	tx, rx := l.Transmitter, l.Receiver
+	budget := LinkBudget{
+		Range:           rangeKm,
+		Elevation:       elevation,
+		EIRP:            tx.EIRP(),
+		PathLoss:        PathLoss(rangeKm, tx.Frequency),
+		AtmosphericLoss: AtmosphericLoss(tx.Frequency, elevation) + l.RainAttenuation*pathFactor(elevation),
+		FigureOfMerit:   rx.FigureOfMerit(),
+	}
+	budget.CN0 = budget.EIRP - budget.PathLoss - budget.AtmosphericLoss + budget.FigureOfMerit - boltzmann
+
+	for i, modCod := range tx.modCods() {
+		rate := tx.SymbolRate * modCod.Efficiency
+		ebN0 := budget.CN0 - decibels(rate)
+		margin := ebN0 - modCod.RequiredEbN0()
+		closes := margin >= l.Margin && rate > budget.DataRate
+		if closes || i == 0 {
+			budget.EbN0 = ebN0
+			budget.Margin = margin
+		}
+		if closes {
+			budget.ModCod = modCod.Name
+			budget.DataRate = rate
+		}
+	}
+	return budget
+}
+
+// PassBudget is the link budget through a pass
+type PassBudget struct {
+	// Points are the budgets at each point of the pass's track
+	Points []LinkBudget `json:"points"`
+
+	// Bytes is the data volume the link can carry through the pass, taking
+	// the lower rate between each pair of points
+	Bytes int64 `json:"bytes"`
+
+	// Usable is how long the link closes for during the pass
+	Usable time.Duration `json:"usable"`
+}
+
+// PassBudget returns the link budget through a pass
+func (l *RadioLink) PassBudget(pass *Pass) PassBudget {
+	var result PassBudget
+	for i, point := range pass.Track {
+		budget := l.Budget(point.Range, point.Elevation)
+		budget.Time = point.Time
+		result.Points = append(result.Points, budget)
+		if i == 0 {
+			continue
+		}
+		previous := result.Points[i-1]
+		rate := math.Min(previous.DataRate, budget.DataRate)
+		interval := point.Time.Sub(previous.Time)
+		result.Bytes += int64(rate * interval.Seconds() / 8)
+		if rate > 0 {
+			result.Usable += interval
+		}
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package satellite
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// newTestLink returns an S-band downlink to a dish with a 10 Msps carrier
+func newTestLink() *RadioLink {
+	transmitter := NewTransceiver("tx")
+	transmitter.Frequency = 2.2e9
+	transmitter.TransmitPower = 2
+	transmitter.AntennaGain = 6
+	transmitter.SymbolRate = 10e6
+
+	receiver := NewTransceiver("rx")
+	receiver.AntennaGain = 35
+	receiver.NoiseTemperature = 150
+
+	return &RadioLink{Transmitter: transmitter, Receiver: receiver, Margin: 3}
+}
+
+func TestPathLoss(t *testing.T) {
+	if loss := PathLoss(1000, 2.2e9); math.Abs(loss-159.30) > 0.01 {
+		t.Errorf("Expected 159.30 dB over 1000 km at 2.2 GHz, got %f", loss)
+	}
+	if difference := PathLoss(2000, 2.2e9) - PathLoss(1000, 2.2e9); math.Abs(difference-6.02) > 0.01 {
+		t.Errorf("Expected doubling the range to add 6 dB, got %f", difference)
+	}
+}
+
+func TestAtmosphericLoss(t *testing.T) {
+	zenith := AtmosphericLoss(2e9, 90)
+	if math.Abs(zenith-0.038) > 1e-9 {
+		t.Errorf("Expected 0.038 dB at zenith at 2 GHz, got %f", zenith)
+	}
+	if low := AtmosphericLoss(2e9, 10); low <= zenith {
+		t.Errorf("Expected more loss at low elevation, got %f", low)
+	}
+	if AtmosphericLoss(2e9, -5) != AtmosphericLoss(2e9, minimumPathElevation) {
+		t.Errorf("Expected the path length to be limited near the horizon")
+	}
+	if water := AtmosphericLoss(22.2e9, 90); water <= AtmosphericLoss(18e9, 90) || water <= AtmosphericLoss(26e9, 90) {
+		t.Errorf("Expected the water vapour line to peak at 22.2 GHz")
+	}
+	if interpolated := AtmosphericLoss(3e9, 90); interpolated <= 0.038 || interpolated >= 0.043 {
+		t.Errorf("Expected loss between table entries to be interpolated, got %f", interpolated)
+	}
+}
+
+func TestRadioLink_Budget(t *testing.T) {
+	link := newTestLink()
+
+	near := link.Budget(1000, 60)
+	if near.ModCod != "32APSK 4/5" {
+		t.Errorf("Expected the most efficient scheme at short range, got %q", near.ModCod)
+	}
+	if near.Margin < link.Margin {
+		t.Errorf("Expected the margin to be met, got %f", near.Margin)
+	}
+	expected := near.EIRP - near.PathLoss - near.AtmosphericLoss + near.FigureOfMerit + 228.5991672
+	if math.Abs(near.CN0-expected) > 1e-9 {
+		t.Errorf("Expected C/N0 %f, got %f", expected, near.CN0)
+	}
+
+	far := link.Budget(5000, 10)
+	if far.ModCod == "" || far.DataRate >= near.DataRate {
+		t.Errorf("Expected a slower scheme at long range, got %q at %f bps", far.ModCod, far.DataRate)
+	}
+	if far.Margin < link.Margin {
+		t.Errorf("Expected the margin to be met, got %f", far.Margin)
+	}
+
+	none := link.Budget(100000, 10)
+	if none.ModCod != "" || none.DataRate != 0 {
+		t.Errorf("Expected no scheme to close the link, got %q", none.ModCod)
+	}
+	if none.Margin >= link.Margin {
+		t.Errorf("Expected the margin to fall short, got %f", none.Margin)
+	}
+
+	link.RainAttenuation = 20
+	if rain := link.Budget(1000, 60); rain.DataRate >= near.DataRate {
+		t.Errorf("Expected rain to reduce the data rate")
+	}
+}
+
+func TestRadioLink_PassBudget(t *testing.T) {
+	satellite := newTestSatellite(t)
+	station := NewGroundStationAt("station", Geodetic{Latitude: 28.5, Longitude: -80.6}, 10)
+	start := satellite.TLE().Epoch
+	passes, err := satellite.Passes(station, start, start.Add(24*time.Hour), 0)
+	if err != nil || len(passes) == 0 {
+		t.Fatalf("Expected a pass, got %v", err)
+	}
+	pass := passes[0]
+
+	link := newTestLink()
+	budget := link.PassBudget(&pass)
+	if len(budget.Points) != len(pass.Track) {
+		t.Fatalf("Expected a budget for each track point, got %d", len(budget.Points))
+	}
+	if budget.Bytes <= 0 || budget.Usable <= 0 || budget.Usable > pass.Duration() {
+		t.Errorf("Expected data to fit in the pass, got %d bytes over %v", budget.Bytes, budget.Usable)
+	}
+	var peak float64
+	for _, point := range budget.Points {
+		peak = math.Max(peak, point.DataRate)
+	}
+	if float64(budget.Bytes) > peak*pass.Duration().Seconds()/8 {
+		t.Errorf("Expected the volume to be at most the peak rate over the pass, got %d", budget.Bytes)
+	}
+	for i, point := range budget.Points {
+		if !point.Time.Equal(pass.Track[i].Time) {
+			t.Errorf("Expected budget %d at the track time", i)
+		}
+	}
+
+	// Shorter range gives at least the rate of the start of the pass
+	closest := budget.Points[0]
+	for _, point := range budget.Points {
+		if point.Range < closest.Range {
+			closest = point
+		}
+	}
+	if closest.DataRate < budget.Points[0].DataRate {
+		t.Errorf("Expected the rate to be highest at the shortest range")
+	}
+
+	// A link that never closes carries nothing
+	link.Transmitter.TransmitPower = 1e-9
+	if empty := link.PassBudget(&pass); empty.Bytes != 0 || empty.Usable != 0 {
+		t.Errorf("Expected no data without a closing link, got %d", empty.Bytes)
+	}
+}
@@ -0,0 +1,190 @@
+package satellite
+
+import (
+	"time"
+)
+
+// DefaultPassStep is the interval at which passes are searched for and
+// tracked when no step is given
+const DefaultPassStep = 10 * time.Second
+
+// passTolerance is the precision to which AOS, LOS and the time of maximum
+// elevation are found
+const passTolerance = 100 * time.Millisecond
+
+// Pass is a window in which a satellite is visible from a ground station
+type Pass struct {
+	// Satellite is the id of the satellite
+	Satellite string `json:"satellite"`
+
+	// Station is the id of the ground station
+	Station string `json:"station"`
+
+	// AOS is the acquisition of signal, when the satellite rises above the
+	// station's horizon and elevation mask
+	AOS time.Time `json:"aos"`
+
+	// LOS is the loss of signal, when the satellite sets
+	LOS time.Time `json:"los"`
+
+	// MaxElevation is the highest elevation in degrees during the pass
+	MaxElevation float64 `json:"maxElevation"`
+
+	// MaxElevationTime is the time of the highest elevation
+	MaxElevationTime time.Time `json:"maxElevationTime"`
+
+	// Track is the azimuth and elevation of the satellite through the pass,
+	// including AOS and LOS
+	Track []TrackPoint `json:"track"`
+}
+
+// Duration returns the length of the pass
+func (p *Pass) Duration() time.Duration {
+	return p.LOS.Sub(p.AOS)
+}
+
+// TrackPoint is the look angles to a satellite at a time
+type TrackPoint struct {
+	Time time.Time `json:"time"`
+	LookAngles
+}
+
+// Passes predicts the passes of the satellite over a ground station between
+// start and end. The orbit is sampled every step, or DefaultPassStep if step
+// is zero, so passes shorter than step may be missed. Passes in progress at
+// start or end are cut short at those times.
+func (s *Satellite) Passes(station *GroundStation, start, end time.Time, step time.Duration) ([]Pass, error) {
+	if step <= 0 {
+		step = DefaultPassStep
+	}
+
+	var passes []Pass
+	var current *Pass
+	var previous TrackPoint
+	for t := start; !t.After(end); t = t.Add(step) {
+		point, err := s.look(station, t)
+		if err != nil {
+			return passes, err
+		}
+		visible := station.Visible(point.LookAngles)
+
+		switch {
+		case visible && current == nil:
+			current = &Pass{Satellite: s.id, Station: station.id, AOS: start}
+			if t.After(start) {
+				aos, err := s.crossing(station, point, previous.Time)
+				if err != nil {
+					return passes, err
+				}
+				current.AOS = aos.Time
+				current.Track = append(current.Track, aos)
+			}
+		case !visible && current != nil:
+			los, err := s.crossing(station, previous, t)
+			if err != nil {
+				return passes, err
+			}
+			current.LOS = los.Time
+			current.Track = append(current.Track, los)
+			if err := s.finishPass(station, current); err != nil {
+				return passes, err
+			}
+			passes = append(passes, *current)
+			current = nil
+		}
+		if visible {
+			current.Track = append(current.Track, point)
+		}
+		previous = point
+
+		// Always sample the end of the range
+		if t.Before(end) && t.Add(step).After(end) {
+			step = end.Sub(t)
+		}
+	}
+
+	if current != nil {
+		current.LOS = end
+		if err := s.finishPass(station, current); err != nil {
+			return passes, err
+		}
+		passes = append(passes, *current)
+	}
+	return passes, nil
+}
+
+// look returns the look angles from a station to the satellite at t
+func (s *Satellite) look(station *GroundStation, t time.Time) (TrackPoint, error) {
+	state, err := s.PositionECEF(t)
+	if err != nil {
+		return TrackPoint{}, err
+	}
+	return TrackPoint{Time: t, LookAngles: station.Look(state.Position, state.Velocity)}, nil
+}
+
+// crossing bisects between a visible point and a time at which the
+// satellite is hidden, returning the visible point closest to the horizon
+func (s *Satellite) crossing(station *GroundStation, visible TrackPoint, hidden time.Time) (TrackPoint, error) {
+	for {
+		gap := hidden.Sub(visible.Time)
+		if gap <= passTolerance && gap >= -passTolerance {
+			return visible, nil
+		}
+		point, err := s.look(station, visible.Time.Add(gap/2))
+		if err != nil {
+			return point, err
+		}
+		if station.Visible(point.LookAngles) {
+			visible = point
+		} else {
+			hidden = point.Time
+		}
+	}
+}
+
+// finishPass finds the maximum elevation of a pass, refining the highest
+// sampled point by ternary search between its neighbours
+func (s *Satellite) finishPass(station *GroundStation, pass *Pass) error {
+	if len(pass.Track) == 0 {
+		return nil
+	}
+	highest := 0
+	for i, point := range pass.Track {
+		if point.Elevation > pass.Track[highest].Elevation {
+			highest = i
+		}
+	}
+	pass.MaxElevation = pass.Track[highest].Elevation
+	pass.MaxElevationTime = pass.Track[highest].Time
+	if highest == 0 || highest == len(pass.Track)-1 {
+		return nil
+	}
+
+	a := pass.Track[highest-1].Time
+	b := pass.Track[highest+1].Time
+	for b.Sub(a) > passTolerance {
+		third := b.Sub(a) / 3
+		left, err := s.look(station, a.Add(third))
+		if err != nil {
+			return err
+		}
+		right, err := s.look(station, b.Add(-third))
+		if err != nil {
+			return err
+		}
+		if left.Elevation < right.Elevation {
+			a = left.Time
+		} else {
+			b = right.Time
+		}
+	}
+	top, err := s.look(station, a.Add(b.Sub(a)/2))
+	if err != nil {
+		return err
+	}
+	if top.Elevation > pass.MaxElevation {
+		pass.MaxElevation = top.Elevation
+		pass.MaxElevationTime = top.Time
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package satellite
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// newTestSatellite returns a satellite with the Vanguard 1 elements
+func newTestSatellite(t *testing.T) *Satellite {
+	tle, err := ParseTLE("VANGUARD 1", vanguardLine1, vanguardLine2)
+	if err != nil {
+		t.Fatalf("Expected ParseTLE to succeed, got %v", err)
+	}
+	satellite := NewSatellite("vanguard")
+	if err := satellite.SetTLE(tle); err != nil {
+		t.Fatalf("Expected SetTLE to succeed, got %v", err)
+	}
+	return satellite
+}
+
+func TestSatellite_Passes(t *testing.T) {
+	satellite := newTestSatellite(t)
+	station := NewGroundStationAt("station", Geodetic{Latitude: 28.5, Longitude: -80.6}, 10)
+	start := satellite.TLE().Epoch
+	end := start.Add(24 * time.Hour)
+
+	passes, err := satellite.Passes(station, start, end, 0)
+	if err != nil {
+		t.Fatalf("Expected Passes to succeed, got %v", err)
+	}
+	if len(passes) == 0 {
+		t.Fatalf("Expected Vanguard 1 to pass over the station in a day")
+	}
+
+	for i, pass := range passes {
+		if pass.Satellite != "vanguard" || pass.Station != "station" {
+			t.Errorf("Expected the pass to name the satellite and station, got %+v", pass)
+		}
+		if !pass.LOS.After(pass.AOS) || pass.AOS.Before(start) || pass.LOS.After(end) {
+			t.Errorf("Expected pass %d to be within the range, got %v to %v", i, pass.AOS, pass.LOS)
+		}
+		if i > 0 && !pass.AOS.After(passes[i-1].LOS) {
+			t.Errorf("Expected passes to be in order")
+		}
+		if pass.MaxElevationTime.Before(pass.AOS) || pass.MaxElevationTime.After(pass.LOS) {
+			t.Errorf("Expected the maximum elevation to be during the pass")
+		}
+
+		// The track starts and ends on the mask and stays above it
+		first := pass.Track[0]
+		last := pass.Track[len(pass.Track)-1]
+		if !first.Time.Equal(pass.AOS) || !last.Time.Equal(pass.LOS) {
+			t.Errorf("Expected the track to run from AOS to LOS")
+		}
+		if pass.AOS.After(start) && math.Abs(first.Elevation-10) > 0.05 {
+			t.Errorf("Expected AOS at the elevation mask, got %f", first.Elevation)
+		}
+		if pass.LOS.Before(end) && math.Abs(last.Elevation-10) > 0.05 {
+			t.Errorf("Expected LOS at the elevation mask, got %f", last.Elevation)
+		}
+		for _, point := range pass.Track {
+			if point.Elevation < 10 || point.Elevation > pass.MaxElevation {
+				t.Errorf("Expected the track to stay between the mask and the maximum, got %f", point.Elevation)
+			}
+		}
+		if first.RangeRate > 0 && pass.AOS.After(start) {
+			t.Errorf("Expected the satellite to approach at AOS")
+		}
+
+		// Passes just outside the window are not visible
+		for _, at := range []time.Time{pass.AOS.Add(-time.Second), pass.LOS.Add(time.Second)} {
+			if at.Before(start) || at.After(end) {
+				continue
+			}
+			point, _ := satellite.look(station, at)
+			if station.Visible(point.LookAngles) {
+				t.Errorf("Expected the satellite to be hidden outside the pass at %v", at)
+			}
+		}
+	}
+
+	// A higher mask shortens every pass
+	station.ElevationMask = 30
+	high, err := satellite.Passes(station, start, end, 0)
+	if err != nil {
+		t.Fatalf("Expected Passes to succeed, got %v", err)
+	}
+	var total, highTotal time.Duration
+	for _, pass := range passes {
+		total += pass.Duration()
+	}
+	for _, pass := range high {
+		highTotal += pass.Duration()
+	}
+	if highTotal >= total {
+		t.Errorf("Expected a higher mask to reduce the visible time, got %v and %v", total, highTotal)
+	}
+}
+
+func TestSatellite_PassesGeosynchronous(t *testing.T) {
+	line1 := withChecksum("1 28626U 05008A   06176.46683397 -.00000205  00000-0  10000-3 0  219")
+	line2 := withChecksum("2 28626   0.0019 286.9433 0000335  13.7918  55.6504  1.00270176  458")
+	tle, err := ParseTLE("", line1, line2)
+	if err != nil {
+		t.Fatalf("Expected ParseTLE to succeed, got %v", err)
+	}
+	satellite := NewSatellite("geo")
+	if err := satellite.SetTLE(tle); err != nil {
+		t.Fatalf("Expected SetTLE to succeed, got %v", err)
+	}
+	subpoint, err := satellite.Geodetic(tle.Epoch)
+	if err != nil {
+		t.Fatalf("Expected Geodetic to succeed, got %v", err)
+	}
+
+	// A station under a geosynchronous satellite sees it for the whole range
+	start := tle.Epoch
+	end := start.Add(6 * time.Hour)
+	below := NewGroundStationAt("below", Geodetic{Longitude: subpoint.Longitude}, 10)
+	passes, err := satellite.Passes(below, start, end, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected Passes to succeed, got %v", err)
+	}
+	if len(passes) != 1 || !passes[0].AOS.Equal(start) || !passes[0].LOS.Equal(end) {
+		t.Fatalf("Expected one pass covering the range, got %+v", passes)
+	}
+	if passes[0].MaxElevation < 80 {
+		t.Errorf("Expected the satellite to be nearly overhead, got %f", passes[0].MaxElevation)
+	}
+
+	// The other side of the Earth never sees it
+	opposite := NewGroundStationAt("opposite", Geodetic{Longitude: subpoint.Longitude + 180}, 0)
+	if passes, err := satellite.Passes(opposite, start, end, time.Minute); err != nil || len(passes) != 0 {
+		t.Errorf("Expected no passes from the far side of the Earth, got %d, %v", len(passes), err)
+	}
+
+	if _, err := NewSatellite("none").Passes(below, start, end, 0); err != ErrNoTLE {
+		t.Errorf("Expected Passes to fail without a TLE, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package satellite
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// ErrNoRoute is returned when no sequence of contacts reaches a destination
+var ErrNoRoute = errors.New("no route to destination")
+
+// ContactWindow is a window in which one node of a constellation, a
+// satellite or ground station, can send to another
+type ContactWindow struct {
+	// From is the id of the sending node
+	From string `json:"from"`
+
+	// To is the id of the receiving node
+	To string `json:"to"`
+
+	// Start is when the window opens
+	Start time.Time `json:"start"`
+
+	// End is when the window closes
+	End time.Time `json:"end"`
+
+	// Range is the longest distance in kilometres between the nodes during
+	// the window, bounding the propagation delay
+	Range float64 `json:"range"`
+}
+
+// Delay returns the one-way light time over the window's longest range
+func (w ContactWindow) Delay() time.Duration {
+	return time.Duration(w.Range * 1000 / SpeedOfLight * float64(time.Second))
+}
+
+// ContactGraph is the time-varying topology of a constellation as the
+// contact windows between its nodes, ordered by start time
+type ContactGraph struct {
+	windows []ContactWindow
+}
+
+// NewContactGraph returns a contact graph of the windows
+func NewContactGraph(windows []ContactWindow) *ContactGraph {
+	graph := &ContactGraph{windows: make([]ContactWindow, len(windows))}
+	copy(graph.windows, windows)
+	sort.SliceStable(graph.windows, func(i, j int) bool {
+		return graph.windows[i].Start.Before(graph.windows[j].Start)
+	})
+	return graph
+}
+
+// BuildContactGraph predicts the contact graph of satellites between start
+// and end from their inter-satellite links, in both directions, and their
+// passes over their ground stations, for downlink and uplink. Orbits are
+// sampled every step, or DefaultPassStep if step is zero.
+func BuildContactGraph(satellites []*Satellite, start, end time.Time, step time.Duration) (*ContactGraph, error) {
+	var windows []ContactWindow
+	seen := make(map[*InterSatelliteLink]bool)
+	for _, satellite := range satellites {
+		for _, link := range satellite.GetInterSatelliteLinks() {
+			if seen[link] {
+				continue
+			}
+			seen[link] = true
+			linkWindows, err := link.Windows(start, end, step)
+			if err != nil {
+				return nil, err
+			}
+			for _, window := range linkWindows {
+				windows = append(windows, window)
+				window.From, window.To = window.To, window.From
+				windows = append(windows, window)
+			}
+		}
+
+		for _, station := range satellite.GetGroundStations() {
+			passes, err := satellite.Passes(station, start, end, step)
+			if err != nil {
+				return nil, err
+			}
+			for _, pass := range passes {
+				window := ContactWindow{From: satellite.id, To: station.id, Start: pass.AOS, End: pass.LOS}
+				for _, point := range pass.Track {
+					window.Range = math.Max(window.Range, point.Range)
+				}
+				windows = append(windows, window)
+				window.From, window.To = window.To, window.From
+				windows = append(windows, window)
+			}
+		}
+	}
+	return NewContactGraph(windows), nil
+}
+
+// Windows returns the contact windows ordered by start time
+func (g *ContactGraph) Windows() []ContactWindow {
+	return g.windows
+}
+
+// Hop is one transmission of a route
+type Hop struct {
+	ContactWindow
+
+	// Departure is when the transmission starts
+	Departure time.Time `json:"departure"`
+
+	// Arrival is when the transmission reaches the receiving node
+	Arrival time.Time `json:"arrival"`
+}
+
+// Route is a sequence of hops through a contact graph
+type Route struct {
+	// Hops are the transmissions from the source to the destination
+	Hops []Hop `json:"hops"`
+}
+
+// Arrival returns when the route reaches its destination
+func (r *Route) Arrival() time.Time {
+	if len(r.Hops) == 0 {
+		return time.Time{}
+	}
+	return r.Hops[len(r.Hops)-1].Arrival
+}
+
+// Nodes returns the ids of the nodes along the route, including the source
+// and destination
+func (r *Route) Nodes() []string {
+	if len(r.Hops) == 0 {
+		return nil
+	}
+	nodes := []string{r.Hops[0].From}
+	for _, hop := range r.Hops {
+		nodes = append(nodes, hop.To)
+	}
+	return nodes
+}
+
+// Route returns the earliest-arrival route from source to destination for
+// data ready at start. Data is stored at each node until the next window
+// opens and is delayed by the light time of each hop.
+func (g *ContactGraph) Route(source, destination string, start time.Time) (*Route, error) {
+	return g.route(source, destination, start, nil)
+}
+
+// Routes returns up to limit routes from source to destination for data
+// ready at start, in order of arrival. As in contact graph routing, each
+// route after the first is found by removing the window that closes first
+// along the previous route, so the routes offer alternatives when that
+// window is missed or congested.
+func (g *ContactGraph) Routes(source, destination string, start time.Time, limit int) ([]*Route, error) {
+	var routes []*Route
+	excluded := make(map[int]bool)
+	for len(routes) < limit {
+		route, err := g.route(source, destination, start, excluded)
+		if err == ErrNoRoute {
+			break
+		}
+		if err != nil {
+			return routes, err
+		}
+		routes = append(routes, route)
+
+		limiting := -1
+		for _, hop := range route.Hops {
+			index := g.index(hop.ContactWindow)
+			if limiting < 0 || hop.End.Before(g.windows[limiting].End) {
+				limiting = index
+			}
+		}
+		excluded[limiting] = true
+	}
+	if len(routes) == 0 {
+		return nil, ErrNoRoute
+	}
+	return routes, nil
+}
+
+// index returns the position of a window in the graph
+func (g *ContactGraph) index(window ContactWindow) int {
+	for i, w := range g.windows {
+		if w == window {
+			return i
+		}
+	}
+	return -1
+}
+
+// route finds the earliest-arrival route with Dijkstra's algorithm over the
+// nodes, skipping excluded windows. Waiting for a window never makes data
+// arrive sooner, so the earliest arrival at each node is enough to extend
+// the search.
+func (g *ContactGraph) route(source, destination string, start time.Time, excluded map[int]bool) (*Route, error) {
+	arrival := map[string]time.Time{source: start}
+	via := make(map[string]Hop)
+	done := make(map[string]bool)
+
+	for {
+		node := ""
+		for candidate, at := range arrival {
+			if !done[candidate] && (node == "" || at.Before(arrival[node])) {
+				node = candidate
+			}
+		}
+		if node == "" {
+			return nil, ErrNoRoute
+		}
+		if node == destination {
+			break
+		}
+		done[node] = true
+
+		ready := arrival[node]
+		for i, window := range g.windows {
+			if window.From != node || excluded[i] || !window.End.After(ready) || done[window.To] {
+				continue
+			}
+			departure := ready
+			if window.Start.After(departure) {
+				departure = window.Start
+			}
+			at := departure.Add(window.Delay())
+			if current, ok := arrival[window.To]; !ok || at.Before(current) {
+				arrival[window.To] = at
+				via[window.To] = Hop{ContactWindow: window, Departure: departure, Arrival: at}
+			}
+		}
+	}
+
+	route := &Route{}
+	for node := destination; node != source; {
+		hop := via[node]
+		route.Hops = append([]Hop{hop}, route.Hops...)
+		node = hop.From
+	}
+	return route, nil
+}
@@ -0,0 +1,152 @@
+package satellite
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestContactWindow_Delay(t *testing.T) {
+	window := ContactWindow{Range: SpeedOfLight / 1000}
+	if delay := window.Delay(); delay != time.Second {
+		t.Errorf("Expected one second of light time, got %v", delay)
+	}
+}
+
+func TestContactGraph_Route(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	graph := NewContactGraph([]ContactWindow{
+		{From: "a", To: "c", Start: at(40), End: at(50)},
+		{From: "b", To: "c", Start: at(20), End: at(30), Range: SpeedOfLight / 1000},
+		{From: "a", To: "b", Start: at(0), End: at(10)},
+		{From: "c", To: "a", Start: at(0), End: at(60)},
+	})
+	if windows := graph.Windows(); !windows[0].Start.Equal(at(0)) || !windows[3].Start.Equal(at(40)) {
+		t.Errorf("Expected the windows to be sorted by start")
+	}
+
+	// Relaying through b and waiting for its window beats the direct window
+	route, err := graph.Route("a", "c", at(5))
+	if err != nil {
+		t.Fatalf("Expected Route to succeed, got %v", err)
+	}
+	if nodes := route.Nodes(); !reflect.DeepEqual(nodes, []string{"a", "b", "c"}) {
+		t.Fatalf("Expected the route through b, got %v", nodes)
+	}
+	if !route.Hops[0].Departure.Equal(at(5)) || !route.Hops[1].Departure.Equal(at(20)) {
+		t.Errorf("Expected data to be stored at b until its window opens, got %+v", route.Hops)
+	}
+	if arrival := route.Arrival(); !arrival.Equal(at(20).Add(time.Second)) {
+		t.Errorf("Expected arrival after the light time, got %v", arrival)
+	}
+
+	// Once a's window to b has closed, only the direct window remains
+	route, err = graph.Route("a", "c", at(10))
+	if err != nil {
+		t.Fatalf("Expected Route to succeed, got %v", err)
+	}
+	if nodes := route.Nodes(); !reflect.DeepEqual(nodes, []string{"a", "c"}) || !route.Arrival().Equal(at(40)) {
+		t.Errorf("Expected the direct route at 40 minutes, got %v at %v", nodes, route.Arrival())
+	}
+
+	if _, err := graph.Route("a", "c", at(50)); err != ErrNoRoute {
+		t.Errorf("Expected no route after the windows close, got %v", err)
+	}
+	if _, err := graph.Route("a", "d", at(0)); err != ErrNoRoute {
+		t.Errorf("Expected no route to an unknown node, got %v", err)
+	}
+	if route, err := graph.Route("a", "a", at(0)); err != nil || len(route.Hops) != 0 || route.Nodes() != nil {
+		t.Errorf("Expected an empty route to the source, got %+v, %v", route, err)
+	}
+}
+
+func TestContactGraph_Routes(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	graph := NewContactGraph([]ContactWindow{
+		{From: "a", To: "b", Start: at(0), End: at(10)},
+		{From: "b", To: "c", Start: at(20), End: at(30)},
+		{From: "a", To: "c", Start: at(40), End: at(50)},
+		{From: "a", To: "c", Start: at(70), End: at(80)},
+	})
+
+	routes, err := graph.Routes("a", "c", start, 5)
+	if err != nil {
+		t.Fatalf("Expected Routes to succeed, got %v", err)
+	}
+	if len(routes) != 3 {
+		t.Fatalf("Expected three routes, got %d", len(routes))
+	}
+	expected := []time.Time{at(20), at(40), at(70)}
+	for i, route := range routes {
+		if !route.Arrival().Equal(expected[i]) {
+			t.Errorf("Expected route %d to arrive at %v, got %v", i, expected[i], route.Arrival())
+		}
+	}
+
+	if routes, err := graph.Routes("a", "c", start, 1); err != nil || len(routes) != 1 {
+		t.Errorf("Expected the limit to apply, got %d, %v", len(routes), err)
+	}
+	if _, err := graph.Routes("c", "a", start, 5); err != ErrNoRoute {
+		t.Errorf("Expected no route back, got %v", err)
+	}
+}
+
+func TestBuildContactGraph(t *testing.T) {
+	leader := newTestSatelliteAt(t, "leader", 0)
+	follower := newTestSatelliteAt(t, "follower", -30)
+	leader.AddInterSatelliteLink(follower, 0)
+	station := NewGroundStationAt("station", Geodetic{Latitude: 28.5, Longitude: -80.6}, 10)
+	leader.AddGroundStation(station)
+
+	start := leader.TLE().Epoch
+	end := start.Add(24 * time.Hour)
+	graph, err := BuildContactGraph([]*Satellite{leader, follower}, start, end, 0)
+	if err != nil {
+		t.Fatalf("Expected BuildContactGraph to succeed, got %v", err)
+	}
+
+	directions := make(map[string]int)
+	for _, window := range graph.Windows() {
+		directions[window.From+">"+window.To]++
+		if window.Range <= 0 {
+			t.Errorf("Expected each window to have a range, got %+v", window)
+		}
+	}
+	for _, direction := range []string{"leader>follower", "follower>leader", "leader>station", "station>leader"} {
+		if directions[direction] == 0 {
+			t.Errorf("Expected windows from %s", direction)
+		}
+	}
+	if directions["leader>follower"] != directions["follower>leader"] || directions["follower>station"] != 0 {
+		t.Errorf("Expected links in both directions and no passes for the follower, got %v", directions)
+	}
+
+	// The follower reaches the station through the leader
+	route, err := graph.Route("follower", "station", start)
+	if err != nil {
+		t.Fatalf("Expected Route to succeed, got %v", err)
+	}
+	if nodes := route.Nodes(); !reflect.DeepEqual(nodes, []string{"follower", "leader", "station"}) {
+		t.Fatalf("Expected the route through the leader, got %v", nodes)
+	}
+	ready := start
+	for _, hop := range route.Hops {
+		if hop.Departure.Before(ready) || hop.Departure.Before(hop.Start) || !hop.Departure.Before(hop.End) {
+			t.Errorf("Expected each hop to depart within its window after the data is ready, got %+v", hop)
+		}
+		if hop.Arrival.Sub(hop.Departure) != hop.Delay() {
+			t.Errorf("Expected each hop to take its light time")
+		}
+		ready = hop.Arrival
+	}
+
+	if _, err := BuildContactGraph([]*Satellite{NewSatellite("none")}, start, end, 0); err != nil {
+		t.Errorf("Expected a satellite without links to add nothing, got %v", err)
+	}
+}
@@ -1,41 +1,76 @@
 package satellite
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
-	"net/http"
 	"sync"
 	"time"
 
-	"github.com /your-username/utils"
+	"github.com/skybridge/satellite/ccsds"
+	"github.com/skybridge/satellite/sdls"
 )
 
+// ErrNoTLE is returned when a satellite has no orbital elements
+var ErrNoTLE = errors.New("satellite has no TLE")
+
 // Satellite represents a satellite in orbit
 type Satellite struct {
-	id          string
+	id             string
 	groundStations []*GroundStation
-	transceivers []*Transceiver
+	transceivers   []*Transceiver
+
+	mutex      sync.RWMutex
+	links      []*InterSatelliteLink
+	tle        *TLE
+	propagator *Propagator
+	scheduler  *Scheduler
+	channel    Channel
+
+	telemetry      *ccsds.TMEncoder
+	sequence       *ccsds.SequenceCounter
+	telecommand    *ccsds.TCConfig
+	security       *sdls.Endpoint
+	commandHandler func(packet *ccsds.SpacePacket) error
 }
 
 // NewSatellite returns a new Satellite instance
 func NewSatellite(id string) *Satellite {
-	return &Satellite{
-		id:          id,
+	satellite := &Satellite{
+		id:             id,
 		groundStations: make([]*GroundStation, 0),
-		transceivers: make([]*Transceiver, 0),
+		transceivers:   make([]*Transceiver, 0),
 	}
+	satellite.scheduler = NewScheduler(satellite, SchedulerConfig{})
+	return satellite
 }
 
 // AddGroundStation adds a ground station to the satellite
 func (s *Satellite) AddGroundStation(groundStation *GroundStation) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	s.groundStations = append(s.groundStations, groundStation)
 }
 
 // GetGroundStations returns the satellite's ground stations
 func (s *Satellite) GetGroundStations() []*GroundStation {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 	return s.groundStations
 }
 
+// groundStation returns the ground station with an id, or nil
+func (s *Satellite) groundStation(id string) *GroundStation {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, groundStation := range s.groundStations {
+		if groundStation.id == id {
+			return groundStation
+		}
+	}
+	return nil
+}
+
 // AddTransceiver adds a transceiver to the satellite
 func (s *Satellite) AddTransceiver(transceiver *Transceiver) {
 	s.transceivers = append(s.transceivers, transceiver)
@@ -46,12 +81,110 @@ func (s *Satellite) GetTransceivers() []*Transceiver {
 	return s.transceivers
 }
 
+// AddInterSatelliteLink links the satellite with a peer over a maximum range
+// in kilometres. The link is added to both satellites.
+func (s *Satellite) AddInterSatelliteLink(peer *Satellite, maxRange float64) *InterSatelliteLink {
+	link := NewInterSatelliteLink(s, peer, maxRange)
+	for _, satellite := range []*Satellite{s, peer} {
+		satellite.mutex.Lock()
+		satellite.links = append(satellite.links, link)
+		satellite.mutex.Unlock()
+	}
+	return link
+}
+
+// GetInterSatelliteLinks returns the satellite's inter-satellite links
+func (s *Satellite) GetInterSatelliteLinks() []*InterSatelliteLink {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.links
+}
+
+// SetTLE sets the satellite's orbital elements
+func (s *Satellite) SetTLE(tle *TLE) error {
+	propagator, err := NewPropagator(tle)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tle = tle
+	s.propagator = propagator
+	return nil
+}
+
+// TLE returns the satellite's orbital elements, or nil if none are set
+func (s *Satellite) TLE() *TLE {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.tle
+}
+
+// Position returns the satellite's position and velocity in the TEME frame
+// at t
+func (s *Satellite) Position(t time.Time) (StateVector, error) {
+	s.mutex.RLock()
+	propagator := s.propagator
+	s.mutex.RUnlock()
+	if propagator == nil {
+		return StateVector{}, ErrNoTLE
+	}
+	return propagator.Propagate(t)
+}
+
+// PositionECEF returns the satellite's position and velocity in the
+// Earth-fixed frame at t
+func (s *Satellite) PositionECEF(t time.Time) (StateVector, error) {
+	state, err := s.Position(t)
+	if err != nil {
+		return state, err
+	}
+	state.Position, state.Velocity = TEMEToECEF(state.Position, state.Velocity, t)
+	return state, nil
+}
+
+// Geodetic returns the satellite's geodetic position at t
+func (s *Satellite) Geodetic(t time.Time) (Geodetic, error) {
+	state, err := s.PositionECEF(t)
+	if err != nil {
+		return Geodetic{}, err
+	}
+	return ECEFToGeodetic(state.Position), nil
+}
+
 // CommunicateWithGroundStation communicates with a ground station
 func (s *Satellite) CommunicateWithGroundStation(groundStation *GroundStation, data []byte) error {
 	log.Printf("Communicating with ground station %s", groundStation.id)
+	s.mutex.RLock()
+	channel := s.channel
+	s.mutex.RUnlock()
+	if channel != nil {
+		return channel.Transmit(s, groundStation, data)
+	}
 	return groundStation.ReceiveData(data)
 }
 
+// uplink sends data from a ground station to the satellite through the
+// satellite's channel, or directly without one
+func (s *Satellite) uplink(station *GroundStation, data []byte) error {
+	s.mutex.RLock()
+	channel := s.channel
+	s.mutex.RUnlock()
+	if channel != nil {
+		return channel.Uplink(station, s, data)
+	}
+	return s.ReceiveTelecommand(data)
+}
+
+// SetChannel routes the satellite's communication with its ground stations,
+// both ways, through a channel, such as a ChannelSimulator for testing lossy
+// links. A nil channel delivers data directly.
+func (s *Satellite) SetChannel(channel Channel) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.channel = channel
+}
+
 // CommunicateWithMultipleGroundStations communicates with multiple ground stations concurrently
 func (s *Satellite) CommunicateWithMultipleGroundStations(groundStations []*GroundStation, data []byte) error {
 	var wg sync.WaitGroup
@@ -67,44 +200,37 @@ func (s *Satellite) CommunicateWithMultipleGroundStations(groundStations []*Grou
 	return nil
 }
 
-// StartOrbit starts the satellite's orbit
-func (s *Satellite) StartOrbit() {
-	log.Println("Starting orbit")
-	for {
-		for _, groundStation := range s.groundStations {
-			s.CommunicateWithGroundStation(groundStation, []byte("Hello, world!"))
-		}
-
-		time.Sleep(10 * time.Second)
-	}
-}
-
-// GroundStation represents a ground station
-type GroundStation struct {
-	id string
+// Scheduler returns the scheduler that sends data during contacts
+func (s *Satellite) Scheduler() *Scheduler {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.scheduler
 }
 
-// NewGroundStation returns a new GroundStation instance
-func NewGroundStation(id string) *GroundStation {
-	return &GroundStation{
-		id: id,
-	}
+// SetScheduler replaces the scheduler, for example to run the contact plan
+// on a simulated clock. It must be called before StartOrbit.
+func (s *Satellite) SetScheduler(config SchedulerConfig) *Scheduler {
+	scheduler := NewScheduler(s, config)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scheduler = scheduler
+	return scheduler
 }
 
-// ReceiveData receives data from the satellite
-func (g *GroundStation) ReceiveData(data []byte) error {
-	log.Printf("Received data from satellite: %s", data)
-	return nil
+// SetContactPlan replaces the contact plan of the satellite's scheduler
+func (s *Satellite) SetContactPlan(plan ContactPlan) {
+	s.Scheduler().SetPlan(plan)
 }
 
-// Transceiver represents a transceiver
-type Transceiver struct {
-	id string
+// Send queues data for a ground station until the satellite is in contact
+// with it
+func (s *Satellite) Send(station string, data []byte) {
+	s.Scheduler().Send(station, data)
 }
 
-// NewTransceiver returns a new Transceiver instance
-func NewTransceiver(id string) *Transceiver {
-	return &Transceiver{
-		id: id,
-	}
+// StartOrbit runs the satellite's scheduler, sending queued data to ground
+// stations during contacts, until ctx is done
+func (s *Satellite) StartOrbit(ctx context.Context) {
+	log.Println("Starting orbit")
+	s.Scheduler().Run(ctx)
 }
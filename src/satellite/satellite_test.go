@@ -1,7 +1,9 @@
 package satellite
 
 import (
+	"context"
 	"testing"
+	"time"
 )
 
 func TestSatellite(t *testing.T) {
@@ -26,7 +28,45 @@ func TestSatellite(t *testing.T) {
 		t.Errorf("Error communicating with multiple ground stations: %v", err)
 	}
 
-	go satellite.StartOrbit()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	satellite.StartOrbit(ctx)
+}
+
+func TestSatellite_Position(t *testing.T) {
+	satellite := NewSatellite("vanguard")
+	if _, err := satellite.Position(time.Now()); err != ErrNoTLE {
+		t.Errorf("Expected Position to fail without a TLE, got %v", err)
+	}
+	tle, err := ParseTLE("VANGUARD 1", vanguardLine1, vanguardLine2)
+	if err != nil {
+		t.Fatalf("Expected ParseTLE to succeed, got %v", err)
+	}
+	if err := satellite.SetTLE(tle); err != nil {
+		t.Fatalf("Expected SetTLE to succeed, got %v", err)
+	}
+	if satellite.TLE() != tle {
+		t.Errorf("Expected TLE to return the elements")
+	}
 
-	time.Sleep(30 * time.Second)
+	at := tle.Epoch.Add(time.Hour)
+	state, err := satellite.Position(at)
+	if err != nil {
+		t.Fatalf("Expected Position to succeed, got %v", err)
+	}
+	ecef, err := satellite.PositionECEF(at)
+	if err != nil {
+		t.Fatalf("Expected PositionECEF to succeed, got %v", err)
+	}
+	if d := ecef.Position.Norm() - state.Position.Norm(); d > 1e-6 || d < -1e-6 {
+		t.Errorf("Expected the Earth-fixed position to be a rotation of the TEME position")
+	}
+	geodetic, err := satellite.Geodetic(at)
+	if err != nil {
+		t.Fatalf("Expected Geodetic to succeed, got %v", err)
+	}
+	// Vanguard 1 orbits between about 650 and 3850 km at 34 degrees
+	if geodetic.Altitude < 600 || geodetic.Altitude > 3900 || geodetic.Latitude < -35 || geodetic.Latitude > 35 {
+		t.Errorf("Expected a geodetic position on Vanguard 1's orbit, got %+v", geodetic)
+	}
 }
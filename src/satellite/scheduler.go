@@ -0,0 +1,196 @@
+package satellite
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultRetryInterval is how long the scheduler waits before retrying a
+// failed transmission within a contact
+const DefaultRetryInterval = 10 * time.Second
+
+// SchedulerConfig is the configuration of a Scheduler
+type SchedulerConfig struct {
+	// Clock is the source of time, SystemClock if nil
+	Clock Clock
+
+	// Plan is the initial contact plan
+	Plan ContactPlan
+
+	// RetryInterval is how long to wait before retrying a failed
+	// transmission, DefaultRetryInterval if zero
+	RetryInterval time.Duration
+}
+
+// Scheduler queues data for ground stations and sends it only while the
+// satellite is in contact with them
+type Scheduler struct {
+	satellite     *Satellite
+	clock         Clock
+	retryInterval time.Duration
+
+	mutex  sync.Mutex
+	plan   ContactPlan
+	queues map[string][][]byte
+	retry  map[string]time.Time
+	wake   chan struct{}
+
+	tickMutex sync.Mutex
+}
+
+// NewScheduler returns a new Scheduler for the satellite
+func NewScheduler(satellite *Satellite, config SchedulerConfig) *Scheduler {
+	if config.Clock == nil {
+		config.Clock = SystemClock
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = DefaultRetryInterval
+	}
+	return &Scheduler{
+		satellite:     satellite,
+		clock:         config.Clock,
+		retryInterval: config.RetryInterval,
+		plan:          NewContactPlan(config.Plan),
+		queues:        make(map[string][][]byte),
+		retry:         make(map[string]time.Time),
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Clock returns the scheduler's clock
+func (s *Scheduler) Clock() Clock {
+	return s.clock
+}
+
+// SetPlan replaces the contact plan
+func (s *Scheduler) SetPlan(plan ContactPlan) {
+	s.mutex.Lock()
+	s.plan = NewContactPlan(plan)
+	s.mutex.Unlock()
+	s.signal()
+}
+
+// Plan returns the contact plan
+func (s *Scheduler) Plan() ContactPlan {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.plan
+}
+
+// Send queues data for a ground station. It is sent at the station's next
+// contact, or immediately if the station is in contact now.
+func (s *Scheduler) Send(station string, data []byte) {
+	s.mutex.Lock()
+	s.queues[station] = append(s.queues[station], append([]byte(nil), data...))
+	s.mutex.Unlock()
+	s.signal()
+}
+
+// Queued returns the number of messages waiting for a ground station
+func (s *Scheduler) Queued(station string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.queues[station])
+}
+
+// signal wakes the scheduler's loop
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Tick sends the queued data for every ground station in contact at the
+// current time and returns when the scheduler next needs to run, or the zero
+// time if no contacts remain
+func (s *Scheduler) Tick() time.Time {
+	s.tickMutex.Lock()
+	defer s.tickMutex.Unlock()
+
+	now := s.clock.Now()
+	s.mutex.Lock()
+	plan := s.plan
+	s.mutex.Unlock()
+
+	var next time.Time
+	wakeAt := func(t time.Time) {
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	for _, contact := range plan.Active(now) {
+		if retry := s.drain(contact, now); !retry.IsZero() && retry.Before(contact.End) {
+			wakeAt(retry)
+		}
+	}
+	if contact, ok := plan.Next(now); ok {
+		wakeAt(contact.Start)
+	}
+	return next
+}
+
+// drain sends the queue for a contact's ground station. If a transmission
+// fails it returns when to retry.
+func (s *Scheduler) drain(contact Contact, now time.Time) time.Time {
+	s.mutex.Lock()
+	retry := s.retry[contact.Station]
+	s.mutex.Unlock()
+	if now.Before(retry) {
+		return retry
+	}
+
+	station := s.satellite.groundStation(contact.Station)
+	if station == nil {
+		log.Printf("Contact with unknown ground station %s", contact.Station)
+		return time.Time{}
+	}
+	for {
+		s.mutex.Lock()
+		queue := s.queues[contact.Station]
+		if len(queue) == 0 {
+			delete(s.queues, contact.Station)
+			delete(s.retry, contact.Station)
+			s.mutex.Unlock()
+			return time.Time{}
+		}
+		data := queue[0]
+		s.mutex.Unlock()
+
+		if err := s.satellite.CommunicateWithGroundStation(station, data); err != nil {
+			log.Printf("Failed to send to ground station %s: %v", contact.Station, err)
+			retry = now.Add(s.retryInterval)
+			s.mutex.Lock()
+			s.retry[contact.Station] = retry
+			s.mutex.Unlock()
+			return retry
+		}
+
+		s.mutex.Lock()
+		s.queues[contact.Station] = s.queues[contact.Station][1:]
+		s.mutex.Unlock()
+	}
+}
+
+// Run sends queued data during contacts until ctx is done. A pending timer
+// is kept across wakeups until the next run time changes, so wakeups do not
+// pile up timers on the clock.
+func (s *Scheduler) Run(ctx context.Context) {
+	var timer <-chan time.Time
+	var timerAt time.Time
+	for {
+		if next := s.Tick(); !next.IsZero() && (timer == nil || !next.Equal(timerAt)) {
+			timer = s.clock.After(next.Sub(s.clock.Now()))
+			timerAt = next
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer:
+			timer = nil
+		case <-s.wake:
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package satellite
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the data a ground station receives
+type recorder struct {
+	mutex    sync.Mutex
+	received []string
+	fail     int
+}
+
+// handle records data, failing while fail is positive
+func (r *recorder) handle(data []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.fail > 0 {
+		r.fail--
+		return errors.New("antenna not ready")
+	}
+	r.received = append(r.received, string(data))
+	return nil
+}
+
+// count returns the number of messages received
+func (r *recorder) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.received)
+}
+
+// newSchedulerTest returns a satellite with two recording ground stations
+// and a simulated clock
+func newSchedulerTest(start time.Time, plan ContactPlan) (*Satellite, *SimulatedClock, *recorder, *recorder) {
+	satellite := NewSatellite("sat1")
+	first, second := &recorder{}, &recorder{}
+	gs1 := NewGroundStation("gs1")
+	gs1.Handler = first.handle
+	gs2 := NewGroundStation("gs2")
+	gs2.Handler = second.handle
+	satellite.AddGroundStation(gs1)
+	satellite.AddGroundStation(gs2)
+
+	clock := NewSimulatedClock(start)
+	satellite.SetScheduler(SchedulerConfig{Clock: clock, Plan: plan, RetryInterval: time.Minute})
+	return satellite, clock, first, second
+}
+
+func TestScheduler_Tick(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	plan := NewContactPlan([]Contact{
+		{Station: "gs1", Start: start.Add(time.Hour), End: start.Add(70 * time.Minute)},
+		{Station: "gs2", Start: start.Add(2 * time.Hour), End: start.Add(130 * time.Minute)},
+	})
+	satellite, clock, first, second := newSchedulerTest(start, plan)
+	scheduler := satellite.Scheduler()
+
+	satellite.Send("gs1", []byte("one"))
+	satellite.Send("gs2", []byte("two"))
+	if next := scheduler.Tick(); !next.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected the next wakeup at the first contact, got %v", next)
+	}
+	if first.count() != 0 || scheduler.Queued("gs1") != 1 {
+		t.Errorf("Expected data to be queued outside a contact")
+	}
+
+	clock.Advance(time.Hour)
+	if next := scheduler.Tick(); !next.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("Expected the next wakeup at the second contact, got %v", next)
+	}
+	if first.count() != 1 || second.count() != 0 || scheduler.Queued("gs1") != 0 {
+		t.Errorf("Expected only gs1 to receive data during its contact")
+	}
+
+	// Data sent during a contact goes out straight away
+	clock.Advance(5 * time.Minute)
+	satellite.Send("gs1", []byte("three"))
+	scheduler.Tick()
+	if first.count() != 2 {
+		t.Errorf("Expected data to be sent during a contact")
+	}
+
+	// After LOS data waits, and is not sent during another station's contact
+	clock.Advance(10 * time.Minute)
+	satellite.Send("gs1", []byte("four"))
+	clock.AdvanceTo(start.Add(2 * time.Hour))
+	if next := scheduler.Tick(); !next.IsZero() {
+		t.Errorf("Expected no more wakeups, got %v", next)
+	}
+	if second.count() != 1 || first.count() != 2 || scheduler.Queued("gs1") != 1 {
+		t.Errorf("Expected gs2 to receive its data and gs1 to keep waiting")
+	}
+}
+
+func TestScheduler_Retry(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	plan := NewContactPlan([]Contact{
+		{Station: "gs1", Start: start, End: start.Add(10 * time.Minute)},
+	})
+	satellite, clock, first, _ := newSchedulerTest(start, plan)
+	scheduler := satellite.Scheduler()
+	first.fail = 1
+
+	satellite.Send("gs1", []byte("one"))
+	satellite.Send("gs1", []byte("two"))
+	if next := scheduler.Tick(); !next.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected a retry after a minute, got %v", next)
+	}
+	if first.count() != 0 || scheduler.Queued("gs1") != 2 {
+		t.Errorf("Expected failed data to stay queued in order")
+	}
+
+	// Ticks before the retry time do not resend
+	scheduler.Tick()
+	if first.fail != 0 || first.count() != 0 {
+		t.Errorf("Expected no resend before the retry interval")
+	}
+
+	clock.Advance(time.Minute)
+	scheduler.Tick()
+	if first.count() != 2 || first.received[0] != "one" {
+		t.Errorf("Expected the queue to be sent in order on retry, got %v", first.received)
+	}
+}
+
+func TestSatellite_StartOrbit(t *testing.T) {
+	satellite := newTestSatellite(t)
+	start := satellite.TLE().Epoch
+	station := NewGroundStationAt("gs1", Geodetic{Latitude: 28.5, Longitude: -80.6}, 10)
+	received := make(chan time.Time, 10)
+	satellite.AddGroundStation(station)
+
+	passes, err := satellite.Passes(station, start, start.Add(24*time.Hour), 0)
+	if err != nil || len(passes) < 2 {
+		t.Fatalf("Expected passes to plan with, got %d, %v", len(passes), err)
+	}
+	clock := NewSimulatedClock(start)
+	scheduler := satellite.SetScheduler(SchedulerConfig{Clock: clock, Plan: ContactPlanFromPasses(passes)})
+	station.Handler = func(data []byte) error {
+		received <- clock.Now()
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		satellite.StartOrbit(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Simulate a day, jumping from one wakeup to the next
+	satellite.Send("gs1", []byte("telemetry"))
+	for clock.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	next, _ := clock.Next()
+	clock.AdvanceTo(next)
+	select {
+	case at := <-received:
+		if !at.Equal(passes[0].AOS) {
+			t.Errorf("Expected the data to be sent at the first AOS %v, got %v", passes[0].AOS, at)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the data to be sent at the first contact")
+	}
+
+	// Between passes data waits for the next AOS
+	clock.AdvanceTo(passes[0].LOS.Add(time.Minute))
+	scheduler.Send("gs1", []byte("telemetry"))
+	clock.AdvanceTo(passes[1].AOS)
+	select {
+	case at := <-received:
+		if !at.Equal(passes[1].AOS) {
+			t.Errorf("Expected the data to be sent at the second AOS %v, got %v", passes[1].AOS, at)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the data to be sent at the second contact")
+	}
+}
+
+func TestScheduler_RunReusesTimer(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	plan := NewContactPlan([]Contact{
+		{Station: "gs1", Start: start.Add(time.Hour), End: start.Add(70 * time.Minute)},
+	})
+	satellite, clock, _, _ := newSchedulerTest(start, plan)
+	scheduler := satellite.Scheduler()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	for clock.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Wakeups that leave the next run time alone keep the pending timer
+	for i := 0; i < 10; i++ {
+		scheduler.SetPlan(plan)
+		time.Sleep(2 * time.Millisecond)
+	}
+	if pending := clock.Pending(); pending != 1 {
+		t.Errorf("Expected a single pending timer, got %d", pending)
+	}
+}
@@ -0,0 +1,473 @@
+// Package sdls secures the telecommand uplink after the CCSDS Space Data
+// Link Security protocol (CCSDS 355.0-B). Each TC frame carries a security
+// header naming a security association and an initialization vector that
+// holds the anti-replay sequence number, and its data field is encrypted and
+// authenticated with AES-GCM under the association's key. Keys are uploaded,
+// switched and destroyed with management commands sent over the secured
+// channel itself.
+package sdls
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/skybridge/crypto/encryption"
+	"github.com/skybridge/satellite/ccsds"
+)
+
+const (
+	// HeaderLength is the length of the security header, the security
+	// parameter index followed by the initialization vector
+	HeaderLength = 2 + encryption.NonceSize
+
+	// TrailerLength is the length of the security trailer, the MAC
+	TrailerLength = encryption.Overhead
+
+	// DefaultManagementAPID is the APID of management commands and
+	// security reports when none is given
+	DefaultManagementAPID = 0x7f0
+)
+
+var (
+	// ErrUnknownSA is returned for a frame or command naming a security
+	// association that is not set up
+	ErrUnknownSA = errors.New("sdls: unknown security association")
+
+	// ErrUnknownKey is returned when a key is not loaded
+	ErrUnknownKey = errors.New("sdls: unknown key")
+
+	// ErrInvalidKey is returned for a key that is not an AES key or is in
+	// use
+	ErrInvalidKey = errors.New("sdls: invalid key")
+
+	// ErrAuthentication is returned when a frame fails authentication
+	ErrAuthentication = errors.New("sdls: frame failed authentication")
+
+	// ErrReplay is returned for a frame whose sequence number is not newer
+	// than the last accepted or is outside the replay window
+	ErrReplay = errors.New("sdls: sequence number rejected")
+
+	// ErrMalformed is returned for a frame too short to be secured
+	ErrMalformed = errors.New("sdls: malformed secured frame")
+
+	// ErrStateNotRestored is returned when protecting frames before the
+	// sequence numbers saved by an earlier run are loaded
+	ErrStateNotRestored = errors.New("sdls: security association state not restored")
+)
+
+// Config is the configuration of a secured TC channel
+type Config struct {
+	// SpacecraftID identifies the spacecraft
+	SpacecraftID uint16
+
+	// FECF adds a frame error control field to each frame
+	FECF bool
+
+	// SecondaryHeaderLength is the length of the packet secondary headers
+	SecondaryHeaderLength int
+
+	// ManagementAPID is the APID of management commands and security
+	// reports, DefaultManagementAPID if zero
+	ManagementAPID uint16
+
+	// ReplayWindow is how far ahead of the last accepted sequence number a
+	// frame may be, or zero to accept any newer frame
+	ReplayWindow uint64
+
+	// StatePath is the file the sequence numbers of the security
+	// associations are saved to, so they carry on across restarts
+	StatePath string
+}
+
+// SecurityAssociation binds a virtual channel to a key and keeps the
+// sequence number of the frames sent or accepted on it
+type SecurityAssociation struct {
+	// SPI is the security parameter index naming the association
+	SPI uint16
+
+	// VirtualChannel is the virtual channel the association secures
+	VirtualChannel uint8
+
+	// KeyID is the key the association encrypts with
+	KeyID uint16
+
+	// Sequence is the sequence number of the last frame sent or accepted.
+	// It carries on across key changes, so an initialization vector is
+	// never reused even if the association returns to an earlier key.
+	Sequence uint64
+}
+
+// Counters count the frames a receiving endpoint accepted and rejected
+type Counters struct {
+	Accepted       uint64 `json:"accepted"`
+	UnknownSA      uint64 `json:"unknownSA"`
+	Authentication uint64 `json:"authentication"`
+	Replay         uint64 `json:"replay"`
+	Malformed      uint64 `json:"malformed"`
+	Commands       uint64 `json:"commands"`
+	BadCommands    uint64 `json:"badCommands"`
+}
+
+// Rejected returns the number of frames rejected
+func (c Counters) Rejected() uint64 {
+	return c.UnknownSA + c.Authentication + c.Replay + c.Malformed
+}
+
+// Endpoint is one end of a secured TC channel, protecting frames on the
+// ground and processing them on the spacecraft
+type Endpoint struct {
+	config Config
+
+	mutex        sync.Mutex
+	keys         map[uint16]*encryption.Encryption
+	associations map[uint16]*SecurityAssociation
+	sequences    [ccsds.MaxTCVirtualChannel + 1]uint8
+	counters     Counters
+	lastSPI      uint16
+	saved        map[uint16]uint64
+	restored     bool
+}
+
+// NewEndpoint returns a new Endpoint instance
+func NewEndpoint(config Config) *Endpoint {
+	if config.ManagementAPID == 0 {
+		config.ManagementAPID = DefaultManagementAPID
+	}
+	return &Endpoint{
+		config:       config,
+		keys:         make(map[uint16]*encryption.Encryption),
+		associations: make(map[uint16]*SecurityAssociation),
+		saved:        make(map[uint16]uint64),
+	}
+}
+
+// Load restores the sequence numbers saved at the state path by an earlier
+// run. A sender must load them before protecting frames, even on its first
+// run, since restarting an association at zero would reuse initialization
+// vectors under its key. A missing file is not an error.
+func (e *Endpoint) Load() error {
+	if e.config.StatePath == "" {
+		return fmt.Errorf("%w: no state path", ErrStateNotRestored)
+	}
+	saved := make(map[uint16]uint64)
+	data, err := os.ReadFile(e.config.StatePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return err
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for spi, sequence := range saved {
+		if sequence > e.saved[spi] {
+			e.saved[spi] = sequence
+		}
+		if sa, ok := e.associations[spi]; ok && sequence > sa.Sequence {
+			sa.Sequence = sequence
+		}
+	}
+	e.restored = true
+	return nil
+}
+
+// save records the sequence number of a security association at the state
+// path with the mutex held. The file is synced before it replaces the old
+// one, so a sequence number is on disk before it is used.
+func (e *Endpoint) save(spi uint16, sequence uint64) error {
+	e.saved[spi] = sequence
+	data, err := json.Marshal(e.saved)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(e.config.StatePath), 0755); err != nil {
+		return err
+	}
+	tmp := e.config.StatePath + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, e.config.StatePath)
+}
+
+// AddKey loads a 128, 192 or 256 bit AES key
+func (e *Endpoint) AddKey(id uint16, key []byte) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.addKey(id, key)
+}
+
+// addKey loads a key with the mutex held. A key a security association
+// uses cannot be replaced; the association must be rekeyed to a new key id.
+func (e *Endpoint) addKey(id uint16, key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("%w: %d octets", ErrInvalidKey, len(key))
+	}
+	for _, sa := range e.associations {
+		if sa.KeyID == id {
+			return fmt.Errorf("%w: key %d is used by SA %d", ErrInvalidKey, id, sa.SPI)
+		}
+	}
+	cipher, err := encryption.NewEncryption(string(key))
+	if err != nil {
+		return err
+	}
+	e.keys[id] = cipher
+	return nil
+}
+
+// DestroyKey erases a key that no security association uses
+func (e *Endpoint) DestroyKey(id uint16) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.destroyKey(id)
+}
+
+// destroyKey erases a key with the mutex held
+func (e *Endpoint) destroyKey(id uint16) error {
+	if _, ok := e.keys[id]; !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownKey, id)
+	}
+	for _, sa := range e.associations {
+		if sa.KeyID == id {
+			return fmt.Errorf("%w: key %d is used by SA %d", ErrInvalidKey, id, sa.SPI)
+		}
+	}
+	delete(e.keys, id)
+	return nil
+}
+
+// AddSecurityAssociation sets up a security association securing a virtual
+// channel with a loaded key
+func (e *Endpoint) AddSecurityAssociation(spi uint16, virtualChannel uint8, keyID uint16) error {
+	if virtualChannel > ccsds.MaxTCVirtualChannel {
+		return fmt.Errorf("%w: virtual channel %d", ccsds.ErrInvalidFrame, virtualChannel)
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if _, ok := e.keys[keyID]; !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownKey, keyID)
+	}
+	e.associations[spi] = &SecurityAssociation{SPI: spi, VirtualChannel: virtualChannel, KeyID: keyID, Sequence: e.saved[spi]}
+	return nil
+}
+
+// Rekey switches a security association to another loaded key
+func (e *Endpoint) Rekey(spi, keyID uint16) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.rekey(spi, keyID)
+}
+
+// rekey switches the key of a security association with the mutex held
+func (e *Endpoint) rekey(spi, keyID uint16) error {
+	sa, ok := e.associations[spi]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownSA, spi)
+	}
+	if _, ok := e.keys[keyID]; !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownKey, keyID)
+	}
+	sa.KeyID = keyID
+	return nil
+}
+
+// SecurityAssociation returns a copy of a security association
+func (e *Endpoint) SecurityAssociation(spi uint16) (SecurityAssociation, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	sa, ok := e.associations[spi]
+	if !ok {
+		return SecurityAssociation{}, false
+	}
+	return *sa, true
+}
+
+// Counters returns the frames accepted and rejected so far
+func (e *Endpoint) Counters() Counters {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.counters
+}
+
+// Protect returns secured TC frames carrying packets, one frame per packet,
+// on the virtual channel of a security association. The sequence numbers
+// are saved before any frame is sealed, so Load must have been called.
+func (e *Endpoint) Protect(spi uint16, packets ...*ccsds.SpacePacket) ([][]byte, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	sa, ok := e.associations[spi]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownSA, spi)
+	}
+	if !e.restored {
+		return nil, fmt.Errorf("%w: SA %d", ErrStateNotRestored, spi)
+	}
+	cipher, ok := e.keys[sa.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKey, sa.KeyID)
+	}
+
+	// Sequence numbers reserved but left unused after a failure are
+	// skipped, which the receiver accepts
+	if err := e.save(spi, sa.Sequence+uint64(len(packets))); err != nil {
+		return nil, fmt.Errorf("saving SA %d state: %w", spi, err)
+	}
+
+	var frames [][]byte
+	for _, packet := range packets {
+		plaintext, err := packet.Encode()
+		if err != nil {
+			return frames, err
+		}
+		frame := &ccsds.TCFrame{
+			SpacecraftID:   e.config.SpacecraftID,
+			VirtualChannel: sa.VirtualChannel,
+			Sequence:       e.sequences[sa.VirtualChannel],
+			Data:           make([]byte, HeaderLength+len(plaintext)+TrailerLength),
+		}
+
+		// The primary and security headers are authenticated, so the
+		// frame is encoded once with an empty data field to learn them
+		header := frame.Data[:HeaderLength]
+		binary.BigEndian.PutUint16(header, spi)
+		iv := header[2:]
+		binary.BigEndian.PutUint16(iv, spi)
+		binary.BigEndian.PutUint64(iv[4:], sa.Sequence+1)
+		unsecured, err := frame.Encode(e.config.FECF)
+		if err != nil {
+			return frames, err
+		}
+		aad := unsecured[:ccsds.TCHeaderLength+HeaderLength]
+		sealed, err := cipher.Seal(iv, plaintext, aad)
+		if err != nil {
+			return frames, err
+		}
+		copy(frame.Data[HeaderLength:], sealed)
+
+		encoded, err := frame.Encode(e.config.FECF)
+		if err != nil {
+			return frames, err
+		}
+		sa.Sequence++
+		e.sequences[sa.VirtualChannel]++
+		frames = append(frames, encoded)
+	}
+	return frames, nil
+}
+
+// Process verifies and decrypts a secured TC frame and returns the packets
+// it carries, updating the counters. Management commands in the frame are
+// carried out and left out of the packets returned.
+func (e *Endpoint) Process(data []byte) (*ccsds.TCFrame, []*ccsds.SpacePacket, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	frame, packets, err := e.open(data)
+	if err != nil {
+		return frame, nil, err
+	}
+	e.counters.Accepted++
+
+	var user []*ccsds.SpacePacket
+	for _, packet := range packets {
+		if packet.APID != e.config.ManagementAPID {
+			user = append(user, packet)
+			continue
+		}
+		if err := e.execute(packet); err != nil {
+			e.counters.BadCommands++
+			return frame, user, err
+		}
+		e.counters.Commands++
+	}
+	return frame, user, nil
+}
+
+// open authenticates a frame against its security association and returns
+// its packets, counting rejections
+func (e *Endpoint) open(data []byte) (*ccsds.TCFrame, []*ccsds.SpacePacket, error) {
+	frame, _, err := ccsds.DecodeTCFrame(data, e.config.FECF)
+	if err != nil {
+		e.counters.Malformed++
+		return nil, nil, err
+	}
+	if frame.SpacecraftID != e.config.SpacecraftID {
+		e.counters.Malformed++
+		return frame, nil, fmt.Errorf("%w: spacecraft %d", ccsds.ErrWrongSpacecraft, frame.SpacecraftID)
+	}
+	if len(frame.Data) < HeaderLength+TrailerLength {
+		e.counters.Malformed++
+		return frame, nil, fmt.Errorf("%w: data field of %d octets", ErrMalformed, len(frame.Data))
+	}
+
+	header := frame.Data[:HeaderLength]
+	spi := binary.BigEndian.Uint16(header)
+	e.lastSPI = spi
+	sa, ok := e.associations[spi]
+	if !ok || sa.VirtualChannel != frame.VirtualChannel {
+		e.counters.UnknownSA++
+		return frame, nil, fmt.Errorf("%w: %d on virtual channel %d", ErrUnknownSA, spi, frame.VirtualChannel)
+	}
+	cipher, ok := e.keys[sa.KeyID]
+	if !ok {
+		e.counters.UnknownSA++
+		return frame, nil, fmt.Errorf("%w: %d", ErrUnknownKey, sa.KeyID)
+	}
+
+	iv := header[2:]
+	aad := append(append([]byte(nil), data[:ccsds.TCHeaderLength]...), header...)
+	plaintext, err := cipher.Open(iv, frame.Data[HeaderLength:], aad)
+	if err != nil {
+		e.counters.Authentication++
+		return frame, nil, ErrAuthentication
+	}
+
+	// The sequence number is checked after authentication so a forged
+	// frame cannot advance it
+	sequence := binary.BigEndian.Uint64(iv[4:])
+	if sequence <= sa.Sequence || e.config.ReplayWindow > 0 && sequence-sa.Sequence > e.config.ReplayWindow {
+		e.counters.Replay++
+		return frame, nil, fmt.Errorf("%w: %d after %d", ErrReplay, sequence, sa.Sequence)
+	}
+	sa.Sequence = sequence
+	if e.restored {
+		if err := e.save(spi, sequence); err != nil {
+			return frame, nil, fmt.Errorf("saving SA %d state: %w", spi, err)
+		}
+	}
+
+	frame.Data = plaintext
+	var packets []*ccsds.SpacePacket
+	for field := plaintext; len(field) > 0; {
+		packet, n, err := ccsds.DecodeSpacePacket(field, e.config.SecondaryHeaderLength)
+		if err != nil {
+			e.counters.Malformed++
+			return frame, nil, err
+		}
+		field = field[n:]
+		packets = append(packets, packet)
+	}
+	return frame, packets, nil
+}
@@ -0,0 +1,250 @@
+package sdls
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/skybridge/satellite/ccsds"
+)
+
+var (
+	testKey  = bytes.Repeat([]byte{0x11}, 32)
+	otherKey = bytes.Repeat([]byte{0x22}, 32)
+)
+
+// newTestPair returns a ground and a spacecraft endpoint sharing key 1 on
+// SA 5 over virtual channel 2
+func newTestPair(t *testing.T, config Config) (*Endpoint, *Endpoint) {
+	dir := t.TempDir()
+	return newTestEndpoint(t, config, filepath.Join(dir, "ground.json")), newTestEndpoint(t, config, filepath.Join(dir, "spacecraft.json"))
+}
+
+// newTestEndpoint returns an endpoint keeping its state at path with key 1
+// on SA 5 over virtual channel 2
+func newTestEndpoint(t *testing.T, config Config, path string) *Endpoint {
+	config.StatePath = path
+	endpoint := NewEndpoint(config)
+	if err := endpoint.Load(); err != nil {
+		t.Fatalf("Expected Load to succeed, got %v", err)
+	}
+	if err := endpoint.AddKey(1, testKey); err != nil {
+		t.Fatalf("Expected AddKey to succeed, got %v", err)
+	}
+	if err := endpoint.AddSecurityAssociation(5, 2, 1); err != nil {
+		t.Fatalf("Expected AddSecurityAssociation to succeed, got %v", err)
+	}
+	return endpoint
+}
+
+func TestEndpoint_Protect(t *testing.T) {
+	ground, spacecraft := newTestPair(t, Config{SpacecraftID: 42, FECF: true})
+	command := ccsds.NewSpacePacket(ccsds.Telecommand, 100, []byte("open valve"))
+	frames, err := ground.Protect(5, command, command)
+	if err != nil || len(frames) != 2 {
+		t.Fatalf("Expected a frame per packet, got %d, %v", len(frames), err)
+	}
+	if bytes.Contains(frames[0], []byte("open valve")) {
+		t.Errorf("Expected the command to be encrypted")
+	}
+	if bytes.Equal(frames[0], frames[1]) {
+		t.Errorf("Expected each frame to use a new initialization vector")
+	}
+
+	for i, data := range frames {
+		frame, packets, err := spacecraft.Process(data)
+		if err != nil {
+			t.Fatalf("Expected Process to succeed, got %v", err)
+		}
+		if frame.VirtualChannel != 2 || frame.Sequence != uint8(i) {
+			t.Errorf("Expected frame %d on the association's channel, got %+v", i, frame)
+		}
+		if len(packets) != 1 || packets[0].APID != 100 || string(packets[0].Data) != "open valve" {
+			t.Errorf("Expected the command, got %+v", packets)
+		}
+	}
+	if sa, _ := spacecraft.SecurityAssociation(5); sa.Sequence != 2 {
+		t.Errorf("Expected the accepted sequence number to advance, got %d", sa.Sequence)
+	}
+	if counters := spacecraft.Counters(); counters.Accepted != 2 || counters.Rejected() != 0 {
+		t.Errorf("Expected two accepted frames, got %+v", counters)
+	}
+
+	if _, err := ground.Protect(6, command); !errors.Is(err, ErrUnknownSA) {
+		t.Errorf("Expected an unknown association to fail, got %v", err)
+	}
+}
+
+func TestEndpoint_Restart(t *testing.T) {
+	dir := t.TempDir()
+	config := Config{SpacecraftID: 42}
+	ground := newTestEndpoint(t, config, filepath.Join(dir, "ground.json"))
+	spacecraft := newTestEndpoint(t, config, filepath.Join(dir, "spacecraft.json"))
+	command := ccsds.NewSpacePacket(ccsds.Telecommand, 100, []byte("open valve"))
+	before, _ := ground.Protect(5, command, command)
+	for _, data := range before {
+		if _, _, err := spacecraft.Process(data); err != nil {
+			t.Fatalf("Expected Process to succeed, got %v", err)
+		}
+	}
+
+	// A restarted sender refuses to protect frames until it loads its state
+	config.StatePath = filepath.Join(dir, "ground.json")
+	unrestored := NewEndpoint(config)
+	unrestored.AddKey(1, testKey)
+	unrestored.AddSecurityAssociation(5, 2, 1)
+	if _, err := unrestored.Protect(5, command); !errors.Is(err, ErrStateNotRestored) {
+		t.Errorf("Expected Protect before Load to fail, got %v", err)
+	}
+
+	// Once loaded it carries on after the last sequence number, so the
+	// initialization vector is not reused
+	ground = newTestEndpoint(t, Config{SpacecraftID: 42}, filepath.Join(dir, "ground.json"))
+	if sa, _ := ground.SecurityAssociation(5); sa.Sequence != 2 {
+		t.Errorf("Expected the restored sequence number to be 2, got %d", sa.Sequence)
+	}
+	after, err := ground.Protect(5, command)
+	if err != nil {
+		t.Fatalf("Expected Protect to succeed, got %v", err)
+	}
+	if bytes.Equal(after[0][ccsds.TCHeaderLength:ccsds.TCHeaderLength+HeaderLength], before[0][ccsds.TCHeaderLength:ccsds.TCHeaderLength+HeaderLength]) {
+		t.Errorf("Expected a new initialization vector after the restart")
+	}
+
+	// A restarted receiver still rejects the frames it accepted
+	spacecraft = newTestEndpoint(t, Config{SpacecraftID: 42}, filepath.Join(dir, "spacecraft.json"))
+	if _, _, err := spacecraft.Process(before[1]); !errors.Is(err, ErrReplay) {
+		t.Errorf("Expected a frame replayed after the restart to be rejected, got %v", err)
+	}
+	if _, _, err := spacecraft.Process(after[0]); err != nil {
+		t.Errorf("Expected the new frame to be accepted, got %v", err)
+	}
+}
+
+func TestEndpoint_ProcessRejects(t *testing.T) {
+	ground, spacecraft := newTestPair(t, Config{SpacecraftID: 42, FECF: true})
+	command := ccsds.NewSpacePacket(ccsds.Telecommand, 100, []byte("fire thruster"))
+	frames, _ := ground.Protect(5, command)
+	if _, _, err := spacecraft.Process(frames[0]); err != nil {
+		t.Fatalf("Expected Process to succeed, got %v", err)
+	}
+
+	// Replaying an accepted frame
+	if _, _, err := spacecraft.Process(frames[0]); !errors.Is(err, ErrReplay) {
+		t.Errorf("Expected a replayed frame to be rejected, got %v", err)
+	}
+
+	// Tampering with the ciphertext or the authenticated header, with a
+	// valid FECF so only the MAC catches it
+	frames, _ = ground.Protect(5, command)
+	for _, offset := range []int{ccsds.TCHeaderLength + HeaderLength, 4} {
+		tampered := refresh(frames[0], offset)
+		if _, _, err := spacecraft.Process(tampered); err != ErrAuthentication {
+			t.Errorf("Expected tampering at %d to fail authentication, got %v", offset, err)
+		}
+	}
+
+	// A frame naming another association or sent on another channel
+	unknown := append([]byte(nil), frames[0]...)
+	unknown[ccsds.TCHeaderLength+1] = 9
+	if _, _, err := spacecraft.Process(refresh(unknown, -1)); !errors.Is(err, ErrUnknownSA) {
+		t.Errorf("Expected an unknown SPI to be rejected, got %v", err)
+	}
+
+	// Unsecured frames are rejected
+	plain, _ := (&ccsds.TCFrame{SpacecraftID: 42, VirtualChannel: 2, Data: []byte{1}}).Encode(true)
+	if _, _, err := spacecraft.Process(plain); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected an unsecured frame to be rejected, got %v", err)
+	}
+	if _, _, err := spacecraft.Process(frames[0][:3]); !errors.Is(err, ccsds.ErrTruncated) {
+		t.Errorf("Expected a short frame to be rejected, got %v", err)
+	}
+
+	counters := spacecraft.Counters()
+	if counters.Replay != 1 || counters.Authentication != 2 || counters.UnknownSA != 1 || counters.Malformed != 2 {
+		t.Errorf("Expected each rejection to be counted, got %+v", counters)
+	}
+
+	// The genuine frame is still accepted after the forgeries
+	if _, _, err := spacecraft.Process(frames[0]); err != nil {
+		t.Errorf("Expected the genuine frame to be accepted, got %v", err)
+	}
+}
+
+// refresh flips a bit at an offset of a frame, if not negative, and
+// recomputes its FECF
+func refresh(data []byte, offset int) []byte {
+	frame := append([]byte(nil), data...)
+	if offset >= 0 {
+		frame[offset] ^= 1
+	}
+	end := len(frame) - 2
+	crc := ccsds.CRC16(frame[:end])
+	frame[end], frame[end+1] = byte(crc>>8), byte(crc)
+	return frame
+}
+
+func TestEndpoint_ReplayWindow(t *testing.T) {
+	ground, spacecraft := newTestPair(t, Config{ReplayWindow: 2})
+	command := ccsds.NewSpacePacket(ccsds.Telecommand, 1, []byte{1})
+	frames, _ := ground.Protect(5, command, command, command, command)
+
+	// Frames lost in between are tolerated within the window
+	if _, _, err := spacecraft.Process(frames[1]); err != nil {
+		t.Errorf("Expected a frame within the window to be accepted, got %v", err)
+	}
+	if _, _, err := spacecraft.Process(frames[0]); !errors.Is(err, ErrReplay) {
+		t.Errorf("Expected an older frame to be rejected, got %v", err)
+	}
+	if _, _, err := spacecraft.Process(frames[3]); err != nil {
+		t.Errorf("Expected a frame at the edge of the window to be accepted, got %v", err)
+	}
+
+	frames, _ = ground.Protect(5, command, command, command)
+	if _, _, err := spacecraft.Process(frames[2]); !errors.Is(err, ErrReplay) {
+		t.Errorf("Expected a frame beyond the window to be rejected, got %v", err)
+	}
+}
+
+func TestEndpoint_Rekey(t *testing.T) {
+	ground, spacecraft := newTestPair(t, Config{})
+	if err := ground.AddKey(2, otherKey); err != nil {
+		t.Fatalf("Expected AddKey to succeed, got %v", err)
+	}
+	if err := ground.Rekey(5, 2); err != nil {
+		t.Fatalf("Expected Rekey to succeed, got %v", err)
+	}
+	frames, _ := ground.Protect(5, ccsds.NewSpacePacket(ccsds.Telecommand, 1, []byte{1}))
+	if _, _, err := spacecraft.Process(frames[0]); err != ErrAuthentication {
+		t.Errorf("Expected a frame under a key the spacecraft lacks to fail, got %v", err)
+	}
+
+	if err := ground.Rekey(5, 3); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected rekeying to an unknown key to fail, got %v", err)
+	}
+	if err := ground.Rekey(6, 2); !errors.Is(err, ErrUnknownSA) {
+		t.Errorf("Expected rekeying an unknown association to fail, got %v", err)
+	}
+	if err := ground.DestroyKey(2); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected destroying a key in use to fail, got %v", err)
+	}
+	if err := ground.DestroyKey(1); err != nil {
+		t.Errorf("Expected destroying an unused key to succeed, got %v", err)
+	}
+	if err := ground.DestroyKey(1); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected destroying a missing key to fail, got %v", err)
+	}
+	if err := ground.AddKey(2, testKey); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected replacing a key in use to fail, got %v", err)
+	}
+	if err := ground.AddKey(3, []byte("short")); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected a key of an invalid length to fail, got %v", err)
+	}
+	if err := ground.AddSecurityAssociation(7, 0, 9); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected an association with an unknown key to fail, got %v", err)
+	}
+	if err := ground.AddSecurityAssociation(7, ccsds.MaxTCVirtualChannel+1, 2); !errors.Is(err, ccsds.ErrInvalidFrame) {
+		t.Errorf("Expected an invalid virtual channel to fail, got %v", err)
+	}
+}
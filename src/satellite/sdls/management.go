@@ -0,0 +1,125 @@
+package sdls
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/skybridge/satellite/ccsds"
+)
+
+// ErrInvalidCommand is returned for a management command that cannot be
+// decoded
+var ErrInvalidCommand = errors.New("sdls: invalid management command")
+
+// Management command codes, the first octet of a management command
+const (
+	// CommandUploadKey loads a key, followed by the key id and the key
+	CommandUploadKey = 1
+
+	// CommandRekey switches a security association to a loaded key,
+	// followed by the SPI and the key id
+	CommandRekey = 2
+
+	// CommandDestroyKey erases a key no association uses, followed by the
+	// key id
+	CommandDestroyKey = 3
+
+	// CommandResetCounters clears the frame counters
+	CommandResetCounters = 4
+)
+
+// reportLength is the length of the data of a security report
+const reportLength = 2 + 7*8
+
+// UploadKeyCommand returns a management command loading a key. The command
+// is only as secret as the channel it is sent on, so it must be protected
+// with a key the spacecraft already holds.
+func (e *Endpoint) UploadKeyCommand(id uint16, key []byte) *ccsds.SpacePacket {
+	data := []byte{CommandUploadKey}
+	data = binary.BigEndian.AppendUint16(data, id)
+	return e.command(append(data, key...))
+}
+
+// RekeyCommand returns a management command switching a security
+// association to a loaded key. The sender calls Rekey once the command is
+// sent so both ends switch together.
+func (e *Endpoint) RekeyCommand(spi, keyID uint16) *ccsds.SpacePacket {
+	data := []byte{CommandRekey}
+	data = binary.BigEndian.AppendUint16(data, spi)
+	return e.command(binary.BigEndian.AppendUint16(data, keyID))
+}
+
+// DestroyKeyCommand returns a management command erasing a key
+func (e *Endpoint) DestroyKeyCommand(id uint16) *ccsds.SpacePacket {
+	return e.command(binary.BigEndian.AppendUint16([]byte{CommandDestroyKey}, id))
+}
+
+// ResetCountersCommand returns a management command clearing the counters
+func (e *Endpoint) ResetCountersCommand() *ccsds.SpacePacket {
+	return e.command([]byte{CommandResetCounters})
+}
+
+// command returns a management command packet
+func (e *Endpoint) command(data []byte) *ccsds.SpacePacket {
+	return ccsds.NewSpacePacket(ccsds.Telecommand, e.config.ManagementAPID, data)
+}
+
+// execute carries out a management command with the mutex held
+func (e *Endpoint) execute(packet *ccsds.SpacePacket) error {
+	data := packet.Data
+	if len(data) == 0 {
+		return fmt.Errorf("%w: empty command", ErrInvalidCommand)
+	}
+	code, args := data[0], data[1:]
+	switch {
+	case code == CommandUploadKey && len(args) > 2:
+		return e.addKey(binary.BigEndian.Uint16(args), args[2:])
+	case code == CommandRekey && len(args) == 4:
+		return e.rekey(binary.BigEndian.Uint16(args), binary.BigEndian.Uint16(args[2:]))
+	case code == CommandDestroyKey && len(args) == 2:
+		return e.destroyKey(binary.BigEndian.Uint16(args))
+	case code == CommandResetCounters && len(args) == 0:
+		e.counters = Counters{}
+		return nil
+	}
+	return fmt.Errorf("%w: code %d with %d octets", ErrInvalidCommand, code, len(args))
+}
+
+// Report is a security report sent in telemetry
+type Report struct {
+	// LastSPI is the SPI of the last frame received
+	LastSPI uint16 `json:"lastSPI"`
+
+	Counters
+}
+
+// Report returns a telemetry packet reporting the last SPI received and the
+// frame counters
+func (e *Endpoint) Report() *ccsds.SpacePacket {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	c := e.counters
+	data := binary.BigEndian.AppendUint16(make([]byte, 0, reportLength), e.lastSPI)
+	for _, count := range []uint64{c.Accepted, c.UnknownSA, c.Authentication, c.Replay, c.Malformed, c.Commands, c.BadCommands} {
+		data = binary.BigEndian.AppendUint64(data, count)
+	}
+	return ccsds.NewSpacePacket(ccsds.Telemetry, e.config.ManagementAPID, data)
+}
+
+// DecodeReport decodes the data of a security report packet
+func DecodeReport(packet *ccsds.SpacePacket) (Report, error) {
+	var report Report
+	if packet.Type != ccsds.Telemetry || len(packet.Data) != reportLength {
+		return report, fmt.Errorf("%w: not a security report", ErrMalformed)
+	}
+	report.LastSPI = binary.BigEndian.Uint16(packet.Data)
+	counts := []*uint64{
+		&report.Accepted, &report.UnknownSA, &report.Authentication, &report.Replay,
+		&report.Malformed, &report.Commands, &report.BadCommands,
+	}
+	for i, count := range counts {
+		*count = binary.BigEndian.Uint64(packet.Data[2+8*i:])
+	}
+	return report, nil
+}
@@ -0,0 +1,105 @@
+package sdls
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/skybridge/satellite/ccsds"
+)
+
+// send protects packets on SA 5 and processes them at the spacecraft
+func send(t *testing.T, ground, spacecraft *Endpoint, packets ...*ccsds.SpacePacket) ([]*ccsds.SpacePacket, error) {
+	frames, err := ground.Protect(5, packets...)
+	if err != nil {
+		t.Fatalf("Expected Protect to succeed, got %v", err)
+	}
+	var received []*ccsds.SpacePacket
+	for _, frame := range frames {
+		_, packets, err := spacecraft.Process(frame)
+		if err != nil {
+			return received, err
+		}
+		received = append(received, packets...)
+	}
+	return received, nil
+}
+
+func TestEndpoint_KeyRollover(t *testing.T) {
+	ground, spacecraft := newTestPair(t, Config{SpacecraftID: 42})
+	if err := ground.AddKey(2, otherKey); err != nil {
+		t.Fatalf("Expected AddKey to succeed, got %v", err)
+	}
+
+	// Upload the new key and switch to it over the channel itself
+	received, err := send(t, ground, spacecraft, ground.UploadKeyCommand(2, otherKey), ground.RekeyCommand(5, 2))
+	if err != nil || len(received) != 0 {
+		t.Fatalf("Expected the management commands to be carried out, got %d packets, %v", len(received), err)
+	}
+	if err := ground.Rekey(5, 2); err != nil {
+		t.Fatalf("Expected Rekey to succeed, got %v", err)
+	}
+	if sa, _ := spacecraft.SecurityAssociation(5); sa.KeyID != 2 || sa.Sequence != 2 {
+		t.Errorf("Expected the spacecraft to use the new key without restarting the sequence, got %+v", sa)
+	}
+
+	received, err = send(t, ground, spacecraft, ccsds.NewSpacePacket(ccsds.Telecommand, 1, []byte("after")), ground.DestroyKeyCommand(1))
+	if err != nil || len(received) != 1 || string(received[0].Data) != "after" {
+		t.Fatalf("Expected commands under the new key, got %+v, %v", received, err)
+	}
+	if err := spacecraft.AddSecurityAssociation(6, 0, 1); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected the old key to be destroyed, got %v", err)
+	}
+	if counters := spacecraft.Counters(); counters.Commands != 3 || counters.BadCommands != 0 {
+		t.Errorf("Expected three management commands, got %+v", counters)
+	}
+
+	// A command that cannot be carried out is counted
+	if _, err := send(t, ground, spacecraft, ground.RekeyCommand(5, 9)); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected rekeying to a missing key to fail, got %v", err)
+	}
+	bad := ccsds.NewSpacePacket(ccsds.Telecommand, DefaultManagementAPID, []byte{99})
+	if _, err := send(t, ground, spacecraft, bad); !errors.Is(err, ErrInvalidCommand) {
+		t.Errorf("Expected an unknown command to fail, got %v", err)
+	}
+	if _, err := send(t, ground, spacecraft, ground.UploadKeyCommand(2, testKey)); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected uploading over the key in use to fail, got %v", err)
+	}
+	if counters := spacecraft.Counters(); counters.BadCommands != 3 {
+		t.Errorf("Expected three bad commands, got %+v", counters)
+	}
+
+	if _, err := send(t, ground, spacecraft, ground.ResetCountersCommand()); err != nil {
+		t.Fatalf("Expected the reset to succeed, got %v", err)
+	}
+	if counters := spacecraft.Counters(); counters != (Counters{Commands: 1}) {
+		t.Errorf("Expected the counters to be cleared but for the reset, got %+v", counters)
+	}
+}
+
+func TestEndpoint_Report(t *testing.T) {
+	ground, spacecraft := newTestPair(t, Config{ManagementAPID: 0x100})
+	if _, err := send(t, ground, spacecraft, ccsds.NewSpacePacket(ccsds.Telecommand, 1, []byte{1})); err != nil {
+		t.Fatalf("Expected the command to be accepted, got %v", err)
+	}
+	frames, _ := ground.Protect(5, ccsds.NewSpacePacket(ccsds.Telecommand, 1, []byte{1}))
+	spacecraft.Process(frames[0])
+	spacecraft.Process(frames[0])
+
+	packet := spacecraft.Report()
+	if packet.APID != 0x100 || packet.Type != ccsds.Telemetry {
+		t.Errorf("Expected a telemetry packet on the management APID, got %+v", packet)
+	}
+	encoded, _ := packet.Encode()
+	decoded, _, _ := ccsds.DecodeSpacePacket(encoded, 0)
+	report, err := DecodeReport(decoded)
+	if err != nil {
+		t.Fatalf("Expected DecodeReport to succeed, got %v", err)
+	}
+	if report.LastSPI != 5 || report.Accepted != 2 || report.Replay != 1 || report.Rejected() != 1 {
+		t.Errorf("Expected the report to carry the counters, got %+v", report)
+	}
+
+	if _, err := DecodeReport(ccsds.NewSpacePacket(ccsds.Telemetry, 0x100, []byte{1})); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Expected a short report to fail, got %v", err)
+	}
+}
@@ -0,0 +1,426 @@
+package satellite
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// WGS-72 constants, which the published element sets are fitted with
+const (
+	wgs72Mu     = 398600.8
+	wgs72Radius = 6378.135
+	wgs72J2     = 0.001082616
+	wgs72J3     = -0.00000253881
+	wgs72J4     = -0.00000165597
+	wgs72J3OJ2  = wgs72J3 / wgs72J2
+)
+
+// Derived constants
+var (
+	xke       = 60.0 / math.Sqrt(wgs72Radius*wgs72Radius*wgs72Radius/wgs72Mu)
+	vkmpersec = wgs72Radius * xke / 60.0
+)
+
+const (
+	twoPi   = 2 * math.Pi
+	deg2rad = math.Pi / 180
+	x2o3    = 2.0 / 3.0
+
+	// minutesPerDay converts revolutions per day to radians per minute
+	minutesPerDay = 1440.0
+
+	// epoch1950 is the Julian date SGP4 counts epoch days from
+	epoch1950 = 2433281.5
+)
+
+// Errors returned when the model cannot propagate an element set
+var (
+	// ErrEccentricity is returned when the mean eccentricity leaves [0, 1)
+	ErrEccentricity = errors.New("sgp4: mean eccentricity out of range")
+
+	// ErrMeanMotion is returned when the mean motion drops to zero
+	ErrMeanMotion = errors.New("sgp4: mean motion is not positive")
+
+	// ErrPerturbedEccentricity is returned when the perturbed eccentricity
+	// leaves [0, 1]
+	ErrPerturbedEccentricity = errors.New("sgp4: perturbed eccentricity out of range")
+
+	// ErrSemiLatusRectum is returned when the semi-latus rectum is negative
+	ErrSemiLatusRectum = errors.New("sgp4: semi-latus rectum is negative")
+
+	// ErrDecayed is returned when the satellite is below the Earth's surface
+	ErrDecayed = errors.New("sgp4: satellite has decayed")
+)
+
+// Propagator propagates a TLE with the SGP4 model, switching to the SDP4
+// deep space model for orbits with periods of 225 minutes or more. It
+// follows the revised reference implementation of Vallado et al., "Revisiting
+// Spacetrack Report #3" (AIAA 2006-6753). Positions and velocities are in the
+// true equator, mean equinox (TEME) frame.
+type Propagator struct {
+	// TLE is the element set being propagated
+	TLE *TLE
+
+	mutex sync.Mutex
+	rec   sgp4Record
+}
+
+// sgp4Record holds the model's state for an element set
+type sgp4Record struct {
+	// Elements at the epoch, in radians and radians per minute
+	bstar, ecco, argpo, inclo, mo, no, nodeo float64
+
+	// Near earth
+	isimp                                   bool
+	aycof, con41, cc1, cc4, cc5, d2, d3, d4 float64
+	delmo, eta, argpdot, omgcof, sinmao     float64
+	t2cof, t3cof, t4cof, t5cof              float64
+	x1mth2, x7thm1, mdot, nodedot, xlcof    float64
+	xmcof, nodecf                           float64
+	deep                                    bool
+	deepSpace                               deepSpace
+	gsto                                    float64
+}
+
+// NewPropagator initialises the model for an element set
+func NewPropagator(tle *TLE) (*Propagator, error) {
+	p := &Propagator{TLE: tle}
+	epoch := tle.Epoch.Sub(time.Date(1949, time.December, 31, 0, 0, 0, 0, time.UTC)).Hours() / 24
+	if err := p.rec.init(tle, epoch); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DeepSpace reports whether the element set is propagated with SDP4
+func (p *Propagator) DeepSpace() bool {
+	return p.rec.deep
+}
+
+// Propagate returns the state of the satellite at t
+func (p *Propagator) Propagate(t time.Time) (StateVector, error) {
+	state, err := p.PropagateMinutes(t.Sub(p.TLE.Epoch).Minutes())
+	state.Time = t
+	return state, err
+}
+
+// PropagateMinutes returns the state of the satellite the given number of
+// minutes after the epoch of its element set
+func (p *Propagator) PropagateMinutes(minutes float64) (StateVector, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	r, v, err := p.rec.propagate(minutes)
+	state := StateVector{
+		Time:     p.TLE.Epoch.Add(time.Duration(minutes * float64(time.Minute))),
+		Position: r,
+		Velocity: v,
+	}
+	return state, err
+}
+
+// init initialises the record, following sgp4init. The epoch is in days
+// since 1950 January 0.
+func (s *sgp4Record) init(tle *TLE, epoch float64) error {
+	const temp4 = 1.5e-12
+	xpdotp := minutesPerDay / twoPi
+	s.bstar = tle.BStar
+	s.ecco = tle.Eccentricity
+	s.argpo = tle.ArgumentOfPerigee * deg2rad
+	s.inclo = tle.Inclination * deg2rad
+	s.mo = tle.MeanAnomaly * deg2rad
+	s.nodeo = tle.RightAscension * deg2rad
+	noKozai := tle.MeanMotion / xpdotp
+
+	ss := 78.0/wgs72Radius + 1.0
+	qzms2t := math.Pow((120.0-78.0)/wgs72Radius, 4)
+
+	// initl: recover the original mean motion and semi-major axis
+	eccsq := s.ecco * s.ecco
+	omeosq := 1.0 - eccsq
+	rteosq := math.Sqrt(omeosq)
+	cosio := math.Cos(s.inclo)
+	cosio2 := cosio * cosio
+	ak := math.Pow(xke/noKozai, x2o3)
+	d1 := 0.75 * wgs72J2 * (3.0*cosio2 - 1.0) / (rteosq * omeosq)
+	del := d1 / (ak * ak)
+	adel := ak * (1.0 - del*del - del*(1.0/3.0+134.0*del*del/81.0))
+	del = d1 / (adel * adel)
+	s.no = noKozai / (1.0 + del)
+	ao := math.Pow(xke/s.no, x2o3)
+	sinio := math.Sin(s.inclo)
+	po := ao * omeosq
+	con42 := 1.0 - 5.0*cosio2
+	s.con41 = -con42 - cosio2 - cosio2
+	posq := po * po
+	rp := ao * (1.0 - s.ecco)
+	s.gsto = gstime(epoch + epoch1950)
+
+	if omeosq < 0 && s.no < 0 {
+		return ErrEccentricity
+	}
+	s.isimp = rp < 220.0/wgs72Radius+1.0
+
+	// Lower the drag parameters for perigees below 156 km
+	sfour := ss
+	qzms24 := qzms2t
+	perige := (rp - 1.0) * wgs72Radius
+	if perige < 156.0 {
+		sfour = perige - 78.0
+		if perige < 98.0 {
+			sfour = 20.0
+		}
+		qzms24 = math.Pow((120.0-sfour)/wgs72Radius, 4)
+		sfour = sfour/wgs72Radius + 1.0
+	}
+	pinvsq := 1.0 / posq
+
+	tsi := 1.0 / (ao - sfour)
+	s.eta = ao * s.ecco * tsi
+	etasq := s.eta * s.eta
+	eeta := s.ecco * s.eta
+	psisq := math.Abs(1.0 - etasq)
+	coef := qzms24 * math.Pow(tsi, 4)
+	coef1 := coef / math.Pow(psisq, 3.5)
+	cc2 := coef1 * s.no * (ao*(1.0+1.5*etasq+eeta*(4.0+etasq)) +
+		0.375*wgs72J2*tsi/psisq*s.con41*(8.0+3.0*etasq*(8.0+etasq)))
+	s.cc1 = s.bstar * cc2
+	cc3 := 0.0
+	if s.ecco > 1.0e-4 {
+		cc3 = -2.0 * coef * tsi * wgs72J3OJ2 * s.no * sinio / s.ecco
+	}
+	s.x1mth2 = 1.0 - cosio2
+	s.cc4 = 2.0 * s.no * coef1 * ao * omeosq *
+		(s.eta*(2.0+0.5*etasq) + s.ecco*(0.5+2.0*etasq) -
+			wgs72J2*tsi/(ao*psisq)*
+				(-3.0*s.con41*(1.0-2.0*eeta+etasq*(1.5-0.5*eeta))+
+					0.75*s.x1mth2*(2.0*etasq-eeta*(1.0+etasq))*math.Cos(2.0*s.argpo)))
+	s.cc5 = 2.0 * coef1 * ao * omeosq * (1.0 + 2.75*(etasq+eeta) + eeta*etasq)
+	cosio4 := cosio2 * cosio2
+	temp1 := 1.5 * wgs72J2 * pinvsq * s.no
+	temp2 := 0.5 * temp1 * wgs72J2 * pinvsq
+	temp3 := -0.46875 * wgs72J4 * pinvsq * pinvsq * s.no
+	s.mdot = s.no + 0.5*temp1*rteosq*s.con41 + 0.0625*temp2*rteosq*(13.0-78.0*cosio2+137.0*cosio4)
+	s.argpdot = -0.5*temp1*con42 + 0.0625*temp2*(7.0-114.0*cosio2+395.0*cosio4) +
+		temp3*(3.0-36.0*cosio2+49.0*cosio4)
+	xhdot1 := -temp1 * cosio
+	s.nodedot = xhdot1 + (0.5*temp2*(4.0-19.0*cosio2)+2.0*temp3*(3.0-7.0*cosio2))*cosio
+	xpidot := s.argpdot + s.nodedot
+	s.omgcof = s.bstar * cc3 * math.Cos(s.argpo)
+	s.xmcof = 0.0
+	if s.ecco > 1.0e-4 {
+		s.xmcof = -x2o3 * coef * s.bstar / eeta
+	}
+	s.nodecf = 3.5 * omeosq * xhdot1 * s.cc1
+	s.t2cof = 1.5 * s.cc1
+	if math.Abs(cosio+1.0) > 1.5e-12 {
+		s.xlcof = -0.25 * wgs72J3OJ2 * sinio * (3.0 + 5.0*cosio) / (1.0 + cosio)
+	} else {
+		s.xlcof = -0.25 * wgs72J3OJ2 * sinio * (3.0 + 5.0*cosio) / temp4
+	}
+	s.aycof = -0.5 * wgs72J3OJ2 * sinio
+	s.delmo = math.Pow(1.0+s.eta*math.Cos(s.mo), 3)
+	s.sinmao = math.Sin(s.mo)
+	s.x7thm1 = 7.0*cosio2 - 1.0
+
+	// Deep space initialisation for periods of 225 minutes or more
+	if twoPi/s.no >= 225.0 {
+		s.deep = true
+		s.isimp = true
+		s.deepSpace.init(s, epoch, eccsq, xpidot)
+	}
+
+	if !s.isimp {
+		cc1sq := s.cc1 * s.cc1
+		s.d2 = 4.0 * ao * tsi * cc1sq
+		temp := s.d2 * tsi * s.cc1 / 3.0
+		s.d3 = (17.0*ao + sfour) * temp
+		s.d4 = 0.5 * temp * ao * tsi * (221.0*ao + 31.0*sfour) * s.cc1
+		s.t3cof = s.d2 + 2.0*cc1sq
+		s.t4cof = 0.25 * (3.0*s.d3 + s.cc1*(12.0*s.d2+10.0*cc1sq))
+		s.t5cof = 0.2 * (3.0*s.d4 + 12.0*s.cc1*s.d3 + 6.0*s.d2*s.d2 + 15.0*cc1sq*(2.0*s.d2+cc1sq))
+	}
+
+	_, _, err := s.propagate(0)
+	return err
+}
+
+// propagate returns the position in kilometres and velocity in kilometres
+// per second the given number of minutes after the epoch, following sgp4
+func (s *sgp4Record) propagate(t float64) (Vector, Vector, error) {
+	const temp4 = 1.5e-12
+
+	// Secular gravity and atmospheric drag
+	xmdf := s.mo + s.mdot*t
+	argpdf := s.argpo + s.argpdot*t
+	nodedf := s.nodeo + s.nodedot*t
+	argpm := argpdf
+	mm := xmdf
+	t2 := t * t
+	nodem := nodedf + s.nodecf*t2
+	tempa := 1.0 - s.cc1*t
+	tempe := s.bstar * s.cc4 * t
+	templ := s.t2cof * t2
+
+	if !s.isimp {
+		delomg := s.omgcof * t
+		delmtemp := 1.0 + s.eta*math.Cos(xmdf)
+		delm := s.xmcof * (delmtemp*delmtemp*delmtemp - s.delmo)
+		temp := delomg + delm
+		mm = xmdf + temp
+		argpm = argpdf - temp
+		t3 := t2 * t
+		t4 := t3 * t
+		tempa = tempa - s.d2*t2 - s.d3*t3 - s.d4*t4
+		tempe = tempe + s.bstar*s.cc5*(math.Sin(mm)-s.sinmao)
+		templ = templ + s.t3cof*t3 + t4*(s.t4cof+t*s.t5cof)
+	}
+
+	nm := s.no
+	em := s.ecco
+	inclm := s.inclo
+	if s.deep {
+		em, argpm, inclm, mm, nodem, nm = s.deepSpace.secular(s, t, em, argpm, inclm, mm, nodem)
+	}
+	if nm <= 0.0 {
+		return Vector{}, Vector{}, ErrMeanMotion
+	}
+	am := math.Pow(xke/nm, x2o3) * tempa * tempa
+	nm = xke / math.Pow(am, 1.5)
+	em = em - tempe
+	if em >= 1.0 || em < -0.001 {
+		return Vector{}, Vector{}, ErrEccentricity
+	}
+	if em < 1.0e-6 {
+		em = 1.0e-6
+	}
+	mm = mm + s.no*templ
+	xlm := mm + argpm + nodem
+	nodem = math.Mod(nodem, twoPi)
+	argpm = math.Mod(argpm, twoPi)
+	xlm = math.Mod(xlm, twoPi)
+	mm = math.Mod(xlm-argpm-nodem, twoPi)
+
+	// Lunar-solar periodics
+	sinim := math.Sin(inclm)
+	cosim := math.Cos(inclm)
+	ep := em
+	xincp := inclm
+	argpp := argpm
+	nodep := nodem
+	mp := mm
+	sinip := sinim
+	cosip := cosim
+	aycof := s.aycof
+	xlcof := s.xlcof
+	con41 := s.con41
+	x1mth2 := s.x1mth2
+	x7thm1 := s.x7thm1
+	if s.deep {
+		ep, xincp, nodep, argpp, mp = s.deepSpace.periodics(t, ep, xincp, nodep, argpp, mp)
+		if xincp < 0.0 {
+			xincp = -xincp
+			nodep = nodep + math.Pi
+			argpp = argpp - math.Pi
+		}
+		if ep < 0.0 || ep > 1.0 {
+			return Vector{}, Vector{}, ErrPerturbedEccentricity
+		}
+		sinip = math.Sin(xincp)
+		cosip = math.Cos(xincp)
+		aycof = -0.5 * wgs72J3OJ2 * sinip
+		if math.Abs(cosip+1.0) > 1.5e-12 {
+			xlcof = -0.25 * wgs72J3OJ2 * sinip * (3.0 + 5.0*cosip) / (1.0 + cosip)
+		} else {
+			xlcof = -0.25 * wgs72J3OJ2 * sinip * (3.0 + 5.0*cosip) / temp4
+		}
+	}
+
+	// Long period periodics
+	axnl := ep * math.Cos(argpp)
+	temp := 1.0 / (am * (1.0 - ep*ep))
+	aynl := ep*math.Sin(argpp) + temp*aycof
+	xl := mp + argpp + nodep + temp*xlcof*axnl
+
+	// Solve Kepler's equation
+	u := math.Mod(xl-nodep, twoPi)
+	eo1 := u
+	tem5 := 9999.9
+	var sineo1, coseo1 float64
+	for ktr := 1; math.Abs(tem5) >= 1.0e-12 && ktr <= 10; ktr++ {
+		sineo1 = math.Sin(eo1)
+		coseo1 = math.Cos(eo1)
+		tem5 = 1.0 - coseo1*axnl - sineo1*aynl
+		tem5 = (u - aynl*coseo1 + axnl*sineo1 - eo1) / tem5
+		if math.Abs(tem5) >= 0.95 {
+			if tem5 > 0.0 {
+				tem5 = 0.95
+			} else {
+				tem5 = -0.95
+			}
+		}
+		eo1 = eo1 + tem5
+	}
+
+	// Short period preliminary quantities
+	ecose := axnl*coseo1 + aynl*sineo1
+	esine := axnl*sineo1 - aynl*coseo1
+	el2 := axnl*axnl + aynl*aynl
+	pl := am * (1.0 - el2)
+	if pl < 0.0 {
+		return Vector{}, Vector{}, ErrSemiLatusRectum
+	}
+	rl := am * (1.0 - ecose)
+	rdotl := math.Sqrt(am) * esine / rl
+	rvdotl := math.Sqrt(pl) / rl
+	betal := math.Sqrt(1.0 - el2)
+	temp = esine / (1.0 + betal)
+	sinu := am / rl * (sineo1 - aynl - axnl*temp)
+	cosu := am / rl * (coseo1 - axnl + aynl*temp)
+	su := math.Atan2(sinu, cosu)
+	sin2u := (cosu + cosu) * sinu
+	cos2u := 1.0 - 2.0*sinu*sinu
+	temp = 1.0 / pl
+	temp1 := 0.5 * wgs72J2 * temp
+	temp2 := temp1 * temp
+
+	if s.deep {
+		cosisq := cosip * cosip
+		con41 = 3.0*cosisq - 1.0
+		x1mth2 = 1.0 - cosisq
+		x7thm1 = 7.0*cosisq - 1.0
+	}
+
+	// Update for short period periodics
+	mrt := rl*(1.0-1.5*temp2*betal*con41) + 0.5*temp1*x1mth2*cos2u
+	su = su - 0.25*temp2*x7thm1*sin2u
+	xnode := nodep + 1.5*temp2*cosip*sin2u
+	xinc := xincp + 1.5*temp2*cosip*sinip*cos2u
+	mvt := rdotl - nm*temp1*x1mth2*sin2u/xke
+	rvdot := rvdotl + nm*temp1*(x1mth2*cos2u+1.5*con41)/xke
+
+	// Orientation vectors
+	sinsu, cossu := math.Sincos(su)
+	snod, cnod := math.Sincos(xnode)
+	sini, cosi := math.Sincos(xinc)
+	xmx := -snod * cosi
+	xmy := cnod * cosi
+	ux := xmx*sinsu + cnod*cossu
+	uy := xmy*sinsu + snod*cossu
+	uz := sini * sinsu
+	vx := xmx*cossu - cnod*sinsu
+	vy := xmy*cossu - snod*sinsu
+	vz := sini * cossu
+
+	r := Vector{mrt * ux, mrt * uy, mrt * uz}.Scale(wgs72Radius)
+	v := Vector{
+		mvt*ux + rvdot*vx,
+		mvt*uy + rvdot*vy,
+		mvt*uz + rvdot*vz,
+	}.Scale(vkmpersec)
+	if mrt < 1.0 {
+		return r, v, ErrDecayed
+	}
+	return r, v, nil
+}
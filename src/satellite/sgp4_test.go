@@ -0,0 +1,144 @@
+package satellite
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// sgp4Vector is a state from the SGP4 verification output published with
+// "Revisiting Spacetrack Report #3"
+type sgp4Vector struct {
+	minutes  float64
+	position Vector
+	velocity Vector
+}
+
+// checkVectors propagates a TLE and compares it with reference states
+func checkVectors(t *testing.T, line1, line2 string, vectors []sgp4Vector) *Propagator {
+	tle, err := ParseTLE("", line1, line2)
+	if err != nil {
+		t.Fatalf("Expected ParseTLE to succeed, got %v", err)
+	}
+	propagator, err := NewPropagator(tle)
+	if err != nil {
+		t.Fatalf("Expected NewPropagator to succeed, got %v", err)
+	}
+	for _, expected := range vectors {
+		state, err := propagator.PropagateMinutes(expected.minutes)
+		if err != nil {
+			t.Fatalf("Expected propagation to %v minutes to succeed, got %v", expected.minutes, err)
+		}
+		if d := state.Position.Sub(expected.position).Norm(); d > 1e-6 {
+			t.Errorf("Expected the position at %v minutes to be %+v, got %+v", expected.minutes, expected.position, state.Position)
+		}
+		if d := state.Velocity.Sub(expected.velocity).Norm(); d > 1e-8 {
+			t.Errorf("Expected the velocity at %v minutes to be %+v, got %+v", expected.minutes, expected.velocity, state.Velocity)
+		}
+	}
+	return propagator
+}
+
+func TestPropagator_NearEarth(t *testing.T) {
+	propagator := checkVectors(t, vanguardLine1, vanguardLine2, []sgp4Vector{
+		{0, Vector{7022.46529266, -1400.08296755, 0.03995155}, Vector{1.893841015, 6.405893759, 4.534807250}},
+		{360, Vector{-7154.03120202, -3783.17682504, -3536.19412294}, Vector{4.741887409, -4.151817765, -2.093935425}},
+		{720, Vector{-7134.59340119, 6531.68641334, 3260.27186483}, Vector{-4.113793027, -2.911922039, -2.557327851}},
+	})
+	if propagator.DeepSpace() {
+		t.Errorf("Expected a 133 minute orbit to use the near earth model")
+	}
+
+	// Propagate also accepts times
+	at := propagator.TLE.Epoch.Add(360 * 60e9)
+	state, err := propagator.Propagate(at)
+	if err != nil || !state.Time.Equal(at) || math.Abs(state.Position.X+7154.03120202) > 1e-6 {
+		t.Errorf("Expected Propagate to match PropagateMinutes, got %+v, %v", state, err)
+	}
+}
+
+func TestPropagator_DeepSpace(t *testing.T) {
+	// Molniya 3-8, a 12 hour orbit with the half day resonance
+	line1 := "1 08195U 75081A   06176.33215444  .00000099  00000-0  11873-3 0   813"
+	line2 := "2 08195  64.1586 279.0717 6877146 264.7651  20.2257  2.00491383225656"
+	propagator := checkVectors(t, line1, line2, []sgp4Vector{
+		{0, Vector{2349.89483350, -14785.93811562, 0.02119378}, Vector{2.721488096, -3.256811655, 4.498416672}},
+		{120, Vector{15223.91713658, -17852.95881713, 25280.39558224}, Vector{1.079041732, 0.875187372, 2.485682813}},
+		{240, Vector{19752.78050009, -8600.07130962, 37522.72921090}, Vector{0.238105279, 1.546110924, 0.986410447}},
+		{720, Vector{2622.13222207, -15125.15464924, 474.51048398}, Vector{2.688287199, -3.078426664, 4.494979530}},
+		{1440, Vector{2890.80638268, -15446.43952300, 948.77010176}, Vector{2.654407490, -2.909344895, 4.486437362}},
+		{2880, Vector{3417.20931586, -16038.79510665, 1894.74934058}, Vector{2.585515864, -2.596818146, 4.456882556}},
+	})
+	if !propagator.DeepSpace() || propagator.rec.deepSpace.irez != 2 {
+		t.Fatalf("Expected a Molniya orbit to use the half day resonance")
+	}
+	checkOrbit(t, propagator, 3*1440)
+}
+
+func TestPropagator_Geosynchronous(t *testing.T) {
+	line1 := withChecksum("1 28626U 05008A   06176.46683397 -.00000205  00000-0  10000-3 0  219")
+	line2 := withChecksum("2 28626   0.0019 286.9433 0000335  13.7918  55.6504  1.00270176  458")
+	propagator := checkVectors(t, line1, line2, []sgp4Vector{
+		{0, Vector{42080.71852213, -2646.86387436, 0.81851294}, Vector{0.193105177, 3.068688251, 0.000438449}},
+		{120, Vector{37740.00085593, 18802.76872802, 3.45512584}, Vector{-1.371035206, 2.752105932, 0.000336883}},
+		{360, Vector{2467.44290178, 42093.60909959, 5.15062987}, Vector{-3.069341800, 0.179976276, -0.000031739}},
+		{720, Vector{-42103.20138132, 2291.06228893, -0.13274964}, Vector{-0.166974816, -3.070104560, -0.000311007}},
+		{1440, Vector{42119.96263499, -1925.77567263, -0.19827433}, Vector{0.140521206, 3.071541613, 0.000179561}},
+	})
+	if !propagator.DeepSpace() || propagator.rec.deepSpace.irez != 1 {
+		t.Fatalf("Expected a geosynchronous orbit to use the synchronous resonance")
+	}
+	checkOrbit(t, propagator, 3*1440)
+
+	// Integrating backwards restarts the resonance integrator
+	if _, err := propagator.PropagateMinutes(-1440); err != nil {
+		t.Errorf("Expected propagation before the epoch to succeed, got %v", err)
+	}
+}
+
+// checkOrbit checks that the propagated states stay between perigee and
+// apogee and satisfy the vis-viva equation
+func checkOrbit(t *testing.T, propagator *Propagator, minutes float64) {
+	tle := propagator.TLE
+	n := tle.MeanMotion * twoPi / 86400
+	a := math.Cbrt(wgs72Mu / (n * n))
+	perigee := a * (1 - tle.Eccentricity)
+	apogee := a * (1 + tle.Eccentricity)
+	for m := 0.0; m <= minutes; m += 10 {
+		state, err := propagator.PropagateMinutes(m)
+		if err != nil {
+			t.Fatalf("Expected propagation to %v minutes to succeed, got %v", m, err)
+		}
+		r := state.Position.Norm()
+		if r < perigee-100 || r > apogee+100 {
+			t.Fatalf("Expected the radius at %v minutes to be between %.0f and %.0f km, got %.0f", m, perigee, apogee, r)
+		}
+		v := state.Velocity.Norm()
+		expected := math.Sqrt(wgs72Mu * (2/r - 1/a))
+		if math.Abs(v-expected)/expected > 0.01 {
+			t.Fatalf("Expected the speed at %v minutes to be %.3f km/s, got %.3f", m, expected, v)
+		}
+	}
+}
+
+func TestPropagator_Errors(t *testing.T) {
+	// A large drag term brings the orbit down within a year
+	line1 := withChecksum(vanguardLine1[:53] + " 50000-0" + vanguardLine1[61:68])
+	tle, err := ParseTLE("", line1, vanguardLine2)
+	if err != nil {
+		t.Fatalf("Expected ParseTLE to succeed, got %v", err)
+	}
+	propagator, err := NewPropagator(tle)
+	if err != nil {
+		t.Fatalf("Expected NewPropagator to succeed, got %v", err)
+	}
+	_, err = propagator.PropagateMinutes(365 * 1440)
+	known := []error{ErrEccentricity, ErrMeanMotion, ErrPerturbedEccentricity, ErrSemiLatusRectum, ErrDecayed}
+	found := false
+	for _, e := range known {
+		found = found || errors.Is(err, e)
+	}
+	if !found {
+		t.Errorf("Expected a decaying orbit to fail with a model error, got %v", err)
+	}
+}
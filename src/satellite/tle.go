@@ -0,0 +1,262 @@
+package satellite
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTLE is returned when a two-line element set cannot be parsed
+var ErrInvalidTLE = errors.New("invalid TLE")
+
+// tleLineLength is the length of a TLE line including its checksum
+const tleLineLength = 69
+
+// TLE is a NORAD two-line element set. Angles are in degrees and the mean
+// motion is in revolutions per day.
+type TLE struct {
+	// Name is the name of the satellite, from the optional title line
+	Name string `json:"name,omitempty"`
+
+	// SatelliteNumber is the NORAD catalogue number
+	SatelliteNumber int `json:"satellite_number"`
+
+	// Classification is U for unclassified, C for classified or S for secret
+	Classification string `json:"classification"`
+
+	// InternationalDesignator is the COSPAR launch designator, such as 58002B
+	InternationalDesignator string `json:"international_designator"`
+
+	// Epoch is the time the elements are valid at
+	Epoch time.Time `json:"epoch"`
+
+	// MeanMotionDot is half the first derivative of the mean motion, in
+	// revolutions per day squared
+	MeanMotionDot float64 `json:"mean_motion_dot"`
+
+	// MeanMotionDDot is a sixth of the second derivative of the mean motion,
+	// in revolutions per day cubed
+	MeanMotionDDot float64 `json:"mean_motion_ddot"`
+
+	// BStar is the drag term in inverse Earth radii
+	BStar float64 `json:"bstar"`
+
+	// ElementSetNumber is the element set number
+	ElementSetNumber int `json:"element_set_number"`
+
+	// Inclination is the inclination
+	Inclination float64 `json:"inclination"`
+
+	// RightAscension is the right ascension of the ascending node
+	RightAscension float64 `json:"right_ascension"`
+
+	// Eccentricity is the eccentricity
+	Eccentricity float64 `json:"eccentricity"`
+
+	// ArgumentOfPerigee is the argument of perigee
+	ArgumentOfPerigee float64 `json:"argument_of_perigee"`
+
+	// MeanAnomaly is the mean anomaly
+	MeanAnomaly float64 `json:"mean_anomaly"`
+
+	// MeanMotion is the mean motion
+	MeanMotion float64 `json:"mean_motion"`
+
+	// RevolutionNumber is the number of revolutions at the epoch
+	RevolutionNumber int `json:"revolution_number"`
+}
+
+// ParseTLE parses a two-line element set. The name may be empty.
+func ParseTLE(name, line1, line2 string) (*TLE, error) {
+	line1 = strings.TrimRight(line1, " \r\n")
+	line2 = strings.TrimRight(line2, " \r\n")
+	if err := checkTLELine(line1, '1'); err != nil {
+		return nil, err
+	}
+	if err := checkTLELine(line2, '2'); err != nil {
+		return nil, err
+	}
+
+	tle := &TLE{Name: strings.TrimSpace(strings.TrimPrefix(name, "0 "))}
+	p := tleParser{}
+	tle.SatelliteNumber = p.satelliteNumber(line1[2:7])
+	tle.Classification = strings.TrimSpace(line1[7:8])
+	tle.InternationalDesignator = strings.TrimSpace(line1[9:17])
+	year := p.int(line1[18:20])
+	day := p.float(line1[20:32])
+	tle.MeanMotionDot = p.float(line1[33:43])
+	tle.MeanMotionDDot = p.exponent(line1[44:52])
+	tle.BStar = p.exponent(line1[53:61])
+	tle.ElementSetNumber = p.int(line1[64:68])
+
+	if number := p.satelliteNumber(line2[2:7]); p.err == nil && number != tle.SatelliteNumber {
+		return nil, fmt.Errorf("%w: lines are for satellites %d and %d", ErrInvalidTLE, tle.SatelliteNumber, number)
+	}
+	tle.Inclination = p.float(line2[8:16])
+	tle.RightAscension = p.float(line2[17:25])
+	tle.Eccentricity = p.float("." + strings.TrimSpace(line2[26:33]))
+	tle.ArgumentOfPerigee = p.float(line2[34:42])
+	tle.MeanAnomaly = p.float(line2[43:51])
+	tle.MeanMotion = p.float(line2[52:63])
+	tle.RevolutionNumber = p.int(line2[63:68])
+	if p.err != nil {
+		return nil, p.err
+	}
+	if tle.MeanMotion <= 0 {
+		return nil, fmt.Errorf("%w: mean motion must be positive", ErrInvalidTLE)
+	}
+
+	// Two digit years from 57 are in the 1900s, when Sputnik was launched
+	if year < 57 {
+		year += 2000
+	} else {
+		year += 1900
+	}
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tle.Epoch = start.Add(time.Duration(math.Round((day - 1) * 86400e9)))
+	return tle, nil
+}
+
+// ParseTLEs reads element sets in two or three line format, such as those
+// published by CelesTrak, skipping blank lines
+func ParseTLEs(r io.Reader) ([]*TLE, error) {
+	scanner := bufio.NewScanner(r)
+	var tles []*TLE
+	var name, line1 string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		switch {
+		case strings.TrimSpace(line) == "":
+			continue
+		case strings.HasPrefix(line, "1 ") && line1 == "":
+			line1 = line
+		case strings.HasPrefix(line, "2 ") && line1 != "":
+			tle, err := ParseTLE(name, line1, line)
+			if err != nil {
+				return nil, err
+			}
+			tles = append(tles, tle)
+			name, line1 = "", ""
+		case line1 == "":
+			name = line
+		default:
+			return nil, fmt.Errorf("%w: expected line 2 after %q", ErrInvalidTLE, line1)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if line1 != "" {
+		return nil, fmt.Errorf("%w: missing line 2 after %q", ErrInvalidTLE, line1)
+	}
+	return tles, nil
+}
+
+// checkTLELine checks a TLE line's length, line number and checksum
+func checkTLELine(line string, number byte) error {
+	if len(line) != tleLineLength {
+		return fmt.Errorf("%w: line %c must be %d characters, got %d", ErrInvalidTLE, number, tleLineLength, len(line))
+	}
+	if line[0] != number || line[1] != ' ' {
+		return fmt.Errorf("%w: expected line %c", ErrInvalidTLE, number)
+	}
+	if sum := tleChecksum(line[:tleLineLength-1]); int(line[tleLineLength-1]-'0') != sum {
+		return fmt.Errorf("%w: line %c checksum is %c, expected %d", ErrInvalidTLE, number, line[tleLineLength-1], sum)
+	}
+	return nil
+}
+
+// tleChecksum returns the modulo 10 checksum of a TLE line, in which digits
+// count their value and minus signs count one
+func tleChecksum(line string) int {
+	sum := 0
+	for _, c := range line {
+		switch {
+		case c >= '0' && c <= '9':
+			sum += int(c - '0')
+		case c == '-':
+			sum++
+		}
+	}
+	return sum % 10
+}
+
+// tleParser parses TLE fields, remembering the first error
+type tleParser struct {
+	err error
+}
+
+// fail records an error for a field
+func (p *tleParser) fail(field string) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: bad field %q", ErrInvalidTLE, field)
+	}
+}
+
+// int parses an integer field, which may be blank
+func (p *tleParser) int(field string) int {
+	s := strings.TrimSpace(field)
+	if s == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		p.fail(field)
+	}
+	return value
+}
+
+// float parses a decimal field
+func (p *tleParser) float(field string) float64 {
+	s := strings.TrimSpace(field)
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		p.fail(field)
+	}
+	return value
+}
+
+// exponent parses a field with an assumed leading decimal point and a power
+// of ten exponent, such as " 28098-4" for 0.28098e-4
+func (p *tleParser) exponent(field string) float64 {
+	s := strings.TrimSpace(field)
+	if s == "" {
+		return 0
+	}
+	sign := ""
+	if s[0] == '-' || s[0] == '+' {
+		sign, s = s[:1], s[1:]
+	}
+	if len(s) < 3 {
+		p.fail(field)
+		return 0
+	}
+	mantissa, exponent := s[:len(s)-2], s[len(s)-2:]
+	value, err := strconv.ParseFloat(sign+"."+mantissa+"e"+exponent, 64)
+	if err != nil {
+		p.fail(field)
+	}
+	return value
+}
+
+// satelliteNumber parses a catalogue number, including the Alpha-5 format
+// in which a leading letter stands for 10 to 33, skipping I and O
+func (p *tleParser) satelliteNumber(field string) int {
+	s := strings.TrimSpace(field)
+	if s != "" && s[0] >= 'A' && s[0] <= 'Z' && s[0] != 'I' && s[0] != 'O' {
+		prefix := int(s[0]-'A') + 10
+		if s[0] > 'I' {
+			prefix--
+		}
+		if s[0] > 'O' {
+			prefix--
+		}
+		return prefix*10000 + p.int(s[1:])
+	}
+	return p.int(s)
+}
@@ -0,0 +1,95 @@
+package satellite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	vanguardLine1 = "1 00005U 58002B   00179.78495062  .00000023  00000-0  28098-4 0  4753"
+	vanguardLine2 = "2 00005  34.2682 348.7242 1859667 331.7664  19.3264 10.82419157413667"
+)
+
+func TestParseTLE(t *testing.T) {
+	tle, err := ParseTLE("0 VANGUARD 1", vanguardLine1, vanguardLine2)
+	if err != nil {
+		t.Fatalf("Expected ParseTLE to succeed, got %v", err)
+	}
+	if tle.Name != "VANGUARD 1" || tle.SatelliteNumber != 5 || tle.Classification != "U" || tle.InternationalDesignator != "58002B" {
+		t.Errorf("Expected the identification fields to be parsed, got %+v", tle)
+	}
+	epoch := time.Date(2000, time.June, 27, 18, 50, 19, 733568000, time.UTC)
+	if d := tle.Epoch.Sub(epoch); d < -time.Microsecond || d > time.Microsecond {
+		t.Errorf("Expected the epoch to be %v, got %v", epoch, tle.Epoch)
+	}
+	if tle.MeanMotionDot != 0.00000023 || tle.MeanMotionDDot != 0 || math.Abs(tle.BStar-0.28098e-4) > 1e-15 {
+		t.Errorf("Expected the drag terms to be parsed, got %+v", tle)
+	}
+	if tle.Inclination != 34.2682 || tle.RightAscension != 348.7242 || tle.Eccentricity != 0.1859667 {
+		t.Errorf("Expected the orientation to be parsed, got %+v", tle)
+	}
+	if tle.ArgumentOfPerigee != 331.7664 || tle.MeanAnomaly != 19.3264 || tle.MeanMotion != 10.82419157 {
+		t.Errorf("Expected the orbit to be parsed, got %+v", tle)
+	}
+	if tle.ElementSetNumber != 475 || tle.RevolutionNumber != 41366 {
+		t.Errorf("Expected the counters to be parsed, got %+v", tle)
+	}
+}
+
+func TestParseTLE_Invalid(t *testing.T) {
+	cases := map[string][2]string{
+		"checksum":   {vanguardLine1[:68] + "4", vanguardLine2},
+		"short line": {vanguardLine1[:60], vanguardLine2},
+		"swapped":    {vanguardLine2, vanguardLine1},
+		"mismatched": {vanguardLine1, withChecksum("2 00006" + vanguardLine2[7:68])},
+		"bad field":  {vanguardLine1, withChecksum("2 00005  34.2682 348.7242 1859667 331.7664  19.3264 1x.82419157" + vanguardLine2[63:68])},
+	}
+	for name, lines := range cases {
+		if _, err := ParseTLE("", lines[0], lines[1]); !errors.Is(err, ErrInvalidTLE) {
+			t.Errorf("Expected a %s TLE to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestParseTLE_Alpha5(t *testing.T) {
+	line1 := withChecksum("1 A0005" + vanguardLine1[7:68])
+	line2 := withChecksum("2 A0005" + vanguardLine2[7:68])
+	tle, err := ParseTLE("", line1, line2)
+	if err != nil {
+		t.Fatalf("Expected ParseTLE to succeed, got %v", err)
+	}
+	if tle.SatelliteNumber != 100005 {
+		t.Errorf("Expected an Alpha-5 number to be parsed, got %d", tle.SatelliteNumber)
+	}
+}
+
+func TestParseTLEs(t *testing.T) {
+	input := strings.Join([]string{
+		"VANGUARD 1",
+		vanguardLine1,
+		vanguardLine2,
+		"",
+		vanguardLine1 + "\r",
+		vanguardLine2,
+	}, "\n")
+	tles, err := ParseTLEs(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Expected ParseTLEs to succeed, got %v", err)
+	}
+	if len(tles) != 2 || tles[0].Name != "VANGUARD 1" || tles[1].Name != "" {
+		t.Errorf("Expected two and three line sets to be parsed, got %d", len(tles))
+	}
+
+	if _, err := ParseTLEs(strings.NewReader("VANGUARD 1\n" + vanguardLine1)); !errors.Is(err, ErrInvalidTLE) {
+		t.Errorf("Expected a truncated set to be rejected, got %v", err)
+	}
+}
+
+// withChecksum appends the checksum to the first 68 characters of a line
+func withChecksum(line string) string {
+	return line + fmt.Sprint(tleChecksum(line))
+}
@@ -0,0 +1,309 @@
+package satellite
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// DefaultMaxAzimuthRate is the azimuth slew rate in degrees per second
+// assumed for antennas that do not give their own
+const DefaultMaxAzimuthRate = 5.0
+
+// tdmTimeFormat is the time format of tracking data messages
+const tdmTimeFormat = "2006-01-02T15:04:05.000"
+
+// Doppler returns the frequency in Hz received from a carrier transmitted
+// at frequency when the ends separate at rangeRate kilometres per second
+func Doppler(frequency, rangeRate float64) float64 {
+	beta := rangeRate * 1000 / SpeedOfLight
+	return frequency * math.Sqrt((1-beta)/(1+beta))
+}
+
+// TrackingConfig is the configuration of tracking schedules
+type TrackingConfig struct {
+	// Step is the interval between pointing points, DefaultPassStep if zero
+	Step time.Duration
+
+	// DownlinkFrequency is the carrier frequency in Hz transmitted by the
+	// satellite, or zero to leave out the downlink
+	DownlinkFrequency float64
+
+	// UplinkFrequency is the carrier frequency in Hz the satellite receives,
+	// or zero to leave out the uplink
+	UplinkFrequency float64
+
+	// MaxAzimuthRate is the fastest the antenna can turn in azimuth in
+	// degrees per second, DefaultMaxAzimuthRate if zero
+	MaxAzimuthRate float64
+}
+
+// PointingPoint is where to point a ground station antenna and which
+// frequencies to use at a time
+type PointingPoint struct {
+	// Time is the time of the point
+	Time time.Time `json:"time"`
+
+	// Azimuth is the commanded azimuth in degrees clockwise from north
+	Azimuth float64 `json:"azimuth"`
+
+	// Elevation is the commanded elevation in degrees
+	Elevation float64 `json:"elevation"`
+
+	// Range is the distance to the satellite in kilometres
+	Range float64 `json:"range"`
+
+	// RangeRate is the rate of change of the range in kilometres per
+	// second, positive when the satellite is moving away
+	RangeRate float64 `json:"rangeRate"`
+
+	// Downlink is the Doppler-shifted frequency in Hz to receive on
+	Downlink float64 `json:"downlink,omitempty"`
+
+	// Uplink is the frequency in Hz to transmit on so the satellite
+	// receives its uplink frequency
+	Uplink float64 `json:"uplink,omitempty"`
+
+	// PointingError is the angle in degrees between the commanded and true
+	// directions, nonzero where the antenna cannot keep up in azimuth
+	PointingError float64 `json:"pointingError"`
+}
+
+// TrackingSchedule is the pointing table of a ground station for a pass
+type TrackingSchedule struct {
+	Pass
+
+	// Keyhole is set for passes so close to the zenith that the antenna
+	// cannot turn fast enough in azimuth to follow the satellite. The
+	// azimuth is then turned at the maximum rate through the culmination,
+	// starting early and finishing late to spread the pointing error.
+	Keyhole bool `json:"keyhole"`
+
+	// Points are the pointing points from AOS to LOS
+	Points []PointingPoint `json:"points"`
+}
+
+// MaxPointingError returns the largest pointing error in degrees
+func (t *TrackingSchedule) MaxPointingError() float64 {
+	worst := 0.0
+	for _, point := range t.Points {
+		worst = math.Max(worst, point.PointingError)
+	}
+	return worst
+}
+
+// TrackingSchedule returns the pointing table of a pass over a ground
+// station, with Doppler-corrected frequencies when they are configured
+func (s *Satellite) TrackingSchedule(station *GroundStation, pass Pass, config TrackingConfig) (*TrackingSchedule, error) {
+	step := config.Step
+	if step <= 0 {
+		step = DefaultPassStep
+	}
+	rate := config.MaxAzimuthRate
+	if rate <= 0 {
+		rate = DefaultMaxAzimuthRate
+	}
+
+	var track []TrackPoint
+	for t := pass.AOS; ; t = t.Add(step) {
+		if t.After(pass.LOS) {
+			t = pass.LOS
+		}
+		point, err := s.look(station, t)
+		if err != nil {
+			return nil, err
+		}
+		track = append(track, point)
+		if !t.Before(pass.LOS) {
+			break
+		}
+	}
+
+	schedule := &TrackingSchedule{Pass: pass, Points: make([]PointingPoint, len(track))}
+	azimuths := limitAzimuthRate(track, rate)
+	for i, point := range track {
+		azimuth := normalizeAzimuth(azimuths[i])
+		schedule.Points[i] = PointingPoint{
+			Time:          point.Time,
+			Azimuth:       azimuth,
+			Elevation:     point.Elevation,
+			Range:         point.Range,
+			RangeRate:     point.RangeRate,
+			PointingError: pointingError(point.LookAngles, azimuth),
+		}
+		if schedule.Points[i].PointingError > 1e-3 {
+			schedule.Keyhole = true
+		}
+		if config.DownlinkFrequency > 0 {
+			schedule.Points[i].Downlink = Doppler(config.DownlinkFrequency, point.RangeRate)
+		}
+		if config.UplinkFrequency > 0 {
+			schedule.Points[i].Uplink = Doppler(config.UplinkFrequency, -point.RangeRate)
+		}
+	}
+	return schedule, nil
+}
+
+// TrackingSchedules returns the pointing tables of the passes of the
+// satellite over a ground station between start and end
+func (s *Satellite) TrackingSchedules(station *GroundStation, start, end time.Time, config TrackingConfig) ([]*TrackingSchedule, error) {
+	passes, err := s.Passes(station, start, end, config.Step)
+	if err != nil {
+		return nil, err
+	}
+	schedules := make([]*TrackingSchedule, 0, len(passes))
+	for _, pass := range passes {
+		schedule, err := s.TrackingSchedule(station, pass, config)
+		if err != nil {
+			return schedules, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// limitAzimuthRate returns the azimuths of a track unwrapped across north
+// and limited to turning at rate degrees per second. The track is limited
+// forwards, which lags behind fast turns, and backwards, which leads them,
+// and the two are averaged so the antenna turns through a keyhole centred
+// on the culmination.
+func limitAzimuthRate(track []TrackPoint, rate float64) []float64 {
+	n := len(track)
+	unwrapped := make([]float64, n)
+	for i, point := range track {
+		unwrapped[i] = point.Azimuth
+		if i > 0 {
+			turn := math.Mod(point.Azimuth-unwrapped[i-1], 360)
+			switch {
+			case turn > 180:
+				turn -= 360
+			case turn < -180:
+				turn += 360
+			}
+			unwrapped[i] = unwrapped[i-1] + turn
+		}
+	}
+
+	clamp := func(azimuth, from float64, gap time.Duration) float64 {
+		limit := rate * gap.Seconds()
+		return math.Max(from-limit, math.Min(from+limit, azimuth))
+	}
+	forward := make([]float64, n)
+	backward := make([]float64, n)
+	for i := 0; i < n; i++ {
+		forward[i] = unwrapped[i]
+		if i > 0 {
+			forward[i] = clamp(unwrapped[i], forward[i-1], track[i].Time.Sub(track[i-1].Time))
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		backward[i] = unwrapped[i]
+		if i < n-1 {
+			backward[i] = clamp(unwrapped[i], backward[i+1], track[i+1].Time.Sub(track[i].Time))
+		}
+	}
+
+	azimuths := make([]float64, n)
+	for i := range azimuths {
+		azimuths[i] = (forward[i] + backward[i]) / 2
+	}
+	return azimuths
+}
+
+// pointingError returns the angle in degrees between the direction of the
+// look angles and the same elevation at another azimuth, which shrinks
+// towards the zenith
+func pointingError(look LookAngles, azimuth float64) float64 {
+	chord := math.Cos(look.Elevation*deg2rad) * math.Abs(math.Sin((azimuth-look.Azimuth)*deg2rad/2))
+	return 2 * math.Asin(math.Min(1, chord)) / deg2rad
+}
+
+// WriteCSV writes the pointing table as CSV with a header row, with times
+// in RFC 3339 and frequencies left empty when they are not configured
+func (t *TrackingSchedule) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{"time", "azimuth", "elevation", "range", "rangeRate", "downlink", "uplink", "pointingError"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, point := range t.Points {
+		record := []string{
+			point.Time.UTC().Format(time.RFC3339Nano),
+			formatFloat(point.Azimuth, 4),
+			formatFloat(point.Elevation, 4),
+			formatFloat(point.Range, 3),
+			formatFloat(point.RangeRate, 6),
+			"",
+			"",
+			formatFloat(point.PointingError, 4),
+		}
+		if point.Downlink > 0 {
+			record[5] = formatFloat(point.Downlink, 1)
+		}
+		if point.Uplink > 0 {
+			record[6] = formatFloat(point.Uplink, 1)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteTDM writes the pointing table as a CCSDS tracking data message in
+// keyword-value notation, with the station as participant 1 and the
+// satellite as participant 2
+func (t *TrackingSchedule) WriteTDM(w io.Writer, originator string) error {
+	if len(t.Points) == 0 {
+		return nil
+	}
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("CCSDS_TDM_VERS = 2.0\n")
+	printf("CREATION_DATE = %s\n", time.Now().UTC().Format(tdmTimeFormat))
+	printf("ORIGINATOR = %s\n\n", originator)
+	printf("META_START\n")
+	printf("TIME_SYSTEM = UTC\n")
+	printf("START_TIME = %s\n", t.Points[0].Time.UTC().Format(tdmTimeFormat))
+	printf("STOP_TIME = %s\n", t.Points[len(t.Points)-1].Time.UTC().Format(tdmTimeFormat))
+	printf("PARTICIPANT_1 = %s\n", t.Station)
+	printf("PARTICIPANT_2 = %s\n", t.Satellite)
+	printf("MODE = SEQUENTIAL\n")
+	printf("PATH = 2,1\n")
+	printf("ANGLE_TYPE = AZEL\n")
+	printf("RANGE_UNITS = km\n")
+	if t.Keyhole {
+		printf("COMMENT Keyhole pass, azimuth limited to the antenna slew rate\n")
+	}
+	printf("META_STOP\n\n")
+	printf("DATA_START\n")
+	for _, point := range t.Points {
+		epoch := point.Time.UTC().Format(tdmTimeFormat)
+		printf("ANGLE_1 = %s %s\n", epoch, formatFloat(point.Azimuth, 4))
+		printf("ANGLE_2 = %s %s\n", epoch, formatFloat(point.Elevation, 4))
+		printf("RANGE = %s %s\n", epoch, formatFloat(point.Range, 3))
+		printf("DOPPLER_INSTANTANEOUS = %s %s\n", epoch, formatFloat(point.RangeRate, 6))
+		if point.Downlink > 0 {
+			printf("RECEIVE_FREQ_1 = %s %s\n", epoch, formatFloat(point.Downlink, 1))
+		}
+		if point.Uplink > 0 {
+			printf("TRANSMIT_FREQ_1 = %s %s\n", epoch, formatFloat(point.Uplink, 1))
+		}
+	}
+	printf("DATA_STOP\n")
+	return err
+}
+
+// formatFloat formats a number with a fixed number of decimals
+func formatFloat(value float64, decimals int) string {
+	return strconv.FormatFloat(value, 'f', decimals, 64)
+}
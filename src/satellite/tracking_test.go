@@ -0,0 +1,216 @@
+package satellite
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// overheadPass returns Vanguard 1 and a ground station under its ground
+// track, with the pass that culminates at the zenith
+func overheadPass(t *testing.T) (*Satellite, *GroundStation, Pass) {
+	satellite := newTestSatellite(t)
+	culmination := satellite.TLE().Epoch.Add(time.Hour)
+	state, err := satellite.PositionECEF(culmination)
+	if err != nil {
+		t.Fatalf("Expected PositionECEF to succeed, got %v", err)
+	}
+	location := ECEFToGeodetic(state.Position)
+	location.Altitude = 0
+	station := NewGroundStationAt("overhead", location, 10)
+
+	passes, err := satellite.Passes(station, culmination.Add(-time.Hour), culmination.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatalf("Expected Passes to succeed, got %v", err)
+	}
+	for _, pass := range passes {
+		if !pass.AOS.After(culmination) && !pass.LOS.Before(culmination) {
+			if pass.MaxElevation < 89.9 {
+				t.Fatalf("Expected a zenith pass, got a maximum elevation of %v", pass.MaxElevation)
+			}
+			return satellite, station, pass
+		}
+	}
+	t.Fatalf("Expected a pass at %v", culmination)
+	return nil, nil, Pass{}
+}
+
+func TestDoppler(t *testing.T) {
+	const frequency = 437e6
+	if shifted := Doppler(frequency, 0); shifted != frequency {
+		t.Errorf("Expected no shift at a range rate of zero, got %v", shifted)
+	}
+
+	// A low Earth orbit range rate of 7 km/s shifts 437 MHz by about 10 kHz
+	receding := Doppler(frequency, 7)
+	if shift := receding - frequency; shift > -10150 || shift < -10250 {
+		t.Errorf("Expected a shift of about -10.2 kHz, got %v", shift)
+	}
+	if approaching := Doppler(frequency, -7); math.Abs(approaching-frequency+receding-frequency) > 1 {
+		t.Errorf("Expected approaching to shift up as much as receding shifts down, got %v", approaching)
+	}
+	if restored := Doppler(Doppler(frequency, 5), -5); math.Abs(restored-frequency) > 1e-6 {
+		t.Errorf("Expected opposite shifts to cancel, got %v", restored)
+	}
+}
+
+func TestSatellite_TrackingSchedule(t *testing.T) {
+	satellite := newTestSatellite(t)
+	station := NewGroundStationAt("station", Geodetic{Latitude: 28.5, Longitude: -80.6}, 10)
+	start := satellite.TLE().Epoch
+	config := TrackingConfig{Step: 30 * time.Second, DownlinkFrequency: 108e6, UplinkFrequency: 145.9e6}
+
+	schedules, err := satellite.TrackingSchedules(station, start, start.Add(24*time.Hour), config)
+	if err != nil {
+		t.Fatalf("Expected TrackingSchedules to succeed, got %v", err)
+	}
+	if len(schedules) == 0 {
+		t.Fatalf("Expected schedules for the day's passes")
+	}
+
+	for _, schedule := range schedules {
+		points := schedule.Points
+		if !points[0].Time.Equal(schedule.AOS) || !points[len(points)-1].Time.Equal(schedule.LOS) {
+			t.Errorf("Expected the points to run from AOS to LOS")
+		}
+		for i, point := range points {
+			if i > 0 {
+				if gap := point.Time.Sub(points[i-1].Time); gap <= 0 || gap > config.Step {
+					t.Errorf("Expected points at most %v apart, got %v", config.Step, gap)
+				}
+			}
+			if (point.RangeRate < 0) != (point.Downlink > config.DownlinkFrequency) {
+				t.Errorf("Expected the downlink to shift up while approaching, got %v at %v km/s", point.Downlink, point.RangeRate)
+			}
+			if (point.RangeRate < 0) != (point.Uplink < config.UplinkFrequency) {
+				t.Errorf("Expected the uplink to be shifted down while approaching, got %v at %v km/s", point.Uplink, point.RangeRate)
+			}
+			if shifted := Doppler(point.Uplink, point.RangeRate); math.Abs(shifted-config.UplinkFrequency) > 1e-3 {
+				t.Errorf("Expected the satellite to receive %v, got %v", config.UplinkFrequency, shifted)
+			}
+		}
+		if schedule.MaxElevation < 80 && (schedule.Keyhole || schedule.MaxPointingError() > 1e-3) {
+			t.Errorf("Expected no keyhole in a pass up to %v degrees", schedule.MaxElevation)
+		}
+	}
+
+	schedule, err := satellite.TrackingSchedule(station, schedules[0].Pass, TrackingConfig{})
+	if err != nil {
+		t.Fatalf("Expected TrackingSchedule to succeed, got %v", err)
+	}
+	if schedule.Points[1].Time.Sub(schedule.Points[0].Time) != DefaultPassStep {
+		t.Errorf("Expected the default step of %v", DefaultPassStep)
+	}
+	if schedule.Points[0].Downlink != 0 || schedule.Points[0].Uplink != 0 {
+		t.Errorf("Expected no frequencies when none are configured")
+	}
+}
+
+func TestSatellite_TrackingScheduleKeyhole(t *testing.T) {
+	satellite, station, pass := overheadPass(t)
+	config := TrackingConfig{Step: 5 * time.Second, MaxAzimuthRate: 2}
+
+	schedule, err := satellite.TrackingSchedule(station, pass, config)
+	if err != nil {
+		t.Fatalf("Expected TrackingSchedule to succeed, got %v", err)
+	}
+	if !schedule.Keyhole {
+		t.Fatalf("Expected a zenith pass to cross the keyhole")
+	}
+
+	for i := 1; i < len(schedule.Points); i++ {
+		previous, point := schedule.Points[i-1], schedule.Points[i]
+		turn := math.Abs(math.Mod(point.Azimuth-previous.Azimuth+540, 360) - 180)
+		if limit := config.MaxAzimuthRate * point.Time.Sub(previous.Time).Seconds(); turn > limit+1e-9 {
+			t.Errorf("Expected the azimuth to turn at most %v degrees, got %v", limit, turn)
+		}
+	}
+
+	// The error is worst at the culmination, where it is smallest on the sky
+	if worst := schedule.MaxPointingError(); worst <= 0 || worst > 10 {
+		t.Errorf("Expected a small pointing error through the keyhole, got %v", worst)
+	}
+	if first, last := schedule.Points[0], schedule.Points[len(schedule.Points)-1]; first.PointingError > 1e-3 || last.PointingError > 1e-3 {
+		t.Errorf("Expected the antenna to be on target at AOS and LOS")
+	}
+
+	fast, err := satellite.TrackingSchedule(station, pass, TrackingConfig{Step: 5 * time.Second, MaxAzimuthRate: 360})
+	if err != nil {
+		t.Fatalf("Expected TrackingSchedule to succeed, got %v", err)
+	}
+	if fast.Keyhole {
+		t.Errorf("Expected an antenna turning 180 degrees per step to have no keyhole")
+	}
+}
+
+func TestTrackingSchedule_WriteCSV(t *testing.T) {
+	satellite, station, pass := overheadPass(t)
+	schedule, err := satellite.TrackingSchedule(station, pass, TrackingConfig{DownlinkFrequency: 108e6})
+	if err != nil {
+		t.Fatalf("Expected TrackingSchedule to succeed, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := schedule.WriteCSV(&buf); err != nil {
+		t.Fatalf("Expected WriteCSV to succeed, got %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Expected valid CSV, got %v", err)
+	}
+	if len(records) != len(schedule.Points)+1 {
+		t.Fatalf("Expected a header and %d rows, got %d records", len(schedule.Points), len(records))
+	}
+	if strings.Join(records[0], ",") != "time,azimuth,elevation,range,rangeRate,downlink,uplink,pointingError" {
+		t.Errorf("Expected the header row, got %v", records[0])
+	}
+	first := records[1]
+	if parsed, err := time.Parse(time.RFC3339, first[0]); err != nil || !parsed.Equal(schedule.AOS) {
+		t.Errorf("Expected the first row at AOS, got %v", first[0])
+	}
+	if first[5] == "" || first[6] != "" {
+		t.Errorf("Expected a downlink and no uplink, got %v", first)
+	}
+}
+
+func TestTrackingSchedule_WriteTDM(t *testing.T) {
+	satellite, station, pass := overheadPass(t)
+	config := TrackingConfig{Step: 5 * time.Second, DownlinkFrequency: 108e6, UplinkFrequency: 145.9e6, MaxAzimuthRate: 2}
+	schedule, err := satellite.TrackingSchedule(station, pass, config)
+	if err != nil {
+		t.Fatalf("Expected TrackingSchedule to succeed, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := schedule.WriteTDM(&buf, "SKYBRIDGE"); err != nil {
+		t.Fatalf("Expected WriteTDM to succeed, got %v", err)
+	}
+	message := buf.String()
+	for _, line := range []string{
+		"CCSDS_TDM_VERS = 2.0\n",
+		"ORIGINATOR = SKYBRIDGE\n",
+		"PARTICIPANT_1 = overhead\n",
+		"PARTICIPANT_2 = vanguard\n",
+		"ANGLE_TYPE = AZEL\n",
+		"START_TIME = " + schedule.AOS.UTC().Format("2006-01-02T15:04:05.000") + "\n",
+		"COMMENT Keyhole",
+		"DATA_STOP\n",
+	} {
+		if !strings.Contains(message, line) {
+			t.Errorf("Expected the message to contain %q", line)
+		}
+	}
+	for _, keyword := range []string{"ANGLE_1 = ", "ANGLE_2 = ", "RANGE = ", "DOPPLER_INSTANTANEOUS = ", "RECEIVE_FREQ_1 = ", "TRANSMIT_FREQ_1 = "} {
+		if count := strings.Count(message, "\n"+keyword); count != len(schedule.Points) {
+			t.Errorf("Expected %d %s records, got %d", len(schedule.Points), keyword, count)
+		}
+	}
+
+	buf.Reset()
+	if err := (&TrackingSchedule{}).WriteTDM(&buf, "SKYBRIDGE"); err != nil || buf.Len() != 0 {
+		t.Errorf("Expected nothing to be written for an empty schedule")
+	}
+}
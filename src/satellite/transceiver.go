@@ -0,0 +1,149 @@
+package satellite
+
+import (
+	"math"
+
+	"github.com/skybridge/satellite/fec"
+)
+
+// ModCod is a modulation and coding scheme
+type ModCod struct {
+	// Name identifies the scheme, such as "QPSK 1/2"
+	Name string `json:"name"`
+
+	// Efficiency is the number of information bits carried per symbol
+	Efficiency float64 `json:"efficiency"`
+
+	// RequiredEsN0 is the energy per symbol to noise density ratio in dB
+	// needed for quasi-error-free reception
+	RequiredEsN0 float64 `json:"requiredEsN0"`
+}
+
+// RequiredEbN0 returns the energy per bit to noise density ratio in dB
+// needed for quasi-error-free reception
+func (m ModCod) RequiredEbN0() float64 {
+	return m.RequiredEsN0 - decibels(m.Efficiency)
+}
+
+// DefaultModCods are the DVB-S2 schemes with their ideal thresholds from
+// EN 302 307, used by transceivers that do not list their own
+var DefaultModCods = []ModCod{
+	{Name: "QPSK 1/4", Efficiency: 0.490243, RequiredEsN0: -2.35},
+	{Name: "QPSK 1/2", Efficiency: 0.988858, RequiredEsN0: 1.00},
+	{Name: "QPSK 3/4", Efficiency: 1.487473, RequiredEsN0: 4.03},
+	{Name: "8PSK 2/3", Efficiency: 1.980636, RequiredEsN0: 6.62},
+	{Name: "8PSK 3/4", Efficiency: 2.228124, RequiredEsN0: 7.91},
+	{Name: "16APSK 3/4", Efficiency: 2.966728, RequiredEsN0: 10.21},
+	{Name: "16APSK 5/6", Efficiency: 3.300184, RequiredEsN0: 11.61},
+	{Name: "32APSK 4/5", Efficiency: 3.951571, RequiredEsN0: 13.64},
+}
+
+// Transceiver represents a transceiver and its RF parameters. Ground
+// station transceivers only need the receive side.
+type Transceiver struct {
+	id string
+
+	// Frequency is the carrier frequency in Hz
+	Frequency float64
+
+	// TransmitPower is the RF output power in watts
+	TransmitPower float64
+
+	// AntennaGain is the antenna gain in dBi
+	AntennaGain float64
+
+	// NoiseTemperature is the receiving system noise temperature in kelvin
+	NoiseTemperature float64
+
+	// Losses are the feed, cable, pointing and polarization losses in dB
+	Losses float64
+
+	// SymbolRate is the transmitted symbol rate in symbols per second
+	SymbolRate float64
+
+	// ModCods are the modulation and coding schemes the transmitter can
+	// switch between, DefaultModCods if empty
+	ModCods []ModCod
+
+	// FEC is the forward error correction applied to frames sent and
+	// received, or nil for none
+	FEC fec.Codec
+}
+
+// NewTransceiver returns a new Transceiver instance
+func NewTransceiver(id string) *Transceiver {
+	return &Transceiver{
+		id: id,
+	}
+}
+
+// ID returns the transceiver's id
+func (t *Transceiver) ID() string {
+	return t.id
+}
+
+// Band returns the IEEE radar band designation of the carrier frequency
+func (t *Transceiver) Band() string {
+	bands := []struct {
+		upper float64
+		name  string
+	}{
+		{300e6, "VHF"},
+		{1e9, "UHF"},
+		{2e9, "L"},
+		{4e9, "S"},
+		{8e9, "C"},
+		{12e9, "X"},
+		{18e9, "Ku"},
+		{27e9, "K"},
+		{40e9, "Ka"},
+	}
+	if t.Frequency <= 0 {
+		return ""
+	}
+	for _, band := range bands {
+		if t.Frequency < band.upper {
+			return band.name
+		}
+	}
+	return "V"
+}
+
+// EIRP returns the effective isotropic radiated power in dBW
+func (t *Transceiver) EIRP() float64 {
+	return decibels(t.TransmitPower) + t.AntennaGain - t.Losses
+}
+
+// FigureOfMerit returns the receiving G/T in dB/K
+func (t *Transceiver) FigureOfMerit() float64 {
+	return t.AntennaGain - t.Losses - decibels(t.NoiseTemperature)
+}
+
+// EncodeFrame returns a frame protected by the transceiver's FEC
+func (t *Transceiver) EncodeFrame(frame []byte) ([]byte, error) {
+	if t.FEC == nil {
+		return frame, nil
+	}
+	return t.FEC.Encode(frame)
+}
+
+// DecodeFrame returns a received frame corrected by the transceiver's FEC
+func (t *Transceiver) DecodeFrame(data []byte) ([]byte, error) {
+	if t.FEC == nil {
+		return data, nil
+	}
+	return t.FEC.Decode(data)
+}
+
+// modCods returns the schemes the transceiver can use
+func (t *Transceiver) modCods() []ModCod {
+	if len(t.ModCods) == 0 {
+		return DefaultModCods
+	}
+	return t.ModCods
+}
+
+// decibels returns a power ratio in dB
+func decibels(ratio float64) float64 {
+	return 10 * math.Log10(ratio)
+}
@@ -0,0 +1,88 @@
+package satellite
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/skybridge/satellite/fec"
+)
+
+func TestTransceiver_Band(t *testing.T) {
+	tests := []struct {
+		frequency float64
+		band      string
+	}{
+		{0, ""},
+		{145.8e6, "VHF"},
+		{437e6, "UHF"},
+		{1.6e9, "L"},
+		{2.2e9, "S"},
+		{8.2e9, "X"},
+		{12e9, "Ku"},
+		{26e9, "K"},
+		{30e9, "Ka"},
+		{50e9, "V"},
+	}
+	for _, test := range tests {
+		transceiver := NewTransceiver("tx")
+		transceiver.Frequency = test.frequency
+		if band := transceiver.Band(); band != test.band {
+			t.Errorf("Expected %g Hz to be in band %q, got %q", test.frequency, test.band, band)
+		}
+	}
+}
+
+func TestTransceiver_EIRP(t *testing.T) {
+	transceiver := NewTransceiver("tx")
+	transceiver.TransmitPower = 2
+	transceiver.AntennaGain = 6
+	transceiver.Losses = 1
+	if eirp := transceiver.EIRP(); math.Abs(eirp-8.0103) > 0.001 {
+		t.Errorf("Expected an EIRP of 8.01 dBW, got %f", eirp)
+	}
+}
+
+func TestTransceiver_FigureOfMerit(t *testing.T) {
+	transceiver := NewTransceiver("rx")
+	transceiver.AntennaGain = 35
+	transceiver.NoiseTemperature = 150
+	if gt := transceiver.FigureOfMerit(); math.Abs(gt-13.239) > 0.001 {
+		t.Errorf("Expected a G/T of 13.24 dB/K, got %f", gt)
+	}
+}
+
+func TestModCod_RequiredEbN0(t *testing.T) {
+	modCod := ModCod{Name: "QPSK 1/2", Efficiency: 1, RequiredEsN0: 1}
+	if ebN0 := modCod.RequiredEbN0(); ebN0 != 1 {
+		t.Errorf("Expected Eb/N0 to equal Es/N0 at one bit per symbol, got %f", ebN0)
+	}
+
+	// Schemes carrying more bits need more energy per symbol
+	for i := 1; i < len(DefaultModCods); i++ {
+		previous, current := DefaultModCods[i-1], DefaultModCods[i]
+		if current.Efficiency <= previous.Efficiency || current.RequiredEsN0 <= previous.RequiredEsN0 {
+			t.Errorf("Expected %s to be more efficient and demanding than %s", current.Name, previous.Name)
+		}
+	}
+}
+
+func TestTransceiver_EncodeFrame(t *testing.T) {
+	transceiver := NewTransceiver("tx")
+	frame := []byte("frame")
+	if encoded, err := transceiver.EncodeFrame(frame); err != nil || !bytes.Equal(encoded, frame) {
+		t.Errorf("Expected frames to pass through without FEC, got %x, %v", encoded, err)
+	}
+
+	rs, _ := fec.NewReedSolomon(1)
+	transceiver.FEC = fec.Chain{fec.CRC16{}, rs, fec.NewConvolutional()}
+	encoded, err := transceiver.EncodeFrame(frame)
+	if err != nil || len(encoded) <= len(frame) {
+		t.Fatalf("Expected the frame to be encoded, got %d octets, %v", len(encoded), err)
+	}
+	received, _ := fec.NewBinarySymmetricChannel(1e-3, 1).Transmit(encoded)
+	decoded, err := transceiver.DecodeFrame(received)
+	if err != nil || !bytes.Equal(decoded, frame) {
+		t.Errorf("Expected the frame to be corrected, got %q, %v", decoded, err)
+	}
+}